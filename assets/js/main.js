@@ -1,61 +1,818 @@
-// Setup of Datatable
+// Setup of Datatable, the sort order is remembered in the preferences cookie
+var sortColumns = { name: 2, size: 3, modified: 4 };
+
 $(document).ready(function () {
-  $('#tableData').DataTable({
+  var table = $('#tableData').DataTable({
     paging: false,
     language: {
       info: '_TOTAL_ items',
+      search: 'Filter:',
     },
-    order: [[2, 'asc']],
+    order: [[sortColumns[goshsPrefs.sort] || 2, goshsPrefs.order]],
     columnDefs: [
       {
-        targets: [0, 1, 5],
+        targets: 'nosort',
         orderable: false,
       },
+      {
+        targets: 'nosearch',
+        searchable: false,
+      },
     ],
   });
+  table.on('order.dt', function () {
+    var order = table.order()[0];
+    for (var name in sortColumns) {
+      if (sortColumns[name] == order[0]) {
+        savePrefs(name, order[1]);
+        // Other pages hold other items, the server has to sort
+        if (goshsPages > 1) {
+          location.search = '?sort=' + name + '&order=' + order[1];
+        }
+      }
+    }
+  });
 });
 
-// Checkbox handling
-var checkboxes = document.querySelectorAll('.downloadBulkCheckbox');
+function savePrefs(sort, order) {
+  document.cookie =
+    'goshs_prefs=' +
+    new URLSearchParams({
+      hidden: goshsPrefs.hidden,
+      limit: goshsPrefs.limit,
+      channel: goshsPrefs.channel,
+      order: order,
+      sort: sort,
+    }).toString() +
+    '; path=' +
+    goshsPrefix +
+    '/; max-age=31536000; samesite=lax';
+}
 
-Array.prototype.forEach.call(checkboxes, function (cb) {
-  cb.addEventListener('change', function () {
-    checkedBoxes = document.querySelectorAll(
-      'input[type=checkbox]:checked'
-    ).length;
-    if (checkedBoxes >= 1) {
-      document.getElementById('downloadBulkButton').style.display = 'block';
-    } else {
-      document.getElementById('downloadBulkButton').style.display = 'none';
+// Checkbox handling, shift-click selects the range since the last click
+var lastChecked = null;
+
+function visibleCheckboxes() {
+  return Array.prototype.slice.call(
+    document.querySelectorAll('#tableData tbody .downloadBulkCheckbox')
+  );
+}
+
+function selectedItems() {
+  return visibleCheckboxes()
+    .filter(function (cb) {
+      return cb.checked;
+    })
+    .map(function (cb) {
+      return { uri: cb.value, name: cb.getAttribute('data-name') };
+    });
+}
+
+function updateSelection() {
+  var count = selectedItems().length;
+  document.getElementById('bulkActions').style.display =
+    count >= 1 ? 'flex' : 'none';
+  document.getElementById('bulkCount').textContent = count + ' selected';
+}
+
+document.addEventListener('click', function (e) {
+  var cb = e.target;
+  if (!cb.classList || !cb.classList.contains('downloadBulkCheckbox')) {
+    return;
+  }
+  var boxes = visibleCheckboxes();
+  if (e.shiftKey && lastChecked && boxes.indexOf(lastChecked) != -1) {
+    var from = boxes.indexOf(lastChecked);
+    var to = boxes.indexOf(cb);
+    boxes
+      .slice(Math.min(from, to), Math.max(from, to) + 1)
+      .forEach(function (box) {
+        box.checked = cb.checked;
+      });
+  }
+  lastChecked = cb;
+  updateSelection();
+});
+
+// Unchecked boxes are not sent, filtered rows are not part of the selection
+document.addEventListener('submit', function (e) {
+  if (e.target.id != 'bulkForm') {
+    return;
+  }
+  var visible = visibleCheckboxes();
+  Array.prototype.forEach.call(
+    e.target.querySelectorAll('.downloadBulkCheckbox'),
+    function (cb) {
+      if (visible.indexOf(cb) == -1) {
+        cb.checked = false;
+      }
     }
-  });
+  );
 });
 
 function selectAll() {
-  Array.prototype.forEach.call(checkboxes, function (cb) {
+  visibleCheckboxes().forEach(function (cb) {
     cb.checked = true;
   });
-  document.getElementById('downloadBulkButton').style.display = 'block';
+  updateSelection();
 }
 
 function selectNone() {
-  Array.prototype.forEach.call(checkboxes, function (cb) {
-    cb.checked = false;
+  Array.prototype.forEach.call(
+    document.querySelectorAll('.downloadBulkCheckbox'),
+    function (cb) {
+      cb.checked = false;
+    }
+  );
+  updateSelection();
+}
+
+function selectInvert() {
+  visibleCheckboxes().forEach(function (cb) {
+    cb.checked = !cb.checked;
   });
-  document.getElementById('downloadBulkButton').style.display = 'none';
+  updateSelection();
+}
+
+// runEach will call action for every item one after another and reload when done
+function runEach(items, verb, action) {
+  var failed = [];
+  items
+    .reduce(function (chain, item) {
+      return chain.then(function () {
+        return action(item).then(
+          function (response) {
+            if (!response.ok) {
+              failed.push(item.name + ': ' + response.status + ' ' + response.statusText);
+            }
+          },
+          function (err) {
+            failed.push(item.name + ': ' + err);
+          }
+        );
+      });
+    }, Promise.resolve())
+    .then(function () {
+      if (failed.length > 0) {
+        alert(verb + ' failed for\n' + failed.join('\n'));
+      }
+      location.reload();
+    });
+}
+
+function deleteSelected() {
+  var items = selectedItems();
+  if (items.length == 0) {
+    return;
+  }
+  result = confirm(
+    'Are you sure you want to delete ' + items.length + ' selected items?'
+  );
+  if (!result) {
+    return;
+  }
+  runEach(items, 'Deleting', function (item) {
+    return fetch(goshsPrefix + '/' + item.uri, {
+      method: 'DELETE',
+      credentials: 'same-origin',
+    });
+  });
+}
+
+function deleteItem(btn) {
+  var name = btn.getAttribute('data-name');
+  result = confirm('Are you sure you want to delete ' + name + '?');
+  if (!result) {
+    return;
+  }
+  fetch(goshsPrefix + '/' + btn.getAttribute('data-uri'), {
+    method: 'DELETE',
+    credentials: 'same-origin',
+  }).then(function (response) {
+    if (response.ok) {
+      location.reload();
+    } else {
+      alert(
+        'Deleting ' + name + ' failed: ' + response.status + ' ' +
+          response.statusText
+      );
+    }
+  });
+}
+
+function newFolder() {
+  var name = prompt('Name of the new folder');
+  if (!name) {
+    return;
+  }
+  var dir = goshsDir.replace(/\/$/, '').split('/').map(encodeURIComponent);
+  fetch(goshsPrefix + dir.join('/') + '/' + encodeURIComponent(name), {
+    method: 'MKCOL',
+    credentials: 'same-origin',
+  }).then(function (response) {
+    if (response.ok) {
+      location.reload();
+    } else {
+      alert(
+        'Creating ' + name + ' failed: ' + response.status + ' ' +
+          response.statusText
+      );
+    }
+  });
+}
+
+// Rename and move, a single item can be renamed, a selection only be moved
+var moveItems = [];
+
+function openMove(btn) {
+  if (btn) {
+    moveItems = [
+      { uri: btn.getAttribute('data-uri'), name: btn.getAttribute('data-name') },
+    ];
+    document.getElementById('moveName').value = btn.getAttribute('data-name');
+    document.getElementById('moveName').required = true;
+    document.getElementById('moveNameGroup').style.display = 'block';
+    document.getElementById('moveTitle').textContent = 'Rename / Move';
+  } else {
+    moveItems = selectedItems();
+    if (moveItems.length == 0) {
+      return;
+    }
+    document.getElementById('moveName').required = false;
+    document.getElementById('moveNameGroup').style.display = 'none';
+    document.getElementById('moveTitle').textContent =
+      'Move ' + moveItems.length + ' selected items';
+  }
+  var select = document.getElementById('moveDir');
+  select.innerHTML = '';
+  fetch(
+    goshsPrefix +
+      '/073c1634c496cdb649d1afe0a312bbb4b7e1741b271542e4a436c3b8824b1761/dirs',
+    { credentials: 'same-origin' }
+  )
+    .then(function (response) {
+      return response.json();
+    })
+    .then(function (dirs) {
+      dirs.forEach(function (dir) {
+        var option = document.createElement('option');
+        option.value = dir;
+        option.text = dir;
+        option.selected = dir == goshsDir;
+        select.appendChild(option);
+      });
+    });
+  var modal = document.getElementById('moveModal');
+  modal.style.display = 'block';
+  modal.classList.add('show');
+  var backdrop = document.createElement('div');
+  backdrop.className = 'modal-backdrop show';
+  backdrop.id = 'moveBackdrop';
+  document.body.appendChild(backdrop);
+  if (btn) {
+    document.getElementById('moveName').focus();
+  }
+}
+
+function closeMove() {
+  var modal = document.getElementById('moveModal');
+  modal.style.display = 'none';
+  modal.classList.remove('show');
+  var backdrop = document.getElementById('moveBackdrop');
+  if (backdrop) {
+    backdrop.remove();
+  }
+}
+
+function openQR() {
+  var img = document.getElementById('qrImage');
+  if (!img.src) {
+    img.src = img.getAttribute('data-src');
+  }
+  var modal = document.getElementById('qrModal');
+  modal.style.display = 'block';
+  modal.classList.add('show');
+  var backdrop = document.createElement('div');
+  backdrop.className = 'modal-backdrop show';
+  backdrop.id = 'qrBackdrop';
+  document.body.appendChild(backdrop);
+  return false;
+}
+
+function closeQR() {
+  var modal = document.getElementById('qrModal');
+  modal.style.display = 'none';
+  modal.classList.remove('show');
+  var backdrop = document.getElementById('qrBackdrop');
+  if (backdrop) {
+    backdrop.remove();
+  }
+}
+
+function moveItem(e) {
+  e.preventDefault();
+  var dir = (document.getElementById('moveDir').value || goshsDir).replace(/\/$/, '');
+  var rename = moveItems.length == 1 && document.getElementById('moveName').required;
+  var newName = document.getElementById('moveName').value;
+  closeMove();
+  runEach(moveItems, 'Moving', function (item) {
+    var to = dir + '/' + (rename ? newName : item.name.replace(/\/$/, ''));
+    return fetch(goshsPrefix + '/' + item.uri, {
+      method: 'PATCH',
+      credentials: 'same-origin',
+      headers: { 'Content-Type': 'application/x-www-form-urlencoded' },
+      body: 'to=' + encodeURIComponent(to),
+    });
+  });
+  return false;
+}
+
+// File details, checksums are computed on demand
+var infoURI = '';
+
+function openInfo(btn) {
+  infoURI = goshsPrefix + '/' + btn.getAttribute('data-uri');
+  var table = document.getElementById('infoTable');
+  table.innerHTML = '';
+  fetch(infoURI + '?info', { credentials: 'same-origin' })
+    .then(function (response) {
+      if (!response.ok) {
+        throw response.status + ' ' + response.statusText;
+      }
+      return response.json();
+    })
+    .then(function (info) {
+      document.getElementById('infoTitle').textContent = info.name;
+      infoRow('Path', info.path);
+      infoRow('Size', info.size + ' bytes');
+      infoRow('Modified', new Date(info.mod_time).toString());
+      infoRow('Mode', info.mode);
+      if (info.owner) {
+        infoRow('Owner', info.owner + ':' + info.group);
+      }
+      if (!info.is_dir) {
+        infoRow('MIME type', info.mime);
+        ['md5', 'sha1', 'sha256'].forEach(function (algorithm) {
+          var value = infoRow(algorithm.toUpperCase(), '');
+          var compute = document.createElement('button');
+          compute.type = 'button';
+          compute.className = 'btn btn-link p-0';
+          compute.textContent = 'Compute';
+          compute.onclick = function () {
+            computeHash(algorithm, value);
+          };
+          value.appendChild(compute);
+        });
+      }
+    })
+    .catch(function (err) {
+      infoRow('Error', err);
+    });
+  showInfo();
+}
+
+function showInfo() {
+  var modal = document.getElementById('infoModal');
+  modal.style.display = 'block';
+  modal.classList.add('show');
+  var backdrop = document.createElement('div');
+  backdrop.className = 'modal-backdrop show';
+  backdrop.id = 'infoBackdrop';
+  document.body.appendChild(backdrop);
+}
+
+// Download cradles for the file behind btn as reached by this browser
+function openLinks(btn) {
+  var name = btn.getAttribute('data-name');
+  var link =
+    location.origin +
+    goshsPrefix +
+    decodeURIComponent(btn.getAttribute('data-uri'))
+      .replace(/^\/?/, '/')
+      .split('/')
+      .map(encodeURIComponent)
+      .join('/');
+  var insecure = location.protocol == 'https:';
+  var sh = function (str) {
+    return "'" + str.replace(/'/g, "'\\''") + "'";
+  };
+  var ps = function (str) {
+    return "'" + str.replace(/'/g, "''") + "'";
+  };
+  var cmd = function (str) {
+    return '"' + str.replace(/"/g, '') + '"';
+  };
+  document.getElementById('infoTitle').textContent = 'Copy link: ' + name;
+  document.getElementById('infoTable').innerHTML = '';
+  infoRow('URL', link);
+  infoRow('curl', 'curl ' + (insecure ? '-k ' : '') + '-o ' + sh(name) + ' ' + sh(link));
+  infoRow(
+    'wget',
+    'wget ' + (insecure ? '--no-check-certificate ' : '') + '-O ' + sh(name) + ' ' + sh(link)
+  );
+  infoRow(
+    'PowerShell',
+    (insecure
+      ? '[Net.ServicePointManager]::ServerCertificateValidationCallback = {$true}; '
+      : '') +
+      'iwr -UseBasicParsing -Uri ' + ps(link) + ' -OutFile ' + ps(name)
+  );
+  infoRow('certutil', 'certutil -urlcache -split -f ' + cmd(link) + ' ' + cmd(name));
+  infoRow(
+    'bitsadmin',
+    'bitsadmin /transfer goshs /download /priority high ' + cmd(link) + ' ' + cmd('%cd%\\' + name)
+  );
+  showInfo();
+}
+
+function closeInfo() {
+  var modal = document.getElementById('infoModal');
+  modal.style.display = 'none';
+  modal.classList.remove('show');
+  var backdrop = document.getElementById('infoBackdrop');
+  if (backdrop) {
+    backdrop.remove();
+  }
+}
+
+// infoRow adds a row with a copy button and returns the cell holding text
+function infoRow(label, text) {
+  var tr = document.createElement('tr');
+  var th = document.createElement('th');
+  th.textContent = label;
+  var td = document.createElement('td');
+  var value = document.createElement('span');
+  value.className = 'info-value';
+  value.textContent = text;
+  td.appendChild(value);
+  var copy = document.createElement('td');
+  var button = document.createElement('button');
+  button.type = 'button';
+  button.className = 'btn btn-link p-0';
+  button.title = 'Copy';
+  button.innerHTML = '<i class="fas fa-copy"></i>';
+  button.onclick = function () {
+    copyText(value.textContent);
+  };
+  copy.appendChild(button);
+  tr.appendChild(th);
+  tr.appendChild(td);
+  tr.appendChild(copy);
+  document.getElementById('infoTable').appendChild(tr);
+  return value;
+}
+
+function computeHash(algorithm, value) {
+  value.textContent = 'computing...';
+  fetch(infoURI + '?hash=' + algorithm, { credentials: 'same-origin' })
+    .then(function (response) {
+      if (!response.ok) {
+        throw response.status + ' ' + response.statusText;
+      }
+      return response.json();
+    })
+    .then(function (result) {
+      value.textContent = result.sum;
+    })
+    .catch(function (err) {
+      value.textContent = 'failed: ' + err;
+    });
+}
+
+// copyText falls back to a hidden textarea as the clipboard api needs https
+function copyText(text) {
+  if (navigator.clipboard && window.isSecureContext) {
+    navigator.clipboard.writeText(text);
+    return;
+  }
+  var area = document.createElement('textarea');
+  area.value = text;
+  area.style.position = 'fixed';
+  area.style.opacity = '0';
+  document.body.appendChild(area);
+  area.select();
+  document.execCommand('copy');
+  area.remove();
+}
+
+// Directory tree, children are loaded when a node is expanded
+function treeChildren(dir, ul) {
+  return fetch(
+    goshsPrefix +
+      '/073c1634c496cdb649d1afe0a312bbb4b7e1741b271542e4a436c3b8824b1761/tree?path=' +
+      encodeURIComponent(dir),
+    { credentials: 'same-origin' }
+  )
+    .then(function (response) {
+      if (!response.ok) {
+        throw response.status + ' ' + response.statusText;
+      }
+      return response.json();
+    })
+    .then(function (nodes) {
+      ul.innerHTML = '';
+      nodes.forEach(function (node) {
+        ul.appendChild(treeNode(node));
+      });
+      return nodes;
+    });
+}
+
+function treeNode(node) {
+  var li = document.createElement('li');
+  li.setAttribute('data-path', node.path);
+  var toggle = document.createElement('i');
+  toggle.className = 'fas fa-caret-right tree-toggle';
+  toggle.onclick = function () {
+    expandNode(li);
+  };
+  var link = document.createElement('a');
+  link.href =
+    goshsPrefix + node.path.split('/').map(encodeURIComponent).join('/') + '/';
+  link.textContent = node.name;
+  if (node.path == goshsDir.replace(/\/$/, '')) {
+    link.className = 'active';
+  }
+  li.appendChild(toggle);
+  li.appendChild(link);
+  return li;
+}
+
+// expandNode opens or closes li and returns a promise resolving when its children are shown
+function expandNode(li) {
+  var toggle = li.querySelector('.tree-toggle');
+  var ul = li.querySelector('ul');
+  if (ul) {
+    ul.remove();
+    toggle.className = 'fas fa-caret-right tree-toggle';
+    return Promise.resolve();
+  }
+  ul = document.createElement('ul');
+  li.appendChild(ul);
+  toggle.className = 'fas fa-caret-down tree-toggle';
+  return treeChildren(li.getAttribute('data-path'), ul).then(function (nodes) {
+    if (nodes.length == 0) {
+      toggle.className = 'fas tree-toggle';
+    }
+  });
+}
+
+// loadTree shows the top level and opens the nodes down to the current directory
+function loadTree() {
+  var parts = goshsDir.split('/').filter(function (part) {
+    return part != '';
+  });
+  var ul = document.getElementById('tree');
+  var chain = treeChildren('/', ul);
+  parts.forEach(function (part, i) {
+    chain = chain.then(function () {
+      var dir = '/' + parts.slice(0, i + 1).join('/');
+      var li = Array.prototype.find.call(
+        document.querySelectorAll('#tree li'),
+        function (node) {
+          return node.getAttribute('data-path') == dir;
+        }
+      );
+      if (li) {
+        return expandNode(li);
+      }
+    });
+  });
+  chain.catch(function (err) {
+    ul.textContent = 'Loading failed: ' + err;
+  });
+}
+
+function toggleTree() {
+  var sidebar = document.getElementById('treeSidebar');
+  var show = !sidebar.classList.contains('show');
+  sidebar.classList.toggle('show', show);
+  localStorage.setItem('goshsTree', show ? '1' : '0');
+  if (show && document.getElementById('tree').children.length == 0) {
+    loadTree();
+  }
+}
+
+if (localStorage.getItem('goshsTree') == '1') {
+  document.getElementById('treeSidebar').classList.add('show');
+  loadTree();
+}
+
+// Files dropped anywhere on the page are uploaded one after another
+var uploadQueue = [];
+var uploadBusy = false;
+var uploadFailed = 0;
+var uploadRetries = 3;
+var dragDepth = 0;
+
+function dropTarget(e) {
+  return (
+    !goshsReadOnly &&
+    e.dataTransfer &&
+    Array.prototype.indexOf.call(e.dataTransfer.types, 'Files') != -1 &&
+    !e.target.closest('#mydropzone')
+  );
 }
 
+document.addEventListener('dragenter', function (e) {
+  if (!dropTarget(e)) {
+    return;
+  }
+  e.preventDefault();
+  dragDepth++;
+  document.getElementById('dropOverlay').classList.add('show');
+});
+
+document.addEventListener('dragover', function (e) {
+  if (dropTarget(e)) {
+    e.preventDefault();
+  }
+});
+
+document.addEventListener('dragleave', function (e) {
+  if (!dropTarget(e)) {
+    return;
+  }
+  dragDepth--;
+  if (dragDepth <= 0) {
+    dragDepth = 0;
+    document.getElementById('dropOverlay').classList.remove('show');
+  }
+});
+
+document.addEventListener('drop', function (e) {
+  dragDepth = 0;
+  document.getElementById('dropOverlay').classList.remove('show');
+  if (!dropTarget(e)) {
+    return;
+  }
+  e.preventDefault();
+  var items = e.dataTransfer.items;
+  var files = e.dataTransfer.files;
+  for (var i = 0; i < files.length; i++) {
+    var entry = items && items[i] && items[i].webkitGetAsEntry ? items[i].webkitGetAsEntry() : null;
+    queueUpload(files[i], entry && entry.isDirectory);
+  }
+  nextUpload();
+});
+
+function queueUpload(file, isDir) {
+  var li = document.createElement('li');
+  var name = document.createElement('span');
+  name.className = 'upload-name';
+  name.textContent = file.name;
+  var state = document.createElement('span');
+  state.className = 'upload-state';
+  li.appendChild(name);
+  li.appendChild(state);
+  document.getElementById('uploadList').appendChild(li);
+  document.getElementById('uploadQueue').classList.add('show');
+  var job = { file: file, li: li, state: state, tries: 0 };
+  if (isDir) {
+    uploadState(job, 'failed', 'folders are not supported');
+    uploadFailed++;
+    return;
+  }
+  uploadState(job, 'queued', 'queued');
+  uploadQueue.push(job);
+}
+
+function uploadState(job, cls, text) {
+  job.li.className = cls;
+  job.state.textContent = text;
+}
+
+function nextUpload() {
+  if (uploadBusy) {
+    return;
+  }
+  var job = uploadQueue.shift();
+  if (!job) {
+    if (uploadFailed == 0) {
+      location.reload();
+    }
+    return;
+  }
+  uploadBusy = true;
+  job.tries++;
+  var data = new FormData();
+  data.append('files', job.file, job.file.name);
+  var xhr = new XMLHttpRequest();
+  xhr.open('POST', url);
+  xhr.upload.onprogress = function (e) {
+    if (e.lengthComputable) {
+      uploadState(job, 'uploading', Math.floor((e.loaded / e.total) * 100) + '%');
+    }
+  };
+  xhr.onload = function () {
+    if (xhr.status >= 200 && xhr.status < 400) {
+      uploadState(job, 'done', 'done');
+      uploadDone();
+    } else {
+      uploadError(job, xhr.status + ' ' + xhr.statusText);
+    }
+  };
+  xhr.onerror = function () {
+    uploadError(job, 'network error');
+  };
+  uploadState(job, 'uploading', '0%');
+  xhr.send(data);
+}
+
+function uploadError(job, reason) {
+  if (job.tries < uploadRetries) {
+    uploadState(job, 'queued', 'retry ' + job.tries + ' (' + reason + ')');
+    setTimeout(function () {
+      uploadQueue.unshift(job);
+      uploadDone();
+    }, 1000 * job.tries);
+    return;
+  }
+  uploadFailed++;
+  uploadState(job, 'failed', 'failed (' + reason + ')');
+  var retry = document.createElement('button');
+  retry.type = 'button';
+  retry.className = 'btn btn-link p-0 ml-1';
+  retry.textContent = 'retry';
+  retry.onclick = function () {
+    retry.remove();
+    uploadFailed--;
+    job.tries = 0;
+    uploadState(job, 'queued', 'queued');
+    uploadQueue.push(job);
+    nextUpload();
+  };
+  job.li.appendChild(retry);
+  uploadDone();
+}
+
+function uploadDone() {
+  uploadBusy = false;
+  nextUpload();
+}
+
+// Gallery
+var galleryIndex = 0;
+
+function openGallery(img) {
+  var images = Array.prototype.slice.call(
+    document.querySelectorAll('.thumbnail')
+  );
+  galleryIndex = img ? images.indexOf(img) : 0;
+  document.getElementById('lightbox').style.display = 'flex';
+  showImage(0);
+}
+
+function closeGallery() {
+  document.getElementById('lightbox').style.display = 'none';
+  document.getElementById('lightboxImage').removeAttribute('src');
+}
+
+function showImage(step) {
+  var images = document.querySelectorAll('.thumbnail');
+  if (images.length == 0) {
+    return;
+  }
+  galleryIndex = (galleryIndex + step + images.length) % images.length;
+  var img = images[galleryIndex];
+  document.getElementById('lightboxImage').src = img.getAttribute('data-src');
+  document.getElementById('lightboxCaption').innerText =
+    img.getAttribute('data-name') +
+    ' (' +
+    (galleryIndex + 1) +
+    '/' +
+    images.length +
+    ')';
+}
+
+document.addEventListener('keydown', function (e) {
+  if (document.getElementById('lightbox').style.display != 'flex') {
+    return;
+  }
+  if (e.key == 'Escape') {
+    closeGallery();
+  } else if (e.key == 'ArrowLeft') {
+    showImage(-1);
+  } else if (e.key == 'ArrowRight') {
+    showImage(1);
+  }
+});
+
 // Everything related to websockets
 var wsURL = '';
 location.protocol !== 'https:'
   ? (wsURL =
       'ws://' +
       window.location.host +
-      '/14644be038ea0118a1aadfacca2a7d1517d7b209c4b9674ee893b1944d1c2d54/ws')
+      goshsPrefix +
+      '/14644be038ea0118a1aadfacca2a7d1517d7b209c4b9674ee893b1944d1c2d54/ws?channel=' +
+      encodeURIComponent(goshsPrefs.channel))
   : (wsURL =
       'wss://' +
       window.location.host +
-      '/14644be038ea0118a1aadfacca2a7d1517d7b209c4b9674ee893b1944d1c2d54/ws');
+      goshsPrefix +
+      '/14644be038ea0118a1aadfacca2a7d1517d7b209c4b9674ee893b1944d1c2d54/ws?channel=' +
+      encodeURIComponent(goshsPrefs.channel));
 var connection = new WebSocket(wsURL);
 
 connection.onopen = function () {
@@ -70,27 +827,73 @@ connection.onerror = function (e) {
   console.log('Websocket error: ', e);
 };
 
+// Queued messages arrive together separated by newlines
 connection.onmessage = function (m) {
-  try {
-    var message = JSON.parse(m.data);
-    if (message['type'] == 'refreshClipboard') {
-      location.reload();
+  m.data.split('\n').forEach(function (data) {
+    try {
+      var message = JSON.parse(data);
+      if (message['type'] == 'refreshClipboard' && message['content'] == goshsPrefs.channel) {
+        location.reload();
+      }
+      if (message['type'] == 'refreshDirectory') {
+        directoryChanged(message['content']);
+      }
+    } catch (e) {
+      console.log('Error reading message: ', e);
     }
-  } catch (e) {
-    console.log('Error reading message: ', e);
-  }
+  });
 };
 
+// directoryChanged reloads the listing of dir if shown and nothing is going on
+function directoryChanged(dir) {
+  if (dir != (goshsDir.replace(/\/$/, '') || '/')) {
+    return;
+  }
+  var filter = document.querySelector('#tableData_filter input');
+  var busy =
+    selectedItems().length > 0 ||
+    uploadBusy ||
+    uploadQueue.length > 0 ||
+    document.querySelector('.modal.show') != null ||
+    document.getElementById('lightbox').style.display == 'flex' ||
+    (filter && filter.value != '');
+  if (busy) {
+    document.getElementById('changedNotice').style.display = 'block';
+  } else {
+    location.reload();
+  }
+}
+
+// switchChannel shows another clipboard channel, the choice is kept in the preferences cookie
+function switchChannel(name) {
+  if (name == '') {
+    name = prompt('Name of the new channel (letters, digits, ".", "_" and "-")');
+    if (!name) {
+      document.getElementById('cbChannel').value = goshsPrefs.channel;
+      return false;
+    }
+  }
+  location.search = '?channel=' + encodeURIComponent(name);
+  return false;
+}
+
 function sendEntry(e) {
   e.preventDefault();
   entryfield = document.getElementById('cbEntry');
   var text = entryfield.value;
-  var msg = {
-    type: 'newEntry',
-    content: text,
-  };
-  connection.send(JSON.stringify(msg));
-  entryfield.value = '';
+  protectText(text).then(function (content) {
+    var msg = {
+      type: 'newEntry',
+      content: {
+        content: content,
+        ttl: parseInt(document.getElementById('cbTTL').value, 10),
+        language: document.getElementById('cbLanguage').value,
+        encrypted: clipboardPass != '',
+      },
+    };
+    connection.send(JSON.stringify(msg));
+    entryfield.value = '';
+  });
 }
 
 function clearClipboard(e) {
@@ -112,3 +915,168 @@ function delClipboard(id) {
   };
   connection.send(JSON.stringify(msg));
 }
+
+// copyClipboard copies the unformatted content of an entry
+function copyClipboard(id) {
+  copyText(document.querySelector('#card-' + id + ' .cbEdit textarea').value);
+  return false;
+}
+
+// editClipboard toggles between the content of an entry and its edit form
+function editClipboard(id) {
+  var card = document.getElementById('card-' + id);
+  if (card.classList.contains('encrypted') && !card.classList.contains('decrypted')) {
+    alert('Enter the passphrase of this entry first');
+    return false;
+  }
+  card.classList.toggle('editing');
+  if (card.classList.contains('editing')) {
+    card.querySelector('.cbEdit textarea').focus();
+  }
+  return false;
+}
+
+function saveClipboard(e, id) {
+  e.preventDefault();
+  var form = e.target;
+  protectText(form.querySelector('textarea').value).then(function (content) {
+    var msg = {
+      type: 'editEntry',
+      content: {
+        id: parseInt(id, 10),
+        content: content,
+        language: form.querySelector('input').value,
+        encrypted: clipboardPass != '',
+      },
+    };
+    connection.send(JSON.stringify(msg));
+  });
+  return false;
+}
+
+// saveClipboardFile writes an entry to a new file, the current directory is proposed
+function saveClipboardFile(id) {
+  var name = prompt('Save entry as file', goshsDir.replace(/\/?$/, '/') + 'clipboard-' + id + '.txt');
+  if (!name) {
+    return false;
+  }
+  fetch(goshsPrefix + '/api/v1/clipboard/' + id + '/save?channel=' + encodeURIComponent(goshsPrefs.channel), {
+    method: 'POST',
+    headers: { 'Content-Type': 'application/json' },
+    body: JSON.stringify({ path: name }),
+  })
+    .then(function (response) {
+      if (!response.ok) {
+        return response.json().then(function (e) {
+          throw new Error(e.error);
+        });
+      }
+      location.reload();
+    })
+    .catch(function (err) {
+      alert('Unable to save the entry: ' + err.message);
+    });
+  return false;
+}
+
+// End-to-end encrypted clipboard: entries are encrypted with AES-GCM and a key derived from
+// a passphrase, which stays in the browser. The server only stores salt, iv and ciphertext.
+var clipboardPass = sessionStorage.getItem('goshsClipboardPass') || '';
+
+function clipboardKey(salt) {
+  return crypto.subtle
+    .importKey('raw', new TextEncoder().encode(clipboardPass), 'PBKDF2', false, ['deriveKey'])
+    .then(function (base) {
+      return crypto.subtle.deriveKey(
+        { name: 'PBKDF2', salt: salt, iterations: 200000, hash: 'SHA-256' },
+        base,
+        { name: 'AES-GCM', length: 256 },
+        false,
+        ['encrypt', 'decrypt']
+      );
+    });
+}
+
+// protectText resolves to text encrypted if there is a passphrase and to text otherwise
+function protectText(text) {
+  if (!clipboardPass) {
+    return Promise.resolve(text);
+  }
+  var salt = crypto.getRandomValues(new Uint8Array(16));
+  var iv = crypto.getRandomValues(new Uint8Array(12));
+  return clipboardKey(salt)
+    .then(function (key) {
+      return crypto.subtle.encrypt({ name: 'AES-GCM', iv: iv }, key, new TextEncoder().encode(text));
+    })
+    .then(function (ciphertext) {
+      var data = new Uint8Array(28 + ciphertext.byteLength);
+      data.set(salt);
+      data.set(iv, 16);
+      data.set(new Uint8Array(ciphertext), 28);
+      // Chunks keep the arguments below the limit of the browser
+      var binary = '';
+      for (var i = 0; i < data.length; i += 0x8000) {
+        binary += String.fromCharCode.apply(null, data.subarray(i, i + 0x8000));
+      }
+      return btoa(binary);
+    });
+}
+
+function decryptText(encoded) {
+  var data = Uint8Array.from(atob(encoded), function (c) {
+    return c.charCodeAt(0);
+  });
+  return clipboardKey(data.slice(0, 16))
+    .then(function (key) {
+      return crypto.subtle.decrypt({ name: 'AES-GCM', iv: data.slice(16, 28) }, key, data.slice(28));
+    })
+    .then(function (plaintext) {
+      return new TextDecoder().decode(plaintext);
+    });
+}
+
+// toggleClipboardKey asks for the passphrase or forgets it
+function toggleClipboardKey() {
+  if (clipboardPass) {
+    sessionStorage.removeItem('goshsClipboardPass');
+  } else {
+    if (!window.crypto || !crypto.subtle) {
+      alert('Encryption in the browser needs https or localhost');
+      return false;
+    }
+    var pass = prompt('Passphrase shared with everybody who should read the clipboard');
+    if (!pass) {
+      return false;
+    }
+    sessionStorage.setItem('goshsClipboardPass', pass);
+  }
+  location.reload();
+  return false;
+}
+
+// decryptClipboard shows the plaintext of the encrypted entries if the passphrase fits
+function decryptClipboard() {
+  if (!clipboardPass) {
+    return;
+  }
+  var button = document.getElementById('cbLockButton');
+  if (button) {
+    button.classList.replace('btn-secondary', 'btn-warning');
+    button.querySelector('span').textContent = 'Forget passphrase';
+  }
+  document.querySelectorAll('.clipboardCard.encrypted').forEach(function (card) {
+    var textarea = card.querySelector('.cbEdit textarea');
+    var pre = card.querySelector('.card-body pre');
+    decryptText(textarea.value).then(
+      function (text) {
+        textarea.value = text;
+        pre.textContent = text;
+        card.classList.add('decrypted');
+      },
+      function () {
+        pre.textContent = 'Unable to decrypt, the entry was encrypted with another passphrase';
+      }
+    );
+  });
+}
+decryptClipboard();
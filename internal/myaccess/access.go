@@ -0,0 +1,205 @@
+// Package myaccess writes an access log in the Common or Combined Log Format
+// of Apache, so the usual log analyzers can read it, or as CEF or LEEF events
+// for a SIEM, either to a file or straight to a syslog collector.
+package myaccess
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/patrickhener/goshs/internal/myrotate"
+)
+
+const (
+	// FormatCommon is host ident user [time] "request" status bytes
+	FormatCommon = "common"
+	// FormatCombined adds "referer" "user-agent" to FormatCommon
+	FormatCombined = "combined"
+	// FormatCEF is the Common Event Format of ArcSight
+	FormatCEF = "cef"
+	// FormatLEEF is the Log Event Extended Format of QRadar
+	FormatLEEF = "leef"
+)
+
+// ErrFormat is returned for an unknown format
+var ErrFormat = errors.New("the access log format has to be common, combined, cef or leef")
+
+const timeFormat = "02/Jan/2006:15:04:05 -0700"
+
+// Log is the access log writer
+type Log struct {
+	mu      sync.Mutex
+	file    *myrotate.File
+	syslog  *syslog
+	format  string
+	version string
+}
+
+// New will open (or create) the access log at path and append lines in format, rotated according to opts.
+// A path like udp://host:514 or tcp://host:514 sends the lines to a syslog collector instead.
+// The version of goshs is part of CEF and LEEF events.
+func New(path, format, version string, opts myrotate.Options) (*Log, error) {
+	switch format {
+	case FormatCommon, FormatCombined, FormatCEF, FormatLEEF:
+	default:
+		return nil, ErrFormat
+	}
+	l := &Log{format: format, version: version}
+
+	if strings.HasPrefix(path, "udp://") || strings.HasPrefix(path, "tcp://") {
+		s, err := dialSyslog(path[:3], path[6:])
+		if err != nil {
+			return nil, err
+		}
+		l.syslog = s
+		return l, nil
+	}
+
+	file, err := myrotate.Open(path, opts)
+	if err != nil {
+		return nil, err
+	}
+	l.file = file
+	return l, nil
+}
+
+// Close will close the log file or the connection to the collector
+func (l *Log) Close() error {
+	if l.syslog != nil {
+		return l.syslog.Close()
+	}
+	return l.file.Close()
+}
+
+// Handler will log every request to next once it is answered
+func (l *Log) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &recorder{ResponseWriter: w}
+		next.ServeHTTP(rec, r)
+		l.write(r, rec, start)
+	})
+}
+
+func (l *Log) write(r *http.Request, rec *recorder, start time.Time) {
+	status := rec.status
+	if status == 0 {
+		status = http.StatusOK
+	}
+
+	var line string
+	switch l.format {
+	case FormatCEF:
+		line = l.cef(r, status, rec.size, start)
+	case FormatLEEF:
+		line = l.leef(r, status, rec.size, start)
+	default:
+		line = l.clf(r, status, rec.size, start)
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	// Errors are dropped, the access log must not keep requests from being answered
+	if l.syslog != nil {
+		// disable G104 (CWE-703): Errors unhandled
+		// #nosec G104
+		l.syslog.send(severity(status), start, line)
+		return
+	}
+	// disable G104 (CWE-703): Errors unhandled
+	// #nosec G104
+	l.file.Write([]byte(line + "\n"))
+}
+
+// clf returns the line in the Common or Combined Log Format
+func (l *Log) clf(r *http.Request, status int, sent int64, start time.Time) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	user := "-"
+	if u, _, ok := r.BasicAuth(); ok && u != "" {
+		user = escape(u)
+	}
+	size := "-"
+	if sent > 0 {
+		size = strconv.FormatInt(sent, 10)
+	}
+
+	line := fmt.Sprintf("%s - %s [%s] \"%s %s %s\" %d %s",
+		host, user, start.Format(timeFormat), escape(r.Method), escape(r.RequestURI), escape(r.Proto), status, size)
+	if l.format == FormatCombined {
+		line += fmt.Sprintf(" \"%s\" \"%s\"", escape(r.Referer()), escape(r.UserAgent()))
+	}
+	return line
+}
+
+// escape quotes, backslashes and control characters like Apache does, empty values become "-"
+func escape(s string) string {
+	if s == "" {
+		return "-"
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '"' || c == '\\':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		case c < 0x20 || c == 0x7f:
+			fmt.Fprintf(&b, "\\x%02x", c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+// recorder keeps the status and the size of the response
+type recorder struct {
+	http.ResponseWriter
+	status int
+	size   int64
+}
+
+func (r *recorder) WriteHeader(status int) {
+	if r.status == 0 {
+		r.status = status
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *recorder) Write(p []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	n, err := r.ResponseWriter.Write(p)
+	r.size += int64(n)
+	return n, err
+}
+
+// Flush keeps streamed responses like the speedtest working
+func (r *recorder) Flush() {
+	if f, ok := r.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack keeps websockets working, the connection is logged as switching protocols
+func (r *recorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := r.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("hijacking is not supported")
+	}
+	if r.status == 0 {
+		r.status = http.StatusSwitchingProtocols
+	}
+	return h.Hijack()
+}
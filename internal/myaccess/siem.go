@@ -0,0 +1,213 @@
+package myaccess
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The events reported to a SIEM
+const (
+	eventRequest = "request"
+	eventUpload  = "upload"
+	eventAuth    = "auth-failure"
+)
+
+// event returns the id, name and CEF severity (0-10) of a request.
+// A 401 without credentials is only the login prompt, not a failure.
+func event(r *http.Request, status int) (string, string, int) {
+	switch {
+	case status == http.StatusUnauthorized && r.Header.Get("Authorization") != "":
+		return eventAuth, "Authentication failure", 7
+	case (r.Method == http.MethodPost || r.Method == http.MethodPut) && r.ContentLength != 0 && status < http.StatusBadRequest:
+		return eventUpload, "File upload", 5
+	case status >= http.StatusInternalServerError:
+		return eventRequest, "Request failed", 6
+	default:
+		return eventRequest, "Request", 3
+	}
+}
+
+// cef returns the request as an ArcSight Common Event Format event
+func (l *Log) cef(r *http.Request, status int, sent int64, start time.Time) string {
+	id, name, sev := event(r, status)
+	host, port := splitAddr(r.RemoteAddr)
+	user, _, _ := r.BasicAuth()
+
+	ext := []string{
+		"rt=" + strconv.FormatInt(start.UnixNano()/int64(time.Millisecond), 10),
+		"src=" + cefValue(host),
+	}
+	if port != "" {
+		ext = append(ext, "spt="+port)
+	}
+	if user != "" {
+		ext = append(ext, "suser="+cefValue(user))
+	}
+	ext = append(ext,
+		"requestMethod="+cefValue(r.Method),
+		"request="+cefValue(r.RequestURI),
+		"app="+cefValue(r.Proto),
+		"cn1="+strconv.Itoa(status),
+		"cn1Label=status",
+		"out="+strconv.FormatInt(sent, 10),
+	)
+	if r.ContentLength > 0 {
+		ext = append(ext, "in="+strconv.FormatInt(r.ContentLength, 10))
+	}
+	if ua := r.UserAgent(); ua != "" {
+		ext = append(ext, "requestClientApplication="+cefValue(ua))
+	}
+
+	return fmt.Sprintf("CEF:0|goshs|goshs|%s|%s|%s|%d|%s",
+		cefHeader(l.version), id, cefHeader(name), sev, strings.Join(ext, " "))
+}
+
+// leef returns the request as a QRadar Log Event Extended Format 1.0 event
+func (l *Log) leef(r *http.Request, status int, sent int64, start time.Time) string {
+	id, _, sev := event(r, status)
+	host, port := splitAddr(r.RemoteAddr)
+	user, _, _ := r.BasicAuth()
+
+	attrs := []string{
+		"devTime=" + strconv.FormatInt(start.UnixNano()/int64(time.Millisecond), 10),
+		"cat=" + id,
+		"sev=" + strconv.Itoa(sev),
+		"src=" + leefValue(host),
+	}
+	if port != "" {
+		attrs = append(attrs, "srcPort="+port)
+	}
+	if user != "" {
+		attrs = append(attrs, "usrName="+leefValue(user))
+	}
+	attrs = append(attrs,
+		"method="+leefValue(r.Method),
+		"url="+leefValue(r.RequestURI),
+		"proto="+leefValue(r.Proto),
+		"status="+strconv.Itoa(status),
+		"dstBytes="+strconv.FormatInt(sent, 10),
+	)
+	if r.ContentLength > 0 {
+		attrs = append(attrs, "srcBytes="+strconv.FormatInt(r.ContentLength, 10))
+	}
+	if ua := r.UserAgent(); ua != "" {
+		attrs = append(attrs, "userAgent="+leefValue(ua))
+	}
+
+	return fmt.Sprintf("LEEF:1.0|goshs|goshs|%s|%s|%s",
+		leefValue(l.version), id, strings.Join(attrs, "\t"))
+}
+
+func splitAddr(addr string) (string, string) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return addr, ""
+	}
+	return host, port
+}
+
+// cefHeader escapes backslashes and pipes in header fields
+func cefHeader(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `|`, `\|`, "\r", " ", "\n", " ").Replace(s)
+}
+
+// cefValue escapes backslashes, equal signs and line breaks in extension values
+func cefValue(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `=`, `\=`, "\r", `\r`, "\n", `\n`).Replace(s)
+}
+
+// leefValue replaces the tab delimiter and control characters, LEEF 1.0 has no escaping
+func leefValue(s string) string {
+	return strings.Map(func(c rune) rune {
+		if c < 0x20 || c == 0x7f {
+			return ' '
+		}
+		return c
+	}, s)
+}
+
+// severity returns the syslog severity of a request
+func severity(status int) int {
+	switch {
+	case status >= http.StatusInternalServerError:
+		return 3 // err
+	case status == http.StatusUnauthorized:
+		return 4 // warning
+	case status >= http.StatusBadRequest:
+		return 5 // notice
+	default:
+		return 6 // info
+	}
+}
+
+// facilityUser is the syslog facility of the events
+const facilityUser = 1
+
+// syslog sends BSD syslog messages to a collector
+type syslog struct {
+	network string
+	addr    string
+	host    string
+	conn    net.Conn
+}
+
+func dialSyslog(network, addr string) (*syslog, error) {
+	host, err := os.Hostname()
+	if err != nil || host == "" {
+		host = "-"
+	}
+	s := &syslog{network: network, addr: addr, host: host}
+	if err := s.dial(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *syslog) dial() error {
+	conn, err := net.DialTimeout(s.network, s.addr, 5*time.Second)
+	if err != nil {
+		return err
+	}
+	s.conn = conn
+	return nil
+}
+
+// send will write msg, a TCP connection is dialed again once if it broke
+func (s *syslog) send(sev int, t time.Time, msg string) error {
+	line := fmt.Sprintf("<%d>%s %s goshs: %s", facilityUser*8+sev, t.Format(time.Stamp), s.host, msg)
+	// TCP needs a frame, newline delimited is what collectors accept
+	if s.network == "tcp" {
+		line += "\n"
+	}
+
+	if s.conn == nil {
+		if err := s.dial(); err != nil {
+			return err
+		}
+	}
+	_, err := s.conn.Write([]byte(line))
+	if err != nil && s.network == "tcp" {
+		// disable G104 (CWE-703): Errors unhandled
+		// #nosec G104
+		s.conn.Close()
+		s.conn = nil
+		if err := s.dial(); err != nil {
+			return err
+		}
+		_, err = s.conn.Write([]byte(line))
+	}
+	return err
+}
+
+// Close will close the connection
+func (s *syslog) Close() error {
+	if s.conn == nil {
+		return nil
+	}
+	return s.conn.Close()
+}
@@ -0,0 +1,214 @@
+// Package myactivity keeps track of the requests in progress and the recently
+// finished ones to show the operator what is going on right now.
+package myactivity
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	maxRecent  = 50
+	maxUploads = 20
+)
+
+// Transfer is a single request, Rate is the average in bytes per second
+type Transfer struct {
+	ID       uint64    `json:"id"`
+	Remote   string    `json:"remote"`
+	User     string    `json:"user,omitempty"`
+	Method   string    `json:"method"`
+	Path     string    `json:"path"`
+	Status   int       `json:"status,omitempty"`
+	Sent     int64     `json:"sent"`
+	Received int64     `json:"received"`
+	Size     int64     `json:"size,omitempty"`
+	Started  time.Time `json:"started"`
+	Seconds  float64   `json:"seconds"`
+	Rate     float64   `json:"rate"`
+}
+
+// Snapshot is the activity at one point in time, newest first
+type Snapshot struct {
+	Active  []Transfer `json:"active"`
+	Recent  []Transfer `json:"recent"`
+	Uploads []Transfer `json:"uploads"`
+}
+
+// Tracker records the requests passing its handler
+type Tracker struct {
+	skip func(*http.Request) bool
+
+	mu      sync.Mutex
+	nextID  uint64
+	active  map[uint64]*request
+	recent  []Transfer
+	uploads []Transfer
+}
+
+// request is a transfer in progress, the counters are updated while it runs.
+// They come first to be 64 bit aligned for atomic access on 32 bit platforms.
+type request struct {
+	sent     int64
+	received int64
+	size     int64
+	status   int32
+	Transfer
+}
+
+// New will return a tracker ignoring the requests skip returns true for
+func New(skip func(*http.Request) bool) *Tracker {
+	return &Tracker{skip: skip, active: make(map[uint64]*request)}
+}
+
+// Handler will track every request to next which is not skipped
+func (t *Tracker) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if t.skip != nil && t.skip(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		req := t.start(r)
+		defer t.finish(req)
+		if r.Body != nil && r.Body != http.NoBody {
+			r.Body = &countingBody{ReadCloser: r.Body, n: &req.received}
+		}
+		next.ServeHTTP(&countingWriter{ResponseWriter: w, req: req}, r)
+	})
+}
+
+func (t *Tracker) start(r *http.Request) *request {
+	user, _, _ := r.BasicAuth()
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.nextID++
+	req := &request{Transfer: Transfer{
+		ID:      t.nextID,
+		Remote:  host,
+		User:    user,
+		Method:  r.Method,
+		Path:    r.URL.Path,
+		Started: time.Now(),
+	}}
+	// Uploads announce their size, downloads in the response header
+	if r.ContentLength > 0 {
+		req.size = r.ContentLength
+	}
+	t.active[req.ID] = req
+	return req
+}
+
+func (t *Tracker) finish(req *request) {
+	done := req.snapshot()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.active, req.ID)
+	t.recent = prepend(t.recent, done, maxRecent)
+	if done.Received > 0 && (done.Method == http.MethodPost || done.Method == http.MethodPut) && done.Status < http.StatusBadRequest {
+		t.uploads = prepend(t.uploads, done, maxUploads)
+	}
+}
+
+func prepend(list []Transfer, t Transfer, max int) []Transfer {
+	list = append([]Transfer{t}, list...)
+	if len(list) > max {
+		list = list[:max]
+	}
+	return list
+}
+
+// snapshot returns the transfer with the current counters
+func (req *request) snapshot() Transfer {
+	t := req.Transfer
+	t.Sent = atomic.LoadInt64(&req.sent)
+	t.Received = atomic.LoadInt64(&req.received)
+	t.Status = int(atomic.LoadInt32(&req.status))
+	t.Size = atomic.LoadInt64(&req.size)
+	t.Seconds = time.Since(t.Started).Seconds()
+	if t.Seconds > 0 {
+		t.Rate = float64(t.Sent+t.Received) / t.Seconds
+	}
+	return t
+}
+
+// Snapshot returns the requests in progress and the recently finished ones
+func (t *Tracker) Snapshot() Snapshot {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	s := Snapshot{
+		Active:  make([]Transfer, 0, len(t.active)),
+		Recent:  append([]Transfer{}, t.recent...),
+		Uploads: append([]Transfer{}, t.uploads...),
+	}
+	for _, req := range t.active {
+		s.Active = append(s.Active, req.snapshot())
+	}
+	// Newest first like the finished ones
+	sort.Slice(s.Active, func(i, j int) bool { return s.Active[i].ID > s.Active[j].ID })
+	return s
+}
+
+type countingBody struct {
+	io.ReadCloser
+	n *int64
+}
+
+func (b *countingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	atomic.AddInt64(b.n, int64(n))
+	return n, err
+}
+
+// countingWriter counts the bytes sent and keeps the status
+type countingWriter struct {
+	http.ResponseWriter
+	req *request
+}
+
+func (w *countingWriter) WriteHeader(status int) {
+	if atomic.CompareAndSwapInt32(&w.req.status, 0, int32(status)) {
+		if size, err := strconv.ParseInt(w.Header().Get("Content-Length"), 10, 64); err == nil && size > 0 {
+			atomic.CompareAndSwapInt64(&w.req.size, 0, size)
+		}
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	atomic.CompareAndSwapInt32(&w.req.status, 0, http.StatusOK)
+	n, err := w.ResponseWriter.Write(p)
+	atomic.AddInt64(&w.req.sent, int64(n))
+	return n, err
+}
+
+// Flush keeps streamed responses like the speedtest working
+func (w *countingWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack keeps websockets working
+func (w *countingWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("hijacking is not supported")
+	}
+	atomic.CompareAndSwapInt32(&w.req.status, 0, http.StatusSwitchingProtocols)
+	return h.Hijack()
+}
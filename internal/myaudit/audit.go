@@ -0,0 +1,325 @@
+// Package myaudit provides a hash chained and periodically signed audit log.
+// Every record carries the hash of its predecessor, so removing or altering
+// a single line breaks the chain. At a fixed interval the current head of the
+// chain is signed with an ed25519 key generated at startup.
+package myaudit
+
+import (
+	"bufio"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/patrickhener/goshs/internal/mylog"
+	"github.com/patrickhener/goshs/internal/myrotate"
+)
+
+const (
+	typeKey       = "key"
+	typeRequest   = "request"
+	typeSignature = "signature"
+)
+
+// Record is a single line in the audit log
+type Record struct {
+	Seq        int64  `json:"seq"`
+	Time       string `json:"time"`
+	Type       string `json:"type"`
+	RemoteAddr string `json:"remote_addr,omitempty"`
+	Method     string `json:"method,omitempty"`
+	URL        string `json:"url,omitempty"`
+	Proto      string `json:"proto,omitempty"`
+	Status     int    `json:"status,omitempty"`
+	PublicKey  string `json:"public_key,omitempty"`
+	Signature  string `json:"signature,omitempty"`
+	Prev       string `json:"prev"`
+	Hash       string `json:"hash"`
+}
+
+// Log is the audit log writer
+type Log struct {
+	mu       sync.Mutex
+	file     *myrotate.File
+	seq      int64
+	prev     string
+	unsigned int
+	priv     ed25519.PrivateKey
+	// PublicKey is the hex encoded public key used to sign the log
+	PublicKey string
+	done      chan struct{}
+}
+
+// New will open (or create) the audit log at path, generate a fresh signing key
+// and sign the chain head every interval. It is rotated according to opts,
+// every file starts a chain of its own so it can be verified on its own.
+func New(path string, interval time.Duration, opts myrotate.Options) (*Log, error) {
+	if interval <= 0 {
+		return nil, fmt.Errorf("the signing interval needs to be positive, got %s", interval)
+	}
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+
+	opts.Manual = true
+	file, err := myrotate.Open(path, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	l := &Log{
+		file:      file,
+		priv:      priv,
+		PublicKey: hex.EncodeToString(pub),
+		done:      make(chan struct{}),
+	}
+
+	// Every run starts a new chain introduced by the public key
+	if err := l.write(&Record{Type: typeKey, PublicKey: l.PublicKey}); err != nil {
+		return nil, err
+	}
+
+	go l.signer(interval)
+
+	return l, nil
+}
+
+// LogRequest will add a request record to the chain, it matches mylog.RequestHook
+func (l *Log) LogRequest(req *http.Request, status int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.file.Due() {
+		if err := l.rotate(); err != nil {
+			mylog.Errorf("rotating audit log: %+v", err)
+		}
+	}
+
+	if err := l.write(&Record{
+		Type:       typeRequest,
+		RemoteAddr: req.RemoteAddr,
+		Method:     req.Method,
+		URL:        req.URL.String(),
+		Proto:      req.Proto,
+		Status:     status,
+	}); err != nil {
+		mylog.Errorf("writing audit record: %+v", err)
+		return
+	}
+	l.unsigned++
+}
+
+// Sign will sign the current head of the chain if there are unsigned records
+func (l *Log) Sign() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.sign()
+}
+
+// sign is Sign with l.mu held by the caller
+func (l *Log) sign() error {
+	if l.unsigned == 0 {
+		return nil
+	}
+
+	head, err := hex.DecodeString(l.prev)
+	if err != nil {
+		return err
+	}
+
+	if err := l.write(&Record{
+		Type:      typeSignature,
+		Signature: hex.EncodeToString(ed25519.Sign(l.priv, head)),
+	}); err != nil {
+		return err
+	}
+	l.unsigned = 0
+
+	return nil
+}
+
+// Close will sign the remaining records and close the audit log
+func (l *Log) Close() error {
+	close(l.done)
+	if err := l.Sign(); err != nil {
+		mylog.Errorf("signing audit log: %+v", err)
+	}
+	return l.file.Close()
+}
+
+// rotate will sign the chain, start a new file and a new chain in it, l.mu has to be held by the caller
+func (l *Log) rotate() error {
+	if err := l.sign(); err != nil {
+		return err
+	}
+	if err := l.file.Rotate(); err != nil {
+		return err
+	}
+	l.prev = ""
+	return l.write(&Record{Type: typeKey, PublicKey: l.PublicKey})
+}
+
+func (l *Log) signer(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := l.Sign(); err != nil {
+				mylog.Errorf("signing audit log: %+v", err)
+			}
+		case <-l.done:
+			return
+		}
+	}
+}
+
+// write will chain, hash and persist a record, l.mu has to be held by the caller
+func (l *Log) write(r *Record) error {
+	l.seq++
+	r.Seq = l.seq
+	r.Time = time.Now().Format(time.RFC3339Nano)
+	r.Prev = l.prev
+
+	hash, err := hashRecord(r)
+	if err != nil {
+		return err
+	}
+	r.Hash = hash
+
+	line, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+
+	if _, err := l.file.Write(append(line, '\n')); err != nil {
+		return err
+	}
+	l.prev = hash
+
+	return nil
+}
+
+// hashRecord returns the hex encoded sha256 over the record without its own hash
+func hashRecord(r *Record) (string, error) {
+	c := *r
+	c.Hash = ""
+	b, err := json.Marshal(c)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// Verify will check the chain and all signatures of the audit log at path.
+// It returns the public keys found so they can be compared to the ones
+// printed at startup. Requests not covered by a signature fail the check.
+func Verify(path string) ([]string, error) {
+	// disable G304 (CWE-22): Potential file inclusion via variable
+	// as the operator chooses the audit log to verify
+	// #nosec G304
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	// disable G307 (CWE-703): Deferring unsafe method "Close" on type "*os.File"
+	// #nosec G307
+	defer file.Close()
+
+	var (
+		keys    []string
+		pub     ed25519.PublicKey
+		prev    string
+		lineNum int
+		// unsigned are the request records since the last signature starting at line firstUnsigned
+		unsigned      int
+		firstUnsigned int
+	)
+	unsignedErr := func() error {
+		return fmt.Errorf("lines %d to %d: %d records are not covered by a signature, they were appended after the last one", firstUnsigned, lineNum, unsigned)
+	}
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		lineNum++
+		var r Record
+		if err := json.Unmarshal(scanner.Bytes(), &r); err != nil {
+			return keys, fmt.Errorf("line %d: %+v", lineNum, err)
+		}
+
+		// A key record starts a new chain
+		if r.Type == typeKey {
+			if unsigned > 0 {
+				lineNum--
+				return keys, unsignedErr()
+			}
+			key, err := hex.DecodeString(r.PublicKey)
+			if err != nil || len(key) != ed25519.PublicKeySize {
+				return keys, fmt.Errorf("line %d: invalid public key", lineNum)
+			}
+			pub = key
+			prev = ""
+			keys = append(keys, r.PublicKey)
+		}
+
+		if pub == nil {
+			return keys, fmt.Errorf("line %d: record before any public key", lineNum)
+		}
+
+		if r.Prev != prev {
+			return keys, fmt.Errorf("line %d: chain broken, previous hash does not match", lineNum)
+		}
+
+		hash, err := hashRecord(&r)
+		if err != nil {
+			return keys, err
+		}
+		if hash != r.Hash {
+			return keys, fmt.Errorf("line %d: record hash does not match its content", lineNum)
+		}
+
+		if r.Type == typeSignature {
+			head, err := hex.DecodeString(r.Prev)
+			if err != nil {
+				return keys, fmt.Errorf("line %d: %+v", lineNum, err)
+			}
+			sig, err := hex.DecodeString(r.Signature)
+			if err != nil || !ed25519.Verify(pub, head, sig) {
+				return keys, fmt.Errorf("line %d: invalid signature", lineNum)
+			}
+			unsigned = 0
+		}
+		if r.Type == typeRequest {
+			if unsigned == 0 {
+				firstUnsigned = lineNum
+			}
+			unsigned++
+		}
+
+		prev = r.Hash
+	}
+
+	if err := scanner.Err(); err != nil {
+		return keys, err
+	}
+
+	if len(keys) == 0 {
+		return nil, errors.New("audit log does not contain any records")
+	}
+	if unsigned > 0 {
+		return keys, unsignedErr()
+	}
+
+	return keys, nil
+}
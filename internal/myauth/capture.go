@@ -0,0 +1,58 @@
+package myauth
+
+import (
+	"encoding/json"
+	"os"
+	"sync"
+	"time"
+)
+
+// CaptureRecord is a single submitted set of credentials
+type CaptureRecord struct {
+	Time      string `json:"time"`
+	RemoteIP  string `json:"remote_ip"`
+	UserAgent string `json:"user_agent"`
+	URL       string `json:"url"`
+	User      string `json:"user"`
+	Password  string `json:"password"`
+	Success   bool   `json:"success"`
+}
+
+// CaptureLog writes every submitted credential to a file, turning goshs into a credential honeypot
+type CaptureLog struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+// NewCaptureLog will open (or create) the capture log at path
+func NewCaptureLog(path string) (*CaptureLog, error) {
+	// disable G304 (CWE-22): Potential file inclusion via variable
+	// as the operator chooses the capture log location
+	// #nosec G304
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, err
+	}
+	return &CaptureLog{file: file}, nil
+}
+
+// Capture will append the record to the capture log
+func (c *CaptureLog) Capture(r CaptureRecord) error {
+	r.Time = time.Now().Format(time.RFC3339)
+
+	line, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	_, err = c.file.Write(append(line, '\n'))
+	return err
+}
+
+// Close will close the capture log
+func (c *CaptureLog) Close() error {
+	return c.file.Close()
+}
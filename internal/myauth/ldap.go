@@ -0,0 +1,163 @@
+// Package myauth holds the authentication backends which can be used to
+// validate the credentials provided via basic auth
+package myauth
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-ldap/ldap/v3"
+)
+
+// DefaultLDAPFilter matches the user against the common OpenLDAP and Active Directory attributes
+const DefaultLDAPFilter = "(|(uid={user})(sAMAccountName={user})(userPrincipalName={user}))"
+
+// ldapCacheTime is how long a successful login is taken without asking the server again,
+// browsers send the credentials with every request of a page
+const ldapCacheTime = time.Minute
+
+// LDAP validates credentials against an LDAP or Active Directory server
+type LDAP struct {
+	URL      string
+	BaseDN   string
+	BindDN   string
+	BindPass string
+	Filter   string
+	Insecure bool
+
+	// mu guards the connection which is reused for all logins
+	mu   sync.Mutex
+	conn *ldap.Conn
+
+	// cache holds the expiry of successful logins by user and hash of the password
+	cacheMu sync.Mutex
+	cache   map[[sha256.Size]byte]time.Time
+}
+
+// Authenticate will look up the user below BaseDN and try to bind as the found entry with pass.
+// Successful logins are cached for a minute.
+func (l *LDAP) Authenticate(user, pass string) (bool, error) {
+	// An empty password would result in an unauthenticated bind which always succeeds
+	if user == "" || pass == "" {
+		return false, nil
+	}
+
+	key := sha256.Sum256([]byte(user + "\x00" + pass))
+	if l.cached(key) {
+		return true, nil
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	ok, err := l.authenticate(user, pass)
+	// The server may have closed the idle connection, try once more on a fresh one
+	if err != nil && ldap.IsErrorWithCode(err, ldap.ErrorNetwork) {
+		l.close()
+		ok, err = l.authenticate(user, pass)
+	}
+	if err != nil {
+		l.close()
+		return false, err
+	}
+	if ok {
+		l.remember(key)
+	}
+	return ok, nil
+}
+
+// cached reports whether the login of key succeeded recently
+func (l *LDAP) cached(key [sha256.Size]byte) bool {
+	l.cacheMu.Lock()
+	defer l.cacheMu.Unlock()
+	return time.Now().Before(l.cache[key])
+}
+
+// remember will cache the successful login of key and drop the expired ones
+func (l *LDAP) remember(key [sha256.Size]byte) {
+	l.cacheMu.Lock()
+	defer l.cacheMu.Unlock()
+	if l.cache == nil {
+		l.cache = make(map[[sha256.Size]byte]time.Time)
+	}
+	now := time.Now()
+	for k, expiry := range l.cache {
+		if now.After(expiry) {
+			delete(l.cache, k)
+		}
+	}
+	l.cache[key] = now.Add(ldapCacheTime)
+}
+
+// close will drop the connection, l.mu has to be held by the caller
+func (l *LDAP) close() {
+	if l.conn != nil {
+		l.conn.Close()
+		l.conn = nil
+	}
+}
+
+// authenticate is Authenticate on the shared connection, l.mu has to be held by the caller
+func (l *LDAP) authenticate(user, pass string) (bool, error) {
+	if l.conn == nil || l.conn.IsClosing() {
+		// disable G402 (CWE-295): TLS InsecureSkipVerify may be true
+		// as the operator explicitly asks for it
+		// #nosec G402
+		conn, err := ldap.DialURL(l.URL, ldap.DialWithTLSConfig(&tls.Config{InsecureSkipVerify: l.Insecure}))
+		if err != nil {
+			return false, err
+		}
+		l.conn = conn
+	}
+	conn := l.conn
+
+	var err error
+	// Bind with service account for the search if provided, anonymous otherwise
+	if l.BindDN != "" {
+		err = conn.Bind(l.BindDN, l.BindPass)
+	} else {
+		err = conn.UnauthenticatedBind("")
+	}
+	if err != nil {
+		return false, fmt.Errorf("binding for user search: %+v", err)
+	}
+
+	filter := l.Filter
+	if filter == "" {
+		filter = DefaultLDAPFilter
+	}
+	filter = strings.ReplaceAll(filter, "{user}", ldap.EscapeFilter(user))
+
+	result, err := conn.Search(ldap.NewSearchRequest(
+		l.BaseDN,
+		ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 0, 0, false,
+		filter,
+		[]string{"dn"},
+		nil,
+	))
+	if err != nil {
+		return false, fmt.Errorf("searching user: %+v", err)
+	}
+
+	if len(result.Entries) != 1 {
+		if len(result.Entries) > 1 {
+			return false, errors.New("user search returned more than one entry")
+		}
+		return false, nil
+	}
+
+	// Finally bind as the user to check the password
+	if err := conn.Bind(result.Entries[0].DN, pass); err != nil {
+		if ldap.IsErrorWithCode(err, ldap.LDAPResultInvalidCredentials) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	return true, nil
+}
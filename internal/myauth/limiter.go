@@ -0,0 +1,109 @@
+package myauth
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	maxBanTime = 24 * time.Hour
+	// forgetAfter is the time of inactivity after which a source starts from scratch
+	forgetAfter = 24 * time.Hour
+)
+
+// Limiter tracks failed logins per source ip and bans sources temporarily.
+// Every repeated ban of the same source doubles the ban time. A successful
+// login only forgives the failures for the same user, so a valid account
+// cannot be used to reset the count while guessing others.
+type Limiter struct {
+	MaxAttempts int
+	BanTime     time.Duration
+
+	mu      sync.Mutex
+	sources map[string]*source
+}
+
+type source struct {
+	failures int
+	// users are the failures by user name since the last ban
+	users       map[string]int
+	bans        uint
+	bannedUntil time.Time
+	last        time.Time
+}
+
+// NewLimiter will return a limiter banning a source for banTime after maxAttempts failed logins
+func NewLimiter(maxAttempts int, banTime time.Duration) *Limiter {
+	return &Limiter{
+		MaxAttempts: maxAttempts,
+		BanTime:     banTime,
+		sources:     make(map[string]*source),
+	}
+}
+
+// Banned reports if ip is currently banned and for how long
+func (l *Limiter) Banned(ip string) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	s, ok := l.sources[ip]
+	if !ok {
+		return false, 0
+	}
+	remaining := time.Until(s.bannedUntil)
+	return remaining > 0, remaining
+}
+
+// Fail records a failed login of user from ip. It returns the ban time if the source got banned.
+func (l *Limiter) Fail(ip, user string) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.cleanup()
+
+	s, ok := l.sources[ip]
+	if !ok {
+		s = &source{users: make(map[string]int)}
+		l.sources[ip] = s
+	}
+	s.last = time.Now()
+	s.failures++
+	s.users[user]++
+
+	if s.failures < l.MaxAttempts {
+		return 0
+	}
+
+	ban := l.BanTime << s.bans
+	if ban > maxBanTime || ban <= 0 {
+		ban = maxBanTime
+	}
+	s.bans++
+	s.failures = 0
+	s.users = make(map[string]int)
+	s.bannedUntil = time.Now().Add(ban)
+
+	return ban
+}
+
+// Success forgives the failed logins of user from ip, the bans of ip are kept
+func (l *Limiter) Success(ip, user string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	s, ok := l.sources[ip]
+	if !ok {
+		return
+	}
+	s.failures -= s.users[user]
+	delete(s.users, user)
+}
+
+// cleanup removes sources which have been quiet for a long time, l.mu has to be held by the caller
+func (l *Limiter) cleanup() {
+	for ip, s := range l.sources {
+		if time.Since(s.last) > forgetAfter && time.Now().After(s.bannedUntil) {
+			delete(l.sources, ip)
+		}
+	}
+}
@@ -0,0 +1,268 @@
+package myauth
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"golang.org/x/oauth2"
+)
+
+const (
+	// RoleRead allows to browse and download
+	RoleRead = "read"
+	// RoleWrite additionally allows to upload and modify
+	RoleWrite = "write"
+
+	// DefaultGroupsClaim is the id token claim holding the group memberships
+	DefaultGroupsClaim = "groups"
+
+	// StateLifetime is how long a login started may take at the identity provider
+	StateLifetime       = 10 * time.Minute
+	oidcSessionLifetime = 12 * time.Hour
+)
+
+// ErrNotPermitted is returned when a user authenticated successfully but is not in any permitted group
+var ErrNotPermitted = errors.New("user is not member of a permitted group")
+
+// Session represents a logged in user
+type Session struct {
+	User    string
+	Role    string
+	Expires time.Time
+}
+
+type pendingLogin struct {
+	nonce   string
+	next    string
+	expires time.Time
+}
+
+// OIDC is an OpenID Connect relying party
+type OIDC struct {
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	// RedirectURL is optional, it is derived from the request if empty
+	RedirectURL string
+	GroupsClaim string
+	// ReadGroups may read, if empty every authenticated user may read
+	ReadGroups []string
+	// WriteGroups may read and write
+	WriteGroups []string
+
+	provider *oidc.Provider
+	verifier *oidc.IDTokenVerifier
+
+	mu       sync.Mutex
+	pending  map[string]pendingLogin
+	sessions map[string]Session
+}
+
+// Init will run the provider discovery
+func (o *OIDC) Init(ctx context.Context) error {
+	provider, err := oidc.NewProvider(ctx, o.Issuer)
+	if err != nil {
+		return err
+	}
+	o.provider = provider
+	o.verifier = provider.Verifier(&oidc.Config{ClientID: o.ClientID})
+	o.pending = make(map[string]pendingLogin)
+	o.sessions = make(map[string]Session)
+	if o.GroupsClaim == "" {
+		o.GroupsClaim = DefaultGroupsClaim
+	}
+	return nil
+}
+
+func (o *OIDC) config(redirectURL string) *oauth2.Config {
+	if o.RedirectURL != "" {
+		redirectURL = o.RedirectURL
+	}
+	return &oauth2.Config{
+		ClientID:     o.ClientID,
+		ClientSecret: o.ClientSecret,
+		RedirectURL:  redirectURL,
+		Endpoint:     o.provider.Endpoint(),
+		Scopes:       []string{oidc.ScopeOpenID, "profile", "email"},
+	}
+}
+
+// LoginURL returns the url to redirect the browser to and the state to remember.
+// next is the path to return to after a successful login.
+func (o *OIDC) LoginURL(redirectURL, next string) (string, string, error) {
+	state, err := randomToken()
+	if err != nil {
+		return "", "", err
+	}
+	nonce, err := randomToken()
+	if err != nil {
+		return "", "", err
+	}
+
+	o.mu.Lock()
+	o.cleanup()
+	o.pending[state] = pendingLogin{
+		nonce:   nonce,
+		next:    next,
+		expires: time.Now().Add(StateLifetime),
+	}
+	o.mu.Unlock()
+
+	return o.config(redirectURL).AuthCodeURL(state, oidc.Nonce(nonce)), state, nil
+}
+
+// Callback will exchange the code, verify the id token and create a session.
+// It returns the session id and the path to return to.
+func (o *OIDC) Callback(ctx context.Context, redirectURL, state, code string) (string, string, error) {
+	o.mu.Lock()
+	p, ok := o.pending[state]
+	delete(o.pending, state)
+	o.mu.Unlock()
+	if !ok || time.Now().After(p.expires) {
+		return "", "", errors.New("unknown or expired login state")
+	}
+
+	token, err := o.config(redirectURL).Exchange(ctx, code)
+	if err != nil {
+		return "", "", fmt.Errorf("exchanging code: %+v", err)
+	}
+
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok {
+		return "", "", errors.New("no id_token in token response")
+	}
+
+	idToken, err := o.verifier.Verify(ctx, rawIDToken)
+	if err != nil {
+		return "", "", fmt.Errorf("verifying id token: %+v", err)
+	}
+	if idToken.Nonce != p.nonce {
+		return "", "", errors.New("nonce of id token does not match")
+	}
+
+	var claims map[string]interface{}
+	if err := idToken.Claims(&claims); err != nil {
+		return "", "", err
+	}
+
+	user := idToken.Subject
+	for _, c := range []string{"preferred_username", "email"} {
+		if v, ok := claims[c].(string); ok && v != "" {
+			user = v
+			break
+		}
+	}
+
+	role := o.role(groupsFromClaim(claims[o.GroupsClaim]))
+	if role == "" {
+		return "", "", fmt.Errorf("%s: %w", user, ErrNotPermitted)
+	}
+
+	id, err := randomToken()
+	if err != nil {
+		return "", "", err
+	}
+
+	o.mu.Lock()
+	o.sessions[id] = Session{
+		User:    user,
+		Role:    role,
+		Expires: time.Now().Add(oidcSessionLifetime),
+	}
+	o.mu.Unlock()
+
+	return id, p.next, nil
+}
+
+// Session returns the session for id if it exists and is not expired
+func (o *OIDC) Session(id string) (Session, bool) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	s, ok := o.sessions[id]
+	if !ok {
+		return Session{}, false
+	}
+	if time.Now().After(s.Expires) {
+		delete(o.sessions, id)
+		return Session{}, false
+	}
+	return s, true
+}
+
+// Logout will remove the session
+func (o *OIDC) Logout(id string) {
+	o.mu.Lock()
+	delete(o.sessions, id)
+	o.mu.Unlock()
+}
+
+// role maps the group memberships to a role, an empty role means no access
+func (o *OIDC) role(groups []string) string {
+	if containsAny(groups, o.WriteGroups) {
+		return RoleWrite
+	}
+	if len(o.ReadGroups) > 0 && !containsAny(groups, o.ReadGroups) {
+		return ""
+	}
+	// Without write groups everyone who may read may write as well
+	if len(o.WriteGroups) == 0 {
+		return RoleWrite
+	}
+	return RoleRead
+}
+
+// cleanup removes expired logins and sessions, o.mu has to be held by the caller
+func (o *OIDC) cleanup() {
+	now := time.Now()
+	for k, p := range o.pending {
+		if now.After(p.expires) {
+			delete(o.pending, k)
+		}
+	}
+	for k, s := range o.sessions {
+		if now.After(s.Expires) {
+			delete(o.sessions, k)
+		}
+	}
+}
+
+func groupsFromClaim(claim interface{}) []string {
+	var groups []string
+	switch c := claim.(type) {
+	case string:
+		groups = append(groups, c)
+	case []interface{}:
+		for _, g := range c {
+			if s, ok := g.(string); ok {
+				groups = append(groups, s)
+			}
+		}
+	}
+	return groups
+}
+
+func containsAny(have, want []string) bool {
+	for _, h := range have {
+		for _, w := range want {
+			if h == w {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func randomToken() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
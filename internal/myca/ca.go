@@ -6,7 +6,6 @@ package myca
 import (
 	"bytes"
 	"crypto/rand"
-	"crypto/rsa"
 
 	// disable G505 (CWE-327): Blocklisted import crypto/sha1: weak cryptographic primitive
 	// #nosec G505
@@ -16,9 +15,12 @@ import (
 	"crypto/x509"
 	"crypto/x509/pkix"
 	"encoding/pem"
+	"errors"
 	"fmt"
 	"io/ioutil"
+	"math/big"
 	"net"
+	"os"
 	"strings"
 	"time"
 
@@ -87,94 +89,145 @@ func ParseAndSum(cert string) (sha256s, sha1s string, err error) {
 	return sha256s, sha1s, nil
 }
 
-// Setup will deliver a fully initialized CA and server cert
-func Setup() (serverTLSConf *tls.Config, sha256s, sha1s string, err error) {
-	randInt, err := myutils.RandomNumber()
-	if err != nil {
-		mylog.Errorf("when creating certificate: %+v", err)
+// DefaultCommonName is the common name of the generated certificates
+const DefaultCommonName = "goshs - SimpleHTTPServer"
+
+// Options customize the generated server certificate
+type Options struct {
+	// CacheDir is used to persist and reuse the certificates if not empty
+	CacheDir string
+	// CACert and CAKey sign the server certificate instead of a generated CA if set
+	CACert string
+	CAKey  string
+	// KeyAlgorithm is one of rsa (default), ecdsa or ed25519
+	KeyAlgorithm string
+	CommonName   string
+	DNSNames     []string
+	IPAddresses  []net.IP
+	// Anonymous leaves out everything pointing to goshs from the generated certificates
+	Anonymous bool
+}
+
+// ParseSANs will split subject alternative names into ip addresses and dns names
+func (o *Options) ParseSANs(sans []string) {
+	for _, san := range sans {
+		if ip := net.ParseIP(san); ip != nil {
+			o.IPAddresses = append(o.IPAddresses, ip)
+		} else {
+			o.DNSNames = append(o.DNSNames, san)
+		}
 	}
-	ca := &x509.Certificate{
-		SerialNumber: &randInt,
-		Subject: pkix.Name{
-			Organization:       []string{"hesec.de"},
-			OrganizationalUnit: []string{"hesec.de"},
-			CommonName:         "goshs - SimpleHTTPServer",
-			Country:            []string{"DE"},
-			Province:           []string{"BW"},
-			Locality:           []string{"Althengstett"},
-			StreetAddress:      []string{"Gopher-Street"},
-			PostalCode:         []string{"75382"},
-		},
-		NotBefore:             time.Now(),
-		NotAfter:              time.Now().AddDate(10, 0, 0),
-		IsCA:                  true,
-		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth, x509.ExtKeyUsageServerAuth},
-		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
-		BasicConstraintsValid: true,
+}
+
+// names returns the common name, dns names and ip addresses of the server certificate
+func (o Options) names() (string, []string, []net.IP) {
+	cn := o.CommonName
+	dnsNames := append([]string(nil), o.DNSNames...)
+	ips := []net.IP{net.IPv4(127, 0, 0, 1), net.IPv6loopback}
+	ips = append(ips, o.IPAddresses...)
+
+	if cn == "" {
+		cn = DefaultCommonName
+		if o.Anonymous {
+			cn = "localhost"
+		}
+	} else if ip := net.ParseIP(cn); ip != nil {
+		// Clients ignore the common name, so it has to be a SAN as well
+		ips = append(ips, ip)
+	} else {
+		dnsNames = append(dnsNames, cn)
 	}
 
-	// create our private and public key
-	caPrivKey, err := rsa.GenerateKey(rand.Reader, 4096)
-	if err != nil {
-		return nil, "", "", err
+	return cn, dnsNames, ips
+}
+
+// subject returns the subject of a generated certificate with the common name cn
+func (o Options) subject(cn string) pkix.Name {
+	if o.Anonymous {
+		return pkix.Name{CommonName: cn}
+	}
+	return pkix.Name{
+		Organization:       []string{"hesec.de"},
+		OrganizationalUnit: []string{"hesec.de"},
+		CommonName:         cn,
+		Country:            []string{"DE"},
+		Province:           []string{"BW"},
+		Locality:           []string{"Althengstett"},
+		StreetAddress:      []string{"Gopher-Street"},
+		PostalCode:         []string{"75382"},
 	}
+}
 
-	// create the CA
-	caBytes, err := x509.CreateCertificate(rand.Reader, ca, ca, &caPrivKey.PublicKey, caPrivKey)
+// serialNumber returns a random serial, anonymous ones are as long as the ones of public CAs
+func (o Options) serialNumber() *big.Int {
+	if o.Anonymous {
+		n, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 127))
+		if err == nil {
+			return n
+		}
+		mylog.Errorf("when creating certificate: %+v", err)
+	}
+	randInt, err := myutils.RandomNumber()
 	if err != nil {
-		return nil, "", "", err
+		mylog.Errorf("when creating certificate: %+v", err)
 	}
+	return &randInt
+}
 
-	// pem encode
-	caPEM := new(bytes.Buffer)
-	if err := pem.Encode(caPEM, &pem.Block{
-		Type:  "CERTIFICATE",
-		Bytes: caBytes,
-	}); err != nil {
-		mylog.Errorf("encoding pem: %+v", err)
+// Setup will deliver a fully initialized CA and server cert, the pem encoded CA is returned to be handed out.
+// If opts.CacheDir is not empty the CA and server cert are reused from there or persisted there.
+func Setup(opts Options) (serverTLSConf *tls.Config, caPEM []byte, sha256s, sha1s string, err error) {
+	if opts.CacheDir != "" {
+		serverTLSConf, caPEM, sha256s, sha1s, err = loadCache(opts)
+		switch {
+		case err == nil:
+			mylog.Infof("Reusing self-signed certificate from %s", opts.CacheDir)
+			return serverTLSConf, caPEM, sha256s, sha1s, nil
+		case errors.Is(err, errCacheMismatch):
+			mylog.Warnf("Cached certificate in %s does not match the requested names or CA, generating a new one", opts.CacheDir)
+		case !os.IsNotExist(err):
+			return nil, nil, "", "", err
+		}
 	}
+	cn, dnsNames, ips := opts.names()
 
-	caPrivKeyPEM := new(bytes.Buffer)
-	if err := pem.Encode(caPrivKeyPEM, &pem.Block{
-		Type:  "RSA PRIVATE KEY",
-		Bytes: x509.MarshalPKCS1PrivateKey(caPrivKey),
-	}); err != nil {
-		mylog.Errorf("encoding pem: %+v", err)
+	// Either sign with the operator CA or with a fresh one
+	var ca *x509.Certificate
+	var caPrivKey interface{}
+	var caPrivKeyPEM []byte
+	if opts.CACert != "" {
+		ca, caPrivKey, caPEM, err = LoadCA(opts.CACert, opts.CAKey)
+	} else {
+		ca, caPrivKey, caPEM, caPrivKeyPEM, err = newCA(opts)
 	}
-
-	randInt, err = myutils.RandomNumber()
 	if err != nil {
-		mylog.Errorf("when creating certificate: %+v", err)
+		return nil, nil, "", "", err
 	}
+
 	// set up our server certificate
 	cert := &x509.Certificate{
-		SerialNumber: &randInt,
-		Subject: pkix.Name{
-			Organization:       []string{"hesec.de"},
-			OrganizationalUnit: []string{"hesec.de"},
-			CommonName:         "goshs - SimpleHTTPServer",
-			Country:            []string{"DE"},
-			Province:           []string{"BW"},
-			Locality:           []string{"Althengstett"},
-			StreetAddress:      []string{"Gopher-Street"},
-			PostalCode:         []string{"75382"},
-		},
-		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1), net.IPv6loopback},
+		SerialNumber: opts.serialNumber(),
+		Subject:      opts.subject(cn),
+		DNSNames:     dnsNames,
+		IPAddresses:  ips,
 		NotBefore:    time.Now(),
 		NotAfter:     time.Now().AddDate(10, 0, 0),
 		SubjectKeyId: []byte{1, 2, 3, 4, 6},
 		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth, x509.ExtKeyUsageServerAuth},
 		KeyUsage:     x509.KeyUsageDigitalSignature,
 	}
+	if opts.Anonymous {
+		cert.SubjectKeyId = nil
+	}
 
-	certPrivKey, err := rsa.GenerateKey(rand.Reader, 4096)
+	certPrivKey, certPrivKeyPEM, err := generateKey(opts.KeyAlgorithm)
 	if err != nil {
-		return nil, "", "", err
+		return nil, nil, "", "", err
 	}
 
-	certBytes, err := x509.CreateCertificate(rand.Reader, cert, ca, &certPrivKey.PublicKey, caPrivKey)
+	certBytes, err := x509.CreateCertificate(rand.Reader, cert, ca, certPrivKey.Public(), caPrivKey)
 	if err != nil {
-		return nil, "", "", err
+		return nil, nil, "", "", err
 	}
 
 	certPEM := new(bytes.Buffer)
@@ -185,15 +238,25 @@ func Setup() (serverTLSConf *tls.Config, sha256s, sha1s string, err error) {
 		mylog.Errorf("encoding pem: %+v", err)
 	}
 
-	certPrivKeyPEM := new(bytes.Buffer)
-	if err := pem.Encode(certPrivKeyPEM, &pem.Block{
-		Type:  "RSA PRIVATE KEY",
-		Bytes: x509.MarshalPKCS1PrivateKey(certPrivKey),
-	}); err != nil {
-		mylog.Errorf("encoding pem: %+v", err)
+	// Hand out the operator CA along with the leaf, it might be an intermediate
+	if opts.CACert != "" {
+		certPEM.Write(caPEM)
 	}
 
-	serverCert, err := tls.X509KeyPair(certPEM.Bytes(), certPrivKeyPEM.Bytes())
+	if opts.CacheDir != "" {
+		if err := writeCache(opts.CacheDir, caPEM, caPrivKeyPEM, certPEM.Bytes(), certPrivKeyPEM); err != nil {
+			return nil, nil, "", "", err
+		}
+		mylog.Infof("Saved self-signed certificate to %s", opts.CacheDir)
+	}
+
+	serverTLSConf, sha256s, sha1s, err = serverConfig(certPEM.Bytes(), certPrivKeyPEM)
+	return serverTLSConf, caPEM, sha256s, sha1s, err
+}
+
+// serverConfig builds the tls config from the pem encoded server cert and key
+func serverConfig(certPEM, keyPEM []byte) (serverTLSConf *tls.Config, sha256s, sha1s string, err error) {
+	serverCert, err := tls.X509KeyPair(certPEM, keyPEM)
 	if err != nil {
 		return nil, "", "", err
 	}
@@ -203,7 +266,80 @@ func Setup() (serverTLSConf *tls.Config, sha256s, sha1s string, err error) {
 		MinVersion:   tls.VersionTLS12,
 	}
 
-	sha256s, sha1s = Sum(certBytes)
+	sha256s, sha1s = Sum(serverCert.Certificate[0])
 
 	return
 }
+
+// newCA will generate a fresh CA with a key of opts.KeyAlgorithm
+func newCA(opts Options) (ca *x509.Certificate, caPrivKey interface{}, caPEM, caPrivKeyPEM []byte, err error) {
+	cn := DefaultCommonName + " CA"
+	if opts.Anonymous {
+		cn = "Local Root CA"
+	}
+	// The subject has to differ from the server cert, otherwise clients take the server cert for self-signed
+	ca = &x509.Certificate{
+		SerialNumber:          opts.serialNumber(),
+		Subject:               opts.subject(cn),
+		NotBefore:             time.Now(),
+		NotAfter:              time.Now().AddDate(10, 0, 0),
+		IsCA:                  true,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth, x509.ExtKeyUsageServerAuth},
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+
+	// create our private and public key
+	key, caPrivKeyPEM, err := generateKey(opts.KeyAlgorithm)
+	if err != nil {
+		return nil, nil, nil, nil, err
+	}
+
+	// create the CA
+	caBytes, err := x509.CreateCertificate(rand.Reader, ca, ca, key.Public(), key)
+	if err != nil {
+		return nil, nil, nil, nil, err
+	}
+
+	// pem encode
+	caPEMBuf := new(bytes.Buffer)
+	if err := pem.Encode(caPEMBuf, &pem.Block{
+		Type:  "CERTIFICATE",
+		Bytes: caBytes,
+	}); err != nil {
+		mylog.Errorf("encoding pem: %+v", err)
+	}
+
+	return ca, key, caPEMBuf.Bytes(), caPrivKeyPEM, nil
+}
+
+// LoadCA will load an operator provided CA to sign the server certificate with
+func LoadCA(certFile, keyFile string) (ca *x509.Certificate, caPrivKey interface{}, caPEM []byte, err error) {
+	// disable G304 (CWE-22): Potential file inclusion via variable
+	// as the operator chooses the CA
+	// #nosec G304
+	caPEM, err = ioutil.ReadFile(certFile)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	// #nosec G304
+	caKeyPEM, err := ioutil.ReadFile(keyFile)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	// Parses RSA, ECDSA and Ed25519 keys and checks they belong together
+	pair, err := tls.X509KeyPair(caPEM, caKeyPEM)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	ca, err = x509.ParseCertificate(pair.Certificate[0])
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if !ca.IsCA {
+		return nil, nil, nil, fmt.Errorf("%s is not a CA certificate", certFile)
+	}
+
+	return ca, pair.PrivateKey, caPEM, nil
+}
@@ -0,0 +1,125 @@
+package myca
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// Files of the certificate cache
+const (
+	CacheCACert     = "goshs-ca.crt"
+	CacheCAKey      = "goshs-ca.key"
+	CacheServerCert = "goshs.crt"
+	CacheServerKey  = "goshs.key"
+)
+
+var errCacheMismatch = errors.New("cached certificate does not match")
+
+// loadCache will load a previously persisted self-signed certificate
+func loadCache(opts Options) (serverTLSConf *tls.Config, caPEM []byte, sha256s, sha1s string, err error) {
+	dir := opts.CacheDir
+
+	// The CA is not needed to serve but has to be there to be handed out
+	// disable G304 (CWE-22): Potential file inclusion via variable
+	// as the operator chooses the cache directory
+	// #nosec G304
+	caPEM, err = ioutil.ReadFile(filepath.Join(dir, CacheCACert))
+	if err != nil {
+		return nil, nil, "", "", err
+	}
+	// A different operator CA requires a new certificate
+	if opts.CACert != "" {
+		// #nosec G304
+		wantPEM, err := ioutil.ReadFile(opts.CACert)
+		if err != nil {
+			return nil, nil, "", "", err
+		}
+		if !bytes.Equal(caPEM, wantPEM) {
+			return nil, nil, "", "", errCacheMismatch
+		}
+	} else if _, err := os.Stat(filepath.Join(dir, CacheCAKey)); os.IsNotExist(err) {
+		// Signed by an operator CA before
+		return nil, nil, "", "", errCacheMismatch
+	}
+
+	// #nosec G304
+	certPEM, err := ioutil.ReadFile(filepath.Join(dir, CacheServerCert))
+	if err != nil {
+		return nil, nil, "", "", err
+	}
+	// #nosec G304
+	keyPEM, err := ioutil.ReadFile(filepath.Join(dir, CacheServerKey))
+	if err != nil {
+		return nil, nil, "", "", err
+	}
+
+	serverTLSConf, sha256s, sha1s, err = serverConfig(certPEM, keyPEM)
+	if err != nil {
+		return nil, nil, "", "", err
+	}
+
+	leaf, err := x509.ParseCertificate(serverTLSConf.Certificates[0].Certificate[0])
+	if err != nil {
+		return nil, nil, "", "", err
+	}
+	if !opts.matches(leaf) {
+		return nil, nil, "", "", errCacheMismatch
+	}
+
+	return serverTLSConf, caPEM, sha256s, sha1s, nil
+}
+
+// matches checks if the certificate was issued for the names of the options
+func (o Options) matches(cert *x509.Certificate) bool {
+	cn, dnsNames, ips := o.names()
+	if cert.PublicKeyAlgorithm != publicKeyAlgorithm(o.KeyAlgorithm) || cert.Subject.CommonName != cn || len(cert.DNSNames) != len(dnsNames) || len(cert.IPAddresses) != len(ips) {
+		return false
+	}
+	for i := range dnsNames {
+		if cert.DNSNames[i] != dnsNames[i] {
+			return false
+		}
+	}
+	for i := range ips {
+		if !cert.IPAddresses[i].Equal(ips[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// writeCache will persist the self-signed certificate, keys are only readable by the owner
+func writeCache(dir string, caPEM, caKeyPEM, certPEM, keyPEM []byte) error {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+
+	files := []struct {
+		name string
+		data []byte
+		perm os.FileMode
+	}{
+		{CacheCACert, caPEM, 0644},
+		{CacheCAKey, caKeyPEM, 0600},
+		{CacheServerCert, certPEM, 0644},
+		{CacheServerKey, keyPEM, 0600},
+	}
+	for _, f := range files {
+		// The key of an operator CA is never copied
+		if f.data == nil {
+			if err := os.Remove(filepath.Join(dir, f.name)); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+			continue
+		}
+		if err := ioutil.WriteFile(filepath.Join(dir, f.name), f.data, f.perm); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+package myca
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+)
+
+// Key algorithms of the generated certificates
+const (
+	KeyRSA     = "rsa"
+	KeyECDSA   = "ecdsa"
+	KeyEd25519 = "ed25519"
+)
+
+// CheckKeyAlgorithm returns an error if alg is not supported
+func CheckKeyAlgorithm(alg string) error {
+	switch alg {
+	case "", KeyRSA, KeyECDSA, KeyEd25519:
+		return nil
+	}
+	return fmt.Errorf("unknown key algorithm '%s', use one of rsa, ecdsa, ed25519", alg)
+}
+
+// generateKey will create a private key of alg (rsa if empty) and its pem encoding
+func generateKey(alg string) (crypto.Signer, []byte, error) {
+	switch alg {
+	case KeyECDSA:
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			return nil, nil, err
+		}
+		return encodePKCS8(key)
+	case KeyEd25519:
+		_, key, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, nil, err
+		}
+		return encodePKCS8(key)
+	default:
+		key, err := rsa.GenerateKey(rand.Reader, 4096)
+		if err != nil {
+			return nil, nil, err
+		}
+		return key, pem.EncodeToMemory(&pem.Block{
+			Type:  "RSA PRIVATE KEY",
+			Bytes: x509.MarshalPKCS1PrivateKey(key),
+		}), nil
+	}
+}
+
+func encodePKCS8(key crypto.Signer) (crypto.Signer, []byte, error) {
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	return key, pem.EncodeToMemory(&pem.Block{
+		Type:  "PRIVATE KEY",
+		Bytes: der,
+	}), nil
+}
+
+// publicKeyAlgorithm maps alg to the x509 public key algorithm
+func publicKeyAlgorithm(alg string) x509.PublicKeyAlgorithm {
+	switch alg {
+	case KeyECDSA:
+		return x509.ECDSA
+	case KeyEd25519:
+		return x509.Ed25519
+	default:
+		return x509.RSA
+	}
+}
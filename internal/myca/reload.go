@@ -0,0 +1,111 @@
+package myca
+
+import (
+	"crypto/tls"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/patrickhener/goshs/internal/mylog"
+)
+
+// CertReloader serves a certificate loaded from disk and reloads it when the files change
+type CertReloader struct {
+	load  func() (tls.Certificate, error)
+	files []string
+
+	mu      sync.RWMutex
+	cert    *tls.Certificate
+	modTime time.Time
+}
+
+// NewCertReloader will load the certificate initially and start to watch files every interval
+func NewCertReloader(load func() (tls.Certificate, error), interval time.Duration, files ...string) (*CertReloader, error) {
+	r := &CertReloader{
+		load:  load,
+		files: files,
+	}
+
+	modTime, err := r.latestModTime()
+	if err != nil {
+		return nil, err
+	}
+	cert, err := load()
+	if err != nil {
+		return nil, err
+	}
+	r.cert = &cert
+	r.modTime = modTime
+
+	go r.watch(interval)
+
+	return r, nil
+}
+
+// GetCertificate is meant to be used as tls.Config.GetCertificate
+func (r *CertReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.cert, nil
+}
+
+func (r *CertReloader) watch(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		modTime, err := r.latestModTime()
+		if err != nil {
+			mylog.Errorf("watching certificate: %+v", err)
+			continue
+		}
+		if !modTime.After(r.modTime) {
+			continue
+		}
+
+		// Key and cert might not be written both yet, keep the old one and retry
+		cert, err := r.load()
+		if err != nil {
+			mylog.Errorf("reloading certificate: %+v", err)
+			continue
+		}
+
+		r.mu.Lock()
+		r.cert = &cert
+		r.modTime = modTime
+		r.mu.Unlock()
+
+		sha256s, _ := Sum(cert.Certificate[0])
+		mylog.Infof("Reloaded changed certificate with SHA-256 Fingerprint: %+v", sha256s)
+	}
+}
+
+func (r *CertReloader) latestModTime() (time.Time, error) {
+	var latest time.Time
+	for _, f := range r.files {
+		info, err := os.Stat(f)
+		if err != nil {
+			return latest, err
+		}
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest, nil
+}
+
+// SelectBySNI returns a tls.Config.GetCertificate choosing the first certificate valid for the
+// requested server name, the first reloader is the default
+func SelectBySNI(reloaders []*CertReloader) func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	return func(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+		if hello.ServerName != "" {
+			for _, r := range reloaders {
+				cert, _ := r.GetCertificate(hello)
+				if hello.SupportsCertificate(cert) == nil {
+					return cert, nil
+				}
+			}
+		}
+		return reloaders[0].GetCertificate(hello)
+	}
+}
@@ -0,0 +1,90 @@
+package myca
+
+import (
+	"crypto/tls"
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"software.sslmate.com/src/go-pkcs12"
+)
+
+// KeyPair is a pem encoded certificate and key file
+type KeyPair struct {
+	Cert string
+	Key  string
+}
+
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// ParseTLSVersion will translate a version like 1.2 to its tls constant
+func ParseTLSVersion(version string) (uint16, error) {
+	v, ok := tlsVersions[strings.TrimPrefix(strings.ToLower(version), "tls")]
+	if !ok {
+		return 0, fmt.Errorf("unknown TLS version '%s', use one of 1.0, 1.1, 1.2, 1.3", version)
+	}
+	return v, nil
+}
+
+// ParseCipherSuites will translate the IANA names of cipher suites to their ids.
+// Insecure suites are accepted on purpose to be able to talk to legacy clients.
+func ParseCipherSuites(names []string) ([]uint16, error) {
+	known := make(map[string]uint16)
+	for _, s := range append(tls.CipherSuites(), tls.InsecureCipherSuites()...) {
+		known[s.Name] = s.ID
+	}
+
+	ids := make([]uint16, 0, len(names))
+	for _, name := range names {
+		id, ok := known[strings.ToUpper(name)]
+		if !ok {
+			return nil, fmt.Errorf("unknown cipher suite '%s'", name)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// CipherSuiteNames returns the names of all supported cipher suites
+func CipherSuiteNames() []string {
+	var names []string
+	for _, s := range tls.CipherSuites() {
+		names = append(names, s.Name)
+	}
+	for _, s := range tls.InsecureCipherSuites() {
+		names = append(names, s.Name+" (insecure)")
+	}
+	return names
+}
+
+// LoadPKCS12 will load a key and certificate chain from a .p12/.pfx bundle
+func LoadPKCS12(file, password string) (cert tls.Certificate, sha256s, sha1s string, err error) {
+	// disable G304 (CWE-22): Potential file inclusion via variable
+	// as the operator chooses the bundle
+	// #nosec G304
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return cert, "", "", err
+	}
+
+	key, leaf, chain, err := pkcs12.DecodeChain(data, password)
+	if err != nil {
+		return cert, "", "", err
+	}
+
+	cert.PrivateKey = key
+	cert.Leaf = leaf
+	cert.Certificate = append(cert.Certificate, leaf.Raw)
+	for _, c := range chain {
+		cert.Certificate = append(cert.Certificate, c.Raw)
+	}
+
+	sha256s, sha1s = Sum(leaf.Raw)
+
+	return cert, sha256s, sha1s, nil
+}
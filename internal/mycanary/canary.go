@@ -0,0 +1,96 @@
+// Package mycanary watches for requests to canary paths, tripwires nobody
+// legitimate should ever request, and raises an alert when they are hit.
+package mycanary
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"path"
+	"sync"
+	"time"
+
+	"github.com/patrickhener/goshs/internal/mylog"
+	"github.com/patrickhener/goshs/internal/mynotify"
+	"github.com/patrickhener/goshs/internal/mywebhook"
+)
+
+// quiet is how long further hits of a client on the same canary only get logged
+const quiet = time.Minute
+
+// Canary raises alerts for requests matching its patterns
+type Canary struct {
+	patterns []string
+	desktop  bool
+	webhook  *mywebhook.Webhook
+
+	mu   sync.Mutex
+	last map[string]time.Time
+}
+
+// New will return a canary for the path patterns (see path.Match), e.g. /admin or /.git/*.
+// Alerts go to the log, the webhook if not nil and the desktop if set.
+func New(patterns []string, desktop bool, webhook *mywebhook.Webhook) (*Canary, error) {
+	if len(patterns) == 0 {
+		return nil, errors.New("no canary paths given")
+	}
+	for _, p := range patterns {
+		if _, err := path.Match(p, "/"); err != nil {
+			return nil, errors.New("invalid canary path " + p)
+		}
+	}
+	return &Canary{patterns: patterns, desktop: desktop, webhook: webhook, last: make(map[string]time.Time)}, nil
+}
+
+// Handler will check every request before passing it to next, which answers it as usual
+func (c *Canary) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if pattern, ok := c.match(r.URL.Path); ok {
+			c.trip(r, pattern)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (c *Canary) match(upath string) (string, bool) {
+	for _, p := range c.patterns {
+		if ok, _ := path.Match(p, upath); ok {
+			return p, true
+		}
+	}
+	return "", false
+}
+
+func (c *Canary) trip(r *http.Request, pattern string) {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	mylog.Warnf("!!! CANARY !!! %s requested %s %s (canary %s, user agent %q)", host, r.Method, r.URL.Path, pattern, r.UserAgent())
+
+	// Scanners hit a canary over and over, alert once in a while only
+	key := host + " " + pattern
+	now := time.Now()
+	c.mu.Lock()
+	if now.Sub(c.last[key]) < quiet {
+		c.mu.Unlock()
+		return
+	}
+	c.last[key] = now
+	c.mu.Unlock()
+
+	if c.webhook != nil {
+		user, _, _ := r.BasicAuth()
+		c.webhook.Fire(mywebhook.Event{
+			Event:      mywebhook.EventCanary,
+			RemoteAddr: r.RemoteAddr,
+			Method:     r.Method,
+			Path:       r.URL.Path,
+			User:       user,
+			UserAgent:  r.UserAgent(),
+		})
+	}
+	if c.desktop {
+		mynotify.Alert("goshs canary", host+" requested "+r.URL.Path)
+	}
+}
@@ -0,0 +1,118 @@
+package myclipboard
+
+import (
+	"errors"
+	"regexp"
+	"sort"
+	"sync"
+)
+
+const (
+	// DefaultChannel is the clipboard used if no channel is chosen
+	DefaultChannel = "default"
+	// MaxChannels limits the channels as every one is listed to all clients
+	MaxChannels = 100
+)
+
+// ErrTooManyChannels is returned if a new channel would exceed MaxChannels
+var ErrTooManyChannels = errors.New("too many clipboard channels")
+
+var channelName = regexp.MustCompile(`^[A-Za-z0-9_.-]{1,32}$`)
+
+// ValidChannel reports whether name can be used as the name of a channel
+func ValidChannel(name string) bool {
+	return channelName.MatchString(name)
+}
+
+// Channels holds the named clipboards, a channel is created by its first entry
+type Channels struct {
+	mu       sync.Mutex
+	channels map[string]*Clipboard
+	journal  *journal
+}
+
+// NewChannels will return the channels holding only the empty default channel
+func NewChannels() *Channels {
+	cs := &Channels{channels: make(map[string]*Clipboard)}
+	cs.create(DefaultChannel)
+	return cs
+}
+
+// Get returns the clipboard of the channel name to write to, it is created if needed.
+// An invalid name gets the default channel.
+func (cs *Channels) Get(name string) (*Clipboard, error) {
+	if !ValidChannel(name) {
+		name = DefaultChannel
+	}
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	if c, ok := cs.channels[name]; ok {
+		return c, nil
+	}
+	if len(cs.channels) >= MaxChannels {
+		return nil, ErrTooManyChannels
+	}
+	return cs.createLocked(name), nil
+}
+
+// Lookup returns the clipboard of the channel name to read from. A channel which does not exist
+// is not created, an empty clipboard of that name is returned instead. An invalid name gets the default channel.
+func (cs *Channels) Lookup(name string) *Clipboard {
+	if !ValidChannel(name) {
+		name = DefaultChannel
+	}
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	if c, ok := cs.channels[name]; ok {
+		return c
+	}
+	c := New()
+	c.name = name
+	return c
+}
+
+// create returns the channel name, it is created if needed regardless of MaxChannels.
+// An invalid name gets the default channel.
+func (cs *Channels) create(name string) *Clipboard {
+	if !ValidChannel(name) {
+		name = DefaultChannel
+	}
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	if c, ok := cs.channels[name]; ok {
+		return c
+	}
+	return cs.createLocked(name)
+}
+
+// createLocked will add the channel name, cs.mu has to be held by the caller
+func (cs *Channels) createLocked(name string) *Clipboard {
+	c := New()
+	c.name = name
+	c.journal = cs.journal
+	cs.channels[name] = c
+	return c
+}
+
+// Names returns the names of all channels in alphabetical order
+func (cs *Channels) Names() []string {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	names := make([]string, 0, len(cs.channels))
+	for name := range cs.channels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Purge will delete the expired entries of every channel and return the names of the channels which changed
+func (cs *Channels) Purge() []string {
+	purged := []string{}
+	for _, name := range cs.Names() {
+		if cs.Lookup(name).Purge() > 0 {
+			purged = append(purged, name)
+		}
+	}
+	return purged
+}
@@ -1,92 +1,154 @@
 package myclipboard
 
 import (
-	"encoding/json"
+	"errors"
+	"sync"
 	"time"
-
-	"github.com/patrickhener/goshs/internal/mylog"
 )
 
+// ErrNotFound is returned for operations on an id which is not in the clipboard
+var ErrNotFound = errors.New("no clipboard entry with this id")
+
 // Clipboard is the in memory clipboard to hold the copy-pasteable content
 type Clipboard struct {
 	Entries []Entry
+
+	mu      sync.Mutex
+	nextID  int
+	name    string
+	journal *journal
 }
 
-// Entry will represent a single entry in the clipboard
+// Entry will represent a single entry in the clipboard, the id stays the same until it is deleted
 type Entry struct {
 	ID      int
 	Content string
 	Time    string
+	// Language tags the content for syntax highlighting
+	Language string `json:",omitempty"`
+	// Encrypted content was encrypted by the browser with a passphrase the server does not know
+	Encrypted bool `json:",omitempty"`
+	// Expires is when the entry is purged, nil keeps it until deleted
+	Expires *time.Time `json:",omitempty"`
 }
 
 // New will return an instantiated Clipboard
 func New() *Clipboard {
-	cb := &Clipboard{}
+	cb := &Clipboard{name: DefaultChannel}
 	return cb
 }
 
-// AddEntry will give the opportunity to add an entry to the clipboard
-func (c *Clipboard) AddEntry(con string) error {
-	entries := c.Entries
-	if len(entries) > 0 {
-		lastEntry := entries[len(entries)-1]
-		newID := lastEntry.ID + 1
-		entries = append(entries, Entry{
-			ID:      newID,
-			Content: con,
-			Time:    time.Now().Format("Mon Jan _2 15:04:05 2006"),
-		})
-	} else {
-		entries = append(entries, Entry{
-			ID:      0,
-			Content: con,
-			Time:    time.Now().Format("Mon Jan _2 15:04:05 2006"),
-		})
+// Name returns the name of the channel of the clipboard
+func (c *Clipboard) Name() string {
+	return c.name
+}
+
+func now() string {
+	return time.Now().Format("Mon Jan _2 15:04:05 2006")
+}
+
+// AddEntry will give the opportunity to add the content, language and encryption of e to the clipboard,
+// it expires after ttl if not 0
+func (c *Clipboard) AddEntry(e Entry, ttl time.Duration) (Entry, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry := Entry{
+		ID:        c.nextID,
+		Content:   e.Content,
+		Time:      now(),
+		Language:  e.Language,
+		Encrypted: e.Encrypted,
 	}
-	c.Entries = entries
-	return nil
+	if ttl > 0 {
+		expires := time.Now().Add(ttl)
+		entry.Expires = &expires
+	}
+	c.nextID++
+	c.Entries = append(c.Entries, entry)
+	c.record("add", entry)
+	return entry, nil
+}
+
+// UpdateEntry will replace the content, language and encryption of the entry with id by the ones of e
+func (c *Clipboard) UpdateEntry(id int, e Entry) (Entry, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	i := c.index(id)
+	if i < 0 {
+		return Entry{}, ErrNotFound
+	}
+	c.Entries[i].Content = e.Content
+	c.Entries[i].Language = e.Language
+	c.Entries[i].Encrypted = e.Encrypted
+	c.Entries[i].Time = now()
+	c.record("update", c.Entries[i])
+	return c.Entries[i], nil
 }
 
 // DeleteEntry will give the opportunity to delete an entry from the clipboard
 func (c *Clipboard) DeleteEntry(id int) error {
-	entries := c.Entries
-	entries = append(entries[:id], entries[id+1:]...)
-	newEntries := reindex(entries)
-	c.Entries = newEntries
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	i := c.index(id)
+	if i < 0 {
+		return ErrNotFound
+	}
+	c.Entries = append(c.Entries[:i:i], c.Entries[i+1:]...)
+	c.record("delete", Entry{ID: id})
 	return nil
 }
 
 // ClearClipboard will empty the clipboard
 func (c *Clipboard) ClearClipboard() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
 	c.Entries = nil
+	c.record("clear", Entry{})
 	return nil
 }
 
+// Purge will delete the expired entries and return how many there were
+func (c *Clipboard) Purge() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	purged := 0
+	for i := 0; i < len(c.Entries); i++ {
+		e := c.Entries[i]
+		if e.Expires != nil && time.Now().After(*e.Expires) {
+			c.Entries = append(c.Entries[:i:i], c.Entries[i+1:]...)
+			c.record("delete", Entry{ID: e.ID})
+			purged++
+			i--
+		}
+	}
+	return purged
+}
+
 // GetEntries will give the opportunity to receive the entries from the clipboard
 func (c *Clipboard) GetEntries() ([]Entry, error) {
-	entries := c.Entries
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entries := append([]Entry(nil), c.Entries...)
 	return entries, nil
 }
 
-// Download will return a json encoded representation of the clipboards content for download purposes
-func (c *Clipboard) Download() ([]byte, error) {
-	entries := c.Entries
-	e, err := json.MarshalIndent(entries, "", "    ")
-	if err != nil {
-		return nil, err
+// GetEntry will return the entry with id
+func (c *Clipboard) GetEntry(id int) (Entry, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	i := c.index(id)
+	if i < 0 {
+		return Entry{}, ErrNotFound
 	}
-	return e, nil
+	return c.Entries[i], nil
 }
 
-func reindex(entries []Entry) []Entry {
-	var newEntries []Entry
-	for i, e := range entries {
-		mylog.Debugf("Entry #%d: %+v\n", i, e)
-		newEntries = append(newEntries, Entry{
-			ID:      i,
-			Content: e.Content,
-			Time:    e.Time,
-		})
+// index returns the position of the entry with id or -1, the caller holds the lock
+func (c *Clipboard) index(id int) int {
+	for i, e := range c.Entries {
+		if e.ID == id {
+			return i
+		}
 	}
-	return newEntries
+	return -1
 }
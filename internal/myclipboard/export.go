@@ -0,0 +1,69 @@
+package myclipboard
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrFormat is returned for a download format which is not known
+var ErrFormat = errors.New("unknown clipboard format, use json, txt or md")
+
+// Encode will return entries in format: json keeps every field, txt is just the contents
+// one after the other and md has a section with a code block per entry
+func Encode(entries []Entry, format string) ([]byte, error) {
+	switch format {
+	case "json":
+		return json.MarshalIndent(entries, "", "    ")
+	case "txt":
+		var b bytes.Buffer
+		for _, e := range entries {
+			b.WriteString(e.Content)
+			if !strings.HasSuffix(e.Content, "\n") {
+				b.WriteByte('\n')
+			}
+		}
+		return b.Bytes(), nil
+	case "md":
+		var b bytes.Buffer
+		for i, e := range entries {
+			if i > 0 {
+				b.WriteByte('\n')
+			}
+			fmt.Fprintf(&b, "## #%d - %s\n\n", e.ID, e.Time)
+			if e.Encrypted {
+				b.WriteString("*Encrypted in the browser*\n\n")
+			}
+			// The fence has to be longer than any run of backticks in the content
+			fence := "```"
+			if n := longestRun(e.Content, '`'); n >= len(fence) {
+				fence = strings.Repeat("`", n+1)
+			}
+			fmt.Fprintf(&b, "%s%s\n%s", fence, e.Language, e.Content)
+			if !strings.HasSuffix(e.Content, "\n") {
+				b.WriteByte('\n')
+			}
+			b.WriteString(fence + "\n")
+		}
+		return b.Bytes(), nil
+	}
+	return nil, ErrFormat
+}
+
+// longestRun returns the length of the longest run of c in s
+func longestRun(s string, c byte) int {
+	longest, run := 0, 0
+	for i := 0; i < len(s); i++ {
+		if s[i] != c {
+			run = 0
+			continue
+		}
+		run++
+		if run > longest {
+			longest = run
+		}
+	}
+	return longest
+}
@@ -0,0 +1,158 @@
+package myclipboard
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/patrickhener/goshs/internal/mylog"
+)
+
+// record is a single change in the journal file
+type record struct {
+	// Channel is empty in journals written before channels existed
+	Channel string `json:"channel,omitempty"`
+	Op      string `json:"op"`
+	Entry   Entry  `json:"entry"`
+}
+
+// journal is the file shared by the clipboards of all channels
+type journal struct {
+	mu sync.Mutex
+	f  *os.File
+}
+
+// Open will return the channels with the entries from the journal file, changes are appended to it.
+// The journal is compacted to the current entries on every start.
+func Open(file string) (*Channels, error) {
+	cs := NewChannels()
+	if err := cs.replay(file); err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	cs.Purge()
+
+	// Rewrite the journal with the current entries only, expired ones do not stay on disk
+	tmp := file + ".tmp"
+	// disable G304 (CWE-22): Potential file inclusion via variable
+	// as the operator chooses the file
+	// #nosec G304
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, err
+	}
+	w := bufio.NewWriter(f)
+	enc := json.NewEncoder(w)
+	for _, name := range cs.Names() {
+		for _, e := range cs.Lookup(name).Entries {
+			if err := enc.Encode(record{Channel: name, Op: "add", Entry: e}); err != nil {
+				f.Close()
+				return nil, err
+			}
+		}
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return nil, err
+	}
+	if err := f.Close(); err != nil {
+		return nil, err
+	}
+	if err := os.Rename(tmp, file); err != nil {
+		return nil, err
+	}
+
+	// disable G304 (CWE-22): Potential file inclusion via variable
+	// as the operator chooses the file
+	// #nosec G304
+	f, err = os.OpenFile(file, os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, err
+	}
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	cs.journal = &journal{f: f}
+	for _, c := range cs.channels {
+		c.journal = cs.journal
+	}
+	return cs, nil
+}
+
+// replay will apply the records in file, a line cut off by a crash is skipped
+func (cs *Channels) replay(file string) error {
+	// disable G304 (CWE-22): Potential file inclusion via variable
+	// as the operator chooses the file
+	// #nosec G304
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+	for {
+		line, err := r.ReadBytes('\n')
+		if len(line) > 0 {
+			var rec record
+			if jerr := json.Unmarshal(line, &rec); jerr != nil {
+				mylog.Warnf("Skipping unreadable clipboard record in %s: %+v", file, jerr)
+			} else {
+				cs.create(rec.Channel).apply(rec)
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// apply will replay a single record
+func (c *Clipboard) apply(rec record) {
+	switch rec.Op {
+	case "add":
+		c.Entries = append(c.Entries, rec.Entry)
+		if rec.Entry.ID >= c.nextID {
+			c.nextID = rec.Entry.ID + 1
+		}
+	case "update":
+		if i := c.index(rec.Entry.ID); i >= 0 {
+			c.Entries[i] = rec.Entry
+		}
+	case "delete":
+		if i := c.index(rec.Entry.ID); i >= 0 {
+			c.Entries = append(c.Entries[:i:i], c.Entries[i+1:]...)
+		}
+	case "clear":
+		c.Entries = nil
+	}
+}
+
+// record will append a change to the journal if there is one, the caller holds the lock
+func (c *Clipboard) record(op string, e Entry) {
+	if c.journal == nil {
+		return
+	}
+	c.journal.write(record{Channel: c.name, Op: op, Entry: e})
+}
+
+// write will append rec to the journal, the clipboards of several channels write concurrently
+func (j *journal) write(rec record) {
+	line, err := json.Marshal(rec)
+	if err != nil {
+		mylog.Errorf("encoding clipboard record: %+v", err)
+		return
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if _, err := j.f.Write(append(line, '\n')); err != nil {
+		mylog.Errorf("writing clipboard record: %+v", err)
+		return
+	}
+	if err := j.f.Sync(); err != nil {
+		mylog.Errorf("writing clipboard record: %+v", err)
+	}
+}
@@ -0,0 +1,227 @@
+// Package mycompletion generates shell completion scripts from the command line flags
+package mycompletion
+
+import (
+	"flag"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Shells are the shells a completion script can be generated for
+var Shells = []string{"bash", "zsh", "fish", "powershell"}
+
+// Command is a subcommand taking one of Args as argument
+type Command struct {
+	Name        string
+	Description string
+	Args        []string
+}
+
+// option is a flag with all its names, the short ones first
+type option struct {
+	names       []string
+	description string
+	takesValue  bool
+}
+
+// Script returns the completion script of shell for program with flags and commands
+func Script(shell, program string, flags *flag.FlagSet, commands []Command) (string, error) {
+	options := collect(flags)
+	switch shell {
+	case "bash":
+		return bash(program, options, commands), nil
+	case "zsh":
+		return zsh(program, options, commands), nil
+	case "fish":
+		return fish(program, options, commands), nil
+	case "powershell":
+		return powershell(program, options, commands), nil
+	default:
+		return "", fmt.Errorf("unknown shell %q, use one of %s", shell, strings.Join(Shells, ", "))
+	}
+}
+
+// collect groups the short and the long name of a flag, they share the variable they set
+func collect(flags *flag.FlagSet) []*option {
+	var options []*option
+	byTarget := make(map[uintptr]*option)
+	flags.VisitAll(func(f *flag.Flag) {
+		key := reflect.ValueOf(f.Value).Pointer()
+		if o, ok := byTarget[key]; ok {
+			o.names = append(o.names, f.Name)
+			return
+		}
+		o := &option{names: []string{f.Name}, description: f.Usage, takesValue: true}
+		if b, ok := f.Value.(interface{ IsBoolFlag() bool }); ok && b.IsBoolFlag() {
+			o.takesValue = false
+		}
+		byTarget[key] = o
+		options = append(options, o)
+	})
+	for _, o := range options {
+		sort.SliceStable(o.names, func(i, j int) bool { return len(o.names[i]) < len(o.names[j]) })
+	}
+	return options
+}
+
+// spellings returns the names as given on the command line, the long name of a pair with two dashes
+func (o *option) spellings() []string {
+	spelled := make([]string, len(o.names))
+	for i, name := range o.names {
+		spelled[i] = "-" + name
+		if i > 0 {
+			spelled[i] = "--" + name
+		}
+	}
+	return spelled
+}
+
+func bash(program string, options []*option, commands []Command) string {
+	var all, valued []string
+	for _, o := range options {
+		all = append(all, o.spellings()...)
+		if o.takesValue {
+			valued = append(valued, o.spellings()...)
+		}
+	}
+	fn := "_" + strings.ReplaceAll(program, "-", "_")
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "# bash completion for %s, load with: source <(%s completion bash)\n", program, program)
+	fmt.Fprintf(&b, "%s() {\n", fn)
+	b.WriteString("    local cur prev\n")
+	b.WriteString("    cur=\"${COMP_WORDS[COMP_CWORD]}\"\n")
+	b.WriteString("    prev=\"${COMP_WORDS[COMP_CWORD-1]}\"\n")
+	b.WriteString("    case \"$prev\" in\n")
+	for _, c := range commands {
+		fmt.Fprintf(&b, "        %s)\n", c.Name)
+		fmt.Fprintf(&b, "            COMPREPLY=($(compgen -W \"%s\" -- \"$cur\"))\n", strings.Join(c.Args, " "))
+		b.WriteString("            return ;;\n")
+	}
+	if len(valued) > 0 {
+		fmt.Fprintf(&b, "        %s)\n", strings.Join(valued, "|"))
+		b.WriteString("            COMPREPLY=($(compgen -f -- \"$cur\"))\n")
+		b.WriteString("            return ;;\n")
+	}
+	b.WriteString("    esac\n")
+	b.WriteString("    if [[ \"$cur\" == -* ]]; then\n")
+	fmt.Fprintf(&b, "        COMPREPLY=($(compgen -W \"%s\" -- \"$cur\"))\n", strings.Join(all, " "))
+	b.WriteString("    elif [[ $COMP_CWORD -eq 1 ]]; then\n")
+	fmt.Fprintf(&b, "        COMPREPLY=($(compgen -W \"%s\" -- \"$cur\"))\n", strings.Join(commandNames(commands), " "))
+	b.WriteString("    fi\n")
+	b.WriteString("}\n")
+	fmt.Fprintf(&b, "complete -o filenames -F %s %s\n", fn, program)
+	return b.String()
+}
+
+func zsh(program string, options []*option, commands []Command) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "#compdef %s\n", program)
+	fmt.Fprintf(&b, "# zsh completion for %s, load with: source <(%s completion zsh)\n\n", program, program)
+	fmt.Fprintf(&b, "_%s() {\n", program)
+	b.WriteString("    _arguments \\\n")
+	for _, o := range options {
+		spelled := o.spellings()
+		description := strings.NewReplacer("'", "'\\''", "[", "\\[", "]", "\\]", ":", "\\:").Replace(o.description)
+		value := ""
+		if o.takesValue {
+			value = ":value:_files"
+		}
+		if len(spelled) == 1 {
+			fmt.Fprintf(&b, "        '%s[%s]%s' \\\n", spelled[0], description, value)
+			continue
+		}
+		fmt.Fprintf(&b, "        '(%s)'{%s}'[%s]%s' \\\n", strings.Join(spelled, " "), strings.Join(spelled, ","), description, value)
+	}
+	b.WriteString("        '1:command:->command' \\\n")
+	b.WriteString("        '2:argument:->argument'\n")
+	b.WriteString("    case $state in\n")
+	b.WriteString("        command)\n")
+	b.WriteString("            local -a commands\n")
+	b.WriteString("            commands=(")
+	for i, c := range commands {
+		if i > 0 {
+			b.WriteString(" ")
+		}
+		fmt.Fprintf(&b, "'%s:%s'", c.Name, strings.ReplaceAll(c.Description, "'", "'\\''"))
+	}
+	b.WriteString(")\n")
+	b.WriteString("            _describe command commands ;;\n")
+	b.WriteString("        argument)\n")
+	b.WriteString("            case $words[2] in\n")
+	for _, c := range commands {
+		fmt.Fprintf(&b, "                %s) compadd %s ;;\n", c.Name, strings.Join(c.Args, " "))
+	}
+	b.WriteString("            esac ;;\n")
+	b.WriteString("    esac\n")
+	b.WriteString("}\n\n")
+	fmt.Fprintf(&b, "compdef _%s %s\n", program, program)
+	return b.String()
+}
+
+func fish(program string, options []*option, commands []Command) string {
+	quote := strings.NewReplacer("\\", "\\\\", "'", "\\'")
+	var b strings.Builder
+	fmt.Fprintf(&b, "# fish completion for %s, load with: %s completion fish | source\n", program, program)
+	fmt.Fprintf(&b, "complete -c %s -f\n", program)
+	for _, c := range commands {
+		fmt.Fprintf(&b, "complete -c %s -n __fish_use_subcommand -a %s -d '%s'\n", program, c.Name, quote.Replace(c.Description))
+		fmt.Fprintf(&b, "complete -c %s -n '__fish_seen_subcommand_from %s' -a '%s'\n", program, c.Name, strings.Join(c.Args, " "))
+	}
+	for _, o := range options {
+		fmt.Fprintf(&b, "complete -c %s -o %s", program, o.names[0])
+		if len(o.names) > 1 {
+			fmt.Fprintf(&b, " -l %s", o.names[1])
+		}
+		fmt.Fprintf(&b, " -d '%s'", quote.Replace(o.description))
+		if o.takesValue {
+			b.WriteString(" -r -F")
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+func powershell(program string, options []*option, commands []Command) string {
+	quote := strings.NewReplacer("'", "''")
+	var b strings.Builder
+	fmt.Fprintf(&b, "# PowerShell completion for %s, load with: %s completion powershell | Out-String | Invoke-Expression\n", program, program)
+	fmt.Fprintf(&b, "Register-ArgumentCompleter -Native -CommandName '%s', '%s.exe' -ScriptBlock {\n", program, program)
+	b.WriteString("    param($wordToComplete, $commandAst, $cursorPosition)\n")
+	b.WriteString("    $words = @($commandAst.CommandElements | ForEach-Object { $_.ToString() })\n")
+	b.WriteString("    $flags = @(\n")
+	for _, o := range options {
+		for _, s := range o.spellings() {
+			fmt.Fprintf(&b, "        @('%s', '%s')\n", s, quote.Replace(o.description))
+		}
+	}
+	b.WriteString("    )\n")
+	b.WriteString("    $commands = @{\n")
+	for _, c := range commands {
+		fmt.Fprintf(&b, "        '%s' = @('%s')\n", c.Name, strings.Join(c.Args, "', '"))
+	}
+	b.WriteString("    }\n")
+	b.WriteString("    $candidates = @()\n")
+	b.WriteString("    if ($words.Count -ge 2 -and $commands.ContainsKey($words[1]) -and $words[1] -ne $wordToComplete) {\n")
+	b.WriteString("        $candidates = $commands[$words[1]] | ForEach-Object { ,@($_, $_) }\n")
+	b.WriteString("    } elseif ($wordToComplete -like '-*') {\n")
+	b.WriteString("        $candidates = $flags\n")
+	b.WriteString("    } else {\n")
+	b.WriteString("        $candidates = $commands.Keys | ForEach-Object { ,@($_, $_) }\n")
+	b.WriteString("    }\n")
+	b.WriteString("    $candidates | Where-Object { $_[0] -like \"$wordToComplete*\" } | ForEach-Object {\n")
+	b.WriteString("        [System.Management.Automation.CompletionResult]::new($_[0], $_[0], 'ParameterName', $_[1])\n")
+	b.WriteString("    }\n")
+	b.WriteString("}\n")
+	return b.String()
+}
+
+func commandNames(commands []Command) []string {
+	names := make([]string, len(commands))
+	for i, c := range commands {
+		names[i] = c.Name
+	}
+	return names
+}
@@ -0,0 +1,303 @@
+// Package myconfig applies a YAML, TOML or JSON configuration file and environment
+// variables to the command line flags. The keys are the flag names, so every option can be
+// set in a file or the environment. The environment overrides the file and flags given on
+// the command line override both.
+package myconfig
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// Apply will set every flag named in file which was not given on the command line.
+// Lists may be given as arrays, they are joined with commas.
+func Apply(file string, flags *flag.FlagSet) error {
+	options, err := parse(file, flags)
+	if err != nil {
+		return err
+	}
+	onCommandLine := commandLine(flags)
+	for _, o := range options {
+		if onCommandLine[target(o.flag)] {
+			continue
+		}
+		if err := o.flag.Value.Set(o.value); err != nil {
+			return fmt.Errorf("option %q in %s: %+v", o.key, file, err)
+		}
+	}
+	return nil
+}
+
+// Reload will read file again and apply the flags named only, it returns the other
+// options of the file which changed as they need a restart. Named flags which are neither in the
+// file nor on the command line any more are reset to their default.
+func Reload(file string, flags *flag.FlagSet, names ...string) ([]string, error) {
+	options, err := parse(file, flags)
+	if err != nil {
+		return nil, err
+	}
+	onCommandLine := commandLine(flags)
+	reloadable := make(map[uintptr]bool)
+	for _, name := range names {
+		f := flags.Lookup(name)
+		if f == nil || onCommandLine[target(f)] {
+			continue
+		}
+		reloadable[target(f)] = true
+		if err := f.Value.Set(f.DefValue); err != nil {
+			return nil, err
+		}
+	}
+
+	var skipped []string
+	for _, o := range options {
+		if onCommandLine[target(o.flag)] {
+			continue
+		}
+		if !reloadable[target(o.flag)] {
+			if o.value != o.flag.Value.String() {
+				skipped = append(skipped, o.key)
+			}
+			continue
+		}
+		if err := o.flag.Value.Set(o.value); err != nil {
+			return nil, fmt.Errorf("option %q in %s: %+v", o.key, file, err)
+		}
+	}
+	return skipped, nil
+}
+
+// Env will set every flag which was not given on the command line from the environment variable
+// named prefix and its long name in upper case with underscores, e.g. GOSHS_WEBDAV_PORT for -webdav-port.
+// The flags set count as given on the command line afterwards, so a config file applied later does not
+// override them.
+func Env(flags *flag.FlagSet, prefix string) error {
+	onCommandLine := commandLine(flags)
+	names := longNames(flags)
+	sorted := make([]string, 0, len(names))
+	for key, name := range names {
+		if !onCommandLine[key] {
+			sorted = append(sorted, name)
+		}
+	}
+	sort.Strings(sorted)
+
+	for _, name := range sorted {
+		env := prefix + strings.ToUpper(strings.ReplaceAll(name, "-", "_"))
+		value, ok := os.LookupEnv(env)
+		if !ok {
+			continue
+		}
+		if err := flags.Set(name, value); err != nil {
+			return fmt.Errorf("%s: %+v", env, err)
+		}
+	}
+	return nil
+}
+
+// longNames returns the longest name of every flag by its target, the short one is an alias
+func longNames(flags *flag.FlagSet) map[uintptr]string {
+	names := make(map[uintptr]string)
+	flags.VisitAll(func(f *flag.Flag) {
+		if key := target(f); len(f.Name) > len(names[key]) {
+			names[key] = f.Name
+		}
+	})
+	return names
+}
+
+// LongName returns the longest name of the flag called name, the short one is an alias
+func LongName(flags *flag.FlagSet, name string) string {
+	f := flags.Lookup(name)
+	if f == nil {
+		return name
+	}
+	return longNames(flags)[target(f)]
+}
+
+// Effective returns the value of every flag by its long name, as a config file would give
+// it. The flags named in skip are left out.
+func Effective(flags *flag.FlagSet, skip ...string) map[string]interface{} {
+	skipped := make(map[uintptr]bool)
+	for _, name := range skip {
+		if f := flags.Lookup(name); f != nil {
+			skipped[target(f)] = true
+		}
+	}
+
+	names := longNames(flags)
+	values := make(map[uintptr]interface{})
+	flags.VisitAll(func(f *flag.Flag) {
+		key := target(f)
+		if skipped[key] || f.Name != names[key] {
+			return
+		}
+		values[key] = f.Value.String()
+		if getter, ok := f.Value.(flag.Getter); ok {
+			switch v := getter.Get().(type) {
+			case bool, int, int64, uint, uint64, float64, string:
+				values[key] = v
+			}
+		}
+	})
+
+	effective := make(map[string]interface{}, len(names))
+	for key, name := range names {
+		if !skipped[key] {
+			effective[name] = values[key]
+		}
+	}
+	return effective
+}
+
+// Marshal returns values in format, which is yaml, toml or json
+func Marshal(values map[string]interface{}, format string) ([]byte, error) {
+	switch format {
+	case "yaml", "yml":
+		return yaml.Marshal(values)
+	case "toml":
+		var buf bytes.Buffer
+		if err := toml.NewEncoder(&buf).Encode(values); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	case "json":
+		out, err := json.MarshalIndent(values, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		return append(out, '\n'), nil
+	default:
+		return nil, fmt.Errorf("unknown config format %q, use yaml, toml or json", format)
+	}
+}
+
+type option struct {
+	key   string
+	value string
+	flag  *flag.Flag
+}
+
+// parse returns the options of file sorted by key, it fails for unknown options or values
+func parse(file string, flags *flag.FlagSet) ([]option, error) {
+	values, err := read(file)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	// A short and a long flag share the variable they set
+	inFile := make(map[uintptr]string)
+	options := make([]option, 0, len(keys))
+	for _, key := range keys {
+		f := flags.Lookup(key)
+		if f == nil {
+			return nil, fmt.Errorf("unknown option %q in %s", key, file)
+		}
+		if other, ok := inFile[target(f)]; ok {
+			return nil, fmt.Errorf("option %q is given as %q as well in %s", key, other, file)
+		}
+		inFile[target(f)] = key
+
+		value, err := toString(values[key])
+		if err != nil {
+			return nil, fmt.Errorf("option %q in %s: %+v", key, file, err)
+		}
+		options = append(options, option{key: key, value: value, flag: f})
+	}
+	return options, nil
+}
+
+// commandLine returns the targets of the flags given on the command line
+func commandLine(flags *flag.FlagSet) map[uintptr]bool {
+	set := make(map[uintptr]bool)
+	flags.Visit(func(f *flag.Flag) {
+		set[target(f)] = true
+	})
+	return set
+}
+
+// target identifies the variable behind a flag, the flag values of the flag package are pointers to it
+func target(f *flag.Flag) uintptr {
+	v := reflect.ValueOf(f.Value)
+	if v.Kind() == reflect.Ptr {
+		return v.Pointer()
+	}
+	return reflect.ValueOf(f).Pointer()
+}
+
+func read(file string) (map[string]interface{}, error) {
+	var unmarshal func([]byte, interface{}) error
+	switch strings.ToLower(filepath.Ext(file)) {
+	case ".yaml", ".yml":
+		unmarshal = yaml.Unmarshal
+	case ".toml":
+		unmarshal = toml.Unmarshal
+	case ".json":
+		unmarshal = json.Unmarshal
+	default:
+		return nil, fmt.Errorf("unknown config format of %s, use .yaml, .yml, .toml or .json", file)
+	}
+
+	// disable G304 (CWE-22): Potential file inclusion via variable
+	// as the operator chooses the config file
+	// #nosec G304
+	content, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	values := make(map[string]interface{})
+	if err := unmarshal(content, &values); err != nil {
+		return nil, fmt.Errorf("parsing %s: %+v", file, err)
+	}
+	return values, nil
+}
+
+// toString returns a value of the file as it would be given on the command line
+func toString(v interface{}) (string, error) {
+	switch v := v.(type) {
+	case string:
+		return v, nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case int:
+		return strconv.Itoa(v), nil
+	case int64:
+		return strconv.FormatInt(v, 10), nil
+	case uint64:
+		return strconv.FormatUint(v, 10), nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	case []interface{}:
+		items := make([]string, 0, len(v))
+		for _, item := range v {
+			s, err := toString(item)
+			if err != nil {
+				return "", err
+			}
+			items = append(items, s)
+		}
+		return strings.Join(items, ","), nil
+	case nil:
+		return "", nil
+	default:
+		return "", fmt.Errorf("unsupported value of type %T", v)
+	}
+}
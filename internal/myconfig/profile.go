@@ -0,0 +1,66 @@
+package myconfig
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// extensions are the config formats in the order a profile is looked up
+var extensions = []string{".yaml", ".yml", ".toml", ".json"}
+
+// ProfileDir returns the directory of the named profiles, ~/.config/goshs/profiles on Linux
+func ProfileDir() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "goshs", "profiles"), nil
+}
+
+// Profile returns the config file of the profile name in ProfileDir
+func Profile(name string) (string, error) {
+	if name == "" || name == "." || name == ".." || strings.ContainsAny(name, `/\`) {
+		return "", fmt.Errorf("%q is not a profile name", name)
+	}
+	dir, err := ProfileDir()
+	if err != nil {
+		return "", err
+	}
+	for _, ext := range extensions {
+		file := filepath.Join(dir, name+ext)
+		if _, err := os.Stat(file); err == nil {
+			return file, nil
+		}
+	}
+	profiles := Profiles(dir)
+	if len(profiles) == 0 {
+		return "", fmt.Errorf("there is no profile %q in %s", name, dir)
+	}
+	return "", fmt.Errorf("there is no profile %q in %s, the profiles are %s", name, dir, strings.Join(profiles, ", "))
+}
+
+// Profiles returns the names of the profiles in dir
+func Profiles(dir string) []string {
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+	var names []string
+	seen := make(map[string]bool)
+	for _, e := range entries {
+		ext := strings.ToLower(filepath.Ext(e.Name()))
+		name := strings.TrimSuffix(e.Name(), filepath.Ext(e.Name()))
+		for _, known := range extensions {
+			if ext == known && !e.IsDir() && !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,122 @@
+// Package mydump writes complete incoming requests to disk, e.g. to catch
+// callbacks while testing for SSRF, XXE or other out of band issues.
+package mydump
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httputil"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/patrickhener/goshs/internal/mylog"
+	"github.com/patrickhener/goshs/internal/myrotate"
+)
+
+// Dumper writes every request to a file of its own in a directory or appends it to a single file
+type Dumper struct {
+	mu      sync.Mutex
+	dir     string
+	file    *myrotate.File
+	maxBody int64
+	seq     uint64
+}
+
+// New will dump into the directory target if it is one, otherwise append to the file target.
+// Bodies are cut off after maxBody bytes in the dump, the handlers still get them in full.
+// The single file is rotated according to opts.
+func New(target string, maxBody int64, opts myrotate.Options) (*Dumper, error) {
+	d := &Dumper{maxBody: maxBody}
+	if fi, err := os.Stat(target); err == nil && fi.IsDir() {
+		d.dir = target
+		return d, nil
+	}
+
+	file, err := myrotate.Open(target, opts)
+	if err != nil {
+		return nil, err
+	}
+	d.file = file
+	return d, nil
+}
+
+// Close will close the file if dumping to a single one
+func (d *Dumper) Close() error {
+	if d.file == nil {
+		return nil
+	}
+	return d.file.Close()
+}
+
+// Handler will dump every request before passing it to next
+func (d *Dumper) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		d.dump(r)
+		next.ServeHTTP(w, r)
+	})
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+func (d *Dumper) dump(r *http.Request) {
+	now := time.Now()
+	head, err := httputil.DumpRequest(r, false)
+	if err != nil {
+		mylog.Errorf("dumping request: %+v", err)
+		return
+	}
+
+	// Read one byte more than dumped to know whether the body was cut off
+	var body []byte
+	truncated := false
+	if r.Body != nil && r.Body != http.NoBody {
+		body, err = ioutil.ReadAll(io.LimitReader(r.Body, d.maxBody+1))
+		if err != nil {
+			mylog.Errorf("dumping request body: %+v", err)
+		}
+		r.Body = readCloser{io.MultiReader(bytes.NewReader(body), r.Body), r.Body}
+		if int64(len(body)) > d.maxBody {
+			body, truncated = body[:d.maxBody], true
+		}
+	}
+
+	seq := atomic.AddUint64(&d.seq, 1)
+	if d.dir != "" {
+		// A file holds the raw request only, so it can be replayed as is
+		name := filepath.Join(d.dir, fmt.Sprintf("%s-%06d.http", now.Format("20060102-150405"), seq))
+		if err := ioutil.WriteFile(name, append(head, body...), 0600); err != nil {
+			mylog.Errorf("dumping request: %+v", err)
+			return
+		}
+		if truncated {
+			mylog.Warnf("Request %d from %s dumped to %s, body cut off after %d bytes", seq, r.RemoteAddr, name, d.maxBody)
+		} else {
+			mylog.Debugf("Request %d from %s dumped to %s", seq, r.RemoteAddr, name)
+		}
+		return
+	}
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "===== %d %s from %s =====\n", seq, now.Format(time.RFC3339Nano), r.RemoteAddr)
+	b.Write(head)
+	b.Write(body)
+	if truncated {
+		fmt.Fprintf(&b, "\n===== body cut off after %d bytes", d.maxBody)
+	}
+	b.WriteString("\n\n")
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if _, err := d.file.Write(b.Bytes()); err != nil {
+		mylog.Errorf("dumping request: %+v", err)
+	}
+}
@@ -0,0 +1,144 @@
+// Package mygeo looks up where a client comes from, using local MaxMind
+// databases for country and ASN and reverse DNS for the hostname.
+package mygeo
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/oschwald/maxminddb-golang"
+)
+
+const (
+	// maxCached is the number of addresses kept, the cache starts over when it is full
+	maxCached = 10000
+	// dnsTimeout keeps a slow resolver from holding up the log
+	dnsTimeout = 500 * time.Millisecond
+)
+
+// Info is what is known about an address, fields not found are empty
+type Info struct {
+	Country  string
+	ASN      uint
+	Org      string
+	Hostname string
+}
+
+// String returns the info as a short text for log lines, e.g. "DE, AS3320 Deutsche Telekom AG, host.example.com"
+func (i Info) String() string {
+	var parts []string
+	if i.Country != "" {
+		parts = append(parts, i.Country)
+	}
+	if i.ASN != 0 {
+		as := "AS" + strconv.FormatUint(uint64(i.ASN), 10)
+		if i.Org != "" {
+			as += " " + i.Org
+		}
+		parts = append(parts, as)
+	}
+	if i.Hostname != "" {
+		parts = append(parts, i.Hostname)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// record holds the fields of the GeoLite2/GeoIP2 country, city and ASN databases
+type record struct {
+	Country struct {
+		ISOCode string `maxminddb:"iso_code"`
+	} `maxminddb:"country"`
+	ASN uint   `maxminddb:"autonomous_system_number"`
+	Org string `maxminddb:"autonomous_system_organization"`
+}
+
+// Resolver enriches addresses and caches the results
+type Resolver struct {
+	dbs  []*maxminddb.Reader
+	rdns bool
+
+	mu    sync.Mutex
+	cache map[string]Info
+}
+
+// New will open the MaxMind databases in files, e.g. a country and an ASN one,
+// and resolve hostnames via reverse DNS if rdns is set
+func New(files []string, rdns bool) (*Resolver, error) {
+	r := &Resolver{rdns: rdns, cache: make(map[string]Info)}
+	for _, file := range files {
+		db, err := maxminddb.Open(file)
+		if err != nil {
+			r.Close()
+			return nil, err
+		}
+		r.dbs = append(r.dbs, db)
+	}
+	return r, nil
+}
+
+// Close will close the databases
+func (r *Resolver) Close() error {
+	var first error
+	for _, db := range r.dbs {
+		if err := db.Close(); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+// Lookup returns the info for addr, which may carry a port
+func (r *Resolver) Lookup(addr string) Info {
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		addr = host
+	}
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return Info{}
+	}
+
+	r.mu.Lock()
+	info, ok := r.cache[addr]
+	r.mu.Unlock()
+	if ok {
+		return info
+	}
+
+	for _, db := range r.dbs {
+		var rec record
+		if err := db.Lookup(ip, &rec); err != nil {
+			continue
+		}
+		if info.Country == "" {
+			info.Country = rec.Country.ISOCode
+		}
+		if info.ASN == 0 {
+			info.ASN, info.Org = rec.ASN, rec.Org
+		}
+	}
+	if r.rdns {
+		ctx, cancel := context.WithTimeout(context.Background(), dnsTimeout)
+		names, err := net.DefaultResolver.LookupAddr(ctx, addr)
+		cancel()
+		if err == nil && len(names) > 0 {
+			info.Hostname = strings.TrimSuffix(names[0], ".")
+		}
+	}
+
+	r.mu.Lock()
+	if len(r.cache) >= maxCached {
+		r.cache = make(map[string]Info)
+	}
+	r.cache[addr] = info
+	r.mu.Unlock()
+	return info
+}
+
+// Describe returns Lookup(addr) as text, it fits mylog.SetRemoteInfo
+func (r *Resolver) Describe(addr string) string {
+	return r.Lookup(addr).String()
+}
@@ -0,0 +1,70 @@
+package myhttp
+
+import (
+	"encoding/json"
+	"errors"
+	"html/template"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const activityPath = "/073c1634c496cdb649d1afe0a312bbb4b7e1741b271542e4a436c3b8824b1761/activity"
+
+type activityTemplate struct {
+	Prefix       string
+	GoshsVersion string
+}
+
+// Untracked are the requests of the pages themselves, they would drown the activity
+func Untracked(req *http.Request) bool {
+	return strings.HasPrefix(req.URL.Path, "/425bda8487e36deccb30dd24be590b8744e3a28a8bb5a57d9b3fcd24ae09ad3c/") ||
+		strings.HasPrefix(req.URL.Path, "/14644be038ea0118a1aadfacca2a7d1517d7b209c4b9674ee893b1944d1c2d54/ws") ||
+		req.URL.Path == activityPath ||
+		req.URL.Path == "/favicon.ico"
+}
+
+// broadcastActivity will send the current activity to the activity pages every second until done is closed
+func (fs *FileServer) broadcastActivity(done <-chan struct{}) {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			fs.Hub.Activity(fs.activity.Snapshot())
+		}
+	}
+}
+
+// activityPage will show the requests in progress and the recent ones, live via websocket
+func (fs *FileServer) activityPage(w http.ResponseWriter, req *http.Request) {
+	if fs.readOnly(req) {
+		fs.handleError(w, req, errors.New("the activity is only shown to users who may write"), http.StatusForbidden)
+		return
+	}
+
+	if _, ok := req.URL.Query()["json"]; ok {
+		fs.Logger.LogRequest(req, http.StatusOK)
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(fs.activity.Snapshot()); err != nil {
+			fs.Logger.Errorf("Error writing response to browser: %+v", err)
+		}
+		return
+	}
+
+	file, err := fs.readStatic("templates/activity.html")
+	if err != nil {
+		fs.Logger.Errorf("opening embedded file: %+v", err)
+	}
+	t, err := template.New("activity").Parse(string(file))
+	if err != nil {
+		fs.Logger.Errorf("parsing the template: %+v", err)
+		return
+	}
+	fs.Logger.LogRequest(req, http.StatusOK)
+	if err := t.Execute(w, activityTemplate{Prefix: fs.Prefix, GoshsVersion: fs.Version}); err != nil {
+		fs.Logger.Errorf("executing the template: %+v", err)
+	}
+}
@@ -0,0 +1,657 @@
+package myhttp
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/patrickhener/goshs/internal/myclipboard"
+	"github.com/patrickhener/goshs/internal/myutils"
+	"github.com/patrickhener/goshs/internal/mywebhook"
+)
+
+const apiPath = "/api/v1"
+
+type apiEntry struct {
+	Name          string    `json:"name"`
+	Path          string    `json:"path"`
+	IsDir         bool      `json:"is_dir"`
+	Size          int64     `json:"size"`
+	Mode          string    `json:"mode"`
+	ModTime       time.Time `json:"mod_time"`
+	IsSymlink     bool      `json:"is_symlink,omitempty"`
+	SymlinkTarget string    `json:"symlink_target,omitempty"`
+}
+
+type apiMove struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+type apiClipboardEntry struct {
+	Content string `json:"content"`
+	// TTL is the lifetime of a new entry in seconds, 0 keeps it
+	TTL       int    `json:"ttl"`
+	Language  string `json:"language"`
+	Encrypted bool   `json:"encrypted"`
+}
+
+type apiClipboardSave struct {
+	Path string `json:"path"`
+}
+
+type apiErrorResponse struct {
+	Error string `json:"error"`
+}
+
+var (
+	errAPIReadOnly   = errors.New("not allowed due to 'read only' option")
+	errAPIUploadOnly = errors.New("not allowed due to 'upload only' option")
+	errClipboardSize = errors.New("clipboard entry too large")
+	errSaveEncrypted = errors.New("encrypted entries can only be read in the browser")
+	errSaveWebroot   = errors.New("a file name below the webroot is required")
+	errAPIServed     = errors.New("not allowed as the file was served the maximum number of times")
+)
+
+// maxClipboardEntry is the largest clipboard entry accepted via the api, the same as via websocket
+const maxClipboardEntry = 8000000
+
+// registerAPI will add the json api routes below apiPath
+func (fs *FileServer) registerAPI(r *mux.Router) {
+	api := r.PathPrefix(apiPath).Subrouter()
+	api.Path("/list/{path:.*}").Methods(http.MethodGet).HandlerFunc(fs.apiList)
+	api.Path("/stat/{path:.*}").Methods(http.MethodGet).HandlerFunc(fs.apiStat)
+	api.Path("/download/{path:.*}").Methods(http.MethodGet, http.MethodHead).HandlerFunc(fs.apiDownload)
+	api.Path("/upload/{path:.*}").Methods(http.MethodPut, http.MethodPost).HandlerFunc(fs.apiUpload)
+	api.Path("/delete/{path:.*}").Methods(http.MethodDelete).HandlerFunc(fs.apiDelete)
+	api.Path("/mkdir/{path:.*}").Methods(http.MethodPost).HandlerFunc(fs.apiMkdir)
+	api.Path("/move").Methods(http.MethodPost).HandlerFunc(fs.apiMove)
+	fs.registerClipboardAPI(api)
+	api.PathPrefix("/").HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		fs.apiError(w, req, errors.New("unknown api endpoint"), http.StatusNotFound)
+	})
+}
+
+// registerClipboardAPI will add the clipboard routes to api, they are served without -api as well
+func (fs *FileServer) registerClipboardAPI(api *mux.Router) {
+	api.Path("/clipboard").Methods(http.MethodGet).HandlerFunc(fs.apiClipboard)
+	api.Path("/clipboard").Methods(http.MethodPost).HandlerFunc(fs.apiClipboardAdd)
+	api.Path("/clipboard").Methods(http.MethodDelete).HandlerFunc(fs.apiClipboardClear)
+	api.Path("/clipboard/channels").Methods(http.MethodGet).HandlerFunc(fs.apiClipboardChannels)
+	api.Path("/clipboard/last").Methods(http.MethodGet).HandlerFunc(fs.apiClipboardGet)
+	api.Path("/clipboard/{id:[0-9]+}").Methods(http.MethodGet).HandlerFunc(fs.apiClipboardGet)
+	api.Path("/clipboard/{id:[0-9]+}").Methods(http.MethodPut).HandlerFunc(fs.apiClipboardUpdate)
+	api.Path("/clipboard/{id:[0-9]+}").Methods(http.MethodDelete).HandlerFunc(fs.apiClipboardDelete)
+	api.Path("/clipboard/{id:[0-9]+}/save").Methods(http.MethodPost).HandlerFunc(fs.apiClipboardSave)
+}
+
+// apiTarget returns the cleaned relative path of the request and its location on disk
+func (fs *FileServer) apiTarget(req *http.Request) (string, string) {
+	rel := path.Clean("/" + mux.Vars(req)["path"])
+	return rel, fs.abs(rel)
+}
+
+func (fs *FileServer) apiJSON(w http.ResponseWriter, req *http.Request, v interface{}, status int) {
+	fs.Logger.LogRequest(req, status)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		fs.Logger.Errorf("Error writing response to browser: %+v", err)
+	}
+}
+
+// apiError will send err as json, status 0 derives the status from err
+func (fs *FileServer) apiError(w http.ResponseWriter, req *http.Request, err error, status int) {
+	if status == 0 {
+		switch {
+		case os.IsNotExist(err):
+			status = http.StatusNotFound
+		case os.IsExist(err):
+			status = http.StatusConflict
+		case os.IsPermission(err):
+			status = http.StatusForbidden
+		default:
+			status = http.StatusInternalServerError
+		}
+	}
+	// Do not leak the webroot
+	msg := fs.hidePaths(err).Error()
+	fs.apiJSON(w, req, apiErrorResponse{Error: msg}, status)
+}
+
+func (fs *FileServer) newAPIEntry(rel string, fi os.FileInfo, target string) apiEntry {
+	e := apiEntry{
+		Name:    fi.Name(),
+		Path:    rel,
+		IsDir:   fi.IsDir(),
+		Size:    fi.Size(),
+		Mode:    fi.Mode().String(),
+		ModTime: fi.ModTime(),
+	}
+	if fi.Mode()&os.ModeSymlink != 0 {
+		e.IsSymlink = true
+		link, err := os.Readlink(target)
+		if err != nil {
+			fs.Logger.Errorf("resolving symlink: %+v", err)
+		}
+		e.SymlinkTarget = link
+	}
+	return e
+}
+
+// apiList will list a directory
+func (fs *FileServer) apiList(w http.ResponseWriter, req *http.Request) {
+	if fs.uploadOnly(req) {
+		fs.apiError(w, req, errAPIUploadOnly, http.StatusForbidden)
+		return
+	}
+	rel, target := fs.apiTarget(req)
+	fis, err := ioutil.ReadDir(target)
+	if err != nil {
+		fs.apiError(w, req, err, 0)
+		return
+	}
+	fis = fs.Mounts.List(rel, fis)
+
+	entries := make([]apiEntry, 0, len(fis))
+	for _, fi := range fis {
+		if fi.IsDir() && myutils.CheckSpecialPath(fi.Name()) {
+			continue
+		}
+		entries = append(entries, fs.newAPIEntry(path.Join(rel, fi.Name()), fi, fs.abs(path.Join(rel, fi.Name()))))
+	}
+	fs.apiJSON(w, req, entries, http.StatusOK)
+}
+
+// apiStat will describe a single file or directory
+func (fs *FileServer) apiStat(w http.ResponseWriter, req *http.Request) {
+	if fs.uploadOnly(req) {
+		fs.apiError(w, req, errAPIUploadOnly, http.StatusForbidden)
+		return
+	}
+	rel, target := fs.apiTarget(req)
+	fi, err := os.Lstat(target)
+	if err != nil {
+		fs.apiError(w, req, err, 0)
+		return
+	}
+	fs.apiJSON(w, req, fs.newAPIEntry(rel, fi, target), http.StatusOK)
+}
+
+// apiDownload will send a file with support for range requests
+func (fs *FileServer) apiDownload(w http.ResponseWriter, req *http.Request) {
+	if fs.uploadOnly(req) {
+		fs.apiError(w, req, errAPIUploadOnly, http.StatusForbidden)
+		return
+	}
+	rel, target := fs.apiTarget(req)
+	// disable G304 (CWE-22): Potential file inclusion via variable
+	// as the path is cleaned and joined below the webroot
+	// #nosec G304
+	file, err := os.Open(target)
+	if err != nil {
+		fs.apiError(w, req, err, 0)
+		return
+	}
+	// disable G307 (CWE-703): Deferring unsafe method "Close" on type "*os.File"
+	// #nosec G307
+	defer file.Close()
+
+	fi, err := file.Stat()
+	if err != nil {
+		fs.apiError(w, req, err, 0)
+		return
+	}
+	if fi.IsDir() {
+		fs.apiError(w, req, fmt.Errorf("%s is a directory", path.Base(target)), http.StatusBadRequest)
+		return
+	}
+
+	// HEAD requests do not transfer the file
+	counted := req.Method == http.MethodGet
+	if counted && !fs.reserveServe(rel) {
+		fs.apiError(w, req, errAPIServed, http.StatusGone)
+		return
+	}
+	fs.Logger.LogRequest(req, http.StatusOK)
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", fi.Name()))
+	cw := &countingWriter{ResponseWriter: w}
+	http.ServeContent(cw, req, fi.Name(), fi.ModTime(), file)
+	if counted {
+		fs.served(rel, cw.written == fi.Size())
+	}
+}
+
+// apiUpload will store the request body at the path (PUT) or the files of a multipart form in the directory (POST)
+func (fs *FileServer) apiUpload(w http.ResponseWriter, req *http.Request) {
+	if fs.readOnly(req) {
+		fs.apiError(w, req, errAPIReadOnly, http.StatusForbidden)
+		return
+	}
+	rel, target := fs.apiTarget(req)
+
+	if req.Method == http.MethodPut {
+		if rel == "/" {
+			fs.apiError(w, req, errors.New("missing file name"), http.StatusBadRequest)
+			return
+		}
+		if err := writeFile(target, req.Body); err != nil {
+			fs.apiError(w, req, err, 0)
+			return
+		}
+		fi, err := os.Stat(target)
+		if err != nil {
+			fs.apiError(w, req, err, 0)
+			return
+		}
+		fs.notify(mywebhook.EventUpload, req, http.StatusCreated, rel, target)
+		fs.apiJSON(w, req, fs.newAPIEntry(rel, fi, target), http.StatusCreated)
+		return
+	}
+
+	reader, err := req.MultipartReader()
+	if err != nil {
+		fs.apiError(w, req, err, http.StatusBadRequest)
+		return
+	}
+	entries := []apiEntry{}
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			fs.apiError(w, req, err, http.StatusBadRequest)
+			return
+		}
+		if part.FileName() == "" {
+			continue
+		}
+		// Sanitize filename (No path traversal)
+		name := path.Base("/" + strings.ReplaceAll(part.FileName(), "\\", "/"))
+		if name == "/" {
+			continue
+		}
+		savepath := filepath.Join(target, name)
+		if err := writeFile(savepath, part); err != nil {
+			fs.apiError(w, req, err, 0)
+			return
+		}
+		fi, err := os.Stat(savepath)
+		if err != nil {
+			fs.apiError(w, req, err, 0)
+			return
+		}
+		fs.notify(mywebhook.EventUpload, req, http.StatusCreated, path.Join(rel, name), savepath)
+		entries = append(entries, fs.newAPIEntry(path.Join(rel, name), fi, savepath))
+	}
+	fs.apiJSON(w, req, entries, http.StatusCreated)
+}
+
+func writeFile(target string, r io.Reader) error {
+	// disable G304 (CWE-22): Potential file inclusion via variable
+	// as the path is cleaned and joined below the webroot
+	// #nosec G304
+	f, err := os.Create(target)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// apiDelete will remove a file or an empty directory, ?recursive removes directories with content
+func (fs *FileServer) apiDelete(w http.ResponseWriter, req *http.Request) {
+	if fs.readOnly(req) {
+		fs.apiError(w, req, errAPIReadOnly, http.StatusForbidden)
+		return
+	}
+	if fs.uploadOnly(req) {
+		fs.apiError(w, req, errAPIUploadOnly, http.StatusForbidden)
+		return
+	}
+	rel, target := fs.apiTarget(req)
+	if rel == "/" {
+		fs.apiError(w, req, errors.New("the webroot cannot be deleted"), http.StatusBadRequest)
+		return
+	}
+	if fs.Mounts.IsMountPoint(rel) {
+		fs.apiError(w, req, errors.New("a mounted directory cannot be deleted"), http.StatusBadRequest)
+		return
+	}
+	if _, err := os.Lstat(target); err != nil {
+		fs.apiError(w, req, err, 0)
+		return
+	}
+
+	remove := os.Remove
+	if _, ok := req.URL.Query()["recursive"]; ok {
+		remove = os.RemoveAll
+	}
+	if err := remove(target); err != nil {
+		fs.apiError(w, req, err, http.StatusConflict)
+		return
+	}
+	fs.Logger.LogRequest(req, http.StatusNoContent)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// apiMkdir will create a directory including its parents
+func (fs *FileServer) apiMkdir(w http.ResponseWriter, req *http.Request) {
+	if fs.readOnly(req) {
+		fs.apiError(w, req, errAPIReadOnly, http.StatusForbidden)
+		return
+	}
+	rel, target := fs.apiTarget(req)
+	if err := os.MkdirAll(target, 0750); err != nil {
+		fs.apiError(w, req, err, 0)
+		return
+	}
+	fi, err := os.Stat(target)
+	if err != nil {
+		fs.apiError(w, req, err, 0)
+		return
+	}
+	fs.apiJSON(w, req, fs.newAPIEntry(rel, fi, target), http.StatusCreated)
+}
+
+// apiMove will rename {"from": "a", "to": "b"}
+func (fs *FileServer) apiMove(w http.ResponseWriter, req *http.Request) {
+	if fs.readOnly(req) {
+		fs.apiError(w, req, errAPIReadOnly, http.StatusForbidden)
+		return
+	}
+	if fs.uploadOnly(req) {
+		fs.apiError(w, req, errAPIUploadOnly, http.StatusForbidden)
+		return
+	}
+	var m apiMove
+	if err := json.NewDecoder(req.Body).Decode(&m); err != nil {
+		fs.apiError(w, req, err, http.StatusBadRequest)
+		return
+	}
+	target, err := fs.move(m.From, m.To)
+	if err != nil {
+		fs.apiError(w, req, err, moveStatus(err))
+		return
+	}
+	fi, err := os.Lstat(target)
+	if err != nil {
+		fs.apiError(w, req, err, 0)
+		return
+	}
+	fs.apiJSON(w, req, fs.newAPIEntry(path.Clean("/"+m.To), fi, target), http.StatusOK)
+}
+
+// clipboard returns the clipboard of the channel in the query, the default channel without one.
+// A channel which does not exist yet is not created, see Channels.Get.
+func (fs *FileServer) clipboard(req *http.Request) *myclipboard.Clipboard {
+	return fs.Clipboards.Lookup(req.URL.Query().Get("channel"))
+}
+
+// apiClipboardChannels will list the names of the clipboard channels
+func (fs *FileServer) apiClipboardChannels(w http.ResponseWriter, req *http.Request) {
+	fs.apiJSON(w, req, fs.Clipboards.Names(), http.StatusOK)
+}
+
+// apiClipboard will list the clipboard entries
+func (fs *FileServer) apiClipboard(w http.ResponseWriter, req *http.Request) {
+	entries, err := fs.clipboard(req).GetEntries()
+	if err != nil {
+		fs.apiError(w, req, err, 0)
+		return
+	}
+	if entries == nil {
+		entries = []myclipboard.Entry{}
+	}
+	fs.apiJSON(w, req, entries, http.StatusOK)
+}
+
+// apiClipboardGet will send a single entry or the last one, ?raw sends the content as plain text
+func (fs *FileServer) apiClipboardGet(w http.ResponseWriter, req *http.Request) {
+	entries, err := fs.clipboard(req).GetEntries()
+	if err != nil {
+		fs.apiError(w, req, err, 0)
+		return
+	}
+	var entry *myclipboard.Entry
+	if _, ok := mux.Vars(req)["id"]; ok {
+		id := clipboardID(req)
+		for i := range entries {
+			if entries[i].ID == id {
+				entry = &entries[i]
+			}
+		}
+	} else if len(entries) > 0 {
+		entry = &entries[len(entries)-1]
+	}
+	if entry == nil {
+		fs.apiError(w, req, myclipboard.ErrNotFound, http.StatusNotFound)
+		return
+	}
+
+	if _, ok := req.URL.Query()["raw"]; ok {
+		fs.Logger.LogRequest(req, http.StatusOK)
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		if _, err := io.WriteString(w, entry.Content); err != nil {
+			fs.Logger.Errorf("Error writing response to browser: %+v", err)
+		}
+		return
+	}
+	fs.apiJSON(w, req, entry, http.StatusOK)
+}
+
+func (e apiClipboardEntry) entry() myclipboard.Entry {
+	return myclipboard.Entry{Content: e.Content, Language: e.Language, Encrypted: e.Encrypted}
+}
+
+// clipboardBody returns the entry posted to the clipboard, either as {"content": "...", "ttl": 60, "language": "bash"}
+// or as any other body taken as is with ttl and language from the query, e.g. cmd | curl --data-binary @- ...
+func clipboardBody(req *http.Request) (apiClipboardEntry, error) {
+	var e apiClipboardEntry
+	body, err := ioutil.ReadAll(io.LimitReader(req.Body, maxClipboardEntry+1))
+	if err != nil {
+		return e, err
+	}
+	if len(body) > maxClipboardEntry {
+		return e, errClipboardSize
+	}
+
+	var fields map[string]json.RawMessage
+	if json.Unmarshal(body, &fields) == nil {
+		if _, ok := fields["content"]; ok {
+			return e, json.Unmarshal(body, &e)
+		}
+	}
+
+	e.Content = string(body)
+	e.Language = req.URL.Query().Get("language")
+	if ttl := req.URL.Query().Get("ttl"); ttl != "" {
+		if e.TTL, err = strconv.Atoi(ttl); err != nil {
+			return e, err
+		}
+	}
+	return e, nil
+}
+
+// apiClipboardAdd will add the posted entry to the clipboard
+func (fs *FileServer) apiClipboardAdd(w http.ResponseWriter, req *http.Request) {
+	if fs.readOnly(req) {
+		fs.apiError(w, req, errAPIReadOnly, http.StatusForbidden)
+		return
+	}
+	e, err := clipboardBody(req)
+	if err == errClipboardSize {
+		fs.apiError(w, req, err, http.StatusRequestEntityTooLarge)
+		return
+	}
+	if err != nil {
+		fs.apiError(w, req, err, http.StatusBadRequest)
+		return
+	}
+	cb, err := fs.Clipboards.Get(req.URL.Query().Get("channel"))
+	if err == myclipboard.ErrTooManyChannels {
+		fs.apiError(w, req, err, http.StatusInsufficientStorage)
+		return
+	}
+	entry, err := cb.AddEntry(e.entry(), time.Duration(e.TTL)*time.Second)
+	if err != nil {
+		fs.apiError(w, req, err, 0)
+		return
+	}
+	fs.Hub.RefreshClipboard(cb.Name())
+	fs.apiJSON(w, req, entry, http.StatusCreated)
+}
+
+// clipboardID returns the id of the clipboard entry in the path, -1 matches none
+func clipboardID(req *http.Request) int {
+	id, err := strconv.Atoi(mux.Vars(req)["id"])
+	if err != nil {
+		return -1
+	}
+	return id
+}
+
+// apiClipboardUpdate will replace the content of a single clipboard entry with {"content": "..."}
+func (fs *FileServer) apiClipboardUpdate(w http.ResponseWriter, req *http.Request) {
+	if fs.readOnly(req) {
+		fs.apiError(w, req, errAPIReadOnly, http.StatusForbidden)
+		return
+	}
+	var e apiClipboardEntry
+	if err := json.NewDecoder(req.Body).Decode(&e); err != nil {
+		fs.apiError(w, req, err, http.StatusBadRequest)
+		return
+	}
+	cb := fs.clipboard(req)
+	entry, err := cb.UpdateEntry(clipboardID(req), e.entry())
+	if err == myclipboard.ErrNotFound {
+		fs.apiError(w, req, fmt.Errorf("no clipboard entry with id %s", mux.Vars(req)["id"]), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		fs.apiError(w, req, err, 0)
+		return
+	}
+	fs.Hub.RefreshClipboard(cb.Name())
+	fs.apiJSON(w, req, entry, http.StatusOK)
+}
+
+// apiClipboardDelete will remove a single clipboard entry
+func (fs *FileServer) apiClipboardDelete(w http.ResponseWriter, req *http.Request) {
+	if fs.readOnly(req) {
+		fs.apiError(w, req, errAPIReadOnly, http.StatusForbidden)
+		return
+	}
+	cb := fs.clipboard(req)
+	err := cb.DeleteEntry(clipboardID(req))
+	if err == myclipboard.ErrNotFound {
+		fs.apiError(w, req, fmt.Errorf("no clipboard entry with id %s", mux.Vars(req)["id"]), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		fs.apiError(w, req, err, 0)
+		return
+	}
+	fs.Hub.RefreshClipboard(cb.Name())
+	fs.Logger.LogRequest(req, http.StatusNoContent)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// apiClipboardSave will write a clipboard entry to the new file {"path": "..."} below the webroot
+func (fs *FileServer) apiClipboardSave(w http.ResponseWriter, req *http.Request) {
+	if fs.readOnly(req) {
+		fs.apiError(w, req, errAPIReadOnly, http.StatusForbidden)
+		return
+	}
+	var s apiClipboardSave
+	if err := json.NewDecoder(req.Body).Decode(&s); err != nil {
+		fs.apiError(w, req, err, http.StatusBadRequest)
+		return
+	}
+	rel := path.Clean("/" + s.Path)
+	if rel == "/" {
+		fs.apiError(w, req, errSaveWebroot, http.StatusBadRequest)
+		return
+	}
+	if fs.Mounts.ReadOnly(rel) {
+		fs.apiError(w, req, errAPIReadOnly, http.StatusForbidden)
+		return
+	}
+
+	entries, err := fs.clipboard(req).GetEntries()
+	if err != nil {
+		fs.apiError(w, req, err, 0)
+		return
+	}
+	var entry *myclipboard.Entry
+	for i := range entries {
+		if entries[i].ID == clipboardID(req) {
+			entry = &entries[i]
+		}
+	}
+	if entry == nil {
+		fs.apiError(w, req, myclipboard.ErrNotFound, http.StatusNotFound)
+		return
+	}
+	if entry.Encrypted {
+		fs.apiError(w, req, errSaveEncrypted, http.StatusBadRequest)
+		return
+	}
+
+	// Existing files are never overwritten
+	target := fs.abs(rel)
+	// disable G304 (CWE-22): Potential file inclusion via variable
+	// as the path is cleaned and below the webroot
+	// #nosec G304
+	f, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		fs.apiError(w, req, err, 0)
+		return
+	}
+	if _, err := io.WriteString(f, entry.Content); err != nil {
+		f.Close()
+		fs.apiError(w, req, err, 0)
+		return
+	}
+	if err := f.Close(); err != nil {
+		fs.apiError(w, req, err, 0)
+		return
+	}
+	fi, err := os.Stat(target)
+	if err != nil {
+		fs.apiError(w, req, err, 0)
+		return
+	}
+	fs.apiJSON(w, req, fs.newAPIEntry(rel, fi, target), http.StatusCreated)
+}
+
+// apiClipboardClear will empty the clipboard
+func (fs *FileServer) apiClipboardClear(w http.ResponseWriter, req *http.Request) {
+	if fs.readOnly(req) {
+		fs.apiError(w, req, errAPIReadOnly, http.StatusForbidden)
+		return
+	}
+	cb := fs.clipboard(req)
+	if err := cb.ClearClipboard(); err != nil {
+		fs.apiError(w, req, err, 0)
+		return
+	}
+	fs.Hub.RefreshClipboard(cb.Name())
+	fs.Logger.LogRequest(req, http.StatusNoContent)
+	w.WriteHeader(http.StatusNoContent)
+}
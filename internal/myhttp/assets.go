@@ -0,0 +1,31 @@
+package myhttp
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+)
+
+// readStatic returns the embedded file name below static, a file at the same place below fs.Templates takes precedence
+func (fs *FileServer) readStatic(name string) ([]byte, error) {
+	content, err := fs.readStaticFile(name)
+	return fs.disguiseFile(name, content), err
+}
+
+func (fs *FileServer) readStaticFile(name string) ([]byte, error) {
+	name = path.Clean("/" + name)
+	if fs.Templates != "" {
+		// disable G304 (CWE-22): Potential file inclusion via variable
+		// as the path is cleaned and the operator chooses the directory
+		// #nosec G304
+		content, err := ioutil.ReadFile(filepath.Join(fs.Templates, filepath.FromSlash(name)))
+		if err == nil {
+			return content, nil
+		}
+		if !os.IsNotExist(err) {
+			fs.Logger.Errorf("reading template override: %+v", err)
+		}
+	}
+	return static.ReadFile("static" + name)
+}
@@ -0,0 +1,50 @@
+package myhttp
+
+import (
+	"net/http"
+	"sort"
+)
+
+// Ban will refuse every request of ip to the web interface until Unban
+func (fs *FileServer) Ban(ip string) {
+	fs.bannedMu.Lock()
+	defer fs.bannedMu.Unlock()
+	if fs.banned == nil {
+		fs.banned = make(map[string]bool)
+	}
+	fs.banned[ip] = true
+}
+
+// Unban will accept requests of ip again
+func (fs *FileServer) Unban(ip string) {
+	fs.bannedMu.Lock()
+	defer fs.bannedMu.Unlock()
+	delete(fs.banned, ip)
+}
+
+// Banned returns the banned ip addresses sorted
+func (fs *FileServer) Banned() []string {
+	fs.bannedMu.RLock()
+	defer fs.bannedMu.RUnlock()
+	ips := make([]string, 0, len(fs.banned))
+	for ip := range fs.banned {
+		ips = append(ips, ip)
+	}
+	sort.Strings(ips)
+	return ips
+}
+
+// banGate will refuse the requests of banned clients
+func (fs *FileServer) banGate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fs.bannedMu.RLock()
+		banned := fs.banned[fs.clientIP(r)]
+		fs.bannedMu.RUnlock()
+		if banned {
+			fs.Logger.LogRequest(r, http.StatusForbidden)
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
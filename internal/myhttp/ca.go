@@ -0,0 +1,50 @@
+package myhttp
+
+import (
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+const caPath = "/6959097001d10501ac7d54c0bdb8db61420f658f2922cc26e46d536119a31126"
+
+// caDownload will hand out the CA and server certificate as pem or der, e.g. ca.pem or cert.der
+func (fs *FileServer) caDownload(w http.ResponseWriter, req *http.Request) {
+	conf, err := fs.tlsConfig()
+	if err != nil {
+		fs.handleError(w, req, err, http.StatusInternalServerError)
+		return
+	}
+
+	var der []byte
+	file := mux.Vars(req)["file"]
+	switch file {
+	case "ca.pem", "ca.der", "ca.crt":
+		if block, _ := pem.Decode(fs.caPEM); block != nil {
+			der = block.Bytes
+		}
+	case "cert.pem", "cert.der", "cert.crt":
+		der = conf.Certificates[0].Certificate[0]
+	}
+	if der == nil {
+		fs.handleError(w, req, fmt.Errorf("unknown certificate %s", file), http.StatusNotFound)
+		return
+	}
+
+	body := der
+	contentType := "application/x-x509-ca-cert"
+	if strings.HasSuffix(file, ".pem") {
+		body = pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+		contentType = "application/x-pem-file"
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", fs.disguise(fmt.Sprintf("attachment; filename=\"goshs-%s\"", file)))
+	if _, err := w.Write(body); err != nil {
+		fs.Logger.Errorf("Error writing response to browser: %+v", err)
+	}
+	fs.Logger.LogRequest(req, http.StatusOK)
+}
@@ -0,0 +1,183 @@
+package myhttp
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/alecthomas/chroma"
+	"github.com/alecthomas/chroma/formatters/html"
+	"github.com/alecthomas/chroma/lexers"
+	"github.com/alecthomas/chroma/styles"
+)
+
+// maxCodeSize is the largest file in bytes the code viewer will highlight
+const maxCodeSize = 1 << 20
+
+var (
+	codeFormatter = html.New(html.WithClasses(true), html.WithLineNumbers(true), html.LineNumbersInTable(true), html.LinkableLineNumbers(true, "L"))
+	codeStyle     = styles.Get("github")
+	// snippetFormatter renders clipboard entries without line numbers
+	snippetFormatter = html.New(html.WithClasses(true))
+)
+
+type codeTemplate struct {
+	Prefix       string
+	GoshsVersion string
+	Path         string
+	Back         string
+	Language     string
+	CSS          template.CSS
+	Content      template.HTML
+}
+
+// lexerCache remembers hasLexer by extension as matching all lexers is slow for large directories
+var lexerCache sync.Map
+
+var (
+	lexerNamesOnce sync.Once
+	// lexerNames are the file names without extension like Makefile the lexers match
+	lexerNames map[string]bool
+)
+
+// hasLexer reports whether the code viewer knows the language of name
+func hasLexer(name string) bool {
+	ext := strings.ToLower(path.Ext(name))
+	if ext == "" {
+		lexerNamesOnce.Do(func() {
+			lexerNames = make(map[string]bool)
+			for _, lexer := range lexers.Registry.Lexers {
+				config := lexer.Config()
+				for _, glob := range append(config.Filenames, config.AliasFilenames...) {
+					if !strings.ContainsAny(glob, "*?[.") {
+						lexerNames[glob] = true
+					}
+				}
+			}
+		})
+		return lexerNames[name]
+	}
+	if ok, found := lexerCache.Load(ext); found {
+		return ok.(bool)
+	}
+	ok := lexers.Match(name) != nil
+	lexerCache.Store(ext, ok)
+	return ok
+}
+
+// viewCode will render file with syntax highlighting and line numbers
+func (fs *FileServer) viewCode(w http.ResponseWriter, req *http.Request, file *os.File, relpath string) {
+	if fs.uploadOnly(req) {
+		fs.handleError(w, req, fmt.Errorf("%s", "Viewing not allowed due to 'upload only' option"), http.StatusForbidden)
+		return
+	}
+
+	source, err := ioutil.ReadAll(io.LimitReader(file, maxCodeSize+1))
+	if err != nil {
+		fs.handleError(w, req, err, http.StatusInternalServerError)
+		return
+	}
+	if len(source) > maxCodeSize {
+		fs.handleError(w, req, fmt.Errorf("files larger than %d bytes are not highlighted", maxCodeSize), http.StatusRequestEntityTooLarge)
+		return
+	}
+	if !utf8.Valid(source) {
+		fs.handleError(w, req, errors.New("only text files can be viewed"), http.StatusUnsupportedMediaType)
+		return
+	}
+
+	lexer := lexers.Match(relpath)
+	if lexer == nil {
+		lexer = lexers.Analyse(string(source))
+	}
+	if lexer == nil {
+		lexer = lexers.Fallback
+	}
+	lexer = chroma.Coalesce(lexer)
+
+	iterator, err := lexer.Tokenise(nil, string(source))
+	if err != nil {
+		fs.handleError(w, req, err, http.StatusInternalServerError)
+		return
+	}
+	var content, css bytes.Buffer
+	if err := codeFormatter.Format(&content, codeStyle, iterator); err != nil {
+		fs.handleError(w, req, err, http.StatusInternalServerError)
+		return
+	}
+	if err := codeFormatter.WriteCSS(&css, codeStyle); err != nil {
+		fs.Logger.Errorf("writing highlighting css: %+v", err)
+	}
+
+	codeFile, err := fs.readStatic("templates/code.html")
+	if err != nil {
+		fs.Logger.Errorf("opening embedded file: %+v", err)
+	}
+
+	relpath = path.Clean("/" + filepath.ToSlash(relpath))
+	// disable G203 (CWE-79): The used method does not auto-escape HTML
+	// as chroma escapes the source
+	// #nosec G203
+	tem := codeTemplate{
+		Prefix:       fs.Prefix,
+		GoshsVersion: fs.Version,
+		Path:         relpath,
+		Back:         path.Dir(relpath),
+		Language:     lexer.Config().Name,
+		CSS:          template.CSS(css.String()),
+		Content:      template.HTML(content.String()),
+	}
+
+	t := template.New("code")
+	if _, err := t.Parse(string(codeFile)); err != nil {
+		fs.Logger.Errorf("parsing the template: %+v", err)
+	}
+	if err := t.Execute(w, tem); err != nil {
+		fs.Logger.Errorf("executing the template: %+v", err)
+	}
+}
+
+// highlightSnippet will render source in language for the clipboard, unknown languages are shown as is
+func (fs *FileServer) highlightSnippet(source, language string) template.HTML {
+	// disable G203 (CWE-79): The used method does not auto-escape HTML
+	// as the source is escaped by hand or by chroma
+	// #nosec G203
+	plain := template.HTML("<pre>" + template.HTMLEscapeString(source) + "</pre>")
+	lexer := lexers.Get(language)
+	if lexer == nil || len(source) > maxCodeSize {
+		return plain
+	}
+	iterator, err := chroma.Coalesce(lexer).Tokenise(nil, source)
+	if err != nil {
+		return plain
+	}
+	var content bytes.Buffer
+	if err := snippetFormatter.Format(&content, codeStyle, iterator); err != nil {
+		fs.Logger.Errorf("highlighting clipboard entry: %+v", err)
+		return plain
+	}
+	// #nosec G203
+	return template.HTML(content.String())
+}
+
+// snippetCSS returns the stylesheet of highlighted clipboard entries
+func (fs *FileServer) snippetCSS() template.CSS {
+	var css bytes.Buffer
+	if err := snippetFormatter.WriteCSS(&css, codeStyle); err != nil {
+		fs.Logger.Errorf("writing highlighting css: %+v", err)
+	}
+	// disable G203 (CWE-79): The used method does not auto-escape HTML
+	// as chroma generates the css
+	// #nosec G203
+	return template.CSS(css.String())
+}
@@ -0,0 +1,121 @@
+package myhttp
+
+import (
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"unicode/utf8"
+)
+
+// maxEditSize is the largest file in bytes the editor will open
+const maxEditSize = 1 << 20
+
+type editTemplate struct {
+	Prefix       string
+	GoshsVersion string
+	Path         string
+	Back         string
+	Content      string
+}
+
+// editFile will render the editor for file
+func (fs *FileServer) editFile(w http.ResponseWriter, req *http.Request, file *os.File, relpath string) {
+	if fs.readOnly(req) {
+		fs.handleError(w, req, fmt.Errorf("%s", "Editing not allowed due to 'read only' option"), http.StatusForbidden)
+		return
+	}
+	if fs.uploadOnly(req) {
+		fs.handleError(w, req, fmt.Errorf("%s", "Editing not allowed due to 'upload only' option"), http.StatusForbidden)
+		return
+	}
+
+	content, err := ioutil.ReadAll(io.LimitReader(file, maxEditSize+1))
+	if err != nil {
+		fs.handleError(w, req, err, http.StatusInternalServerError)
+		return
+	}
+	if len(content) > maxEditSize {
+		fs.handleError(w, req, fmt.Errorf("files larger than %d bytes cannot be edited", maxEditSize), http.StatusRequestEntityTooLarge)
+		return
+	}
+	if !utf8.Valid(content) {
+		fs.handleError(w, req, errors.New("only text files can be edited"), http.StatusUnsupportedMediaType)
+		return
+	}
+
+	editFile, err := fs.readStatic("templates/edit.html")
+	if err != nil {
+		fs.Logger.Errorf("opening embedded file: %+v", err)
+	}
+
+	relpath = path.Clean("/" + filepath.ToSlash(relpath))
+	tem := editTemplate{
+		Prefix:       fs.Prefix,
+		GoshsVersion: fs.Version,
+		Path:         relpath,
+		Back:         path.Dir(relpath),
+		Content:      string(content),
+	}
+
+	t := template.New("edit")
+	if _, err := t.Parse(string(editFile)); err != nil {
+		fs.Logger.Errorf("parsing the template: %+v", err)
+	}
+	if err := t.Execute(w, tem); err != nil {
+		fs.Logger.Errorf("executing the template: %+v", err)
+	}
+}
+
+// saveFile handles the PUT request of the editor and replaces the content of the file with the request body
+func (fs *FileServer) saveFile(w http.ResponseWriter, req *http.Request) {
+	if fs.readOnly(req) {
+		fs.handleError(w, req, fmt.Errorf("%s", "Saving not allowed due to 'read only' option"), http.StatusForbidden)
+		return
+	}
+	if fs.uploadOnly(req) {
+		fs.handleError(w, req, fmt.Errorf("%s", "Saving not allowed due to 'upload only' option"), http.StatusForbidden)
+		return
+	}
+
+	// Sanitize path (No path traversal)
+	upath := path.Clean("/" + req.URL.Path)
+	target := fs.abs(upath)
+	fi, err := os.Stat(target)
+	if err != nil {
+		if os.IsNotExist(err) {
+			fs.handleError(w, req, err, http.StatusNotFound)
+			return
+		}
+		fs.handleError(w, req, err, http.StatusInternalServerError)
+		return
+	}
+	if fi.IsDir() {
+		fs.handleError(w, req, fmt.Errorf("%s is a directory", upath), http.StatusBadRequest)
+		return
+	}
+
+	content, err := ioutil.ReadAll(io.LimitReader(req.Body, maxEditSize+1))
+	if err != nil {
+		fs.handleError(w, req, err, http.StatusBadRequest)
+		return
+	}
+	if len(content) > maxEditSize {
+		fs.handleError(w, req, fmt.Errorf("files larger than %d bytes cannot be edited", maxEditSize), http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	if err := ioutil.WriteFile(target, content, fi.Mode().Perm()); err != nil {
+		fs.Logger.Errorf("Not able to write file to disk")
+		fs.handleError(w, req, fs.hidePaths(err), http.StatusInternalServerError)
+		return
+	}
+
+	fs.Logger.LogRequest(req, http.StatusNoContent)
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,37 @@
+package myhttp
+
+import (
+	"io/ioutil"
+	"net/http"
+
+	"github.com/patrickhener/goshs/internal/myutils"
+)
+
+// favicon will send the icon from fs.Favicon or the embedded one
+func (fs *FileServer) favicon(w http.ResponseWriter, req *http.Request) {
+	var icon []byte
+	var err error
+	contentType := "image/x-icon"
+	if fs.Favicon != "" {
+		// disable G304 (CWE-22): Potential file inclusion via variable
+		// as the operator chooses the file
+		// #nosec G304
+		icon, err = ioutil.ReadFile(fs.Favicon)
+		if ct := myutils.MimeByExtension(fs.Favicon); ct != "" {
+			contentType = ct
+		}
+	} else {
+		icon, err = static.ReadFile("static/images/favicon.ico")
+	}
+	if err != nil {
+		fs.handleError(w, req, err, http.StatusInternalServerError)
+		return
+	}
+
+	fs.Logger.LogRequest(req, http.StatusOK)
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Cache-Control", "public, max-age=86400")
+	if _, err := w.Write(icon); err != nil {
+		fs.Logger.Errorf("Error writing response to browser: %+v", err)
+	}
+}
@@ -2,32 +2,58 @@ package myhttp
 
 import (
 	"archive/zip"
+	"context"
+	"crypto/tls"
 	"embed"
 	"errors"
 	"fmt"
 	"html/template"
 	"io"
 	"io/ioutil"
+	"net"
 	"net/http"
 	"net/url"
 	"os"
 	"path"
 	"path/filepath"
 	"sort"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/gorilla/mux"
+	"github.com/patrickhener/goshs/internal/myaccess"
+	"github.com/patrickhener/goshs/internal/myactivity"
+	"github.com/patrickhener/goshs/internal/myauth"
 	"github.com/patrickhener/goshs/internal/myca"
+	"github.com/patrickhener/goshs/internal/mycanary"
 	"github.com/patrickhener/goshs/internal/myclipboard"
+	"github.com/patrickhener/goshs/internal/mydump"
 	"github.com/patrickhener/goshs/internal/mylog"
+	"github.com/patrickhener/goshs/internal/mymount"
+	"github.com/patrickhener/goshs/internal/mymonitor"
+	"github.com/patrickhener/goshs/internal/mynotify"
+	"github.com/patrickhener/goshs/internal/myreport"
 	"github.com/patrickhener/goshs/internal/mysock"
 	"github.com/patrickhener/goshs/internal/myutils"
-	"golang.org/x/net/webdav"
+	"github.com/patrickhener/goshs/internal/mywatch"
+	"github.com/patrickhener/goshs/internal/mywebhook"
+	"github.com/quic-go/quic-go/http3"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
 )
 
 const (
-	modeWeb = "web"
+	modeWeb   = "web"
+	loginPath = "/073c1634c496cdb649d1afe0a312bbb4b7e1741b271542e4a436c3b8824b1761/login"
+)
+
+type ctxKey int
+
+const (
+	ctxUser ctxKey = iota
+	ctxRole
 )
 
 // Static will provide the embedded files as http.FS
@@ -35,23 +61,58 @@ const (
 var static embed.FS
 
 type indexTemplate struct {
+	Prefix       string
 	Clipboard    *myclipboard.Clipboard
+	Channels     []string
+	StatusPath   string
+	ActivityPath string
+	LoginPath    string
+	CAPath       string
+	PublicURL    string
+	QRPath       string
+	ShareURL     string
 	GoshsVersion string
+	ReadOnly     bool
+	Permissions  bool
+	Banner       string
+	Readme       template.HTML
+	SnippetCSS   template.CSS
+	Prefs        listPrefs
+	Page         int
+	Pages        int
+	PrevPage     int
+	NextPage     int
 	Directory    *directory
 }
 
 type directory struct {
-	RelPath        string
-	AbsPath        string
-	IsSubdirectory bool
-	Back           string
-	Content        []item
+	RelPath     string
+	AbsPath     string
+	Files       int
+	Dirs        int
+	TotalSize   string
+	DiskFree    string
+	DiskTotal   string
+	HasImages   bool
+	Breadcrumbs []breadcrumb
+	Content     []item
+}
+
+type breadcrumb struct {
+	Name string
+	Path string
 }
 
 type item struct {
 	URI                 string
 	Name                string
 	IsDir               bool
+	Editable            bool
+	IsMarkdown          bool
+	IsCode              bool
+	HasThumbnail        bool
+	Media               string
+	IsPDF               bool
 	IsSymlink           bool
 	SymlinkTarget       string
 	Ext                 string
@@ -59,6 +120,10 @@ type item struct {
 	SortSize            int64
 	DisplayLastModified string
 	SortLastModified    time.Time
+	Mode                string
+	Owner               string
+	Group               string
+	fi                  os.FileInfo
 }
 
 // FileServer holds the fileserver information
@@ -66,11 +131,23 @@ type FileServer struct {
 	IP             string
 	Port           int
 	WebdavPort     int
+	WebdavMount    bool
+	API            bool
+	Listeners      []net.Listener
+	PublicURL      string
+	ProxyProtocol  bool
+	ProxyTrusted   []*net.IPNet
 	Webroot        string
+	// Mounts serve further directories below virtual paths next to the webroot
+	Mounts         mymount.Mounts
 	SSL            bool
 	SelfSigned     bool
 	MyKey          string
 	MyCert         string
+	SNICerts       []myca.KeyPair
+	PKCS12         string
+	PKCS12Pass     string
+	CertOptions    myca.Options
 	User           string
 	Pass           string
 	Version        string
@@ -78,11 +155,107 @@ type FileServer struct {
 	Fingerprint1   string
 	UploadOnly     bool
 	ReadOnly       bool
+	Speedtest      bool
+	Permissions    bool
 	Hub            *mysock.Hub
-	Clipboard      *myclipboard.Clipboard
+	Clipboards     *myclipboard.Channels
+	LDAP           *myauth.LDAP
+	OIDC           *myauth.OIDC
+	Limiter        *myauth.Limiter
+	Monitor        *mymonitor.Monitor
+	AuthExempt     []*net.IPNet
+	AnonymousRead  bool
+	CaptureLog     *myauth.CaptureLog
+	// Prefix is a secret path all routes are served below, e.g. /<token>
+	Prefix string
+	// HTTP3 adds a QUIC listener on the same udp port if SSL
+	HTTP3 bool
+	// H2C serves cleartext HTTP/2 (prior knowledge and upgrade) if not SSL
+	H2C bool
+	// HSTS, SecurityHeaders and CSP add the according headers to every response
+	HSTS            bool
+	SecurityHeaders bool
+	CSP             string
+	Templates       string
+	// TLSMinVersion and TLSCiphers override the defaults if set
+	TLSMinVersion uint16
+	TLSCiphers    []uint16
+	// Stealth hides the name of the tool and its well known routes, Version should be empty then
+	Stealth bool
+	// Banner is shown above every listing
+	Banner string
+	// Favicon replaces the embedded /favicon.ico if set
+	Favicon string
+	// ClipboardFile keeps the clipboard across restarts if set
+	ClipboardFile string
+	// AccessLog records every request in the Common or Combined Log Format if set
+	AccessLog *myaccess.Log
+	// Dump writes every request with headers and body to disk if set
+	Dump *mydump.Dumper
+	// Webhook is fired for downloads, uploads and failed logins if set
+	Webhook *mywebhook.Webhook
+	// TrustedProxies may tell the client address in a Forwarded or X-Forwarded-For header
+	TrustedProxies []*net.IPNet
+	// Desktop shows notifications for first downloads and uploads if set
+	Desktop *mynotify.Notifier
+	// Canary raises an alert for requests to canary paths if set
+	Canary *mycanary.Canary
+	// Report collects the files served and received and failed logins for a summary if set
+	Report *myreport.Report
+	// Activity serves a page with the live activity to users who may write
+	Activity bool
+	// Tracker records the requests for the activity page and others if set,
+	// the activity page creates one if needed
+	Tracker *myactivity.Tracker
+	// ClipboardChanged is called with the channel after every change of a clipboard if set
+	ClipboardChanged func(channel string)
+	// MaxServes refuses downloads of a file served that often already if set,
+	// MaxServed is called once the last one allowed finished
+	MaxServes int
+	MaxServed func(upath string)
+	// File is served at / instead of the webroot if set
+	File string
+	// Receive gets the first file uploaded instead of the webroot if set,
+	// Received is called once it was written
+	Receive  io.Writer
+	Received func(err error)
+	// Logger logs the messages of this server, the zero value logs to the package log
+	Logger mylog.Logger
+
+	bannedMu sync.RWMutex
+	banned   map[string]bool
+
+	servesMu sync.Mutex
+	serves   map[string]*serveCount
+
+	receiveMu sync.Mutex
+	receiving bool
+
+	// servers are stopped by Shutdown, done is closed and stops run then
+	serversMu sync.Mutex
+	servers   []*http.Server
+	shutdown  bool
+	done      chan struct{}
+	stops     []func()
+
+	// live guards the settings changed by Reload
+	live sync.RWMutex
+
+	tlsOnce sync.Once
+	tlsConf *tls.Config
+	tlsErr  error
+	caPEM   []byte
+	h3      *http3.Server
+
+	activity *myactivity.Tracker
+
+	stealthOnce     sync.Once
+	stealthTokens   map[string]string
+	stealthReplacer *strings.Replacer
 }
 
 type httperror struct {
+	Prefix       string
 	ErrorCode    int
 	ErrorMessage string
 	AbsPath      string
@@ -92,138 +265,610 @@ type httperror struct {
 // BasicAuthMiddleware is a middleware to handle the basic auth
 func (fs *FileServer) BasicAuthMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ip := fs.clientIP(r)
+		if fs.authExempt(ip) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if fs.Limiter != nil {
+			if banned, remaining := fs.Limiter.Banned(ip); banned {
+				w.Header().Set("Retry-After", fmt.Sprintf("%.0f", remaining.Seconds()+1))
+				http.Error(w, "Too many failed logins", http.StatusTooManyRequests)
+				return
+			}
+		}
+
 		w.Header().Set("WWW-Authenticate", `Basic realm="Restricted"`)
 
 		username, password, authOK := r.BasicAuth()
 		if !authOK {
+			// Anonymous users may read but get challenged as soon as they want to write
+			if fs.AnonymousRead && r.URL.Path != loginPath && isReadMethod(r.Method) {
+				w.Header().Del("WWW-Authenticate")
+				next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), ctxRole, myauth.RoleRead)))
+				return
+			}
 			http.Error(w, "Not authorized", http.StatusUnauthorized)
 			return
 		}
 
-		if username != fs.User || password != fs.Pass {
+		if !fs.verifyLogin(ip, r.UserAgent(), r.URL.String(), username, password) {
 			http.Error(w, "Not authorized", http.StatusUnauthorized)
 			return
 		}
 
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), ctxUser, username)))
+	})
+}
+
+// verifyLogin checks the credentials and takes care of the credential capture and the brute-force protection
+func (fs *FileServer) verifyLogin(ip, userAgent, url, username, password string) bool {
+	success := fs.checkCredentials(username, password)
+	if fs.CaptureLog != nil {
+		if err := fs.CaptureLog.Capture(myauth.CaptureRecord{
+			RemoteIP:  ip,
+			UserAgent: userAgent,
+			URL:       url,
+			User:      username,
+			Password:  password,
+			Success:   success,
+		}); err != nil {
+			fs.Logger.Errorf("writing credential capture log: %+v", err)
+		}
+	}
+
+	if !success {
+		fs.Logger.Warnf("Failed login for user '%s' from %s", username, ip)
+		if fs.Report != nil {
+			fs.Report.AuthFailure(ip, username)
+		}
+		if fs.Webhook != nil {
+			fs.Webhook.Fire(mywebhook.Event{Event: mywebhook.EventAuth, RemoteAddr: ip, Path: url, Status: http.StatusUnauthorized, User: username, UserAgent: userAgent})
+		}
+		if fs.Limiter != nil {
+			if ban := fs.Limiter.Fail(ip, username); ban > 0 {
+				fs.Logger.Warnf("Banning %s for %s due to too many failed logins", ip, ban)
+			}
+		}
+		return false
+	}
+
+	if fs.Limiter != nil {
+		fs.Limiter.Success(ip, username)
+	}
+	return true
+}
+
+// login is protected by the auth middleware even for anonymous readers,
+// so the browser asks for credentials before redirecting back
+func (fs *FileServer) login(w http.ResponseWriter, req *http.Request) {
+	fs.Logger.LogRequest(req, http.StatusSeeOther)
+	http.Redirect(w, req, fs.Prefix+"/", http.StatusSeeOther)
+}
+
+// isReadMethod reports whether method does not modify anything
+func isReadMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, "PROPFIND":
+		return true
+	}
+	return false
+}
+
+// address returns the listen address for port, ipv6 addresses are bracketed
+func (fs *FileServer) address(port int) string {
+	return net.JoinHostPort(fs.IP, strconv.Itoa(port))
+}
+
+// prefixGate will only pass requests below fs.Prefix, the prefix gets stripped if strip is set
+func (fs *FileServer) prefixGate(next http.Handler, strip bool) http.Handler {
+	stripped := http.StripPrefix(fs.Prefix, next)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == fs.Prefix {
+			http.Redirect(w, r, fs.Prefix+"/", http.StatusMovedPermanently)
+			return
+		}
+		if !strings.HasPrefix(r.URL.Path, fs.Prefix+"/") {
+			fs.Logger.LogRequest(r, http.StatusNotFound)
+			http.NotFound(w, r)
+			return
+		}
+		if strip {
+			stripped.ServeHTTP(w, r)
+			return
+		}
 		next.ServeHTTP(w, r)
 	})
 }
 
+// clientIP returns the ip address of the requesting client
+func (fs *FileServer) clientIP(req *http.Request) string {
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		return req.RemoteAddr
+	}
+	return host
+}
+
+// readOnly reports whether the request is not allowed to modify the webroot or the mount it is for
+func (fs *FileServer) readOnly(req *http.Request) bool {
+	if fs.IsReadOnly() || fs.Mounts.ReadOnly(requestPath(req)) {
+		return true
+	}
+	role, _ := req.Context().Value(ctxRole).(string)
+	return role == myauth.RoleRead
+}
+
+// uploadOnly reports whether the request is not allowed to download from the webroot or the mount it is for
+func (fs *FileServer) uploadOnly(req *http.Request) bool {
+	return fs.UploadOnly || fs.Mounts.UploadOnly(requestPath(req))
+}
+
+// requestPath returns the url path a request is for, the API takes it from the route
+func requestPath(req *http.Request) string {
+	if p, ok := mux.Vars(req)["path"]; ok {
+		return p
+	}
+	return req.URL.Path
+}
+
+// abs returns where upath is stored on disk, in the webroot or a mounted directory
+func (fs *FileServer) abs(upath string) string {
+	return fs.Mounts.Path(fs.Webroot, upath)
+}
+
+// hidePaths returns err without the directories on disk, clients shall not learn them
+func (fs *FileServer) hidePaths(err error) error {
+	return errors.New(fs.Mounts.Hide(fs.Webroot, err.Error()))
+}
+
+// checkCredentials validates the credentials against the configured auth backend
+func (fs *FileServer) checkCredentials(username, password string) bool {
+	if fs.LDAP != nil {
+		ok, err := fs.LDAP.Authenticate(username, password)
+		if err != nil {
+			fs.Logger.Errorf("LDAP authentication for user '%s': %+v", username, err)
+		}
+		return ok
+	}
+
+	user, pass := fs.credentials()
+	return username == user && password == pass
+}
+
 // Start will start the file server
 func (fs *FileServer) Start(what string) {
+	port := fs.Port
+	if what != modeWeb {
+		port = fs.WebdavPort
+	}
+	listener, err := fs.listen(fs.address(port))
+	if err != nil {
+		mylog.Panic(err)
+	}
+	if err := fs.Serve(what, listener); err != nil {
+		mylog.Fatal(err)
+	}
+}
+
+// Serve will serve what (web or webdav) on listener until Shutdown is called, the web
+// interface is served on fs.Listeners as well. It returns nil after Shutdown.
+func (fs *FileServer) Serve(what string, listener net.Listener) error {
 	var addr string
 	// Setup routing with gorilla/mux
 	mux := mux.NewRouter()
 
 	switch what {
 	case modeWeb:
+		if fs.File != "" {
+			fs.registerFile(mux)
+			addr = fs.address(fs.Port)
+			break
+		}
+		if fs.Receive != nil {
+			fs.registerReceive(mux)
+			addr = fs.address(fs.Port)
+			break
+		}
 		mux.PathPrefix("/425bda8487e36deccb30dd24be590b8744e3a28a8bb5a57d9b3fcd24ae09ad3c/").HandlerFunc(fs.static)
 		// Websocket
 		mux.PathPrefix("/14644be038ea0118a1aadfacca2a7d1517d7b209c4b9674ee893b1944d1c2d54/ws").HandlerFunc(fs.socket)
 		// Clipboard
 		mux.PathPrefix("/14644be038ea0118a1aadfacca2a7d1517d7b209c4b9674ee893b1944d1c2d54/download").HandlerFunc(fs.cbDown)
 		mux.PathPrefix("/cf985bddf28fed5d5c53b069d6a6ebe601088ca6e20ec5a5a8438f8e1ffd9390/").HandlerFunc(fs.bulkDownload)
+		mux.Path(dirsPath).Methods(http.MethodGet).HandlerFunc(fs.dirs)
+		mux.Path(qrPath).Methods(http.MethodGet).HandlerFunc(fs.qr)
+		mux.Path(treePath).Methods(http.MethodGet).HandlerFunc(fs.tree)
+		// Speedtest
+		if fs.Speedtest {
+			mux.Path(speedtestPath).Methods(http.MethodGet).HandlerFunc(fs.speedtest)
+			mux.Path(speedtestPath + "/download").Methods(http.MethodGet).HandlerFunc(fs.speedtestDown)
+			mux.Path(speedtestPath + "/upload").Methods(http.MethodPost).HandlerFunc(fs.speedtestUp)
+		}
+		// Login for anonymous readers
+		if fs.AnonymousRead {
+			mux.Path(loginPath).HandlerFunc(fs.login)
+		}
+		// Certificates to trust the self-signed server
+		if fs.SSL && fs.SelfSigned {
+			mux.Path(caPath + "/{file}").Methods(http.MethodGet).HandlerFunc(fs.caDownload)
+		}
+		// Status
+		if fs.Monitor != nil {
+			mux.Path(statusPath).HandlerFunc(fs.status)
+		}
+		// Live activity
+		if fs.Activity {
+			if fs.Tracker == nil {
+				fs.Tracker = myactivity.New(Untracked)
+			}
+			fs.activity = fs.Tracker
+			mux.Path(activityPath).Methods(http.MethodGet).HandlerFunc(fs.activityPage)
+		}
+		// OpenID Connect
+		if fs.OIDC != nil {
+			mux.Path(oidcPath + "/login").HandlerFunc(fs.oidcLogin)
+			mux.Path(oidcPath + "/callback").HandlerFunc(fs.oidcCallback)
+			mux.Path(oidcPath + "/logout").HandlerFunc(fs.oidcLogout)
+		}
+		// JSON API
+		if fs.API {
+			fs.registerAPI(mux)
+		} else {
+			fs.registerClipboardAPI(mux.PathPrefix(apiPath).Subrouter())
+		}
+		// WebDAV on the same port
+		if fs.WebdavMount {
+			mux.Path(webdavPath).Handler(http.RedirectHandler(fs.Prefix+webdavPath+"/", http.StatusMovedPermanently))
+			mux.PathPrefix(webdavPath + "/").Handler(fs.webdavMount())
+		}
 		mux.Methods(http.MethodPost).HandlerFunc(fs.upload)
+		mux.Methods(http.MethodDelete).HandlerFunc(fs.delete)
+		mux.Methods(http.MethodPatch).HandlerFunc(fs.moveFile)
+		mux.Methods("MKCOL").HandlerFunc(fs.mkdir)
+		mux.Methods(http.MethodPut).HandlerFunc(fs.saveFile)
 		mux.PathPrefix("/").HandlerFunc(fs.handler)
 
-		addr = fmt.Sprintf("%+v:%+v", fs.IP, fs.Port)
+		addr = fs.address(fs.Port)
 	case "webdav":
-		wdHandler := &webdav.Handler{
-			FileSystem: webdav.Dir(fs.Webroot),
-			LockSystem: webdav.NewMemLS(),
-			Logger: func(r *http.Request, e error) {
-				if e != nil && r.Method != "PROPFIND" {
-					mylog.Errorf("WEBDAV: %s - - \"%s %s %s\"", r.RemoteAddr, r.Method, r.URL.Path, r.Proto)
-					return
-				} else if r.Method != "PROPFIND" {
-					mylog.Infof("WEBDAV:  %s - - \"%s %s %s\"", r.RemoteAddr, r.Method, r.URL.Path, r.Proto)
-				}
-			},
-		}
-
-		mux.PathPrefix("/").Handler(wdHandler)
-		addr = fmt.Sprintf("%+v:%+v", fs.IP, fs.WebdavPort)
+		mux.PathPrefix("/").Handler(fs.webdavHandler(fs.Prefix))
+		addr = fs.address(fs.WebdavPort)
 	default:
 	}
 
+	// Serve everything below the secret prefix only
+	var handler http.Handler = mux
+	if fs.Tracker != nil && what == modeWeb {
+		handler = fs.Tracker.Handler(handler)
+	}
+	if fs.Stealth && what == modeWeb {
+		handler = fs.stealthGate(handler)
+	}
+	if fs.Prefix != "" {
+		handler = fs.prefixGate(handler, what == modeWeb)
+	}
+	if fs.AccessLog != nil {
+		handler = fs.AccessLog.Handler(handler)
+	}
+	handler = fs.hooked(handler)
+	if fs.Canary != nil {
+		handler = fs.Canary.Handler(handler)
+	}
+	if fs.Dump != nil {
+		handler = fs.Dump.Handler(handler)
+	}
+	handler = fs.banGate(handler)
+	if len(fs.TrustedProxies) > 0 {
+		handler = fs.forwarded(handler)
+	}
+
+	// Cleartext HTTP/2 for tooling, HTTP/2 over TLS is negotiated anyway
+	if fs.H2C && !fs.SSL {
+		handler = h2c.NewHandler(handler, &http2.Server{})
+	}
+
 	// construct server
 	server := http.Server{
 		Addr:    addr,
-		Handler: http.AllowQuerySemicolons(mux),
+		Handler: http.AllowQuerySemicolons(handler),
 		// Against good practice no timeouts here, otherwise big files would be terminated when downloaded
 	}
 
-	// init clipboard
-	fs.Clipboard = myclipboard.New()
+	// init clipboard and websocket hub, only the web interface uses them
+	if what == modeWeb {
+		fs.Clipboards = myclipboard.NewChannels()
+		if fs.ClipboardFile != "" {
+			cs, err := myclipboard.Open(fs.ClipboardFile)
+			if err != nil {
+				return fmt.Errorf("unable to open the clipboard file %s: %+v", fs.ClipboardFile, err)
+			}
+			fs.Clipboards = cs
+			fs.Logger.Infof("Persisting the clipboard to %s", fs.ClipboardFile)
+		}
+
+		fs.Hub = mysock.NewHub(fs.Clipboards, fs.Webroot, fs.UploadOnly)
+		fs.Hub.Mounts = fs.Mounts
+		fs.Hub.OnClipboard = fs.ClipboardChanged
+		fs.Hub.ReserveServe = fs.reserveServe
+		fs.Hub.Served = fs.served
+		fs.Hub.Transferred = fs.wsTransferred
+		fs.Hub.Logger = fs.Logger
+		go fs.Hub.Run()
+		fs.atShutdown(fs.Hub.Stop)
+		go fs.purgeClipboard(fs.stopped())
+		if fs.activity != nil {
+			go fs.broadcastActivity(fs.stopped())
+		}
+	}
+
+	// Open listings refresh when files change on disk
+	if what == modeWeb && !fs.UploadOnly && fs.File == "" && fs.Receive == nil {
+		fs.watch(fs.Webroot, fs.Hub.RefreshDirectory)
+		for _, m := range fs.Mounts {
+			if m.UploadOnly {
+				continue
+			}
+			mountPath := m.Path
+			refresh := func(dir string) {
+				fs.Hub.RefreshDirectory(path.Join(mountPath, dir))
+			}
+			fs.watch(m.Dir, refresh)
+		}
+	}
 
-	// init websocket hub
-	fs.Hub = mysock.NewHub(fs.Clipboard)
-	go fs.Hub.Run()
+	// Security headers go first to be part of auth challenges, too
+	if fs.HSTS || fs.SecurityHeaders || fs.CSP != "" {
+		mux.Use(fs.SecurityHeadersMiddleware)
+	}
+	if fs.HTTP3 && fs.SSL && what == modeWeb {
+		mux.Use(fs.AltSvcMiddleware)
+	}
 
-	// Check BasicAuth and use middleware
-	if fs.User != "" && what == modeWeb {
+	// Check OpenID Connect and use middleware
+	if fs.OIDC != nil && what == modeWeb {
 		if !fs.SSL {
-			mylog.Warnf("You are using basic auth without SSL. Your credentials will be transferred in cleartext. Consider using -s, too.")
+			fs.Logger.Warnf("You are using OpenID Connect without SSL. Your session will be transferred in cleartext. Consider using -s, too.")
+		}
+		fs.Logger.Infof("Using OpenID Connect login with issuer '%s'", fs.OIDC.Issuer)
+		mux.Use(fs.OIDCMiddleware)
+	}
+
+	// Check BasicAuth and use middleware, this protects the webdav listener as well
+	if fs.User != "" || fs.LDAP != nil {
+		// Only log once, not for every listener
+		if what == modeWeb {
+			if !fs.SSL {
+				fs.Logger.Warnf("You are using basic auth without SSL. Your credentials will be transferred in cleartext. Consider using -s, too.")
+			}
+			if fs.LDAP != nil {
+				fs.Logger.Infof("Using basic auth against LDAP server '%s' with base dn '%s'", fs.LDAP.URL, fs.LDAP.BaseDN)
+			} else {
+				fs.Logger.Infof("Using basic auth with user '%s' and password '%s'", fs.User, fs.Pass)
+			}
 		}
-		mylog.Infof("Using basic auth with user '%s' and password '%s'", fs.User, fs.Pass)
 		// Use middleware
 		mux.Use(fs.BasicAuthMiddleware)
 	}
 
 	// Check if ssl
 	if fs.SSL {
-		// Check if selfsigned
-		if fs.SelfSigned {
-			serverTLSConf, fingerprint256, fingerprint1, err := myca.Setup()
-			if err != nil {
-				mylog.Fatalf("Unable to start SSL enabled server: %+v\n", err)
-			}
-			server.TLSConfig = serverTLSConf
-			fs.Fingerprint256 = fingerprint256
-			fs.Fingerprint1 = fingerprint1
-			fs.logStart(what)
+		serverTLSConf, err := fs.tlsConfig()
+		if err != nil {
+			return fmt.Errorf("unable to start SSL enabled server: %+v", err)
+		}
+		server.TLSConfig = serverTLSConf
+		// HTTP/2 refuses to start without the cipher suites it requires, so stick to HTTP/1.1
+		if !http2Capable(fs.TLSCiphers) {
+			fs.Logger.Warn("HTTP/2 is disabled as the cipher suites lack TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256 or TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256")
+			server.TLSNextProto = make(map[string]func(*http.Server, *tls.Conn, http.Handler))
+		}
+		fs.logStart(what)
 
-			mylog.Panic(server.ListenAndServeTLS("", ""))
-		} else {
-			if fs.MyCert == "" || fs.MyKey == "" {
-				mylog.Fatal("You need to provide server.key and server.crt if -s and not -ss")
-			}
+		if fs.HTTP3 && what == modeWeb {
+			fs.newHTTP3(addr, server.Handler, serverTLSConf)
+			go fs.serveHTTP3()
+		}
 
-			fingerprint256, fingerprint1, err := myca.ParseAndSum(fs.MyCert)
-			if err != nil {
-				mylog.Fatalf("Unable to start SSL enabled server: %+v\n", err)
-			}
-			fs.Fingerprint256 = fingerprint256
-			fs.Fingerprint1 = fingerprint1
-			fs.logStart(what)
+		if !fs.track(&server) {
+			return listener.Close()
+		}
+		if what == modeWeb {
+			fs.serveListeners(&server)
+		}
+		return serveResult(server.ServeTLS(listener, "", ""))
+	}
+	fs.logStart(what)
+	if !fs.track(&server) {
+		return listener.Close()
+	}
+	if what == modeWeb {
+		fs.serveListeners(&server)
+	}
+	return serveResult(server.Serve(listener))
+}
 
-			mylog.Panic(server.ListenAndServeTLS(fs.MyCert, fs.MyKey))
+// serveResult returns err of serving, nil if the server was shut down
+func serveResult(err error) error {
+	if err == http.ErrServerClosed {
+		return nil
+	}
+	return err
+}
+
+// track will remember server to stop it on Shutdown, it returns false if that happened already
+func (fs *FileServer) track(server *http.Server) bool {
+	fs.serversMu.Lock()
+	defer fs.serversMu.Unlock()
+	if fs.shutdown {
+		return false
+	}
+	fs.servers = append(fs.servers, server)
+	return true
+}
+
+// stopped returns a channel which is closed by Shutdown, the background work of the server ends then
+func (fs *FileServer) stopped() <-chan struct{} {
+	fs.serversMu.Lock()
+	defer fs.serversMu.Unlock()
+	if fs.done == nil {
+		fs.done = make(chan struct{})
+	}
+	return fs.done
+}
+
+// atShutdown will call stop on Shutdown, right away if that happened already
+func (fs *FileServer) atShutdown(stop func()) {
+	fs.serversMu.Lock()
+	if !fs.shutdown {
+		fs.stops = append(fs.stops, stop)
+		fs.serversMu.Unlock()
+		return
+	}
+	fs.serversMu.Unlock()
+	stop()
+}
+
+// watch will call changed with the directory below dir whose content changed until Shutdown
+func (fs *FileServer) watch(dir string, changed func(dir string)) {
+	w, err := mywatch.New(dir, fs.Logger, changed)
+	if err != nil {
+		fs.Logger.Errorf("Unable to watch %s for live refresh: %+v", dir, err)
+		return
+	}
+	fs.atShutdown(func() {
+		if err := w.Close(); err != nil {
+			fs.Logger.Debugf("closing the watcher of %s: %+v", dir, err)
+		}
+	})
+}
+
+// Shutdown will stop all listeners and wait for the requests in flight to finish until ctx is done,
+// the remaining connections are closed then. The background work of the server ends as well.
+func (fs *FileServer) Shutdown(ctx context.Context) error {
+	fs.serversMu.Lock()
+	servers, stops := fs.servers, fs.stops
+	if fs.done == nil {
+		fs.done = make(chan struct{})
+	}
+	if !fs.shutdown {
+		close(fs.done)
+	}
+	fs.servers, fs.stops, fs.shutdown = nil, nil, true
+	fs.serversMu.Unlock()
+
+	for _, stop := range stops {
+		stop()
+	}
+
+	if fs.h3 != nil {
+		if err := fs.h3.Close(); err != nil {
+			fs.Logger.Debugf("closing the HTTP/3 listener: %+v", err)
+		}
+	}
+	var err error
+	for _, server := range servers {
+		if serr := server.Shutdown(ctx); serr != nil {
+			err = serr
+			if cerr := server.Close(); cerr != nil {
+				fs.Logger.Debugf("closing server: %+v", cerr)
+			}
 		}
-	} else {
-		fs.logStart(what)
-		mylog.Panic(server.ListenAndServe())
+	}
+	return err
+}
+
+// serveListeners will serve the web interface on the additional listeners as well
+func (fs *FileServer) serveListeners(server *http.Server) {
+	for _, l := range fs.Listeners {
+		go func(l net.Listener) {
+			var err error
+			if fs.SSL {
+				err = server.ServeTLS(l, "", "")
+			} else {
+				err = server.Serve(l)
+			}
+			if err != nil && err != http.ErrServerClosed {
+				fs.Logger.Errorf("Serving on %s: %+v", l.Addr(), err)
+			}
+		}(l)
 	}
 }
 
 // socket will handle the socket connection
 func (fs *FileServer) socket(w http.ResponseWriter, req *http.Request) {
-	mysock.ServeWS(fs.Hub, w, req)
+	_, watch := req.URL.Query()["activity"]
+	readOnly := func() bool {
+		return fs.readOnly(req)
+	}
+	mysock.ServeWS(fs.Hub, w, req, readOnly, watch && fs.activity != nil && !fs.readOnly(req))
 }
 
-// clipboardAdd will handle the add request for adding text to the clipboard
+// purgeClipboard will remove expired clipboard entries and tell the clients about it until done is closed
+func (fs *FileServer) purgeClipboard(done <-chan struct{}) {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			for _, channel := range fs.Clipboards.Purge() {
+				fs.Logger.Debugf("Clipboard entries of channel %s expired", channel)
+				fs.Hub.RefreshClipboard(channel)
+			}
+		}
+	}
+}
+
+// cbDown will send the clipboard as download, ?format= is json, txt or md and ?id= picks a single entry
 func (fs *FileServer) cbDown(w http.ResponseWriter, req *http.Request) {
-	filename := fmt.Sprintf("%+v-clipboard.json", int32(time.Now().Unix()))
+	cb := fs.Clipboards.Lookup(fs.listPrefs(w, req).Channel)
+	name := "clipboard"
+	if cb.Name() != myclipboard.DefaultChannel {
+		name += "-" + cb.Name()
+	}
+	format := req.URL.Query().Get("format")
+	if format == "" {
+		format = "json"
+	}
+
+	entries, err := cb.GetEntries()
+	if err != nil {
+		fs.handleError(w, req, err, http.StatusInternalServerError)
+		return
+	}
+	if id := req.URL.Query().Get("id"); id != "" {
+		iid, err := strconv.Atoi(id)
+		if err != nil {
+			fs.handleError(w, req, myclipboard.ErrNotFound, http.StatusNotFound)
+			return
+		}
+		entry, err := cb.GetEntry(iid)
+		if err != nil {
+			fs.handleError(w, req, err, http.StatusNotFound)
+			return
+		}
+		entries = []myclipboard.Entry{entry}
+		name += "-" + id
+	}
+	content, err := myclipboard.Encode(entries, format)
+	if err == myclipboard.ErrFormat {
+		fs.handleError(w, req, err, http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		fs.handleError(w, req, err, http.StatusInternalServerError)
+		return
+	}
+
+	filename := fmt.Sprintf("%+v-%s.%s", int32(time.Now().Unix()), name, format)
 	contentDisposition := fmt.Sprintf("attachment; filename=\"%s\"", filename)
 	// Handle as download
 	w.Header().Add("Content-Type", "application/octet-stream")
 	w.Header().Add("Content-Disposition", contentDisposition)
-	content, err := fs.Clipboard.Download()
-	if err != nil {
-		fs.handleError(w, req, err, 500)
-	}
-
 	if _, err := w.Write(content); err != nil {
-		mylog.Errorf("Error writing response to browser: %+v", err)
+		fs.Logger.Errorf("Error writing response to browser: %+v", err)
 	}
 }
 
@@ -232,11 +877,10 @@ func (fs *FileServer) static(w http.ResponseWriter, req *http.Request) {
 	// Check which file to serve
 	upath := req.URL.Path
 	staticPath := strings.SplitAfterN(upath, "/", 3)[2]
-	path := "static/" + staticPath
-	// Load file with parcello
-	staticFile, err := static.ReadFile(path)
+	// Load file, overrides from disk take precedence
+	staticFile, err := fs.readStatic(staticPath)
 	if err != nil {
-		mylog.Errorf("static file: %+v cannot be loaded: %+v", path, err)
+		fs.Logger.Errorf("static file: %+v cannot be loaded: %+v", staticPath, err)
 	}
 
 	// Get mimetype from extension
@@ -245,7 +889,7 @@ func (fs *FileServer) static(w http.ResponseWriter, req *http.Request) {
 	// Set mimetype and deliver to browser
 	w.Header().Add("Content-Type", contentType)
 	if _, err := w.Write(staticFile); err != nil {
-		mylog.Errorf("Error writing response to browser: %+v", err)
+		fs.Logger.Errorf("Error writing response to browser: %+v", err)
 	}
 }
 
@@ -254,17 +898,18 @@ func (fs *FileServer) handler(w http.ResponseWriter, req *http.Request) {
 	// Get url so you can extract Headline and title
 	upath := req.URL.Path
 
-	// Ignore default browser call to /favicon.ico
-	if upath == "/favicon.ico" {
+	// Default browser call to /favicon.ico, the embedded one would give away goshs in stealth mode
+	if upath == "/favicon.ico" && (fs.Favicon != "" || !fs.Stealth) {
+		fs.favicon(w, req)
 		return
 	}
 	upath = path.Clean(upath)
 	upath = filepath.Clean(upath)
 
-	mylog.Debugf("Cleaned upath is: %+v", upath)
+	fs.Logger.Debugf("Cleaned upath is: %+v", upath)
 
 	// Define absolute path
-	open := fs.Webroot + upath
+	open := fs.abs(upath)
 
 	// Check if you are in a dir
 	// disable G304 (CWE-22): Potential file inclusion via variable
@@ -281,7 +926,7 @@ func (fs *FileServer) handler(w http.ResponseWriter, req *http.Request) {
 	}
 	if err != nil {
 		// Handle general error
-		mylog.Info(err)
+		fs.Logger.Info(err)
 		return
 	}
 	// disable G307 (CWE-703): Deferring unsafe method "Close" on type "*os.File"
@@ -289,12 +934,30 @@ func (fs *FileServer) handler(w http.ResponseWriter, req *http.Request) {
 	defer file.Close()
 
 	// Log request
-	mylog.LogRequest(req, http.StatusOK)
+	fs.Logger.LogRequest(req, http.StatusOK)
 
 	// Switch and check if dir
 	stat, _ := file.Stat()
-	if stat.IsDir() {
+	if _, ok := req.URL.Query()["info"]; ok {
+		fs.info(w, req, file, upath)
+	} else if _, ok := req.URL.Query()["hash"]; ok && !stat.IsDir() {
+		fs.hash(w, req, file)
+	} else if _, ok := req.URL.Query()["search"]; ok && stat.IsDir() {
+		fs.search(w, req, upath)
+	} else if stat.IsDir() {
 		fs.processDir(w, req, file, upath)
+	} else if _, ok := req.URL.Query()["edit"]; ok {
+		fs.editFile(w, req, file, upath)
+	} else if _, ok := req.URL.Query()["preview"]; ok && isMarkdown(upath) {
+		fs.previewMarkdown(w, req, file, upath)
+	} else if _, ok := req.URL.Query()["view"]; ok && isPDF(upath) {
+		fs.viewPDF(w, req, upath)
+	} else if _, ok := req.URL.Query()["view"]; ok {
+		fs.viewCode(w, req, file, upath)
+	} else if _, ok := req.URL.Query()["thumbnail"]; ok && hasThumbnail(upath) {
+		fs.thumbnail(w, req, file)
+	} else if _, ok := req.URL.Query()["play"]; ok && mediaType(upath) != "" {
+		fs.play(w, req, upath)
 	} else {
 		fs.sendFile(w, req, file)
 	}
@@ -302,7 +965,7 @@ func (fs *FileServer) handler(w http.ResponseWriter, req *http.Request) {
 
 // upload handles the POST request to upload files
 func (fs *FileServer) upload(w http.ResponseWriter, req *http.Request) {
-	if fs.ReadOnly {
+	if fs.readOnly(req) {
 		fs.handleError(w, req, fmt.Errorf("%s", "Upload not allowed due to 'read only' option"), http.StatusForbidden)
 		return
 	}
@@ -316,7 +979,7 @@ func (fs *FileServer) upload(w http.ResponseWriter, req *http.Request) {
 
 	// Parse request
 	if err := req.ParseMultipartForm(10 << 20); err != nil {
-		mylog.Errorf("parsing multipart request: %+v", err)
+		fs.Logger.Errorf("parsing multipart request: %+v", err)
 		return
 	}
 
@@ -326,7 +989,7 @@ func (fs *FileServer) upload(w http.ResponseWriter, req *http.Request) {
 	for _, f := range m.File {
 		file, err := f[0].Open()
 		if err != nil {
-			mylog.Errorf("retrieving the file: %+v\n", err)
+			fs.Logger.Errorf("retrieving the file: %+v\n", err)
 		}
 		defer file.Close()
 
@@ -337,36 +1000,103 @@ func (fs *FileServer) upload(w http.ResponseWriter, req *http.Request) {
 		filenameClean := filenameSlice[len(filenameSlice)-1]
 
 		// Construct absolute savepath
-		savepath := fmt.Sprintf("%s%s/%s", fs.Webroot, target, filenameClean)
+		savepath := filepath.Join(fs.abs(target), filenameClean)
 
 		// Create file to write to
 		// disable G304 (CWE-22): Potential file inclusion via variable
 		// as we want a file inclusion here
 		// #nosec G304
 		if _, err := os.Create(savepath); err != nil {
-			mylog.Errorf("Not able to create file on disk")
+			fs.Logger.Errorf("Not able to create file on disk")
 			fs.handleError(w, req, err, http.StatusInternalServerError)
 		}
 
 		// Read file from post body
 		fileBytes, err := ioutil.ReadAll(file)
 		if err != nil {
-			mylog.Errorf("Not able to read file from request")
+			fs.Logger.Errorf("Not able to read file from request")
 			fs.handleError(w, req, err, http.StatusInternalServerError)
 		}
 
 		// Write file to disk
 		if err := ioutil.WriteFile(savepath, fileBytes, os.ModePerm); err != nil {
-			mylog.Errorf("Not able to write file to disk")
+			fs.Logger.Errorf("Not able to write file to disk")
 			fs.handleError(w, req, err, http.StatusInternalServerError)
+		} else {
+			fs.notify(mywebhook.EventUpload, req, http.StatusOK, path.Join("/", target, filenameClean), savepath)
 		}
 	}
 
 	// Log request
-	mylog.LogRequest(req, http.StatusOK)
+	fs.Logger.LogRequest(req, http.StatusOK)
 
 	// Redirect back from where we came from
-	http.Redirect(w, req, target, http.StatusSeeOther)
+	http.Redirect(w, req, fs.Prefix+target, http.StatusSeeOther)
+}
+
+// delete handles the DELETE request to remove a file or a directory with its content
+func (fs *FileServer) delete(w http.ResponseWriter, req *http.Request) {
+	if fs.readOnly(req) {
+		fs.handleError(w, req, fmt.Errorf("%s", "Delete not allowed due to 'read only' option"), http.StatusForbidden)
+		return
+	}
+	if fs.uploadOnly(req) {
+		fs.handleError(w, req, fmt.Errorf("%s", "Delete not allowed due to 'upload only' option"), http.StatusForbidden)
+		return
+	}
+
+	// Sanitize path (No path traversal)
+	upath := path.Clean("/" + req.URL.Path)
+	if upath == "/" {
+		fs.handleError(w, req, fmt.Errorf("%s", "The webroot cannot be deleted"), http.StatusBadRequest)
+		return
+	}
+	if fs.Mounts.IsMountPoint(upath) {
+		fs.handleError(w, req, fmt.Errorf("%s", "A mounted directory cannot be deleted"), http.StatusBadRequest)
+		return
+	}
+	target := fs.abs(upath)
+
+	if _, err := os.Lstat(target); err != nil {
+		if os.IsNotExist(err) {
+			fs.handleError(w, req, err, http.StatusNotFound)
+			return
+		}
+		fs.handleError(w, req, err, http.StatusInternalServerError)
+		return
+	}
+	if err := os.RemoveAll(target); err != nil {
+		fs.Logger.Errorf("Not able to delete %s: %+v", target, err)
+		fs.handleError(w, req, err, http.StatusInternalServerError)
+		return
+	}
+
+	fs.Logger.LogRequest(req, http.StatusNoContent)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// mkdir handles the MKCOL request to create a directory including its parents
+func (fs *FileServer) mkdir(w http.ResponseWriter, req *http.Request) {
+	if fs.readOnly(req) {
+		fs.handleError(w, req, fmt.Errorf("%s", "Creating directories not allowed due to 'read only' option"), http.StatusForbidden)
+		return
+	}
+
+	// Sanitize path (No path traversal)
+	upath := path.Clean("/" + req.URL.Path)
+	target := fs.abs(upath)
+	if _, err := os.Lstat(target); err == nil {
+		fs.handleError(w, req, fmt.Errorf("%s already exists", upath), http.StatusConflict)
+		return
+	}
+	if err := os.MkdirAll(target, 0750); err != nil {
+		fs.Logger.Errorf("Not able to create directory %s: %+v", target, err)
+		fs.handleError(w, req, fs.hidePaths(err), http.StatusInternalServerError)
+		return
+	}
+
+	fs.Logger.LogRequest(req, http.StatusCreated)
+	w.WriteHeader(http.StatusCreated)
 }
 
 // bulkDownload will provide zip archived download bundle of multiple selected files
@@ -393,11 +1123,15 @@ func (fs *FileServer) bulkDownload(w http.ResponseWriter, req *http.Request) {
 			// Just skip this file
 			continue
 		}
+		// Files of upload only mounts cannot be downloaded either
+		if fs.Mounts.UploadOnly(fileCleaned) {
+			continue
+		}
 		filesCleaned = append(filesCleaned, fileCleaned)
 	}
 
 	// Construct filename to download
-	filename := fmt.Sprintf("%+v_goshs_download.zip", int32(time.Now().Unix()))
+	filename := fs.disguise(fmt.Sprintf("%+v_goshs_download.zip", int32(time.Now().Unix())))
 
 	// Set header and serve file
 	contentDispo := fmt.Sprintf("attachment; filename=\"%s\"", filename)
@@ -410,7 +1144,11 @@ func (fs *FileServer) bulkDownload(w http.ResponseWriter, req *http.Request) {
 	resultZip := zip.NewWriter(w)
 	defer resultZip.Close()
 
-	// Path walker for recursion
+	// Path walker for recursion, it starts at walkRoot on disk which is walkPath below the webroot
+	var walkRoot, walkPath string
+	// reserved are the files counted towards MaxServes, failed tells whether the zip is incomplete
+	var reserved []string
+	failed := false
 	walker := func(filepath string, info os.FileInfo, err error) error {
 		if err != nil {
 			return err
@@ -419,6 +1157,17 @@ func (fs *FileServer) bulkDownload(w http.ResponseWriter, req *http.Request) {
 			return nil
 		}
 
+		// filepath is the directory on disk + file relative path
+		// this would result in a lot of nested folders
+		// so we are stripping the directory on disk again from the structure of the zip file
+		// Leaving us with the relative path of the file
+		zippath := path.Join(walkPath, strings.TrimPrefix(filepath, walkRoot))
+		if !fs.reserveServe(zippath) {
+			fs.Logger.Warnf("Leaving %s out of the zip file as it was served the maximum number of times", zippath)
+			return nil
+		}
+		reserved = append(reserved, zippath)
+
 		// disable G304 (CWE-22): Potential file inclusion via variable
 		// as we want a file inclusion here
 		// #nosec G304
@@ -430,12 +1179,7 @@ func (fs *FileServer) bulkDownload(w http.ResponseWriter, req *http.Request) {
 		// #nosec G307
 		defer file.Close()
 
-		// filepath is fs.Webroot + file relative path
-		// this would result in a lot of nested folders
-		// so we are stripping fs.Webroot again from the structure of the zip file
-		// Leaving us with the relative path of the file
-		zippath := strings.ReplaceAll(filepath, fs.Webroot, "")
-		f, err := resultZip.Create(zippath[1:])
+		f, err := resultZip.Create(strings.TrimPrefix(zippath, "/"))
 		if err != nil {
 			return err
 		}
@@ -449,16 +1193,55 @@ func (fs *FileServer) bulkDownload(w http.ResponseWriter, req *http.Request) {
 	}
 
 	// Loop over files and add to zip
-	for _, file := range filesCleaned {
-		err := filepath.Walk(path.Join(fs.Webroot, file), walker)
+	for _, walkPath = range filesCleaned {
+		walkRoot = fs.abs(walkPath)
+		err := filepath.Walk(walkRoot, walker)
 		if err != nil {
-			mylog.Errorf("creating zip file: %+v", err)
+			failed = true
+			fs.Logger.Errorf("creating zip file: %+v", err)
 		}
 	}
 
 	// Close Zip Writer and Flush to http.ResponseWriter
 	if err := resultZip.Close(); err != nil {
-		mylog.Error(err)
+		failed = true
+		fs.Logger.Error(err)
+	}
+	for _, upath := range reserved {
+		fs.served(upath, !failed)
+	}
+}
+
+// fillItem will set the display fields of an item, which is only done for the items of the rendered page
+func (fs *FileServer) fillItem(item *item, relpath string) {
+	fi := item.fi
+	item.Ext = strings.ToLower(myutils.ReturnExt(fi.Name()))
+	if item.IsDir {
+		item.Ext = ""
+	}
+	item.URI = url.PathEscape(path.Join(relpath, fi.Name()))
+	item.Editable = !item.IsDir && fi.Size() <= maxEditSize
+	item.IsMarkdown = !item.IsDir && isMarkdown(fi.Name())
+	item.IsCode = !item.IsDir && fi.Size() <= maxCodeSize && hasLexer(fi.Name())
+	item.HasThumbnail = !item.IsDir && hasThumbnail(fi.Name())
+	item.IsPDF = !item.IsDir && isPDF(fi.Name())
+	if !item.IsDir {
+		item.Media = mediaType(fi.Name())
+	}
+	item.DisplaySize = myutils.ByteCountDecimal(fi.Size())
+	item.DisplayLastModified = fi.ModTime().Format("Mon Jan _2 15:04:05 2006")
+	if fs.Permissions {
+		item.Mode = fi.Mode().String()
+		item.Owner, item.Group = fileOwner(fi)
+	}
+	// Check and resolve symlink
+	if fi.Mode()&os.ModeSymlink != 0 {
+		var err error
+		item.IsSymlink = true
+		item.SymlinkTarget, err = os.Readlink(fs.abs(path.Join(relpath, fi.Name())))
+		if err != nil {
+			fs.Logger.Errorf("resolving symlink: %+v", err)
+		}
 	}
 }
 
@@ -469,15 +1252,21 @@ func (fs *FileServer) processDir(w http.ResponseWriter, req *http.Request, file
 		fs.handleError(w, req, err, http.StatusNotFound)
 		return
 	}
+	fis = fs.Mounts.List(relpath, fis)
+
+	prefs := fs.listPrefs(w, req)
 
 	// Create empty slice
 	items := make([]item, 0, len(fis))
+	readmeName := ""
 	// Iterate over FileInfo of dir
 	for _, fi := range fis {
-		item := item{}
+		if !prefs.Hidden && strings.HasPrefix(fi.Name(), ".") {
+			continue
+		}
+		item := item{fi: fi}
 		// Need to set this up here for directories to work
 		item.Name = fi.Name()
-		item.Ext = strings.ToLower(myutils.ReturnExt(fi.Name()))
 		// Add / to name if dir
 		if fi.IsDir() {
 			// Check if special path exists as dir on disk and do not add
@@ -486,35 +1275,50 @@ func (fs *FileServer) processDir(w http.ResponseWriter, req *http.Request, file
 			}
 			item.Name += "/"
 			item.IsDir = true
-			item.Ext = ""
 		}
-		// Set item fields
-		item.URI = url.PathEscape(path.Join(relpath, fi.Name()))
-		item.DisplaySize = myutils.ByteCountDecimal(fi.Size())
+		if !item.IsDir && strings.EqualFold(fi.Name(), "readme.md") {
+			readmeName = fi.Name()
+		}
 		item.SortSize = fi.Size()
-		item.DisplayLastModified = fi.ModTime().Format("Mon Jan _2 15:04:05 2006")
 		item.SortLastModified = fi.ModTime()
-		// Check and resolve symlink
-		if fi.Mode()&os.ModeSymlink != 0 {
-			item.IsSymlink = true
-			item.SymlinkTarget, err = os.Readlink(path.Join(fs.Webroot, relpath, fi.Name()))
-			if err != nil {
-				mylog.Errorf("resolving symlink: %+v", err)
-			}
-		}
 		// Add to items slice
 		items = append(items, item)
 	}
 
-	// Sort slice all lowercase
-	sort.Slice(items, func(i, j int) bool {
-		return strings.ToLower(items[i].Name) < strings.ToLower(items[j].Name)
-	})
+	// Totals of the whole directory, not only the shown page
+	var files, dirs int
+	var totalSize int64
+	for _, item := range items {
+		if item.IsDir {
+			dirs++
+			continue
+		}
+		files++
+		totalSize += item.SortSize
+	}
+
+	// Sort slice as preferred
+	sortItems(items, prefs)
+
+	// Only the requested page gets rendered
+	page, pages := paginate(req, len(items), prefs.Limit)
+	if prefs.Limit > 0 {
+		end := page * prefs.Limit
+		if end > len(items) {
+			end = len(items)
+		}
+		items = items[(page-1)*prefs.Limit : end]
+	}
+
+	// Set item fields
+	for i := range items {
+		fs.fillItem(&items[i], relpath)
+	}
 
 	// Template parsing and writing to browser
-	indexFile, err := static.ReadFile("static/templates/index.html")
+	indexFile, err := fs.readStatic("templates/index.html")
 	if err != nil {
-		mylog.Errorf("opening embedded file: %+v", err)
+		fs.Logger.Errorf("opening embedded file: %+v", err)
 	}
 
 	// Windows upload compatibility
@@ -524,75 +1328,196 @@ func (fs *FileServer) processDir(w http.ResponseWriter, req *http.Request, file
 
 	// Construct directory for template
 	d := &directory{
-		RelPath: relpath,
-		AbsPath: filepath.Join(fs.Webroot, relpath),
-		Content: items,
-	}
-	if relpath != "/" {
-		d.IsSubdirectory = true
-		pathSlice := strings.Split(relpath, "/")
-		if len(pathSlice) > 2 {
-			pathSlice = pathSlice[1 : len(pathSlice)-1]
-
-			var backString string
-			for _, part := range pathSlice {
-				backString += "/" + part
-			}
-			d.Back = backString
-		} else {
-			d.Back = "/"
+		RelPath:   relpath,
+		AbsPath:   fs.abs(relpath),
+		Files:     files,
+		Dirs:      dirs,
+		TotalSize: myutils.ByteCountDecimal(totalSize),
+		Content:   items,
+	}
+	for _, i := range items {
+		if i.HasThumbnail {
+			d.HasImages = true
+			break
 		}
-	} else {
-		d.IsSubdirectory = false
+	}
+	// Breadcrumbs of all parent directories
+	parent := ""
+	for _, part := range strings.Split(strings.Trim(relpath, "/"), "/") {
+		if part == "" {
+			continue
+		}
+		parent += "/" + part
+		d.Breadcrumbs = append(d.Breadcrumbs, breadcrumb{Name: part, Path: parent})
 	}
 
 	// upload only mode empty directory
-	if fs.UploadOnly {
+	if fs.uploadOnly(req) {
 		d = &directory{}
 	}
 
+	// Free space tells uploaders whether their files fit
+	if free, total, err := myutils.DiskFree(fs.abs(relpath)); err == nil {
+		d.DiskFree = myutils.ByteCountDecimal(int64(free))
+		d.DiskTotal = myutils.ByteCountDecimal(int64(total))
+	} else {
+		fs.Logger.Debugf("reading free disk space: %+v", err)
+	}
+
+	// A new channel exists once written to, until then only the one who chose it sees it
+	cb := fs.Clipboards.Lookup(prefs.Channel)
+	channels := fs.Clipboards.Names()
+	if i := sort.SearchStrings(channels, cb.Name()); i == len(channels) || channels[i] != cb.Name() {
+		channels = append(channels, cb.Name())
+		sort.Strings(channels)
+	}
+
 	// Construct template
 	tem := &indexTemplate{
+		Prefix:       fs.Prefix,
 		Directory:    d,
 		GoshsVersion: fs.Version,
-		Clipboard:    fs.Clipboard,
+		Clipboard:    cb,
+		Channels:     channels,
+		PublicURL:    fs.PublicURL,
+		QRPath:       fs.disguise(fs.Prefix + qrPath),
+		ShareURL:     fs.shareURL(req, relpath),
+		ReadOnly:     fs.readOnly(req),
+		Permissions:  fs.Permissions,
+		Banner:       fs.banner(),
+		Prefs:        prefs,
+		Page:         page,
+		Pages:        pages,
+	}
+	if page > 1 {
+		tem.PrevPage = page - 1
+	}
+	if page < pages {
+		tem.NextPage = page + 1
+	}
+	if readmeName != "" && !fs.uploadOnly(req) {
+		tem.Readme = fs.readme(filepath.Join(fs.abs(relpath), readmeName))
+	}
+	if fs.Monitor != nil && !tem.ReadOnly {
+		tem.StatusPath = fs.disguise(fs.Prefix + statusPath)
+	}
+	if fs.activity != nil && !tem.ReadOnly {
+		tem.ActivityPath = fs.disguise(fs.Prefix + activityPath)
+	}
+	if fs.SSL && fs.SelfSigned {
+		tem.CAPath = fs.disguise(fs.Prefix + caPath)
+	}
+	if user, _ := req.Context().Value(ctxUser).(string); fs.AnonymousRead && user == "" {
+		tem.LoginPath = fs.disguise(fs.Prefix + loginPath)
+		if fs.OIDC != nil {
+			tem.LoginPath = fs.Prefix + oidcPath + "/login?next=" + url.QueryEscape(fs.Prefix+relpath)
+		}
+	}
+
+	entries, _ := tem.Clipboard.GetEntries()
+	for _, e := range entries {
+		if e.Language != "" && !e.Encrypted {
+			tem.SnippetCSS = fs.snippetCSS()
+			break
+		}
 	}
 
-	t := template.New("index")
+	t := template.New("index").Funcs(template.FuncMap{"highlight": fs.highlightSnippet})
 	if _, err := t.Parse(string(indexFile)); err != nil {
-		mylog.Errorf("Error parsing template: %+v", err)
+		fs.Logger.Errorf("Error parsing template: %+v", err)
 	}
 	if err := t.Execute(w, tem); err != nil {
-		mylog.Errorf("Error executing template: %+v", err)
+		fs.Logger.Errorf("Error executing template: %+v", err)
 	}
 }
 
 func (fs *FileServer) sendFile(w http.ResponseWriter, req *http.Request, file *os.File) {
-	if fs.UploadOnly {
+	if fs.uploadOnly(req) {
 		fs.handleError(w, req, fmt.Errorf("%s", "Download not allowed due to 'upload only' option"), http.StatusForbidden)
 		return
 	}
+	stat, err := file.Stat()
+	if err != nil {
+		fs.handleError(w, req, err, http.StatusInternalServerError)
+		return
+	}
+	// HEAD requests do not transfer the file
+	upath := path.Clean("/" + req.URL.Path)
+	counted := req.Method == http.MethodGet
+	if counted && !fs.reserveServe(upath) {
+		fs.handleError(w, req, fmt.Errorf("%s", "Download not allowed as the file was served the maximum number of times"), http.StatusGone)
+		return
+	}
 	// Extract download parameter
 	download := req.URL.Query()
 	if _, ok := download["download"]; ok {
-		stat, err := file.Stat()
-		if err != nil {
-			mylog.Errorf("reading file stats for download: %+v", err)
-		}
 		contentDisposition := fmt.Sprintf("attachment; filename=\"%s\"", stat.Name())
 		// Handle as download
 		w.Header().Add("Content-Type", "application/octet-stream")
 		w.Header().Add("Content-Disposition", contentDisposition)
-		w.Header().Add("Content-Length", fmt.Sprintf("%d", stat.Size()))
-		if _, err := io.Copy(w, file); err != nil {
-			mylog.Errorf("Error writing response to browser: %+v", err)
+	}
+	// Write to browser, ServeContent handles range requests for seeking in media
+	cw := &countingWriter{ResponseWriter: w}
+	http.ServeContent(cw, req, stat.Name(), stat.ModTime(), file)
+	complete := counted && cw.written == stat.Size()
+	if counted {
+		fs.served(upath, complete)
+	}
+	// Only a body written to the end is reported as download
+	if complete {
+		fs.notify(mywebhook.EventDownload, req, http.StatusOK, req.URL.Path, file.Name())
+	}
+}
+
+// notify will record the event in the report, show it on the desktop and fire the webhook for req if there are any
+func (fs *FileServer) notify(event string, req *http.Request, status int, upath, file string) {
+	switch event {
+	case mywebhook.EventDownload:
+		if fs.Report != nil {
+			fs.Report.Served(req.RemoteAddr, upath, file)
 		}
-	} else {
-		// Write to browser
-		if _, err := io.Copy(w, file); err != nil {
-			mylog.Errorf("Error writing response to browser: %+v", err)
+		if fs.Desktop != nil {
+			fs.Desktop.Download(req.RemoteAddr, upath)
+		}
+	case mywebhook.EventUpload:
+		if fs.Report != nil {
+			fs.Report.Received(req.RemoteAddr, upath, file)
+		}
+		if fs.Desktop != nil {
+			fs.Desktop.Upload(req.RemoteAddr, upath)
 		}
 	}
+	if fs.Webhook == nil {
+		return
+	}
+	user, _ := req.Context().Value(ctxUser).(string)
+	fs.Webhook.Fire(mywebhook.Event{
+		Event:      event,
+		RemoteAddr: req.RemoteAddr,
+		Method:     req.Method,
+		Path:       upath,
+		Status:     status,
+		User:       user,
+		UserAgent:  req.UserAgent(),
+	})
+}
+
+// wsTransferred logs a file transfer via websocket like a request and notifies about a successful one
+func (fs *FileServer) wsTransferred(req *http.Request, method, upath, file string, status int) {
+	r := req.Clone(req.Context())
+	r.Method = method
+	r.URL = &url.URL{Path: upath}
+	r.RequestURI = upath
+	fs.Logger.LogRequest(r, status)
+	mylog.RunRequestHooks(r, status)
+	if status != http.StatusOK {
+		return
+	}
+	event := mywebhook.EventDownload
+	if method == http.MethodPut {
+		event = mywebhook.EventUpload
+	}
+	fs.notify(event, r, status, upath, file)
 }
 
 func (fs *FileServer) handleError(w http.ResponseWriter, req *http.Request, err error, status int) {
@@ -603,42 +1528,60 @@ func (fs *FileServer) handleError(w http.ResponseWriter, req *http.Request, err
 	var e httperror
 
 	// Log to console
-	mylog.LogRequest(req, status)
+	fs.Logger.LogRequest(req, status)
 
 	// Construct error for template filling
 	e.ErrorCode = status
 	e.ErrorMessage = err.Error()
-	e.AbsPath = path.Join(fs.Webroot, req.URL.Path)
+	e.AbsPath = fs.abs(req.URL.Path)
 	e.GoshsVersion = fs.Version
+	e.Prefix = fs.Prefix
 
 	// Template handling
-	file, err := static.ReadFile("static/templates/error.html")
+	file, err := fs.readStatic("templates/error.html")
 	if err != nil {
-		mylog.Errorf("opening embedded file: %+v", err)
+		fs.Logger.Errorf("opening embedded file: %+v", err)
 	}
 	t := template.New("error")
 	if _, err := t.Parse(string(file)); err != nil {
-		mylog.Errorf("parsing the template: %+v", err)
+		fs.Logger.Errorf("parsing the template: %+v", err)
 	}
 	if err := t.Execute(w, e); err != nil {
-		mylog.Errorf("executing the template: %+v", err)
+		fs.Logger.Errorf("executing the template: %+v", err)
 	}
 }
 
 func (fs *FileServer) logStart(what string) {
-	var interfaceAdresses map[string]string
-	var err error
 	if what == modeWeb {
-		if fs.IP == "0.0.0.0" {
-			interfaceAdresses, err = myutils.GetAllIPAdresses()
-			if err != nil {
-				mylog.Errorf("There has been an error fetching the interface addresses: %+v\n", err)
+		if fs.IP == "0.0.0.0" || fs.IP == "::" {
+			// The ipv6 wildcard serves ipv4 and ipv6
+			families := []bool{false}
+			if fs.IP == "::" {
+				families = append(families, true)
 			}
-			for k, v := range interfaceAdresses {
-				mylog.Infof("Serving on interface %s bound to %s:%+v\n", k, v, fs.Port)
+			for _, ipv6 := range families {
+				interfaceAdresses, err := myutils.GetAllIPAdresses(ipv6)
+				if err != nil {
+					fs.Logger.Errorf("There has been an error fetching the interface addresses: %+v\n", err)
+				}
+				for k, v := range interfaceAdresses {
+					fs.Logger.Infof("Serving on interface %s bound to %s%s/\n", k, net.JoinHostPort(v, strconv.Itoa(fs.Port)), fs.Prefix)
+				}
 			}
 		} else {
-			mylog.Infof("Serving on %s:%+v\n", fs.IP, fs.Port)
+			fs.Logger.Infof("Serving on %s%s/\n", fs.address(fs.Port), fs.Prefix)
+		}
+		if fs.Prefix != "" {
+			fs.Logger.Infof("All routes are only reachable below the secret prefix %s/", fs.Prefix)
+		}
+		if fs.API {
+			fs.Logger.Infof("Serving the JSON API below %s%s/", fs.Prefix, apiPath)
+		}
+		if fs.ProxyProtocol {
+			fs.Logger.Info("Accepting the PROXY protocol, client addresses are taken from the header")
+		}
+		if fs.WebdavMount {
+			fs.Logger.Infof("Serving WEBDAV on the same port below %s%s/", fs.Prefix, webdavPath)
 		}
 	}
 
@@ -652,35 +1595,51 @@ func (fs *FileServer) logStart(what string) {
 		if fs.SSL {
 			// Check if selfsigned
 			if fs.SelfSigned {
-				mylog.Infof("Serving %s from %+v with ssl enabled and self-signed certificate\n", protocol, fs.Webroot)
-				mylog.Warn("Be sure to check the fingerprint of certificate")
-				mylog.Infof("SHA-256 Fingerprint: %+v\n", fs.Fingerprint256)
-				mylog.Infof("SHA-1   Fingerprint: %+v\n", fs.Fingerprint1)
+				fs.Logger.Infof("Serving %s from %+v with ssl enabled and self-signed certificate\n", protocol, fs.Webroot)
+				fs.Logger.Warn("Be sure to check the fingerprint of certificate")
+				fs.Logger.Infof("SHA-256 Fingerprint: %+v\n", fs.Fingerprint256)
+				fs.Logger.Infof("SHA-1   Fingerprint: %+v\n", fs.Fingerprint1)
+				fs.Logger.Infof("Download the CA to trust the server from %s/ca.pem (also ca.der, cert.pem and cert.der)", fs.disguise(fs.Prefix+caPath))
 			} else {
-				mylog.Infof("Serving %s from %+v with ssl enabled server key: %+v, server cert: %+v\n", protocol, fs.Webroot, fs.MyKey, fs.MyCert)
-				mylog.Info("You provided a certificate and might want to check the fingerprint nonetheless")
-				mylog.Infof("SHA-256 Fingerprint: %+v\n", fs.Fingerprint256)
-				mylog.Infof("SHA-1   Fingerprint: %+v\n", fs.Fingerprint1)
+				fs.Logger.Infof("Serving %s from %+v with ssl enabled server key: %+v, server cert: %+v\n", protocol, fs.Webroot, fs.MyKey, fs.MyCert)
+				fs.Logger.Info("You provided a certificate and might want to check the fingerprint nonetheless")
+				fs.Logger.Infof("SHA-256 Fingerprint: %+v\n", fs.Fingerprint256)
+				fs.Logger.Infof("SHA-1   Fingerprint: %+v\n", fs.Fingerprint1)
 			}
 		} else {
-			mylog.Infof("Serving %s from %+v\n", protocol, fs.Webroot)
+			fs.Logger.Infof("Serving %s from %+v\n", protocol, fs.Webroot)
+		}
+		for _, m := range fs.Mounts {
+			mode := ""
+			if m.ReadOnly {
+				mode = " read only"
+			} else if m.UploadOnly {
+				mode = " upload only"
+			}
+			fs.Logger.Infof("Serving %s below %s%s/%s", m.Dir, fs.Prefix, m.Path, mode)
+		}
+		if fs.File != "" {
+			fs.Logger.Infof("Serving only %s at %s/", filepath.Base(fs.File), fs.Prefix)
+		}
+		if fs.Receive != nil {
+			fs.Logger.Infof("Receiving a single file by PUT or POST to %s/", fs.Prefix)
 		}
 	case "webdav":
 		if fs.SSL {
 			// Check if selfsigned
 			if fs.SelfSigned {
-				mylog.Infof("Serving WEBDAV on %+v:%+v from %+v with ssl enabled and self-signed certificate\n", fs.IP, fs.WebdavPort, fs.Webroot)
-				mylog.Warn("WARNING! Be sure to check the fingerprint of certificate")
-				mylog.Infof("SHA-256 Fingerprint: %+v\n", fs.Fingerprint256)
-				mylog.Infof("SHA-1   Fingerprint: %+v\n", fs.Fingerprint1)
+				fs.Logger.Infof("Serving WEBDAV on %+v from %+v with ssl enabled and self-signed certificate\n", fs.address(fs.WebdavPort), fs.Webroot)
+				fs.Logger.Warn("WARNING! Be sure to check the fingerprint of certificate")
+				fs.Logger.Infof("SHA-256 Fingerprint: %+v\n", fs.Fingerprint256)
+				fs.Logger.Infof("SHA-1   Fingerprint: %+v\n", fs.Fingerprint1)
 			} else {
-				mylog.Infof("Serving WEBDAV on %+v:%+v from %+v with ssl enabled server key: %+v, server cert: %+v\n", fs.IP, fs.WebdavPort, fs.Webroot, fs.MyKey, fs.MyCert)
-				mylog.Info("INFO! You provided a certificate and might want to check the fingerprint nonetheless")
-				mylog.Infof("SHA-256 Fingerprint: %+v\n", fs.Fingerprint256)
-				mylog.Infof("SHA-1   Fingerprint: %+v\n", fs.Fingerprint1)
+				fs.Logger.Infof("Serving WEBDAV on %+v from %+v with ssl enabled server key: %+v, server cert: %+v\n", fs.address(fs.WebdavPort), fs.Webroot, fs.MyKey, fs.MyCert)
+				fs.Logger.Info("INFO! You provided a certificate and might want to check the fingerprint nonetheless")
+				fs.Logger.Infof("SHA-256 Fingerprint: %+v\n", fs.Fingerprint256)
+				fs.Logger.Infof("SHA-1   Fingerprint: %+v\n", fs.Fingerprint1)
 			}
 		} else {
-			mylog.Infof("Serving WEBDAV on %+v:%+v from %+v\n", fs.IP, fs.WebdavPort, fs.Webroot)
+			fs.Logger.Infof("Serving WEBDAV on %+v from %+v\n", fs.address(fs.WebdavPort), fs.Webroot)
 		}
 	default:
 	}
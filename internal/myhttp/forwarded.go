@@ -0,0 +1,73 @@
+package myhttp
+
+import (
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/patrickhener/goshs/internal/myutils"
+)
+
+// forwarded will take the client address from the Forwarded or X-Forwarded-For header
+// if the request comes from a trusted proxy, so logs and ip based controls see the client
+func (fs *FileServer) forwarded(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if ip, port := fs.forwardedFor(r); ip != "" {
+			r.RemoteAddr = net.JoinHostPort(ip, port)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// forwardedFor returns the client address behind the trusted proxies, empty if there is none
+func (fs *FileServer) forwardedFor(r *http.Request) (string, string) {
+	if !myutils.InNetworks(fs.clientIP(r), fs.TrustedProxies) {
+		return "", ""
+	}
+
+	var hops []string
+	if values := r.Header.Values("Forwarded"); len(values) > 0 {
+		for _, element := range strings.Split(strings.Join(values, ","), ",") {
+			for _, pair := range strings.Split(element, ";") {
+				if kv := strings.SplitN(strings.TrimSpace(pair), "=", 2); len(kv) == 2 && strings.EqualFold(kv[0], "for") {
+					hops = append(hops, strings.Trim(kv[1], `"`))
+				}
+			}
+		}
+	} else {
+		for _, hop := range strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",") {
+			if hop = strings.TrimSpace(hop); hop != "" {
+				hops = append(hops, hop)
+			}
+		}
+	}
+
+	// Every proxy appends the address it got the request from, so the client is
+	// the last one not added by a trusted proxy. Anything else could be forged.
+	ip, port := "", "0"
+	for i := len(hops) - 1; i >= 0; i-- {
+		hopIP, hopPort := splitHop(hops[i])
+		if net.ParseIP(hopIP) == nil {
+			// unknown or an obfuscated identifier, the last proxy is as far as we get
+			break
+		}
+		ip, port = hopIP, hopPort
+		if !myutils.InNetworks(hopIP, fs.TrustedProxies) {
+			break
+		}
+	}
+	return ip, port
+}
+
+// splitHop returns ip and port of a hop like 192.0.2.1, "[2001:db8::1]:4711" or 2001:db8::1
+func splitHop(hop string) (string, string) {
+	if host, port, err := net.SplitHostPort(hop); err == nil {
+		// Obfuscated ports like _abc are of no use
+		if _, err := strconv.ParseUint(port, 10, 16); err != nil {
+			port = "0"
+		}
+		return host, port
+	}
+	return strings.Trim(hop, "[]"), "0"
+}
@@ -0,0 +1,24 @@
+package myhttp
+
+import "net/http"
+
+const hstsMaxAge = "max-age=31536000; includeSubDomains"
+
+// SecurityHeadersMiddleware will add the configured security headers to all responses
+func (fs *FileServer) SecurityHeadersMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h := w.Header()
+		// Browsers ignore HSTS received via plain HTTP
+		if fs.HSTS && r.TLS != nil {
+			h.Set("Strict-Transport-Security", hstsMaxAge)
+		}
+		if fs.SecurityHeaders {
+			h.Set("X-Content-Type-Options", "nosniff")
+			h.Set("X-Frame-Options", "SAMEORIGIN")
+		}
+		if fs.CSP != "" {
+			h.Set("Content-Security-Policy", fs.CSP)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,86 @@
+package myhttp
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+
+	"github.com/patrickhener/goshs/internal/mylog"
+	"github.com/pkg/sftp"
+)
+
+// hooked will pass every request with its final status to the request hooks once, a handler
+// may log a request several times on its way
+func (fs *FileServer) hooked(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rec := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(rec, r)
+		if rec.status == 0 {
+			rec.status = http.StatusOK
+		}
+		mylog.RunRequestHooks(r, rec.status)
+	})
+}
+
+// sftpLogged passes an SFTP request to the request hooks like a request to the web interface
+func (fs *FileServer) sftpLogged(remote, method, p string, err error) {
+	status := http.StatusOK
+	switch {
+	case err == nil:
+	case os.IsNotExist(err) || errors.Is(err, sftp.ErrSSHFxNoSuchFile):
+		status = http.StatusNotFound
+	case os.IsPermission(err) || errors.Is(err, sftp.ErrSSHFxPermissionDenied):
+		status = http.StatusForbidden
+	default:
+		status = http.StatusInternalServerError
+	}
+	mylog.RunRequestHooks(&http.Request{
+		Method:     method,
+		URL:        &url.URL{Scheme: "sftp", Path: p},
+		Proto:      "SFTP",
+		Header:     http.Header{},
+		RemoteAddr: remote,
+	}, status)
+}
+
+// statusRecorder keeps the status of the response
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	if r.status == 0 {
+		r.status = status
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Write(p []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	return r.ResponseWriter.Write(p)
+}
+
+// Flush keeps streamed responses like the speedtest working
+func (r *statusRecorder) Flush() {
+	if f, ok := r.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack keeps websockets working, the connection is recorded as switching protocols
+func (r *statusRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := r.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("hijacking is not supported")
+	}
+	if r.status == 0 {
+		r.status = http.StatusSwitchingProtocols
+	}
+	return h.Hijack()
+}
@@ -0,0 +1,37 @@
+package myhttp
+
+import (
+	"crypto/tls"
+	"net/http"
+
+	"github.com/quic-go/quic-go/http3"
+)
+
+// newHTTP3 will prepare the QUIC listener on the udp port of addr
+func (fs *FileServer) newHTTP3(addr string, handler http.Handler, tlsConf *tls.Config) {
+	fs.h3 = &http3.Server{
+		Addr:      addr,
+		Handler:   handler,
+		TLSConfig: tlsConf,
+	}
+}
+
+// serveHTTP3 will serve the QUIC listener, a failure leaves the tcp listener running
+func (fs *FileServer) serveHTTP3() {
+	fs.Logger.Infof("Serving HTTP/3 (QUIC) on udp %s", fs.h3.Addr)
+	if err := fs.h3.ListenAndServe(); err != nil {
+		fs.Logger.Errorf("HTTP/3 listener stopped: %+v", err)
+	}
+}
+
+// AltSvcMiddleware will advertise the HTTP/3 listener to clients of the tcp listener
+func (fs *FileServer) AltSvcMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if fs.h3 != nil && r.ProtoMajor < 3 {
+			if err := fs.h3.SetQuicHeaders(w.Header()); err != nil {
+				fs.Logger.Debugf("setting Alt-Svc header: %+v", err)
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,107 @@
+package myhttp
+
+import (
+	"crypto/md5"  // #nosec G501 checksum for comparison only
+	"crypto/sha1" // #nosec G505 checksum for comparison only
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"time"
+
+	"github.com/patrickhener/goshs/internal/myutils"
+)
+
+type fileDetails struct {
+	Name    string    `json:"name"`
+	Path    string    `json:"path"`
+	IsDir   bool      `json:"is_dir"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mod_time"`
+	Mode    string    `json:"mode"`
+	Owner   string    `json:"owner,omitempty"`
+	Group   string    `json:"group,omitempty"`
+	MIME    string    `json:"mime,omitempty"`
+}
+
+type fileHash struct {
+	Algorithm string `json:"algorithm"`
+	Sum       string `json:"sum"`
+}
+
+var hashes = map[string]func() hash.Hash{
+	"md5":    md5.New,
+	"sha1":   sha1.New,
+	"sha256": sha256.New,
+}
+
+// detectMIME returns the mime type of file by its extension or else by its first bytes
+func detectMIME(file *os.File) (string, error) {
+	if t := mime.TypeByExtension(myutils.ReturnExt(file.Name())); t != "" {
+		return t, nil
+	}
+	buf := make([]byte, 512)
+	n, err := file.Read(buf)
+	if err != nil && err != io.EOF {
+		return "", err
+	}
+	return http.DetectContentType(buf[:n]), nil
+}
+
+// info will send the details of the file or directory at relpath as json
+func (fs *FileServer) info(w http.ResponseWriter, req *http.Request, file *os.File, relpath string) {
+	if fs.uploadOnly(req) {
+		fs.handleError(w, req, fmt.Errorf("%s", "Details not allowed due to 'upload only' option"), http.StatusForbidden)
+		return
+	}
+
+	fi, err := file.Stat()
+	if err != nil {
+		fs.handleError(w, req, err, http.StatusInternalServerError)
+		return
+	}
+	relpath = path.Clean("/" + filepath.ToSlash(relpath))
+	d := fileDetails{
+		Name:    fi.Name(),
+		Path:    relpath,
+		IsDir:   fi.IsDir(),
+		Size:    fi.Size(),
+		ModTime: fi.ModTime(),
+		Mode:    fi.Mode().String(),
+	}
+	d.Owner, d.Group = fileOwner(fi)
+	if !fi.IsDir() {
+		if d.MIME, err = detectMIME(file); err != nil {
+			fs.handleError(w, req, err, http.StatusInternalServerError)
+			return
+		}
+	}
+	fs.apiJSON(w, req, d, http.StatusOK)
+}
+
+// hash will send the checksum of file as json, the algorithm is the query parameter 'hash'
+func (fs *FileServer) hash(w http.ResponseWriter, req *http.Request, file *os.File) {
+	if fs.uploadOnly(req) {
+		fs.handleError(w, req, fmt.Errorf("%s", "Checksums not allowed due to 'upload only' option"), http.StatusForbidden)
+		return
+	}
+
+	algorithm := req.URL.Query().Get("hash")
+	newHash, ok := hashes[algorithm]
+	if !ok {
+		fs.handleError(w, req, fmt.Errorf("unknown hash algorithm %q, use md5, sha1 or sha256", algorithm), http.StatusBadRequest)
+		return
+	}
+	h := newHash()
+	if _, err := io.Copy(h, file); err != nil {
+		fs.handleError(w, req, err, http.StatusInternalServerError)
+		return
+	}
+	fs.apiJSON(w, req, fileHash{Algorithm: algorithm, Sum: hex.EncodeToString(h.Sum(nil))}, http.StatusOK)
+}
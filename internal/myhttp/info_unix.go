@@ -0,0 +1,29 @@
+//go:build !windows
+// +build !windows
+
+package myhttp
+
+import (
+	"os"
+	"os/user"
+	"strconv"
+	"syscall"
+)
+
+// fileOwner returns the user and group owning the file, ids if the names are unknown
+func fileOwner(fi os.FileInfo) (string, string) {
+	st, ok := fi.Sys().(*syscall.Stat_t)
+	if !ok {
+		return "", ""
+	}
+	uid := strconv.FormatUint(uint64(st.Uid), 10)
+	gid := strconv.FormatUint(uint64(st.Gid), 10)
+	owner, group := uid, gid
+	if u, err := user.LookupId(uid); err == nil {
+		owner = u.Username
+	}
+	if g, err := user.LookupGroupId(gid); err == nil {
+		group = g.Name
+	}
+	return owner, group
+}
@@ -0,0 +1,8 @@
+package myhttp
+
+import "os"
+
+// fileOwner is not supported on windows
+func fileOwner(fi os.FileInfo) (string, string) {
+	return "", ""
+}
@@ -0,0 +1,114 @@
+package myhttp
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+)
+
+// maxMarkdownSize is the largest markdown file in bytes that gets rendered
+const maxMarkdownSize = 1 << 20
+
+// markdown renders without raw html and drops dangerous links like javascript:
+var markdown = goldmark.New(goldmark.WithExtensions(extension.GFM))
+
+type markdownTemplate struct {
+	Prefix       string
+	GoshsVersion string
+	Path         string
+	Back         string
+	Content      template.HTML
+}
+
+// isMarkdown reports whether name is a markdown file by its extension
+func isMarkdown(name string) bool {
+	switch strings.ToLower(path.Ext(name)) {
+	case ".md", ".markdown":
+		return true
+	}
+	return false
+}
+
+// renderMarkdown will read at most maxMarkdownSize bytes of r and return it as html
+func renderMarkdown(r io.Reader) (template.HTML, error) {
+	source, err := ioutil.ReadAll(io.LimitReader(r, maxMarkdownSize+1))
+	if err != nil {
+		return "", err
+	}
+	if len(source) > maxMarkdownSize {
+		return "", fmt.Errorf("markdown files larger than %d bytes are not rendered", maxMarkdownSize)
+	}
+	var buf bytes.Buffer
+	if err := markdown.Convert(source, &buf); err != nil {
+		return "", err
+	}
+	// disable G203 (CWE-79): The used method does not auto-escape HTML
+	// as goldmark omits raw html and unsafe links
+	// #nosec G203
+	return template.HTML(buf.String()), nil
+}
+
+// previewMarkdown will render file as html page
+func (fs *FileServer) previewMarkdown(w http.ResponseWriter, req *http.Request, file *os.File, relpath string) {
+	if fs.uploadOnly(req) {
+		fs.handleError(w, req, fmt.Errorf("%s", "Preview not allowed due to 'upload only' option"), http.StatusForbidden)
+		return
+	}
+
+	content, err := renderMarkdown(file)
+	if err != nil {
+		fs.handleError(w, req, err, http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	markdownFile, err := fs.readStatic("templates/markdown.html")
+	if err != nil {
+		fs.Logger.Errorf("opening embedded file: %+v", err)
+	}
+
+	relpath = path.Clean("/" + filepath.ToSlash(relpath))
+	tem := markdownTemplate{
+		Prefix:       fs.Prefix,
+		GoshsVersion: fs.Version,
+		Path:         relpath,
+		Back:         path.Dir(relpath),
+		Content:      content,
+	}
+
+	t := template.New("markdown")
+	if _, err := t.Parse(string(markdownFile)); err != nil {
+		fs.Logger.Errorf("parsing the template: %+v", err)
+	}
+	if err := t.Execute(w, tem); err != nil {
+		fs.Logger.Errorf("executing the template: %+v", err)
+	}
+}
+
+// readme will render the readme at file for the directory listing, errors result in an empty string
+func (fs *FileServer) readme(file string) template.HTML {
+	// disable G304 (CWE-22): Potential file inclusion via variable
+	// as we want a file inclusion here
+	// #nosec G304
+	f, err := os.Open(file)
+	if err != nil {
+		fs.Logger.Errorf("opening readme: %+v", err)
+		return ""
+	}
+	defer f.Close()
+	content, err := renderMarkdown(f)
+	if err != nil {
+		fs.Logger.Debugf("rendering readme: %+v", err)
+		return ""
+	}
+	return content
+}
@@ -0,0 +1,146 @@
+package myhttp
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	dirsPath = "/073c1634c496cdb649d1afe0a312bbb4b7e1741b271542e4a436c3b8824b1761/dirs"
+	// maxDirs limits the directories offered as move target
+	maxDirs = 1000
+)
+
+var (
+	errMoveWebroot = errors.New("from and to are required and cannot be the webroot")
+	errMoveInto    = errors.New("a directory cannot be moved into itself")
+	errMoveMount   = errors.New("a mounted directory cannot be moved")
+	errMoveMode    = errors.New("not allowed due to 'read only' or 'upload only' option of the mount")
+	errMaxDirs     = errors.New("too many directories")
+)
+
+// move will rename or move from to to, both relative to the webroot, and return the new location on disk
+func (fs *FileServer) move(from, to string) (string, error) {
+	from, to = path.Clean("/"+from), path.Clean("/"+to)
+	if from == "/" || to == "/" {
+		return "", errMoveWebroot
+	}
+	if strings.HasPrefix(to, from+"/") {
+		return "", errMoveInto
+	}
+	if fs.Mounts.IsMountPoint(from) || fs.Mounts.IsMountPoint(to) {
+		return "", errMoveMount
+	}
+	for _, p := range []string{from, to} {
+		if fs.Mounts.ReadOnly(p) || fs.Mounts.UploadOnly(p) {
+			return "", errMoveMode
+		}
+	}
+	target := fs.abs(to)
+	if _, err := os.Lstat(target); err == nil {
+		return "", &os.PathError{Op: "move", Path: to, Err: os.ErrExist}
+	}
+	return target, os.Rename(fs.abs(from), target)
+}
+
+// moveStatus returns the http status for an error of move, 0 derives it from err
+func moveStatus(err error) int {
+	switch err {
+	case errMoveWebroot, errMoveInto, errMoveMount:
+		return http.StatusBadRequest
+	case errMoveMode:
+		return http.StatusForbidden
+	}
+	return 0
+}
+
+// moveFile handles the PATCH request to rename or move a file, the form value 'to' is the new path
+func (fs *FileServer) moveFile(w http.ResponseWriter, req *http.Request) {
+	if fs.readOnly(req) {
+		fs.handleError(w, req, fmt.Errorf("%s", "Move not allowed due to 'read only' option"), http.StatusForbidden)
+		return
+	}
+	if fs.uploadOnly(req) {
+		fs.handleError(w, req, fmt.Errorf("%s", "Move not allowed due to 'upload only' option"), http.StatusForbidden)
+		return
+	}
+
+	if _, err := fs.move(req.URL.Path, req.FormValue("to")); err != nil {
+		status := moveStatus(err)
+		switch {
+		case status != 0:
+		case os.IsNotExist(err):
+			status = http.StatusNotFound
+		case os.IsExist(err):
+			status = http.StatusConflict
+		default:
+			fs.Logger.Errorf("Not able to move %s: %+v", req.URL.Path, err)
+			status = http.StatusInternalServerError
+		}
+		fs.handleError(w, req, fs.hidePaths(err), status)
+		return
+	}
+
+	fs.Logger.LogRequest(req, http.StatusNoContent)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// dirs will list the directories below the webroot and the writable mounts as json to pick a move target
+func (fs *FileServer) dirs(w http.ResponseWriter, req *http.Request) {
+	if fs.UploadOnly {
+		fs.handleError(w, req, fmt.Errorf("%s", "Listing not allowed due to 'upload only' option"), http.StatusForbidden)
+		return
+	}
+
+	dirs := []string{}
+	walk := func(root, upath string) error {
+		return filepath.Walk(root, func(p string, fi os.FileInfo, err error) error {
+			if err != nil {
+				// Skip what cannot be read
+				return nil
+			}
+			if !fi.IsDir() {
+				return nil
+			}
+			if len(dirs) == maxDirs {
+				return errMaxDirs
+			}
+			rel, err := filepath.Rel(root, p)
+			if err != nil {
+				return err
+			}
+			dir := path.Join(upath, filepath.ToSlash(rel))
+			// The mounts are walked on their own
+			if root == fs.Webroot && fs.Mounts.IsMountPoint(dir) {
+				return filepath.SkipDir
+			}
+			dirs = append(dirs, dir)
+			return nil
+		})
+	}
+	err := walk(fs.Webroot, "/")
+	for _, m := range fs.Mounts {
+		if err != nil {
+			break
+		}
+		if !m.ReadOnly && !m.UploadOnly {
+			err = walk(m.Dir, m.Path)
+		}
+	}
+	if err != nil && err != errMaxDirs {
+		fs.handleError(w, req, err, http.StatusInternalServerError)
+		return
+	}
+
+	fs.Logger.LogRequest(req, http.StatusOK)
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(dirs); err != nil {
+		fs.Logger.Errorf("Error writing response to browser: %+v", err)
+	}
+}
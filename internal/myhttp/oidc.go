@@ -0,0 +1,168 @@
+package myhttp
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/patrickhener/goshs/internal/myauth"
+)
+
+const (
+	oidcPath          = "/oidc"
+	oidcSessionCookie = "goshs_session"
+	// oidcStateCookie ties a login to the browser which started it
+	oidcStateCookie = "goshs_state"
+)
+
+// OIDCMiddleware is a middleware to require a valid OpenID Connect session
+func (fs *FileServer) OIDCMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Login flow itself has to be reachable
+		if strings.HasPrefix(r.URL.Path, oidcPath+"/") || fs.authExempt(fs.clientIP(r)) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if cookie, err := r.Cookie(fs.disguise(oidcSessionCookie)); err == nil {
+			if session, ok := fs.OIDC.Session(cookie.Value); ok {
+				ctx := context.WithValue(r.Context(), ctxUser, session.User)
+				ctx = context.WithValue(ctx, ctxRole, session.Role)
+				next.ServeHTTP(w, r.WithContext(ctx))
+				return
+			}
+		}
+
+		// Anonymous users may read, login is offered in the ui
+		if fs.AnonymousRead && isReadMethod(r.Method) {
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), ctxRole, myauth.RoleRead)))
+			return
+		}
+
+		// Only plain page loads get redirected to the identity provider
+		if r.Method != http.MethodGet {
+			http.Error(w, "Not authorized", http.StatusUnauthorized)
+			return
+		}
+		http.Redirect(w, r, fs.Prefix+oidcPath+"/login?next="+url.QueryEscape(fs.Prefix+r.URL.RequestURI()), http.StatusFound)
+	})
+}
+
+// oidcLogin will redirect to the identity provider
+func (fs *FileServer) oidcLogin(w http.ResponseWriter, req *http.Request) {
+	next := req.URL.Query().Get("next")
+	// Only allow local redirects after login
+	if !localPath(next) {
+		next = fs.Prefix + "/"
+	}
+
+	loginURL, state, err := fs.OIDC.LoginURL(fs.oidcRedirectURL(req), next)
+	if err != nil {
+		fs.handleError(w, req, err, http.StatusInternalServerError)
+		return
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     fs.disguise(oidcStateCookie),
+		Value:    state,
+		Path:     fs.Prefix + oidcPath,
+		MaxAge:   int(myauth.StateLifetime.Seconds()),
+		HttpOnly: true,
+		Secure:   fs.SSL,
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	fs.Logger.LogRequest(req, http.StatusFound)
+	http.Redirect(w, req, loginURL, http.StatusFound)
+}
+
+// oidcCallback will finish the login and set the session cookie
+func (fs *FileServer) oidcCallback(w http.ResponseWriter, req *http.Request) {
+	query := req.URL.Query()
+	if e := query.Get("error"); e != "" {
+		fs.handleError(w, req, fmt.Errorf("login failed: %s %s", e, query.Get("error_description")), http.StatusUnauthorized)
+		return
+	}
+
+	// The state has to come from the browser which started the login, otherwise a login of
+	// somebody else could be finished here
+	state := query.Get("state")
+	cookie, err := req.Cookie(fs.disguise(oidcStateCookie))
+	if err != nil || state == "" || subtle.ConstantTimeCompare([]byte(cookie.Value), []byte(state)) != 1 {
+		fs.Logger.Warnf("OIDC login from %s failed: the state does not match the login started by the browser", req.RemoteAddr)
+		fs.handleError(w, req, errors.New("login failed"), http.StatusUnauthorized)
+		return
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     fs.disguise(oidcStateCookie),
+		Value:    "",
+		Path:     fs.Prefix + oidcPath,
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   fs.SSL,
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	session, next, err := fs.OIDC.Callback(req.Context(), fs.oidcRedirectURL(req), state, query.Get("code"))
+	if err != nil {
+		fs.Logger.Warnf("OIDC login from %s failed: %+v", req.RemoteAddr, err)
+		status := http.StatusUnauthorized
+		if errors.Is(err, myauth.ErrNotPermitted) {
+			status = http.StatusForbidden
+		}
+		fs.handleError(w, req, errors.New("login failed"), status)
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     fs.disguise(oidcSessionCookie),
+		Value:    session,
+		Path:     "/",
+		HttpOnly: true,
+		Secure:   fs.SSL,
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	fs.Logger.LogRequest(req, http.StatusFound)
+	http.Redirect(w, req, next, http.StatusFound)
+}
+
+// localPath tells whether p is a path on this server. Browsers take // and /\ for another host.
+func localPath(p string) bool {
+	if !strings.HasPrefix(p, "/") || strings.HasPrefix(p, "//") || strings.Contains(p, "\\") {
+		return false
+	}
+	u, err := url.Parse(p)
+	return err == nil && u.Scheme == "" && u.Host == ""
+}
+
+// oidcLogout will end the session
+func (fs *FileServer) oidcLogout(w http.ResponseWriter, req *http.Request) {
+	if cookie, err := req.Cookie(fs.disguise(oidcSessionCookie)); err == nil {
+		fs.OIDC.Logout(cookie.Value)
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     fs.disguise(oidcSessionCookie),
+		Value:    "",
+		Path:     "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   fs.SSL,
+	})
+
+	fs.Logger.LogRequest(req, http.StatusOK)
+	fmt.Fprintln(w, "Logged out")
+}
+
+// oidcRedirectURL will derive the callback url from the request
+func (fs *FileServer) oidcRedirectURL(req *http.Request) string {
+	scheme := "http"
+	if fs.SSL {
+		scheme = "https"
+	}
+	return fmt.Sprintf("%s://%s%s%s/callback", scheme, req.Host, fs.Prefix, oidcPath)
+}
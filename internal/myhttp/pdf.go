@@ -0,0 +1,53 @@
+package myhttp
+
+import (
+	"fmt"
+	"html/template"
+	"net/http"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/patrickhener/goshs/internal/myutils"
+)
+
+type pdfTemplate struct {
+	Prefix       string
+	GoshsVersion string
+	Path         string
+	Back         string
+}
+
+// isPDF reports whether name is a pdf document by its extension
+func isPDF(name string) bool {
+	return strings.ToLower(myutils.ReturnExt(name)) == ".pdf"
+}
+
+// viewPDF will embed the pdf at relpath into a page using the pdf viewer of the browser
+func (fs *FileServer) viewPDF(w http.ResponseWriter, req *http.Request, relpath string) {
+	if fs.uploadOnly(req) {
+		fs.handleError(w, req, fmt.Errorf("%s", "Viewing not allowed due to 'upload only' option"), http.StatusForbidden)
+		return
+	}
+
+	pdfFile, err := fs.readStatic("templates/pdf.html")
+	if err != nil {
+		fs.Logger.Errorf("opening embedded file: %+v", err)
+	}
+
+	relpath = path.Clean("/" + filepath.ToSlash(relpath))
+	tem := pdfTemplate{
+		Prefix:       fs.Prefix,
+		GoshsVersion: fs.Version,
+		Path:         relpath,
+		Back:         path.Dir(relpath),
+	}
+
+	t := template.New("pdf")
+	if _, err := t.Parse(string(pdfFile)); err != nil {
+		fs.Logger.Errorf("parsing the template: %+v", err)
+	}
+	if err := t.Execute(w, tem); err != nil {
+		fs.Logger.Errorf("executing the template: %+v", err)
+	}
+}
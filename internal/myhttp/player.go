@@ -0,0 +1,64 @@
+package myhttp
+
+import (
+	"fmt"
+	"html/template"
+	"mime"
+	"net/http"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/patrickhener/goshs/internal/myutils"
+)
+
+type playerTemplate struct {
+	Prefix       string
+	GoshsVersion string
+	Path         string
+	Back         string
+	Media        string
+	Type         string
+}
+
+// mediaType returns audio or video for files the browser player can handle, otherwise an empty string
+func mediaType(name string) string {
+	switch strings.ToLower(myutils.ReturnExt(name)) {
+	case ".mp3", ".wav", ".flac", ".oga", ".m4a", ".aac", ".opus":
+		return "audio"
+	case ".mp4", ".webm", ".ogg", ".ogv", ".mov", ".m4v", ".mkv":
+		return "video"
+	}
+	return ""
+}
+
+// play will render the media player for the file at relpath
+func (fs *FileServer) play(w http.ResponseWriter, req *http.Request, relpath string) {
+	if fs.uploadOnly(req) {
+		fs.handleError(w, req, fmt.Errorf("%s", "Playing not allowed due to 'upload only' option"), http.StatusForbidden)
+		return
+	}
+
+	playerFile, err := fs.readStatic("templates/player.html")
+	if err != nil {
+		fs.Logger.Errorf("opening embedded file: %+v", err)
+	}
+
+	relpath = path.Clean("/" + filepath.ToSlash(relpath))
+	tem := playerTemplate{
+		Prefix:       fs.Prefix,
+		GoshsVersion: fs.Version,
+		Path:         relpath,
+		Back:         path.Dir(relpath),
+		Media:        mediaType(relpath),
+		Type:         mime.TypeByExtension(path.Ext(relpath)),
+	}
+
+	t := template.New("player")
+	if _, err := t.Parse(string(playerFile)); err != nil {
+		fs.Logger.Errorf("parsing the template: %+v", err)
+	}
+	if err := t.Execute(w, tem); err != nil {
+		fs.Logger.Errorf("executing the template: %+v", err)
+	}
+}
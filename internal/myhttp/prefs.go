@@ -0,0 +1,122 @@
+package myhttp
+
+import (
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/patrickhener/goshs/internal/myclipboard"
+)
+
+const prefsCookie = "goshs_prefs"
+
+// listPrefs are the view options of the directory listing, they are kept in a cookie
+type listPrefs struct {
+	Sort   string
+	Order  string
+	Hidden bool
+	// Limit is the page size, 0 shows everything
+	Limit int
+	// Channel is the clipboard channel shown next to the listing
+	Channel string
+}
+
+// maxLimit is the largest page size to choose
+const maxLimit = 10000
+
+var defaultPrefs = listPrefs{Sort: "name", Order: "asc", Hidden: true, Limit: 1000, Channel: myclipboard.DefaultChannel}
+
+// apply will take over the known options of v and report whether any was present
+func (p *listPrefs) apply(v url.Values) bool {
+	changed := false
+	switch s := v.Get("sort"); s {
+	case "name", "size", "modified":
+		p.Sort, changed = s, true
+	}
+	switch o := v.Get("order"); o {
+	case "asc", "desc":
+		p.Order, changed = o, true
+	}
+	if l, err := strconv.Atoi(v.Get("limit")); err == nil && l >= 0 && l <= maxLimit {
+		p.Limit, changed = l, true
+	}
+	switch v.Get("hidden") {
+	case "0":
+		p.Hidden, changed = false, true
+	case "1":
+		p.Hidden, changed = true, true
+	}
+	if c := v.Get("channel"); myclipboard.ValidChannel(c) {
+		p.Channel, changed = c, true
+	}
+	return changed
+}
+
+func (p listPrefs) encode() string {
+	hidden := "0"
+	if p.Hidden {
+		hidden = "1"
+	}
+	return url.Values{"sort": {p.Sort}, "order": {p.Order}, "hidden": {hidden}, "limit": {strconv.Itoa(p.Limit)}, "channel": {p.Channel}}.Encode()
+}
+
+// listPrefs returns the preferences from the cookie overridden by the query, changes via query are stored in the cookie
+func (fs *FileServer) listPrefs(w http.ResponseWriter, req *http.Request) listPrefs {
+	p := defaultPrefs
+	if cookie, err := req.Cookie(fs.disguise(prefsCookie)); err == nil {
+		if v, err := url.ParseQuery(cookie.Value); err == nil {
+			p.apply(v)
+		}
+	}
+	if p.apply(req.URL.Query()) {
+		http.SetCookie(w, &http.Cookie{
+			Name:     fs.disguise(prefsCookie),
+			Value:    p.encode(),
+			Path:     fs.Prefix + "/",
+			Expires:  time.Now().AddDate(1, 0, 0),
+			Secure:   fs.SSL,
+			SameSite: http.SameSiteLaxMode,
+		})
+	}
+	return p
+}
+
+// sortItems will sort items as configured in p, names ignore case
+func sortItems(items []item, p listPrefs) {
+	less := func(i, j int) bool {
+		return strings.ToLower(items[i].Name) < strings.ToLower(items[j].Name)
+	}
+	switch p.Sort {
+	case "size":
+		less = func(i, j int) bool { return items[i].SortSize < items[j].SortSize }
+	case "modified":
+		less = func(i, j int) bool { return items[i].SortLastModified.Before(items[j].SortLastModified) }
+	}
+	if p.Order == "desc" {
+		asc := less
+		less = func(i, j int) bool { return asc(j, i) }
+	}
+	sort.SliceStable(items, less)
+}
+
+// paginate returns the requested page and the number of pages for count items shown limit per page
+func paginate(req *http.Request, count, limit int) (int, int) {
+	if limit <= 0 {
+		return 1, 1
+	}
+	pages := (count + limit - 1) / limit
+	if pages < 1 {
+		pages = 1
+	}
+	page, err := strconv.Atoi(req.URL.Query().Get("page"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+	if page > pages {
+		page = pages
+	}
+	return page, pages
+}
@@ -0,0 +1,31 @@
+package myhttp
+
+import (
+	"net"
+
+	"github.com/patrickhener/goshs/internal/myutils"
+	proxyproto "github.com/pires/go-proxyproto"
+)
+
+// listen will open the tcp listener, accepting the PROXY protocol if enabled
+func (fs *FileServer) listen(addr string) (net.Listener, error) {
+	l, err := net.Listen("tcp", addr)
+	if err != nil || !fs.ProxyProtocol {
+		return l, err
+	}
+
+	return &proxyproto.Listener{
+		Listener: l,
+		// Only trusted load balancers may tell us the client address, empty trusts nobody
+		Policy: func(upstream net.Addr) (proxyproto.Policy, error) {
+			host, _, err := net.SplitHostPort(upstream.String())
+			if err != nil {
+				return proxyproto.IGNORE, nil
+			}
+			if myutils.InNetworks(host, fs.ProxyTrusted) {
+				return proxyproto.USE, nil
+			}
+			return proxyproto.IGNORE, nil
+		},
+	}, nil
+}
@@ -0,0 +1,45 @@
+package myhttp
+
+import (
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/patrickhener/goshs/internal/myqr"
+)
+
+const (
+	qrPath = "/073c1634c496cdb649d1afe0a312bbb4b7e1741b271542e4a436c3b8824b1761/qr"
+	// qrSize is the width and height of the qr code image in pixels
+	qrSize = 256
+)
+
+// shareURL returns the url of relpath as seen by the visitor or via the public url if there is one
+func (fs *FileServer) shareURL(req *http.Request, relpath string) string {
+	base := fs.PublicURL
+	if base == "" {
+		scheme := "http"
+		if req.TLS != nil {
+			scheme = "https"
+		}
+		base = scheme + "://" + req.Host + fs.Prefix + "/"
+	}
+	u := url.URL{Path: strings.TrimPrefix(path.Clean("/"+relpath), "/")}
+	return base + u.EscapedPath()
+}
+
+// qr will send a png qr code of the url of the directory given in the query parameter 'path'
+func (fs *FileServer) qr(w http.ResponseWriter, req *http.Request) {
+	png, err := myqr.PNG(fs.shareURL(req, req.URL.Query().Get("path")), qrSize)
+	if err != nil {
+		fs.handleError(w, req, err, http.StatusInternalServerError)
+		return
+	}
+
+	fs.Logger.LogRequest(req, http.StatusOK)
+	w.Header().Set("Content-Type", "image/png")
+	if _, err := w.Write(png); err != nil {
+		fs.Logger.Errorf("Error writing response to browser: %+v", err)
+	}
+}
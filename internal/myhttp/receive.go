@@ -0,0 +1,108 @@
+package myhttp
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/patrickhener/goshs/internal/myutils"
+	"github.com/patrickhener/goshs/internal/mywebhook"
+)
+
+var errReceiveNoFile = errors.New("the upload contains no file")
+
+const receiveForm = `<!DOCTYPE html>
+<html><body>
+<form method="post" enctype="multipart/form-data">
+<input type="file" name="files"> <input type="submit" value="Send">
+</form>
+</body></html>
+`
+
+// registerReceive will stream the first file uploaded by PUT or POST to Receive
+func (fs *FileServer) registerReceive(mux *mux.Router) {
+	mux.PathPrefix("/425bda8487e36deccb30dd24be590b8744e3a28a8bb5a57d9b3fcd24ae09ad3c/").HandlerFunc(fs.static)
+	mux.Methods(http.MethodPut, http.MethodPost).HandlerFunc(fs.receive)
+	mux.Path("/").Methods(http.MethodGet, http.MethodHead).HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		fs.Logger.LogRequest(req, http.StatusOK)
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		fmt.Fprint(w, receiveForm)
+	})
+	mux.PathPrefix("/").HandlerFunc(fs.notFound)
+}
+
+// receive will write the upload to Receive, only the first one which got that far
+// is taken and Received is called after it
+func (fs *FileServer) receive(w http.ResponseWriter, req *http.Request) {
+	if fs.readOnly(req) {
+		fs.Logger.LogRequest(req, http.StatusForbidden)
+		http.Error(w, "Upload not allowed", http.StatusForbidden)
+		return
+	}
+	fs.receiveMu.Lock()
+	if fs.receiving {
+		fs.receiveMu.Unlock()
+		fs.Logger.LogRequest(req, http.StatusConflict)
+		http.Error(w, "A file was received already", http.StatusConflict)
+		return
+	}
+	fs.receiving = true
+	fs.receiveMu.Unlock()
+
+	body, name, err := receiveBody(req)
+	if err != nil {
+		// Nothing was written yet, so another upload may try
+		fs.receiveMu.Lock()
+		fs.receiving = false
+		fs.receiveMu.Unlock()
+		fs.Logger.LogRequest(req, http.StatusBadRequest)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	n, err := io.Copy(fs.Receive, body)
+	status := http.StatusOK
+	if err != nil {
+		status = http.StatusInternalServerError
+		http.Error(w, "Receiving the file failed", status)
+	} else {
+		fs.Logger.Infof("Received %s (%s) from %s", name, myutils.ByteCountDecimal(n), req.RemoteAddr)
+		fmt.Fprintln(w, "Received")
+	}
+	fs.Logger.LogRequest(req, status)
+	fs.notify(mywebhook.EventUpload, req, status, "/"+name, "")
+	if fs.Received != nil {
+		fs.Received(err)
+	}
+}
+
+// receiveBody returns the first file of a multipart upload or the request body with the name of the url
+func receiveBody(req *http.Request) (io.Reader, string, error) {
+	if !strings.HasPrefix(req.Header.Get("Content-Type"), "multipart/form-data") {
+		name := path.Base(path.Clean("/" + req.URL.Path))
+		if name == "/" {
+			name = "upload"
+		}
+		return req.Body, name, nil
+	}
+	mr, err := req.MultipartReader()
+	if err != nil {
+		return nil, "", err
+	}
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			return nil, "", errReceiveNoFile
+		}
+		if err != nil {
+			return nil, "", err
+		}
+		if part.FileName() != "" {
+			return part, part.FileName(), nil
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package myhttp
+
+import (
+	"net"
+
+	"github.com/patrickhener/goshs/internal/myutils"
+)
+
+// Reloadable are the settings which can change while serving, see Reload
+type Reloadable struct {
+	User       string
+	Pass       string
+	AuthExempt []*net.IPNet
+	ReadOnly   bool
+	Banner     string
+}
+
+// Reload will apply s to the running server, requests in flight finish with the old settings
+func (fs *FileServer) Reload(s Reloadable) {
+	fs.live.Lock()
+	defer fs.live.Unlock()
+	fs.User, fs.Pass = s.User, s.Pass
+	fs.AuthExempt = s.AuthExempt
+	fs.ReadOnly = s.ReadOnly
+	fs.Banner = s.Banner
+}
+
+// credentials returns user and password of basic auth
+func (fs *FileServer) credentials() (string, string) {
+	fs.live.RLock()
+	defer fs.live.RUnlock()
+	return fs.User, fs.Pass
+}
+
+// authExempt reports whether ip may skip authentication
+func (fs *FileServer) authExempt(ip string) bool {
+	fs.live.RLock()
+	defer fs.live.RUnlock()
+	return myutils.InNetworks(ip, fs.AuthExempt)
+}
+
+// IsReadOnly reports whether the whole server is read only
+func (fs *FileServer) IsReadOnly() bool {
+	fs.live.RLock()
+	defer fs.live.RUnlock()
+	return fs.ReadOnly
+}
+
+// SetReadOnly will switch the read only mode of the running server
+func (fs *FileServer) SetReadOnly(readOnly bool) {
+	fs.live.Lock()
+	defer fs.live.Unlock()
+	fs.ReadOnly = readOnly
+}
+
+// banner returns the text shown above every listing
+func (fs *FileServer) banner() string {
+	fs.live.RLock()
+	defer fs.live.RUnlock()
+	return fs.Banner
+}
@@ -0,0 +1,159 @@
+package myhttp
+
+import (
+	"errors"
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/patrickhener/goshs/internal/myutils"
+)
+
+const (
+	// maxSearchDepth is the number of directory levels below the start of a search
+	maxSearchDepth = 16
+	// maxSearchResults stops the search after this many matches
+	maxSearchResults = 500
+)
+
+var errSearchLimit = errors.New("search limit reached")
+
+type searchTemplate struct {
+	Prefix       string
+	GoshsVersion string
+	Path         string
+	Query        string
+	Truncated    bool
+	Results      []item
+}
+
+// searchMatch returns a function reporting whether a file name matches query,
+// queries with wildcards are globs, everything else is a substring, both ignore case
+func searchMatch(query string) (func(name string) bool, error) {
+	query = strings.ToLower(query)
+	if strings.ContainsAny(query, "*?[") {
+		if _, err := path.Match(query, ""); err != nil {
+			return nil, err
+		}
+		return func(name string) bool {
+			ok, _ := path.Match(query, strings.ToLower(name))
+			return ok
+		}, nil
+	}
+	return func(name string) bool {
+		return strings.Contains(strings.ToLower(name), query)
+	}, nil
+}
+
+// search will walk the directory at relpath and render the files and directories matching the search query
+func (fs *FileServer) search(w http.ResponseWriter, req *http.Request, relpath string) {
+	if fs.uploadOnly(req) {
+		fs.handleError(w, req, fmt.Errorf("%s", "Search not allowed due to 'upload only' option"), http.StatusForbidden)
+		return
+	}
+
+	query := req.URL.Query().Get("search")
+	match, err := searchMatch(query)
+	if err != nil {
+		fs.handleError(w, req, err, http.StatusBadRequest)
+		return
+	}
+
+	relpath = path.Clean("/" + filepath.ToSlash(relpath))
+	results := []item{}
+	// walk will search root on disk which is relpath below the webroot
+	walk := func(root, relpath string) error {
+		return filepath.Walk(root, func(p string, fi os.FileInfo, err error) error {
+			if err != nil {
+				// Skip what cannot be read
+				return nil
+			}
+			if p == root {
+				return nil
+			}
+			rel, err := filepath.Rel(root, p)
+			if err != nil {
+				return err
+			}
+			rel = path.Join(relpath, filepath.ToSlash(rel))
+			if fi.IsDir() && myutils.CheckSpecialPath(fi.Name()) {
+				return filepath.SkipDir
+			}
+			// Hidden by a mounted directory
+			if fs.Mounts.IsMountPoint(rel) {
+				if fi.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if query != "" && match(fi.Name()) {
+				if len(results) == maxSearchResults {
+					return errSearchLimit
+				}
+				i := item{
+					URI:                 url.PathEscape(rel),
+					Name:                strings.TrimPrefix(rel, "/"),
+					IsDir:               fi.IsDir(),
+					DisplaySize:         myutils.ByteCountDecimal(fi.Size()),
+					SortSize:            fi.Size(),
+					DisplayLastModified: fi.ModTime().Format("Mon Jan _2 15:04:05 2006"),
+					SortLastModified:    fi.ModTime(),
+				}
+				if i.IsDir {
+					i.Name += "/"
+				}
+				results = append(results, i)
+			}
+			if fi.IsDir() {
+				sub, err := filepath.Rel(root, p)
+				if err != nil {
+					return err
+				}
+				if strings.Count(sub, string(filepath.Separator))+1 >= maxSearchDepth {
+					return filepath.SkipDir
+				}
+			}
+			return nil
+		})
+	}
+	err = walk(fs.abs(relpath), relpath)
+	// The mounts are searched from the webroot as well
+	if relpath == "/" {
+		for _, m := range fs.Mounts {
+			if err == nil && !m.UploadOnly {
+				err = walk(m.Dir, m.Path)
+			}
+		}
+	}
+	if err != nil && err != errSearchLimit {
+		fs.handleError(w, req, err, http.StatusInternalServerError)
+		return
+	}
+
+	searchFile, err := fs.readStatic("templates/search.html")
+	if err != nil {
+		fs.Logger.Errorf("opening embedded file: %+v", err)
+	}
+
+	tem := searchTemplate{
+		Prefix:       fs.Prefix,
+		GoshsVersion: fs.Version,
+		Path:         relpath,
+		Query:        query,
+		Truncated:    err == errSearchLimit,
+		Results:      results,
+	}
+
+	t := template.New("search")
+	if _, err := t.Parse(string(searchFile)); err != nil {
+		fs.Logger.Errorf("parsing the template: %+v", err)
+	}
+	if err := t.Execute(w, tem); err != nil {
+		fs.Logger.Errorf("executing the template: %+v", err)
+	}
+}
@@ -0,0 +1,64 @@
+package myhttp
+
+import "net/http"
+
+// serveCount counts the downloads of a file which started and finished
+type serveCount struct {
+	started  int
+	finished int
+}
+
+// reserveServe will count a download of upath, it returns false if upath was served MaxServes times already
+func (fs *FileServer) reserveServe(upath string) bool {
+	if fs.MaxServes <= 0 {
+		return true
+	}
+	fs.servesMu.Lock()
+	defer fs.servesMu.Unlock()
+	if fs.serves == nil {
+		fs.serves = make(map[string]*serveCount)
+	}
+	c, ok := fs.serves[upath]
+	if !ok {
+		c = &serveCount{}
+		fs.serves[upath] = c
+	}
+	if c.started >= fs.MaxServes {
+		return false
+	}
+	c.started++
+	return true
+}
+
+// served will end the download of upath reserved before. Only complete downloads count, others free
+// their reservation again. MaxServed is called once the last download allowed finished.
+func (fs *FileServer) served(upath string, complete bool) {
+	if fs.MaxServes <= 0 {
+		return
+	}
+	fs.servesMu.Lock()
+	c := fs.serves[upath]
+	if !complete {
+		c.started--
+		fs.servesMu.Unlock()
+		return
+	}
+	c.finished++
+	last := c.finished == fs.MaxServes
+	fs.servesMu.Unlock()
+	if last && fs.MaxServed != nil {
+		fs.MaxServed(upath)
+	}
+}
+
+// countingWriter counts the bytes of the body written, a range or an aborted download is incomplete
+type countingWriter struct {
+	http.ResponseWriter
+	written int64
+}
+
+func (w *countingWriter) Write(b []byte) (int, error) {
+	n, err := w.ResponseWriter.Write(b)
+	w.written += int64(n)
+	return n, err
+}
@@ -0,0 +1,37 @@
+package myhttp
+
+import (
+	"github.com/patrickhener/goshs/internal/mylog"
+	"github.com/patrickhener/goshs/internal/mysftp"
+)
+
+// StartSFTP will serve the webroot via SFTP on port using the same credentials as the web interface
+func (fs *FileServer) StartSFTP(port int, hostKey string) {
+	cfg := mysftp.Config{
+		Webroot:    fs.Webroot,
+		Mounts:     fs.Mounts,
+		ReadOnly:   fs.IsReadOnly,
+		UploadOnly: fs.UploadOnly,
+		HostKey:    hostKey,
+		Logged:     fs.sftpLogged,
+	}
+	if fs.Stealth {
+		cfg.ServerVersion = "SSH-2.0-Go"
+	}
+
+	if fs.User != "" || fs.LDAP != nil {
+		cfg.PasswordCallback = func(user, pass, ip string) bool {
+			if fs.authExempt(ip) {
+				return true
+			}
+			if fs.Limiter != nil {
+				if banned, _ := fs.Limiter.Banned(ip); banned {
+					return false
+				}
+			}
+			return fs.verifyLogin(ip, "ssh", "sftp://", user, pass)
+		}
+	}
+
+	mylog.Panic(mysftp.ListenAndServe(fs.address(port), cfg))
+}
@@ -0,0 +1,44 @@
+package myhttp
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"github.com/gorilla/mux"
+)
+
+// registerFile will serve File at / and nothing else but the embedded assets the health probe asks for
+func (fs *FileServer) registerFile(mux *mux.Router) {
+	mux.PathPrefix("/425bda8487e36deccb30dd24be590b8744e3a28a8bb5a57d9b3fcd24ae09ad3c/").HandlerFunc(fs.static)
+	mux.Path("/").Methods(http.MethodGet, http.MethodHead).HandlerFunc(fs.singleFile)
+	mux.PathPrefix("/").HandlerFunc(fs.notFound)
+}
+
+// notFound answers every other path, the error page would reveal where the file is stored
+func (fs *FileServer) notFound(w http.ResponseWriter, req *http.Request) {
+	fs.Logger.LogRequest(req, http.StatusNotFound)
+	http.NotFound(w, req)
+}
+
+// singleFile sends File as a download with its name
+func (fs *FileServer) singleFile(w http.ResponseWriter, req *http.Request) {
+	// disable G304 (CWE-22): Potential file inclusion via variable
+	// as the file is given by the operator
+	// #nosec G304
+	file, err := os.Open(fs.File)
+	if err != nil {
+		fs.Logger.Errorf("opening the served file: %+v", err)
+		fs.Logger.LogRequest(req, http.StatusInternalServerError)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	// disable G307 (CWE-703): Deferring unsafe method "Close" on type "*os.File"
+	// #nosec G307
+	defer file.Close()
+
+	fs.Logger.LogRequest(req, http.StatusOK)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filepath.Base(fs.File)))
+	fs.sendFile(w, req, file)
+}
@@ -0,0 +1,111 @@
+package myhttp
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	speedtestPath = "/speedtest"
+	// default and maximum download size in MB
+	speedtestDefaultSize = 100
+	speedtestMaxSize     = 10240
+	speedtestChunk       = 1 << 20
+)
+
+type speedtestTemplate struct {
+	Prefix       string
+	GoshsVersion string
+	DefaultSize  int
+}
+
+type speedtestResult struct {
+	Bytes      int64   `json:"bytes"`
+	DurationMS int64   `json:"duration_ms"`
+	Mbps       float64 `json:"mbps"`
+}
+
+// speedtest will serve the speedtest ui
+func (fs *FileServer) speedtest(w http.ResponseWriter, req *http.Request) {
+	file, err := fs.readStatic("templates/speedtest.html")
+	if err != nil {
+		fs.Logger.Errorf("opening embedded file: %+v", err)
+	}
+
+	fs.Logger.LogRequest(req, http.StatusOK)
+
+	t := template.New("speedtest")
+	if _, err := t.Parse(string(file)); err != nil {
+		fs.Logger.Errorf("parsing the template: %+v", err)
+	}
+	if err := t.Execute(w, speedtestTemplate{Prefix: fs.Prefix, GoshsVersion: fs.Version, DefaultSize: speedtestDefaultSize}); err != nil {
+		fs.Logger.Errorf("executing the template: %+v", err)
+	}
+}
+
+// speedtestDown will send ?size=<MB> of random data to the client
+func (fs *FileServer) speedtestDown(w http.ResponseWriter, req *http.Request) {
+	size := speedtestDefaultSize
+	if s := req.URL.Query().Get("size"); s != "" {
+		var err error
+		size, err = strconv.Atoi(s)
+		if err != nil || size < 1 || size > speedtestMaxSize {
+			fs.handleError(w, req, fmt.Errorf("size has to be between 1 and %d MB", speedtestMaxSize), http.StatusBadRequest)
+			return
+		}
+	}
+
+	// One chunk of random data is repeated, generating all of it would
+	// measure the cpu rather than the link
+	chunk := make([]byte, speedtestChunk)
+	if _, err := rand.Read(chunk); err != nil {
+		fs.handleError(w, req, err, http.StatusInternalServerError)
+		return
+	}
+
+	fs.Logger.LogRequest(req, http.StatusOK)
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Length", strconv.FormatInt(int64(size)*speedtestChunk, 10))
+	w.Header().Set("Cache-Control", "no-store")
+
+	for i := 0; i < size; i++ {
+		if _, err := w.Write(chunk); err != nil {
+			fs.Logger.Debugf("speedtest download aborted: %+v", err)
+			return
+		}
+	}
+}
+
+// speedtestUp will discard everything sent by the client and report the throughput
+func (fs *FileServer) speedtestUp(w http.ResponseWriter, req *http.Request) {
+	start := time.Now()
+	n, err := io.Copy(ioutil.Discard, req.Body)
+	if err != nil {
+		fs.handleError(w, req, err, http.StatusInternalServerError)
+		return
+	}
+	duration := time.Since(start)
+
+	fs.Logger.LogRequest(req, http.StatusOK)
+
+	result := speedtestResult{
+		Bytes:      n,
+		DurationMS: duration.Milliseconds(),
+	}
+	if duration > 0 {
+		result.Mbps = float64(n) * 8 / duration.Seconds() / 1000 / 1000
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(result); err != nil {
+		fs.Logger.Errorf("Error writing response to browser: %+v", err)
+	}
+}
@@ -1 +1,4 @@
-$(document).ready(function(){$("#tableData").DataTable({paging:false,language:{info:"_TOTAL_ items"},order:[[2,"asc"]],columnDefs:[{targets:[0,1,5],orderable:false}]})});var checkboxes=document.querySelectorAll(".downloadBulkCheckbox");Array.prototype.forEach.call(checkboxes,function(cb){cb.addEventListener("change",function(){checkedBoxes=document.querySelectorAll("input[type=checkbox]:checked").length;if(checkedBoxes>=1){document.getElementById("downloadBulkButton").style.display="block"}else{document.getElementById("downloadBulkButton").style.display="none"}})});function selectAll(){Array.prototype.forEach.call(checkboxes,function(cb){cb.checked=true});document.getElementById("downloadBulkButton").style.display="block"}function selectNone(){Array.prototype.forEach.call(checkboxes,function(cb){cb.checked=false});document.getElementById("downloadBulkButton").style.display="none"}var wsURL="";location.protocol!=="https:"?wsURL="ws://"+window.location.host+"/14644be038ea0118a1aadfacca2a7d1517d7b209c4b9674ee893b1944d1c2d54/ws":wsURL="wss://"+window.location.host+"/14644be038ea0118a1aadfacca2a7d1517d7b209c4b9674ee893b1944d1c2d54/ws";var connection=new WebSocket(wsURL);connection.onopen=function(){console.log("Connected via WebSockets")};connection.onclose=function(){console.log("Connection has been closed by WebSocket Server")};connection.onerror=function(e){console.log("Websocket error: ",e)};connection.onmessage=function(m){try{var message=JSON.parse(m.data);if(message["type"]=="refreshClipboard"){location.reload()}}catch(e){console.log("Error reading message: ",e)}};function sendEntry(e){e.preventDefault();entryfield=document.getElementById("cbEntry");var text=entryfield.value;var msg={type:"newEntry",content:text};connection.send(JSON.stringify(msg));entryfield.value=""}function clearClipboard(e){e.preventDefault;result=confirm("Are you sure you want to clear the clipboard?");if(result){var msg={type:"clearClipboard",content:""};connection.send(JSON.stringify(msg))}}function delClipboard(id){var msg={type:"delEntry",content:id};connection.send(JSON.stringify(msg))}
\ No newline at end of file
+var lastChecked,moveItems,infoURI,uploadQueue,uploadBusy,uploadFailed,uploadRetries,dragDepth,galleryIndex,wsURL,connection,clipboardPass,sortColumns={name:2,size:3,modified:4};$(document).ready(function(){var e=$("#tableData").DataTable({paging:!1,language:{info:"_TOTAL_ items",search:"Filter:"},order:[[sortColumns[goshsPrefs.sort]||2,goshsPrefs.order]],columnDefs:[{targets:"nosort",orderable:!1},{targets:"nosearch",searchable:!1}]});e.on("order.dt",function(){var n,t=e.order()[0];for(n in sortColumns)sortColumns[n]==t[0]&&(savePrefs(n,t[1]),goshsPages>1&&(location.search="?sort="+n+"&order="+t[1]))})});function savePrefs(e,t){document.cookie="goshs_prefs="+new URLSearchParams({hidden:goshsPrefs.hidden,limit:goshsPrefs.limit,channel:goshsPrefs.channel,order:t,sort:e}).toString()+"; path="+goshsPrefix+"/; max-age=31536000; samesite=lax"}lastChecked=null;function visibleCheckboxes(){return Array.prototype.slice.call(document.querySelectorAll("#tableData tbody .downloadBulkCheckbox"))}function selectedItems(){return visibleCheckboxes().filter(function(e){return e.checked}).map(function(e){return{uri:e.value,name:e.getAttribute("data-name")}})}function updateSelection(){var e=selectedItems().length;document.getElementById("bulkActions").style.display=e>=1?"flex":"none",document.getElementById("bulkCount").textContent=e+" selected"}document.addEventListener("click",function(e){var n,s,o,t=e.target;if(!t.classList||!t.classList.contains("downloadBulkCheckbox"))return;n=visibleCheckboxes(),e.shiftKey&&lastChecked&&n.indexOf(lastChecked)!=-1&&(s=n.indexOf(lastChecked),o=n.indexOf(t),n.slice(Math.min(s,o),Math.max(s,o)+1).forEach(function(e){e.checked=t.checked})),lastChecked=t,updateSelection()}),document.addEventListener("submit",function(e){if(e.target.id!="bulkForm")return;var t=visibleCheckboxes();Array.prototype.forEach.call(e.target.querySelectorAll(".downloadBulkCheckbox"),function(e){t.indexOf(e)==-1&&(e.checked=!1)})});function selectAll(){visibleCheckboxes().forEach(function(e){e.checked=!0}),updateSelection()}function selectNone(){Array.prototype.forEach.call(document.querySelectorAll(".downloadBulkCheckbox"),function(e){e.checked=!1}),updateSelection()}function selectInvert(){visibleCheckboxes().forEach(function(e){e.checked=!e.checked}),updateSelection()}function runEach(e,t,n){var s=[];e.reduce(function(e,t){return e.then(function(){return n(t).then(function(e){e.ok||s.push(t.name+": "+e.status+" "+e.statusText)},function(e){s.push(t.name+": "+e)})})},Promise.resolve()).then(function(){s.length>0&&alert(t+` failed for
+`+s.join(`
+`)),location.reload()})}function deleteSelected(){var e=selectedItems();if(e.length==0)return;if(result=confirm("Are you sure you want to delete "+e.length+" selected items?"),!result)return;runEach(e,"Deleting",function(e){return fetch(goshsPrefix+"/"+e.uri,{method:"DELETE",credentials:"same-origin"})})}function deleteItem(e){var t=e.getAttribute("data-name");if(result=confirm("Are you sure you want to delete "+t+"?"),!result)return;fetch(goshsPrefix+"/"+e.getAttribute("data-uri"),{method:"DELETE",credentials:"same-origin"}).then(function(e){e.ok?location.reload():alert("Deleting "+t+" failed: "+e.status+" "+e.statusText)})}function newFolder(){var t,e=prompt("Name of the new folder");if(!e)return;t=goshsDir.replace(/\/$/,"").split("/").map(encodeURIComponent),fetch(goshsPrefix+t.join("/")+"/"+encodeURIComponent(e),{method:"MKCOL",credentials:"same-origin"}).then(function(t){t.ok?location.reload():alert("Creating "+e+" failed: "+t.status+" "+t.statusText)})}moveItems=[];function openMove(e){if(e)moveItems=[{uri:e.getAttribute("data-uri"),name:e.getAttribute("data-name")}],document.getElementById("moveName").value=e.getAttribute("data-name"),document.getElementById("moveName").required=!0,document.getElementById("moveNameGroup").style.display="block",document.getElementById("moveTitle").textContent="Rename / Move";else{if(moveItems=selectedItems(),moveItems.length==0)return;document.getElementById("moveName").required=!1,document.getElementById("moveNameGroup").style.display="none",document.getElementById("moveTitle").textContent="Move "+moveItems.length+" selected items"}var t,n,s=document.getElementById("moveDir");s.innerHTML="",fetch(goshsPrefix+"/073c1634c496cdb649d1afe0a312bbb4b7e1741b271542e4a436c3b8824b1761/dirs",{credentials:"same-origin"}).then(function(e){return e.json()}).then(function(e){e.forEach(function(e){var t=document.createElement("option");t.value=e,t.text=e,t.selected=e==goshsDir,s.appendChild(t)})}),n=document.getElementById("moveModal"),n.style.display="block",n.classList.add("show"),t=document.createElement("div"),t.className="modal-backdrop show",t.id="moveBackdrop",document.body.appendChild(t),e&&document.getElementById("moveName").focus()}function closeMove(){var e,t=document.getElementById("moveModal");t.style.display="none",t.classList.remove("show"),e=document.getElementById("moveBackdrop"),e&&e.remove()}function openQR(){var e,n,t=document.getElementById("qrImage");return t.src||(t.src=t.getAttribute("data-src")),n=document.getElementById("qrModal"),n.style.display="block",n.classList.add("show"),e=document.createElement("div"),e.className="modal-backdrop show",e.id="qrBackdrop",document.body.appendChild(e),!1}function closeQR(){var e,t=document.getElementById("qrModal");t.style.display="none",t.classList.remove("show"),e=document.getElementById("qrBackdrop"),e&&e.remove()}function moveItem(e){e.preventDefault();var t=(document.getElementById("moveDir").value||goshsDir).replace(/\/$/,""),n=moveItems.length==1&&document.getElementById("moveName").required,s=document.getElementById("moveName").value;return closeMove(),runEach(moveItems,"Moving",function(e){var o=t+"/"+(n?s:e.name.replace(/\/$/,""));return fetch(goshsPrefix+"/"+e.uri,{method:"PATCH",credentials:"same-origin",headers:{"Content-Type":"application/x-www-form-urlencoded"},body:"to="+encodeURIComponent(o)})}),!1}infoURI="";function openInfo(e){infoURI=goshsPrefix+"/"+e.getAttribute("data-uri");var t=document.getElementById("infoTable");t.innerHTML="",fetch(infoURI+"?info",{credentials:"same-origin"}).then(function(e){if(!e.ok)throw e.status+" "+e.statusText;return e.json()}).then(function(e){document.getElementById("infoTitle").textContent=e.name,infoRow("Path",e.path),infoRow("Size",e.size+" bytes"),infoRow("Modified",new Date(e.mod_time).toString()),infoRow("Mode",e.mode),e.owner&&infoRow("Owner",e.owner+":"+e.group),e.is_dir||(infoRow("MIME type",e.mime),["md5","sha1","sha256"].forEach(function(e){var n=infoRow(e.toUpperCase(),""),t=document.createElement("button");t.type="button",t.className="btn btn-link p-0",t.textContent="Compute",t.onclick=function(){computeHash(e,n)},n.appendChild(t)}))}).catch(function(e){infoRow("Error",e)}),showInfo()}function showInfo(){var e,t=document.getElementById("infoModal");t.style.display="block",t.classList.add("show"),e=document.createElement("div"),e.className="modal-backdrop show",e.id="infoBackdrop",document.body.appendChild(e)}function openLinks(e){var t=e.getAttribute("data-name"),n=location.origin+goshsPrefix+decodeURIComponent(e.getAttribute("data-uri")).replace(/^\/?/,"/").split("/").map(encodeURIComponent).join("/"),i=location.protocol=="https:",s=function(e){return"'"+e.replace(/'/g,"'\\''")+"'"},a=function(e){return"'"+e.replace(/'/g,"''")+"'"},o=function(e){return'"'+e.replace(/"/g,"")+'"'};document.getElementById("infoTitle").textContent="Copy link: "+t,document.getElementById("infoTable").innerHTML="",infoRow("URL",n),infoRow("curl","curl "+(i?"-k ":"")+"-o "+s(t)+" "+s(n)),infoRow("wget","wget "+(i?"--no-check-certificate ":"")+"-O "+s(t)+" "+s(n)),infoRow("PowerShell",(i?"[Net.ServicePointManager]::ServerCertificateValidationCallback = {$true}; ":"")+"iwr -UseBasicParsing -Uri "+a(n)+" -OutFile "+a(t)),infoRow("certutil","certutil -urlcache -split -f "+o(n)+" "+o(t)),infoRow("bitsadmin","bitsadmin /transfer goshs /download /priority high "+o(n)+" "+o("%cd%\\"+t)),showInfo()}function closeInfo(){var e,t=document.getElementById("infoModal");t.style.display="none",t.classList.remove("show"),e=document.getElementById("infoBackdrop"),e&&e.remove()}function infoRow(e,t){var n,s,i,a,o=document.createElement("tr"),r=document.createElement("th");return r.textContent=e,i=document.createElement("td"),s=document.createElement("span"),s.className="info-value",s.textContent=t,i.appendChild(s),a=document.createElement("td"),n=document.createElement("button"),n.type="button",n.className="btn btn-link p-0",n.title="Copy",n.innerHTML='<i class="fas fa-copy"></i>',n.onclick=function(){copyText(s.textContent)},a.appendChild(n),o.appendChild(r),o.appendChild(i),o.appendChild(a),document.getElementById("infoTable").appendChild(o),s}function computeHash(e,t){t.textContent="computing...",fetch(infoURI+"?hash="+e,{credentials:"same-origin"}).then(function(e){if(!e.ok)throw e.status+" "+e.statusText;return e.json()}).then(function(e){t.textContent=e.sum}).catch(function(e){t.textContent="failed: "+e})}function copyText(e){if(navigator.clipboard&&window.isSecureContext){navigator.clipboard.writeText(e);return}var t=document.createElement("textarea");t.value=e,t.style.position="fixed",t.style.opacity="0",document.body.appendChild(t),t.select(),document.execCommand("copy"),t.remove()}function treeChildren(e,t){return fetch(goshsPrefix+"/073c1634c496cdb649d1afe0a312bbb4b7e1741b271542e4a436c3b8824b1761/tree?path="+encodeURIComponent(e),{credentials:"same-origin"}).then(function(e){if(!e.ok)throw e.status+" "+e.statusText;return e.json()}).then(function(e){return t.innerHTML="",e.forEach(function(e){t.appendChild(treeNode(e))}),e})}function treeNode(e){var n,s,t=document.createElement("li");return t.setAttribute("data-path",e.path),s=document.createElement("i"),s.className="fas fa-caret-right tree-toggle",s.onclick=function(){expandNode(t)},n=document.createElement("a"),n.href=goshsPrefix+e.path.split("/").map(encodeURIComponent).join("/")+"/",n.textContent=e.name,e.path==goshsDir.replace(/\/$/,"")&&(n.className="active"),t.appendChild(s),t.appendChild(n),t}function expandNode(e){var n=e.querySelector(".tree-toggle"),t=e.querySelector("ul");return t?(t.remove(),n.className="fas fa-caret-right tree-toggle",Promise.resolve()):(t=document.createElement("ul"),e.appendChild(t),n.className="fas fa-caret-down tree-toggle",treeChildren(e.getAttribute("data-path"),t).then(function(e){e.length==0&&(n.className="fas tree-toggle")}))}function loadTree(){var t=goshsDir.split("/").filter(function(e){return e!=""}),n=document.getElementById("tree"),e=treeChildren("/",n);t.forEach(function(n,s){e=e.then(function(){var n="/"+t.slice(0,s+1).join("/"),e=Array.prototype.find.call(document.querySelectorAll("#tree li"),function(e){return e.getAttribute("data-path")==n});if(e)return expandNode(e)})}),e.catch(function(e){n.textContent="Loading failed: "+e})}function toggleTree(){var t=document.getElementById("treeSidebar"),e=!t.classList.contains("show");t.classList.toggle("show",e),localStorage.setItem("goshsTree",e?"1":"0"),e&&document.getElementById("tree").children.length==0&&loadTree()}localStorage.getItem("goshsTree")=="1"&&(document.getElementById("treeSidebar").classList.add("show"),loadTree()),uploadQueue=[],uploadBusy=!1,uploadFailed=0,uploadRetries=3,dragDepth=0;function dropTarget(e){return!goshsReadOnly&&e.dataTransfer&&Array.prototype.indexOf.call(e.dataTransfer.types,"Files")!=-1&&!e.target.closest("#mydropzone")}document.addEventListener("dragenter",function(e){if(!dropTarget(e))return;e.preventDefault(),dragDepth++,document.getElementById("dropOverlay").classList.add("show")}),document.addEventListener("dragover",function(e){dropTarget(e)&&e.preventDefault()}),document.addEventListener("dragleave",function(e){if(!dropTarget(e))return;dragDepth--,dragDepth<=0&&(dragDepth=0,document.getElementById("dropOverlay").classList.remove("show"))}),document.addEventListener("drop",function(e){if(dragDepth=0,document.getElementById("dropOverlay").classList.remove("show"),!dropTarget(e))return;e.preventDefault();for(var s,n=e.dataTransfer.items,o=e.dataTransfer.files,t=0;t<o.length;t++)s=n&&n[t]&&n[t].webkitGetAsEntry?n[t].webkitGetAsEntry():null,queueUpload(o[t],s&&s.isDirectory);nextUpload()});function queueUpload(e,t){var s,o,n=document.createElement("li"),i=document.createElement("span");if(i.className="upload-name",i.textContent=e.name,s=document.createElement("span"),s.className="upload-state",n.appendChild(i),n.appendChild(s),document.getElementById("uploadList").appendChild(n),document.getElementById("uploadQueue").classList.add("show"),o={file:e,li:n,state:s,tries:0},t){uploadState(o,"failed","folders are not supported"),uploadFailed++;return}uploadState(o,"queued","queued"),uploadQueue.push(o)}function uploadState(e,t,n){e.li.className=t,e.state.textContent=n}function nextUpload(){if(uploadBusy)return;var e,n,t=uploadQueue.shift();if(!t){uploadFailed==0&&location.reload();return}uploadBusy=!0,t.tries++,n=new FormData,n.append("files",t.file,t.file.name),e=new XMLHttpRequest,e.open("POST",url),e.upload.onprogress=function(e){e.lengthComputable&&uploadState(t,"uploading",Math.floor(e.loaded/e.total*100)+"%")},e.onload=function(){e.status>=200&&e.status<400?(uploadState(t,"done","done"),uploadDone()):uploadError(t,e.status+" "+e.statusText)},e.onerror=function(){uploadError(t,"network error")},uploadState(t,"uploading","0%"),e.send(n)}function uploadError(e,t){if(e.tries<uploadRetries){uploadState(e,"queued","retry "+e.tries+" ("+t+")"),setTimeout(function(){uploadQueue.unshift(e),uploadDone()},1e3*e.tries);return}uploadFailed++,uploadState(e,"failed","failed ("+t+")");var n=document.createElement("button");n.type="button",n.className="btn btn-link p-0 ml-1",n.textContent="retry",n.onclick=function(){n.remove(),uploadFailed--,e.tries=0,uploadState(e,"queued","queued"),uploadQueue.push(e),nextUpload()},e.li.appendChild(n),uploadDone()}function uploadDone(){uploadBusy=!1,nextUpload()}galleryIndex=0;function openGallery(e){var t=Array.prototype.slice.call(document.querySelectorAll(".thumbnail"));galleryIndex=e?t.indexOf(e):0,document.getElementById("lightbox").style.display="flex",showImage(0)}function closeGallery(){document.getElementById("lightbox").style.display="none",document.getElementById("lightboxImage").removeAttribute("src")}function showImage(e){var n,t=document.querySelectorAll(".thumbnail");if(t.length==0)return;galleryIndex=(galleryIndex+e+t.length)%t.length,n=t[galleryIndex],document.getElementById("lightboxImage").src=n.getAttribute("data-src"),document.getElementById("lightboxCaption").innerText=n.getAttribute("data-name")+" ("+(galleryIndex+1)+"/"+t.length+")"}document.addEventListener("keydown",function(e){if(document.getElementById("lightbox").style.display!="flex")return;e.key=="Escape"?closeGallery():e.key=="ArrowLeft"?showImage(-1):e.key=="ArrowRight"&&showImage(1)}),wsURL="",location.protocol!=="https:"?wsURL="ws://"+window.location.host+goshsPrefix+"/14644be038ea0118a1aadfacca2a7d1517d7b209c4b9674ee893b1944d1c2d54/ws?channel="+encodeURIComponent(goshsPrefs.channel):wsURL="wss://"+window.location.host+goshsPrefix+"/14644be038ea0118a1aadfacca2a7d1517d7b209c4b9674ee893b1944d1c2d54/ws?channel="+encodeURIComponent(goshsPrefs.channel),connection=new WebSocket(wsURL),connection.onopen=function(){console.log("Connected via WebSockets")},connection.onclose=function(){console.log("Connection has been closed by WebSocket Server")},connection.onerror=function(e){console.log("Websocket error: ",e)},connection.onmessage=function(e){e.data.split(`
+`).forEach(function(e){try{var t=JSON.parse(e);t.type=="refreshClipboard"&&t.content==goshsPrefs.channel&&location.reload(),t.type=="refreshDirectory"&&directoryChanged(t.content)}catch(e){console.log("Error reading message: ",e)}})};function directoryChanged(e){if(e!=(goshsDir.replace(/\/$/,"")||"/"))return;var t=document.querySelector("#tableData_filter input"),n=selectedItems().length>0||uploadBusy||uploadQueue.length>0||document.querySelector(".modal.show")!=null||document.getElementById("lightbox").style.display=="flex"||t&&t.value!="";n?document.getElementById("changedNotice").style.display="block":location.reload()}function switchChannel(e){return e==""&&(e=prompt('Name of the new channel (letters, digits, ".", "_" and "-")'),!e)?(document.getElementById("cbChannel").value=goshsPrefs.channel,!1):(location.search="?channel="+encodeURIComponent(e),!1)}function sendEntry(e){e.preventDefault(),entryfield=document.getElementById("cbEntry");var t=entryfield.value;protectText(t).then(function(e){var t={type:"newEntry",content:{content:e,ttl:parseInt(document.getElementById("cbTTL").value,10),language:document.getElementById("cbLanguage").value,encrypted:clipboardPass!=""}};connection.send(JSON.stringify(t)),entryfield.value=""})}function clearClipboard(e){if(e.preventDefault,result=confirm("Are you sure you want to clear the clipboard?"),result){var t={type:"clearClipboard",content:""};connection.send(JSON.stringify(t))}}function delClipboard(e){var t={type:"delEntry",content:e};connection.send(JSON.stringify(t))}function copyClipboard(e){return copyText(document.querySelector("#card-"+e+" .cbEdit textarea").value),!1}function editClipboard(e){var t=document.getElementById("card-"+e);return t.classList.contains("encrypted")&&!t.classList.contains("decrypted")?(alert("Enter the passphrase of this entry first"),!1):(t.classList.toggle("editing"),t.classList.contains("editing")&&t.querySelector(".cbEdit textarea").focus(),!1)}function saveClipboard(e,t){e.preventDefault();var n=e.target;return protectText(n.querySelector("textarea").value).then(function(e){var s={type:"editEntry",content:{id:parseInt(t,10),content:e,language:n.querySelector("input").value,encrypted:clipboardPass!=""}};connection.send(JSON.stringify(s))}),!1}function saveClipboardFile(e){var t=prompt("Save entry as file",goshsDir.replace(/\/?$/,"/")+"clipboard-"+e+".txt");return!!t&&(fetch(goshsPrefix+"/api/v1/clipboard/"+e+"/save?channel="+encodeURIComponent(goshsPrefs.channel),{method:"POST",headers:{"Content-Type":"application/json"},body:JSON.stringify({path:t})}).then(function(e){if(!e.ok)return e.json().then(function(e){throw new Error(e.error)});location.reload()}).catch(function(e){alert("Unable to save the entry: "+e.message)}),!1)}clipboardPass=sessionStorage.getItem("goshsClipboardPass")||"";function clipboardKey(e){return crypto.subtle.importKey("raw",(new TextEncoder).encode(clipboardPass),"PBKDF2",!1,["deriveKey"]).then(function(t){return crypto.subtle.deriveKey({name:"PBKDF2",salt:e,iterations:2e5,hash:"SHA-256"},t,{name:"AES-GCM",length:256},!1,["encrypt","decrypt"])})}function protectText(e){if(!clipboardPass)return Promise.resolve(e);var t=crypto.getRandomValues(new Uint8Array(16)),n=crypto.getRandomValues(new Uint8Array(12));return clipboardKey(t).then(function(t){return crypto.subtle.encrypt({name:"AES-GCM",iv:n},t,(new TextEncoder).encode(e))}).then(function(e){var o,i,s=new Uint8Array(28+e.byteLength);s.set(t),s.set(n,16),s.set(new Uint8Array(e),28);for(i="",o=0;o<s.length;o+=32768)i+=String.fromCharCode.apply(null,s.subarray(o,o+32768));return btoa(i)})}function decryptText(e){var t=Uint8Array.from(atob(e),function(e){return e.charCodeAt(0)});return clipboardKey(t.slice(0,16)).then(function(e){return crypto.subtle.decrypt({name:"AES-GCM",iv:t.slice(16,28)},e,t.slice(28))}).then(function(e){return(new TextDecoder).decode(e)})}function toggleClipboardKey(){if(clipboardPass)sessionStorage.removeItem("goshsClipboardPass");else{if(!window.crypto||!crypto.subtle)return alert("Encryption in the browser needs https or localhost"),!1;var e=prompt("Passphrase shared with everybody who should read the clipboard");if(!e)return!1;sessionStorage.setItem("goshsClipboardPass",e)}return location.reload(),!1}function decryptClipboard(){if(!clipboardPass)return;var e=document.getElementById("cbLockButton");e&&(e.classList.replace("btn-secondary","btn-warning"),e.querySelector("span").textContent="Forget passphrase"),document.querySelectorAll(".clipboardCard.encrypted").forEach(function(e){var t=e.querySelector(".cbEdit textarea"),n=e.querySelector(".card-body pre");decryptText(t.value).then(function(s){t.value=s,n.textContent=s,e.classList.add("decrypted")},function(){n.textContent="Unable to decrypt, the entry was encrypted with another passphrase"})})}decryptClipboard()
\ No newline at end of file
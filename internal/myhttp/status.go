@@ -0,0 +1,71 @@
+package myhttp
+
+import (
+	"encoding/json"
+	"errors"
+	"html/template"
+	"net/http"
+	"time"
+
+	"github.com/patrickhener/goshs/internal/mymonitor"
+)
+
+const statusPath = "/073c1634c496cdb649d1afe0a312bbb4b7e1741b271542e4a436c3b8824b1761/status"
+
+type statusTemplate struct {
+	Prefix       string
+	GoshsVersion string
+	Uptime       string
+	Current      mymonitor.Run
+	State        mymonitor.State
+}
+
+// status will show the uptime, restart history and listener latencies
+func (fs *FileServer) status(w http.ResponseWriter, req *http.Request) {
+	if fs.readOnly(req) {
+		fs.handleError(w, req, errors.New("the status is only shown to users who may write"), http.StatusForbidden)
+		return
+	}
+
+	state := fs.Monitor.Snapshot()
+
+	if _, ok := req.URL.Query()["json"]; ok {
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(state); err != nil {
+			fs.Logger.Errorf("Error writing response to browser: %+v", err)
+		}
+		return
+	}
+
+	file, err := fs.readStatic("templates/status.html")
+	if err != nil {
+		fs.Logger.Errorf("opening embedded file: %+v", err)
+	}
+
+	current := fs.Monitor.Current()
+	tem := statusTemplate{
+		Prefix:       fs.Prefix,
+		GoshsVersion: fs.Version,
+		Uptime:       time.Since(current.Started).Round(time.Second).String(),
+		Current:      current,
+		State:        state,
+	}
+
+	t := template.New("status").Funcs(template.FuncMap{
+		"since": func(t time.Time) string {
+			return time.Since(t).Round(time.Second).String()
+		},
+		"fmtTime": func(t time.Time) string {
+			if t.IsZero() {
+				return ""
+			}
+			return t.Format("Mon Jan _2 15:04:05 2006")
+		},
+	})
+	if _, err := t.Parse(string(file)); err != nil {
+		fs.Logger.Errorf("parsing the template: %+v", err)
+	}
+	if err := t.Execute(w, tem); err != nil {
+		fs.Logger.Errorf("executing the template: %+v", err)
+	}
+}
@@ -0,0 +1,87 @@
+package myhttp
+
+import (
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/patrickhener/goshs/internal/mylog"
+	"github.com/patrickhener/goshs/internal/myutils"
+)
+
+// stealthName replaces the name of the tool in pages, scripts and cookies
+const stealthName = "app"
+
+// internalHashes are the first path elements of the internal routes
+var internalHashes = []string{
+	"425bda8487e36deccb30dd24be590b8744e3a28a8bb5a57d9b3fcd24ae09ad3c",
+	"14644be038ea0118a1aadfacca2a7d1517d7b209c4b9674ee893b1944d1c2d54",
+	"cf985bddf28fed5d5c53b069d6a6ebe601088ca6e20ec5a5a8438f8e1ffd9390",
+	"073c1634c496cdb649d1afe0a312bbb4b7e1741b271542e4a436c3b8824b1761",
+	"6959097001d10501ac7d54c0bdb8db61420f658f2922cc26e46d536119a31126",
+}
+
+// initStealth will draw random replacements for the internal routes of this run
+func (fs *FileServer) initStealth() {
+	fs.stealthOnce.Do(func() {
+		fs.stealthTokens = make(map[string]string, len(internalHashes))
+		pairs := []string{}
+		for _, hash := range internalHashes {
+			token, err := myutils.RandomHex(16)
+			if err != nil {
+				mylog.Fatalf("generating stealth token: %+v", err)
+			}
+			fs.stealthTokens[token] = hash
+			pairs = append(pairs, hash, token)
+		}
+		pairs = append(pairs, "goshs", stealthName)
+		fs.stealthReplacer = strings.NewReplacer(pairs...)
+	})
+}
+
+// disguise will replace the internal routes and the name of the tool in s if running in stealth mode
+func (fs *FileServer) disguise(s string) string {
+	if !fs.Stealth {
+		return s
+	}
+	fs.initStealth()
+	return fs.stealthReplacer.Replace(s)
+}
+
+// disguiseFile does the same as disguise for the text based static files
+func (fs *FileServer) disguiseFile(name string, content []byte) []byte {
+	if !fs.Stealth {
+		return content
+	}
+	switch path.Ext(name) {
+	case ".html", ".js", ".css", ".json":
+		return []byte(fs.disguise(string(content)))
+	}
+	return content
+}
+
+// StaticPath returns the url path of the static file name as served to browsers
+func (fs *FileServer) StaticPath(name string) string {
+	return fs.disguise(fs.Prefix + "/425bda8487e36deccb30dd24be590b8744e3a28a8bb5a57d9b3fcd24ae09ad3c/" + name)
+}
+
+// stealthGate maps the random routes of this run to the internal routes,
+// the well known ones are looked up in the webroot like any other path
+func (fs *FileServer) stealthGate(next http.Handler) http.Handler {
+	fs.initStealth()
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		first := strings.SplitN(strings.TrimPrefix(r.URL.Path, "/"), "/", 2)
+		for _, hash := range internalHashes {
+			if first[0] == hash {
+				fs.handler(w, r)
+				return
+			}
+		}
+		if hash, ok := fs.stealthTokens[first[0]]; ok {
+			first[0] = hash
+			r.URL.Path = "/" + strings.Join(first, "/")
+			r.URL.RawPath = ""
+		}
+		next.ServeHTTP(w, r)
+	})
+}
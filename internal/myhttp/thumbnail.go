@@ -0,0 +1,152 @@
+package myhttp
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	// register decoders for thumbnails
+	_ "image/gif"
+	"image/jpeg"
+	_ "image/png"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/patrickhener/goshs/internal/myutils"
+	// register decoders for thumbnails
+	_ "golang.org/x/image/bmp"
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+)
+
+const (
+	// thumbnailSize is the maximum width and height of a thumbnail in pixels
+	thumbnailSize = 96
+	// maxThumbnailPixels protects from decompression bombs
+	maxThumbnailPixels = 50 * 1000 * 1000
+	// maxThumbnails is the number of thumbnails kept in memory
+	maxThumbnails = 1000
+)
+
+var thumbnails = &thumbnailCache{entries: make(map[string]thumbnail)}
+
+type thumbnail struct {
+	modTime time.Time
+	size    int64
+	data    []byte
+}
+
+// thumbnailCache keeps rendered thumbnails until the source file changes
+type thumbnailCache struct {
+	sync.Mutex
+	entries map[string]thumbnail
+}
+
+func (c *thumbnailCache) get(file string, fi os.FileInfo) ([]byte, bool) {
+	c.Lock()
+	defer c.Unlock()
+	t, ok := c.entries[file]
+	if !ok || !t.modTime.Equal(fi.ModTime()) || t.size != fi.Size() {
+		return nil, false
+	}
+	return t.data, true
+}
+
+func (c *thumbnailCache) put(file string, fi os.FileInfo, data []byte) {
+	c.Lock()
+	defer c.Unlock()
+	if len(c.entries) >= maxThumbnails {
+		// Drop a random entry
+		for k := range c.entries {
+			delete(c.entries, k)
+			break
+		}
+	}
+	c.entries[file] = thumbnail{modTime: fi.ModTime(), size: fi.Size(), data: data}
+}
+
+// hasThumbnail reports whether a thumbnail can be rendered for name
+func hasThumbnail(name string) bool {
+	switch strings.ToLower(myutils.ReturnExt(name)) {
+	case ".jpg", ".jpeg", ".png", ".gif", ".bmp", ".webp":
+		return true
+	}
+	return false
+}
+
+// renderThumbnail will scale the image in file down to fit into thumbnailSize and encode it as jpeg
+func renderThumbnail(file *os.File) ([]byte, error) {
+	cfg, _, err := image.DecodeConfig(file)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Width*cfg.Height > maxThumbnailPixels {
+		return nil, fmt.Errorf("image with %dx%d pixels is too large for a thumbnail", cfg.Width, cfg.Height)
+	}
+	if _, err := file.Seek(0, 0); err != nil {
+		return nil, err
+	}
+	src, _, err := image.Decode(file)
+	if err != nil {
+		return nil, err
+	}
+
+	bounds := src.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width > thumbnailSize || height > thumbnailSize {
+		if width > height {
+			width, height = thumbnailSize, height*thumbnailSize/width
+		} else {
+			width, height = width*thumbnailSize/height, thumbnailSize
+		}
+	}
+	if width < 1 {
+		width = 1
+	}
+	if height < 1 {
+		height = 1
+	}
+
+	// Transparent areas become white as jpeg has no alpha channel
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(dst, dst.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	draw.ApproxBiLinear.Scale(dst, dst.Bounds(), src, bounds, draw.Over, nil)
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, dst, &jpeg.Options{Quality: 80}); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// thumbnail will send a small jpeg preview of the image file
+func (fs *FileServer) thumbnail(w http.ResponseWriter, req *http.Request, file *os.File) {
+	if fs.uploadOnly(req) {
+		fs.handleError(w, req, fmt.Errorf("%s", "Thumbnails not allowed due to 'upload only' option"), http.StatusForbidden)
+		return
+	}
+
+	fi, err := file.Stat()
+	if err != nil {
+		fs.handleError(w, req, err, http.StatusInternalServerError)
+		return
+	}
+	data, ok := thumbnails.get(file.Name(), fi)
+	if !ok {
+		data, err = renderThumbnail(file)
+		if err != nil {
+			fs.Logger.Debugf("rendering thumbnail of %s: %+v", file.Name(), err)
+			fs.handleError(w, req, err, http.StatusUnsupportedMediaType)
+			return
+		}
+		thumbnails.put(file.Name(), fi, data)
+	}
+
+	fs.Logger.LogRequest(req, http.StatusOK)
+	w.Header().Set("Content-Type", "image/jpeg")
+	w.Header().Set("Cache-Control", "private, max-age=3600")
+	http.ServeContent(w, req, "", fi.ModTime(), bytes.NewReader(data))
+}
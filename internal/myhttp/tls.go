@@ -0,0 +1,133 @@
+package myhttp
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/patrickhener/goshs/internal/myca"
+	"github.com/patrickhener/goshs/internal/mylog"
+)
+
+const certReloadInterval = 10 * time.Second
+
+// tlsConfig will build the tls config shared by the listeners and set the fingerprints
+func (fs *FileServer) tlsConfig() (*tls.Config, error) {
+	fs.tlsOnce.Do(func() {
+		fs.tlsConf, fs.tlsErr = fs.buildTLSConfig()
+	})
+	return fs.tlsConf, fs.tlsErr
+}
+
+func (fs *FileServer) buildTLSConfig() (*tls.Config, error) {
+	var conf *tls.Config
+
+	// Check if selfsigned
+	if fs.SelfSigned {
+		serverTLSConf, caPEM, fingerprint256, fingerprint1, err := myca.Setup(fs.CertOptions)
+		if err != nil {
+			return nil, err
+		}
+		conf = serverTLSConf
+		fs.caPEM = caPEM
+		fs.Fingerprint256 = fingerprint256
+		fs.Fingerprint1 = fingerprint1
+	} else {
+		var load func() (tls.Certificate, error)
+		var files []string
+		if fs.PKCS12 != "" {
+			load = func() (tls.Certificate, error) {
+				cert, _, _, err := myca.LoadPKCS12(fs.PKCS12, fs.PKCS12Pass)
+				return cert, err
+			}
+			files = []string{fs.PKCS12}
+		} else {
+			if fs.MyCert == "" || fs.MyKey == "" {
+				return nil, errors.New("you need to provide server.key and server.crt or a PKCS#12 bundle if -s and not -ss")
+			}
+			load = func() (tls.Certificate, error) {
+				return tls.LoadX509KeyPair(fs.MyCert, fs.MyKey)
+			}
+			files = []string{fs.MyCert, fs.MyKey}
+		}
+
+		// Renewed certificates (e.g. by certbot) are picked up without a restart
+		reloader, err := myca.NewCertReloader(load, certReloadInterval, files...)
+		if err != nil {
+			return nil, err
+		}
+		cert, _ := reloader.GetCertificate(nil)
+		fs.Fingerprint256, fs.Fingerprint1 = myca.Sum(cert.Certificate[0])
+
+		// Additional certificates are chosen by the server name the client asks for
+		reloaders := []*myca.CertReloader{reloader}
+		for _, pair := range fs.SNICerts {
+			pair := pair
+			r, err := myca.NewCertReloader(func() (tls.Certificate, error) {
+				return tls.LoadX509KeyPair(pair.Cert, pair.Key)
+			}, certReloadInterval, pair.Cert, pair.Key)
+			if err != nil {
+				return nil, err
+			}
+			reloaders = append(reloaders, r)
+			fs.Logger.Infof("Serving %s to clients asking for one of its names via SNI", pair.Cert)
+		}
+
+		conf = &tls.Config{
+			GetCertificate: myca.SelectBySNI(reloaders),
+			MinVersion:     tls.VersionTLS12,
+		}
+	}
+
+	// Operator choices win over the defaults, even if weaker
+	if fs.TLSMinVersion != 0 {
+		// disable G402 (CWE-295): TLS MinVersion too low
+		// as legacy clients are supported on purpose
+		// #nosec G402
+		conf.MinVersion = fs.TLSMinVersion
+	}
+	if len(fs.TLSCiphers) > 0 {
+		conf.CipherSuites = fs.TLSCiphers
+	}
+
+	return conf, nil
+}
+
+// http2Capable checks if the cipher suites allow HTTP/2, empty means the defaults
+func http2Capable(ciphers []uint16) bool {
+	if len(ciphers) == 0 {
+		return true
+	}
+	for _, c := range ciphers {
+		if c == tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256 || c == tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256 {
+			return true
+		}
+	}
+	return false
+}
+
+// StartRedirect will answer plain HTTP requests on port with a redirect to the TLS listener
+func (fs *FileServer) StartRedirect(port int) {
+	redirect := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host, _, err := net.SplitHostPort(r.Host)
+		if err != nil {
+			host = r.Host
+		}
+		target := fmt.Sprintf("https://%s%s", net.JoinHostPort(host, strconv.Itoa(fs.Port)), r.URL.RequestURI())
+		fs.Logger.LogRequest(r, http.StatusMovedPermanently)
+		http.Redirect(w, r, target, http.StatusMovedPermanently)
+	})
+
+	server := http.Server{
+		Addr:              fs.address(port),
+		Handler:           fs.hooked(redirect),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	fs.Logger.Infof("Redirecting HTTP on port %d to HTTPS on port %d", port, fs.Port)
+	mylog.Panic(server.ListenAndServe())
+}
@@ -0,0 +1,80 @@
+package myhttp
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/patrickhener/goshs/internal/myutils"
+)
+
+const (
+	treePath = "/073c1634c496cdb649d1afe0a312bbb4b7e1741b271542e4a436c3b8824b1761/tree"
+	// maxTreeChildren limits the directories returned for one node
+	maxTreeChildren = 1000
+)
+
+type treeNode struct {
+	Name string `json:"name"`
+	Path string `json:"path"`
+}
+
+// tree will list the subdirectories of the query parameter 'path' as json for the sidebar
+func (fs *FileServer) tree(w http.ResponseWriter, req *http.Request) {
+	// Sanitize path (No path traversal)
+	relpath := path.Clean("/" + req.URL.Query().Get("path"))
+	if fs.UploadOnly || fs.Mounts.UploadOnly(relpath) {
+		fs.handleError(w, req, fmt.Errorf("%s", "Listing not allowed due to 'upload only' option"), http.StatusForbidden)
+		return
+	}
+	// disable G304 (CWE-22): Potential file inclusion via variable
+	// as the path is cleaned and joined below the webroot
+	// #nosec G304
+	dir, err := os.Open(fs.abs(relpath))
+	if err != nil {
+		if os.IsNotExist(err) {
+			fs.handleError(w, req, err, http.StatusNotFound)
+			return
+		}
+		fs.handleError(w, req, err, http.StatusInternalServerError)
+		return
+	}
+	// disable G307 (CWE-703): Deferring unsafe method "Close" on type "*os.File"
+	// #nosec G307
+	defer dir.Close()
+	fis, err := dir.Readdir(-1)
+	if err != nil {
+		fs.handleError(w, req, err, http.StatusBadRequest)
+		return
+	}
+
+	fis = fs.Mounts.List(relpath, fis)
+
+	hidden := fs.listPrefs(w, req).Hidden
+	nodes := []treeNode{}
+	for _, fi := range fis {
+		if !fi.IsDir() || myutils.CheckSpecialPath(fi.Name()) {
+			continue
+		}
+		if !hidden && strings.HasPrefix(fi.Name(), ".") {
+			continue
+		}
+		nodes = append(nodes, treeNode{Name: fi.Name(), Path: path.Join(relpath, fi.Name())})
+	}
+	sort.Slice(nodes, func(i, j int) bool {
+		return strings.ToLower(nodes[i].Name) < strings.ToLower(nodes[j].Name)
+	})
+	if len(nodes) > maxTreeChildren {
+		nodes = nodes[:maxTreeChildren]
+	}
+
+	fs.Logger.LogRequest(req, http.StatusOK)
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(nodes); err != nil {
+		fs.Logger.Errorf("Error writing response to browser: %+v", err)
+	}
+}
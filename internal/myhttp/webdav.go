@@ -0,0 +1,43 @@
+package myhttp
+
+import (
+	"net/http"
+
+	"github.com/patrickhener/goshs/internal/mymount"
+	"golang.org/x/net/webdav"
+)
+
+// webdavPath is where WebDAV is mounted on the main listener
+const webdavPath = "/webdav"
+
+// webdavHandler serves the webroot and the mounts via WebDAV below prefix
+func (fs *FileServer) webdavHandler(prefix string) *webdav.Handler {
+	var fileSystem webdav.FileSystem = webdav.Dir(fs.Webroot)
+	if len(fs.Mounts) > 0 {
+		fileSystem = mymount.FileSystem{Webroot: fs.Webroot, Mounts: fs.Mounts}
+	}
+	return &webdav.Handler{
+		Prefix:     prefix,
+		FileSystem: fileSystem,
+		LockSystem: webdav.NewMemLS(),
+		Logger: func(r *http.Request, e error) {
+			if e != nil && r.Method != "PROPFIND" {
+				fs.Logger.Errorf("WEBDAV: %s - - \"%s %s %s\"", r.RemoteAddr, r.Method, r.URL.Path, r.Proto)
+				return
+			} else if r.Method != "PROPFIND" {
+				fs.Logger.Infof("WEBDAV:  %s - - \"%s %s %s\"", r.RemoteAddr, r.Method, r.URL.Path, r.Proto)
+			}
+		},
+	}
+}
+
+// webdavMount serves WebDAV below /webdav/ of the main listener
+func (fs *FileServer) webdavMount() http.Handler {
+	wdHandler := fs.webdavHandler(fs.Prefix + webdavPath)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The secret prefix is stripped by now but the clients need it in the hrefs
+		r.URL.Path = fs.Prefix + r.URL.Path
+		r.URL.RawPath = ""
+		wdHandler.ServeHTTP(w, r)
+	})
+}
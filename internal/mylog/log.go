@@ -1,30 +1,53 @@
 package mylog
 
 import (
+	"fmt"
+	"io"
+	"io/ioutil"
 	"net/http"
 	"os"
+	"strings"
+	"sync"
 
+	"github.com/patrickhener/goshs/internal/myrotate"
 	"github.com/sirupsen/logrus"
 )
 
+// RequestHook is called for every request passed to LogRequest
+type RequestHook func(req *http.Request, status int)
+
+var requestHooks []RequestHook
+
+// AddRequestHook will register a hook which receives every request once with its final status
+func AddRequestHook(hook RequestHook) {
+	requestHooks = append(requestHooks, hook)
+}
+
+// RunRequestHooks will pass the request and its final status to the hooks, it is called once per
+// request by the server and for the transfers of the websocket and SFTP
+func RunRequestHooks(req *http.Request, status int) {
+	for _, hook := range requestHooks {
+		hook(req, status)
+	}
+}
+
+var remoteInfo func(addr string) string
+
+// SetRemoteInfo will append what info returns for the client address to every logged request
+func SetRemoteInfo(info func(addr string) string) {
+	remoteInfo = info
+}
+
 // LogRequest will log the request in a uniform way
 func LogRequest(req *http.Request, status int) {
-	if status == http.StatusInternalServerError || status == http.StatusNotFound {
-		logger.Errorf("%s - - \"%s %s %s\" - %+v", req.RemoteAddr, req.Method, req.URL, req.Proto, status)
-		return
-	}
-	logger.Infof("%s - - \"%s %s %s\" - %+v", req.RemoteAddr, req.Method, req.URL, req.Proto, status)
-	if req.URL.Query() != nil {
-		for k, v := range req.URL.Query() {
-			logger.Debugf("Parameter %s is %s", k, v)
-		}
-	}
+	Logger{}.LogRequest(req, status)
 }
 
 var logger *StandardLogger
 
 func init() {
 	logger = NewLogger()
+	logger.AddHook(redirect)
 }
 
 // Event stores messages to log later, from our standard interface.
@@ -65,6 +88,21 @@ func NewLogger() *StandardLogger {
 	return standardLogger
 }
 
+// SetLevel will only emit messages of level (debug, info, warn or error) and above
+func SetLevel(level string) error {
+	switch level {
+	case "debug", "info", "warn", "error":
+	default:
+		return fmt.Errorf("unknown log level %q, use debug, info, warn or error", level)
+	}
+	l, err := logrus.ParseLevel(level)
+	if err != nil {
+		return err
+	}
+	logger.SetLevel(l)
+	return nil
+}
+
 // Declare variables to store log messages as new Events
 var (
 	missingEnvMessage = Event{1, "Missing env key: %s"}
@@ -125,6 +163,11 @@ func Errorf(format string, args ...interface{}) {
 	logger.Errorf(format, args...)
 }
 
+// AtExit will run f before a fatal message ends the process
+func AtExit(f func()) {
+	logrus.RegisterExitHandler(f)
+}
+
 // Fatal Log
 func Fatal(args ...interface{}) {
 	logger.Fatalln(args...)
@@ -134,3 +177,199 @@ func Fatal(args ...interface{}) {
 func Fatalf(format string, args ...interface{}) {
 	logger.Fatalf(format, args...)
 }
+
+// fileHook writes every entry to a file without colors
+type fileHook struct {
+	w         io.Writer
+	formatter logrus.Formatter
+}
+
+func (h *fileHook) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+func (h *fileHook) Fire(entry *logrus.Entry) error {
+	line, err := h.formatter.Format(entry)
+	if err != nil {
+		return err
+	}
+	_, err = h.w.Write(line)
+	return err
+}
+
+// LogFile will append every message to file in addition to the terminal, rotated according to opts
+func LogFile(file string, opts myrotate.Options) error {
+	f, err := myrotate.Open(file, opts)
+	if err != nil {
+		return err
+	}
+	logger.AddHook(&fileHook{
+		w: f,
+		formatter: &logrus.TextFormatter{
+			FullTimestamp:   true,
+			DisableColors:   true,
+			PadLevelText:    true,
+			TimestampFormat: "2006-01-02 15:04:05",
+		},
+	})
+	return nil
+}
+
+// Sink receives the messages of the logger, e.g. the logger of a program embedding goshs
+type Sink interface {
+	Debugf(format string, args ...interface{})
+	Infof(format string, args ...interface{})
+	Warnf(format string, args ...interface{})
+	Errorf(format string, args ...interface{})
+}
+
+// sinkHook passes every entry to a sink
+type sinkHook struct {
+	mu   sync.RWMutex
+	sink Sink
+}
+
+func (h *sinkHook) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+func (h *sinkHook) Fire(entry *logrus.Entry) error {
+	h.mu.RLock()
+	sink := h.sink
+	h.mu.RUnlock()
+	if sink == nil {
+		return nil
+	}
+	message := strings.TrimRight(entry.Message, "\n")
+	switch entry.Level {
+	case logrus.DebugLevel, logrus.TraceLevel:
+		sink.Debugf("%s", message)
+	case logrus.InfoLevel:
+		sink.Infof("%s", message)
+	case logrus.WarnLevel:
+		sink.Warnf("%s", message)
+	default:
+		sink.Errorf("%s", message)
+	}
+	return nil
+}
+
+// redirect is registered with the logger, messages go to its sink if set
+var redirect = &sinkHook{}
+
+// Redirect will pass the messages of the current level to sink instead of the terminal,
+// a later call replaces the sink and nil writes to the terminal again
+func Redirect(sink Sink) {
+	redirect.mu.Lock()
+	defer redirect.mu.Unlock()
+	redirect.sink = sink
+	if sink == nil {
+		logger.SetOutput(os.Stderr)
+		return
+	}
+	logger.SetOutput(ioutil.Discard)
+}
+
+// Logger logs to Sink if set and like the package functions otherwise, so the servers embedded
+// in one process can log to their own sinks. The zero value logs like the package functions.
+type Logger struct {
+	Sink Sink
+}
+
+// LogRequest will log the request in a uniform way
+func (l Logger) LogRequest(req *http.Request, status int) {
+	var details string
+	if remoteInfo != nil {
+		if info := remoteInfo(req.RemoteAddr); info != "" {
+			details = " (" + info + ")"
+		}
+	}
+
+	if status == http.StatusInternalServerError || status == http.StatusNotFound {
+		l.Errorf("%s - - \"%s %s %s\" - %+v%s", req.RemoteAddr, req.Method, req.URL, req.Proto, status, details)
+		return
+	}
+	l.Infof("%s - - \"%s %s %s\" - %+v%s", req.RemoteAddr, req.Method, req.URL, req.Proto, status, details)
+	if req.URL.Query() != nil {
+		for k, v := range req.URL.Query() {
+			l.Debugf("Parameter %s is %s", k, v)
+		}
+	}
+}
+
+// line formats args like the ln functions of the package log do without the newline
+func line(args ...interface{}) string {
+	return strings.TrimRight(fmt.Sprintln(args...), "\n")
+}
+
+// Debug Log
+func (l Logger) Debug(args ...interface{}) {
+	if l.Sink == nil {
+		Debug(args...)
+		return
+	}
+	l.Sink.Debugf("%s", line(args...))
+}
+
+// Debugf Log
+func (l Logger) Debugf(format string, args ...interface{}) {
+	if l.Sink == nil {
+		Debugf(format, args...)
+		return
+	}
+	l.Sink.Debugf(format, args...)
+}
+
+// Info Log
+func (l Logger) Info(args ...interface{}) {
+	if l.Sink == nil {
+		Info(args...)
+		return
+	}
+	l.Sink.Infof("%s", line(args...))
+}
+
+// Infof Log
+func (l Logger) Infof(format string, args ...interface{}) {
+	if l.Sink == nil {
+		Infof(format, args...)
+		return
+	}
+	l.Sink.Infof(format, args...)
+}
+
+// Warn Log
+func (l Logger) Warn(args ...interface{}) {
+	if l.Sink == nil {
+		Warn(args...)
+		return
+	}
+	l.Sink.Warnf("%s", line(args...))
+}
+
+// Warnf Log
+func (l Logger) Warnf(format string, args ...interface{}) {
+	if l.Sink == nil {
+		Warnf(format, args...)
+		return
+	}
+	l.Sink.Warnf(format, args...)
+}
+
+// Error Log
+func (l Logger) Error(args ...interface{}) {
+	if l.Sink == nil {
+		Error(args...)
+		return
+	}
+	l.Sink.Errorf("%s", line(args...))
+}
+
+// Errorf Log
+func (l Logger) Errorf(format string, args ...interface{}) {
+	if l.Sink == nil {
+		Errorf(format, args...)
+		return
+	}
+	l.Sink.Errorf(format, args...)
+}
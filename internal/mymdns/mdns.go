@@ -0,0 +1,41 @@
+// Package mymdns announces the share on the local network via mDNS/DNS-SD
+package mymdns
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/grandcat/zeroconf"
+	"github.com/patrickhener/goshs/internal/mylog"
+)
+
+// DefaultInstance returns the instance name used if none is given
+func DefaultInstance() string {
+	host, err := os.Hostname()
+	if err != nil {
+		return "goshs"
+	}
+	return fmt.Sprintf("goshs on %s", host)
+}
+
+// Announce will register the service until the returned function is called.
+// The path is announced as TXT record as proposed for _http._tcp services.
+func Announce(instance string, port int, ssl bool, path string) (func(), error) {
+	service := "_http._tcp"
+	if ssl {
+		service = "_https._tcp"
+	}
+
+	txt := []string{"txtvers=1"}
+	if path != "" {
+		txt = append(txt, "path="+path)
+	}
+
+	server, err := zeroconf.Register(instance, service, "local.", port, txt, nil)
+	if err != nil {
+		return nil, err
+	}
+	mylog.Infof("Announcing '%s' as %s on port %d via mDNS", instance, service, port)
+
+	return server.Shutdown, nil
+}
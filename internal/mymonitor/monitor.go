@@ -0,0 +1,310 @@
+// Package mymonitor keeps track of the uptime and the latency of the goshs
+// listeners. The history of runs and samples can be persisted to a state file
+// so silent restarts or dropped listeners become visible afterwards.
+package mymonitor
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/patrickhener/goshs/internal/mylog"
+)
+
+const (
+	probeInterval = 30 * time.Second
+	probeTimeout  = 5 * time.Second
+	maxRuns       = 100
+	maxSamples    = 240
+)
+
+// Run is a single run of goshs
+type Run struct {
+	Version string    `json:"version"`
+	PID     int       `json:"pid"`
+	Started time.Time `json:"started"`
+	// Stopped stays zero if goshs did not shut down cleanly
+	Stopped time.Time `json:"stopped"`
+}
+
+// Sample is a single probe of a listener
+type Sample struct {
+	Time      time.Time `json:"time"`
+	Up        bool      `json:"up"`
+	LatencyMS float64   `json:"latency_ms"`
+}
+
+// Listener holds the state of a single listener
+type Listener struct {
+	Name    string    `json:"name"`
+	Method  string    `json:"method"`
+	URL     string    `json:"url"`
+	Up      bool      `json:"up"`
+	Since   time.Time `json:"since"`
+	Outages int       `json:"outages"`
+	Samples []Sample  `json:"samples"`
+}
+
+// State is what gets persisted
+type State struct {
+	Runs      []Run                `json:"runs"`
+	Listeners map[string]*Listener `json:"listeners"`
+}
+
+// Monitor probes the listeners and keeps the history
+type Monitor struct {
+	mu     sync.Mutex
+	file   string
+	state  State
+	client *http.Client
+	done   chan struct{}
+}
+
+// New will load the state from file (if not empty) and record the start of this run
+func New(file, version string) *Monitor {
+	m := &Monitor{
+		file: file,
+		state: State{
+			Listeners: make(map[string]*Listener),
+		},
+		client: &http.Client{
+			Timeout: probeTimeout,
+			// disable G402 (CWE-295): TLS InsecureSkipVerify set true
+			// as we are talking to ourselves
+			// #nosec G402
+			Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}},
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		done: make(chan struct{}),
+	}
+
+	if file != "" {
+		if err := m.load(); err != nil && !os.IsNotExist(err) {
+			mylog.Errorf("loading monitor state: %+v", err)
+		}
+	}
+
+	if n := len(m.state.Runs); n > 0 && m.state.Runs[n-1].Stopped.IsZero() {
+		mylog.Warnf("Previous run started at %s did not shut down cleanly", m.state.Runs[n-1].Started.Format("2006-01-02 15:04:05"))
+	}
+
+	m.state.Runs = append(m.state.Runs, Run{
+		Version: version,
+		PID:     os.Getpid(),
+		Started: time.Now(),
+	})
+	if len(m.state.Runs) > maxRuns {
+		m.state.Runs = m.state.Runs[len(m.state.Runs)-maxRuns:]
+	}
+	m.save()
+
+	go m.run()
+
+	return m
+}
+
+// Watch will start probing the listener with a request of method to url
+func (m *Monitor) Watch(name, method, url string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	l, ok := m.state.Listeners[name]
+	if !ok {
+		l = &Listener{Name: name}
+		m.state.Listeners[name] = l
+	}
+	l.Method = method
+	l.URL = url
+	// Every run starts down until the first successful probe
+	l.Up = false
+	l.Since = time.Now()
+}
+
+// Stop records the clean shutdown and persists the state
+func (m *Monitor) Stop() {
+	close(m.done)
+
+	m.mu.Lock()
+	m.state.Runs[len(m.state.Runs)-1].Stopped = time.Now()
+	m.mu.Unlock()
+
+	m.save()
+}
+
+// Snapshot returns a copy of the current state
+func (m *Monitor) Snapshot() State {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	s := State{
+		Runs:      append([]Run(nil), m.state.Runs...),
+		Listeners: make(map[string]*Listener, len(m.state.Listeners)),
+	}
+	for k, l := range m.state.Listeners {
+		c := *l
+		c.Samples = append([]Sample(nil), l.Samples...)
+		s.Listeners[k] = &c
+	}
+	return s
+}
+
+// Current returns the running run
+func (m *Monitor) Current() Run {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.state.Runs[len(m.state.Runs)-1]
+}
+
+// SortedListeners returns the listeners of the state sorted by name
+func (s State) SortedListeners() []*Listener {
+	listeners := make([]*Listener, 0, len(s.Listeners))
+	for _, l := range s.Listeners {
+		listeners = append(listeners, l)
+	}
+	sort.Slice(listeners, func(i, j int) bool {
+		return listeners[i].Name < listeners[j].Name
+	})
+	return listeners
+}
+
+// AvgLatency returns the average latency of the successful samples in ms
+func (l *Listener) AvgLatency() float64 {
+	var sum float64
+	var n int
+	for _, s := range l.Samples {
+		if s.Up {
+			sum += s.LatencyMS
+			n++
+		}
+	}
+	if n == 0 {
+		return 0
+	}
+	return sum / float64(n)
+}
+
+// LastLatency returns the latency of the last sample in ms
+func (l *Listener) LastLatency() float64 {
+	if len(l.Samples) == 0 {
+		return 0
+	}
+	return l.Samples[len(l.Samples)-1].LatencyMS
+}
+
+func (m *Monitor) run() {
+	// Give the listeners a moment to come up
+	time.Sleep(time.Second)
+	m.probeAll()
+
+	ticker := time.NewTicker(probeInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			m.probeAll()
+			m.save()
+		case <-m.done:
+			return
+		}
+	}
+}
+
+func (m *Monitor) probeAll() {
+	m.mu.Lock()
+	probes := make(map[string]Listener, len(m.state.Listeners))
+	for name, l := range m.state.Listeners {
+		if l.URL != "" {
+			probes[name] = *l
+		}
+	}
+	m.mu.Unlock()
+
+	for name, l := range probes {
+		sample := m.probe(l.Method, l.URL)
+
+		m.mu.Lock()
+		l := m.state.Listeners[name]
+		if l.Up != sample.Up {
+			if !sample.Up {
+				l.Outages++
+				mylog.Warnf("Listener %s stopped responding", name)
+			}
+			l.Up = sample.Up
+			l.Since = sample.Time
+		}
+		l.Samples = append(l.Samples, sample)
+		if len(l.Samples) > maxSamples {
+			l.Samples = l.Samples[len(l.Samples)-maxSamples:]
+		}
+		m.mu.Unlock()
+	}
+}
+
+func (m *Monitor) probe(method, url string) Sample {
+	req, err := http.NewRequest(method, url, nil)
+	if err != nil {
+		mylog.Errorf("creating probe request: %+v", err)
+		return Sample{Time: time.Now()}
+	}
+	req.Header.Set("Depth", "0")
+
+	start := time.Now()
+	resp, err := m.client.Do(req)
+	sample := Sample{
+		Time:      start,
+		LatencyMS: float64(time.Since(start).Microseconds()) / 1000,
+	}
+	if err != nil {
+		mylog.Debugf("probing %s: %+v", url, err)
+		return sample
+	}
+	// Any answer, even 401, means the listener is alive
+	sample.Up = true
+	if err := resp.Body.Close(); err != nil {
+		mylog.Debugf("closing probe response: %+v", err)
+	}
+	return sample
+}
+
+func (m *Monitor) load() error {
+	// disable G304 (CWE-22): Potential file inclusion via variable
+	// as the operator chooses the state file
+	// #nosec G304
+	b, err := ioutil.ReadFile(m.file)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(b, &m.state); err != nil {
+		return err
+	}
+	if m.state.Listeners == nil {
+		m.state.Listeners = make(map[string]*Listener)
+	}
+	return nil
+}
+
+func (m *Monitor) save() {
+	if m.file == "" {
+		return
+	}
+
+	m.mu.Lock()
+	b, err := json.MarshalIndent(m.state, "", "    ")
+	m.mu.Unlock()
+	if err != nil {
+		mylog.Errorf("encoding monitor state: %+v", err)
+		return
+	}
+
+	if err := ioutil.WriteFile(m.file, b, 0600); err != nil {
+		mylog.Errorf("writing monitor state: %+v", err)
+	}
+}
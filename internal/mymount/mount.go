@@ -0,0 +1,169 @@
+// Package mymount maps url paths to the webroot and to further directories
+// mounted below virtual paths, each with its own read-only or upload-only mode.
+package mymount
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Mount serves Dir below the url path Path
+type Mount struct {
+	Path       string
+	Dir        string
+	ReadOnly   bool
+	UploadOnly bool
+}
+
+// Parse will parse a mount of the form /path:dir with an optional :ro or :uo suffix
+func Parse(spec string) (Mount, error) {
+	i := strings.Index(spec, ":")
+	if !strings.HasPrefix(spec, "/") || i < 0 {
+		return Mount{}, fmt.Errorf("%s is not of the form /path:dir", spec)
+	}
+	m := Mount{Path: path.Clean(spec[:i]), Dir: spec[i+1:]}
+	switch {
+	case strings.HasSuffix(m.Dir, ":ro"):
+		m.ReadOnly = true
+		m.Dir = strings.TrimSuffix(m.Dir, ":ro")
+	case strings.HasSuffix(m.Dir, ":uo"):
+		m.UploadOnly = true
+		m.Dir = strings.TrimSuffix(m.Dir, ":uo")
+	case strings.HasSuffix(m.Dir, ":rw"):
+		m.Dir = strings.TrimSuffix(m.Dir, ":rw")
+	}
+	if m.Path == "/" || strings.Count(m.Path, "/") != 1 {
+		return Mount{}, fmt.Errorf("%s needs to be a single directory below the webroot like /tools", spec[:i])
+	}
+	if m.Dir == "" {
+		return Mount{}, fmt.Errorf("%s is missing the directory", spec)
+	}
+	return m, nil
+}
+
+// String returns the mount as Parse takes it
+func (m Mount) String() string {
+	s := m.Path + ":" + m.Dir
+	if m.ReadOnly {
+		s += ":ro"
+	}
+	if m.UploadOnly {
+		s += ":uo"
+	}
+	return s
+}
+
+// Mounts are the directories mounted next to the content of the webroot
+type Mounts []Mount
+
+// Add will add m with its directory made absolute, it replaces a mount of the same path
+func (ms *Mounts) Add(m Mount) error {
+	dir, err := filepath.Abs(m.Dir)
+	if err != nil {
+		return err
+	}
+	if fi, err := os.Stat(dir); err != nil || !fi.IsDir() {
+		return fmt.Errorf("%s is not a directory", dir)
+	}
+	m.Dir = dir
+	for i := range *ms {
+		if (*ms)[i].Path == m.Path {
+			(*ms)[i] = m
+			return nil
+		}
+	}
+	*ms = append(*ms, m)
+	return nil
+}
+
+// Find returns the mount upath lies in, nil if it lies in the webroot
+func (ms Mounts) Find(upath string) *Mount {
+	upath = clean(upath)
+	for i := range ms {
+		if upath == ms[i].Path || strings.HasPrefix(upath, ms[i].Path+"/") {
+			return &ms[i]
+		}
+	}
+	return nil
+}
+
+// Path returns where upath is stored on disk
+func (ms Mounts) Path(webroot, upath string) string {
+	upath = clean(upath)
+	if m := ms.Find(upath); m != nil {
+		return filepath.Join(m.Dir, filepath.FromSlash(strings.TrimPrefix(upath, m.Path)))
+	}
+	return filepath.Join(webroot, filepath.FromSlash(upath))
+}
+
+// ReadOnly reports whether upath lies in a read only mount
+func (ms Mounts) ReadOnly(upath string) bool {
+	m := ms.Find(upath)
+	return m != nil && m.ReadOnly
+}
+
+// UploadOnly reports whether upath lies in an upload only mount
+func (ms Mounts) UploadOnly(upath string) bool {
+	m := ms.Find(upath)
+	return m != nil && m.UploadOnly
+}
+
+// IsMountPoint reports whether upath is where a directory is mounted, it cannot be removed or renamed
+func (ms Mounts) IsMountPoint(upath string) bool {
+	m := ms.Find(upath)
+	return m != nil && m.Path == clean(upath)
+}
+
+// List returns the content fis of the directory upath with the mount points in it, they
+// hide what is stored under the same name in the webroot
+func (ms Mounts) List(upath string, fis []os.FileInfo) []os.FileInfo {
+	if len(ms) == 0 || clean(upath) != "/" {
+		return fis
+	}
+	listed := make([]os.FileInfo, 0, len(fis)+len(ms))
+	for _, fi := range fis {
+		if !ms.IsMountPoint(fi.Name()) {
+			listed = append(listed, fi)
+		}
+	}
+	for _, m := range ms {
+		fi, err := os.Stat(m.Dir)
+		if err != nil {
+			continue
+		}
+		listed = append(listed, entry{FileInfo: fi, name: path.Base(m.Path)})
+	}
+	return listed
+}
+
+// Hide will remove the directories on disk from msg, clients shall not learn them
+func (ms Mounts) Hide(webroot, msg string) string {
+	dirs := []string{webroot}
+	for _, m := range ms {
+		dirs = append(dirs, m.Dir)
+	}
+	// Nested directories are removed first
+	sort.Slice(dirs, func(i, j int) bool { return len(dirs[i]) > len(dirs[j]) })
+	for _, dir := range dirs {
+		msg = strings.ReplaceAll(msg, dir, "")
+	}
+	return msg
+}
+
+// entry is a mount point in a listing
+type entry struct {
+	os.FileInfo
+	name string
+}
+
+func (e entry) Name() string {
+	return e.name
+}
+
+func clean(upath string) string {
+	return path.Clean("/" + filepath.ToSlash(upath))
+}
@@ -0,0 +1,113 @@
+package mymount
+
+import (
+	"context"
+	"os"
+	"strings"
+
+	"golang.org/x/net/webdav"
+)
+
+// FileSystem serves the webroot and the mounts via WebDAV with the mode of each mount
+type FileSystem struct {
+	Webroot string
+	Mounts  Mounts
+}
+
+// dir returns the directory name lies in and the name within it
+func (f FileSystem) dir(name string) (webdav.Dir, string) {
+	name = clean(name)
+	if m := f.Mounts.Find(name); m != nil {
+		return webdav.Dir(m.Dir), clean(strings.TrimPrefix(name, m.Path))
+	}
+	return webdav.Dir(f.Webroot), name
+}
+
+// Mkdir implements webdav.FileSystem
+func (f FileSystem) Mkdir(ctx context.Context, name string, perm os.FileMode) error {
+	if f.Mounts.ReadOnly(name) || f.Mounts.IsMountPoint(name) {
+		return os.ErrPermission
+	}
+	dir, rel := f.dir(name)
+	return dir.Mkdir(ctx, rel, perm)
+}
+
+// OpenFile implements webdav.FileSystem
+func (f FileSystem) OpenFile(ctx context.Context, name string, flag int, perm os.FileMode) (webdav.File, error) {
+	write := flag&(os.O_WRONLY|os.O_RDWR|os.O_CREATE|os.O_TRUNC|os.O_APPEND) != 0
+	if write && f.Mounts.ReadOnly(name) {
+		return nil, os.ErrPermission
+	}
+	dir, rel := f.dir(name)
+	file, err := dir.OpenFile(ctx, rel, flag, perm)
+	if err != nil {
+		return nil, err
+	}
+	if !write && f.Mounts.UploadOnly(name) {
+		fi, err := file.Stat()
+		if err != nil || !fi.IsDir() {
+			file.Close()
+			return nil, os.ErrPermission
+		}
+		return emptyDir{file}, nil
+	}
+	if clean(name) == "/" {
+		return rootDir{File: file, mounts: f.Mounts}, nil
+	}
+	return file, nil
+}
+
+// RemoveAll implements webdav.FileSystem
+func (f FileSystem) RemoveAll(ctx context.Context, name string) error {
+	if clean(name) == "/" || f.Mounts.ReadOnly(name) || f.Mounts.UploadOnly(name) || f.Mounts.IsMountPoint(name) {
+		return os.ErrPermission
+	}
+	dir, rel := f.dir(name)
+	return dir.RemoveAll(ctx, rel)
+}
+
+// Rename implements webdav.FileSystem, files can be moved between mounts
+func (f FileSystem) Rename(ctx context.Context, oldName, newName string) error {
+	for _, name := range []string{oldName, newName} {
+		if clean(name) == "/" || f.Mounts.ReadOnly(name) || f.Mounts.UploadOnly(name) || f.Mounts.IsMountPoint(name) {
+			return os.ErrPermission
+		}
+	}
+	return os.Rename(f.Mounts.Path(f.Webroot, oldName), f.Mounts.Path(f.Webroot, newName))
+}
+
+// Stat implements webdav.FileSystem
+func (f FileSystem) Stat(ctx context.Context, name string) (os.FileInfo, error) {
+	dir, rel := f.dir(name)
+	fi, err := dir.Stat(ctx, rel)
+	if err != nil {
+		return nil, err
+	}
+	if f.Mounts.IsMountPoint(name) {
+		return entry{FileInfo: fi, name: strings.TrimPrefix(clean(name), "/")}, nil
+	}
+	return fi, nil
+}
+
+// rootDir lists the mount points with the content of the webroot
+type rootDir struct {
+	webdav.File
+	mounts Mounts
+}
+
+func (d rootDir) Readdir(count int) ([]os.FileInfo, error) {
+	fis, err := d.File.Readdir(count)
+	if err != nil || count > 0 {
+		return fis, err
+	}
+	return d.mounts.List("/", fis), nil
+}
+
+// emptyDir does not reveal the content of an upload only mount
+type emptyDir struct {
+	webdav.File
+}
+
+func (d emptyDir) Readdir(count int) ([]os.FileInfo, error) {
+	return nil, nil
+}
@@ -0,0 +1,165 @@
+// Package mynat requests port mappings from the local gateway via UPnP IGD or NAT-PMP
+package mynat
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/huin/goupnp"
+	"github.com/huin/goupnp/dcps/internetgateway2"
+	"github.com/jackpal/gateway"
+	natpmp "github.com/jackpal/go-nat-pmp"
+	"github.com/patrickhener/goshs/internal/mylog"
+)
+
+const (
+	description = "goshs"
+	// lease is renewed at half time
+	lease = time.Hour
+)
+
+// igdClient is implemented by the WANIPConnection and WANPPPConnection services
+type igdClient interface {
+	AddPortMapping(NewRemoteHost string, NewExternalPort uint16, NewProtocol string, NewInternalPort uint16, NewInternalClient string, NewEnabled bool, NewPortMappingDescription string, NewLeaseDuration uint32) error
+	DeletePortMapping(NewRemoteHost string, NewExternalPort uint16, NewProtocol string) error
+	GetExternalIPAddress() (string, error)
+	GetServiceClient() *goupnp.ServiceClient
+}
+
+// Mapping is a tcp port mapping on the gateway which is renewed until closed
+type Mapping struct {
+	ExternalIP   string
+	ExternalPort int
+	Method       string
+
+	port  int
+	igd   igdClient
+	pmp   *natpmp.Client
+	stop  chan struct{}
+	close sync.Once
+}
+
+// Map will forward the external tcp port of the gateway to port on this host, UPnP is tried first
+func Map(port int) (*Mapping, error) {
+	m := &Mapping{port: port, stop: make(chan struct{})}
+
+	upnpErr := m.mapUPnP()
+	if upnpErr != nil {
+		if pmpErr := m.mapNATPMP(); pmpErr != nil {
+			return nil, fmt.Errorf("UPnP: %v, NAT-PMP: %v", upnpErr, pmpErr)
+		}
+	}
+
+	go m.renew()
+	return m, nil
+}
+
+func discoverIGD() (igdClient, error) {
+	var clients []igdClient
+	if c, _, err := internetgateway2.NewWANIPConnection2Clients(); err == nil {
+		for _, client := range c {
+			clients = append(clients, client)
+		}
+	}
+	if c, _, err := internetgateway2.NewWANIPConnection1Clients(); err == nil {
+		for _, client := range c {
+			clients = append(clients, client)
+		}
+	}
+	if c, _, err := internetgateway2.NewWANPPPConnection1Clients(); err == nil {
+		for _, client := range c {
+			clients = append(clients, client)
+		}
+	}
+	if len(clients) == 0 {
+		return nil, errors.New("no internet gateway device found")
+	}
+	return clients[0], nil
+}
+
+func (m *Mapping) mapUPnP() error {
+	igd, err := discoverIGD()
+	if err != nil {
+		return err
+	}
+	m.igd = igd
+	m.ExternalPort = m.port
+	if err := m.addUPnP(); err != nil {
+		return err
+	}
+	m.ExternalIP, err = igd.GetExternalIPAddress()
+	if err != nil {
+		mylog.Warnf("Unable to get the external ip address via UPnP: %+v", err)
+	}
+	m.Method = "UPnP"
+	return nil
+}
+
+func (m *Mapping) addUPnP() error {
+	local := m.igd.GetServiceClient().LocalAddr().String()
+	err := m.igd.AddPortMapping("", uint16(m.ExternalPort), "TCP", uint16(m.port), local, true, description, uint32(lease.Seconds()))
+	if err != nil {
+		// Some gateways only support permanent leases
+		err = m.igd.AddPortMapping("", uint16(m.ExternalPort), "TCP", uint16(m.port), local, true, description, 0)
+	}
+	return err
+}
+
+func (m *Mapping) mapNATPMP() error {
+	gw, err := gateway.DiscoverGateway()
+	if err != nil {
+		return err
+	}
+	m.pmp = natpmp.NewClientWithTimeout(gw, 5*time.Second)
+	res, err := m.pmp.AddPortMapping("tcp", m.port, m.port, int(lease.Seconds()))
+	if err != nil {
+		return err
+	}
+	m.ExternalPort = int(res.MappedExternalPort)
+	if ext, err := m.pmp.GetExternalAddress(); err == nil {
+		m.ExternalIP = net.IP(ext.ExternalIPAddress[:]).String()
+	} else {
+		mylog.Warnf("Unable to get the external ip address via NAT-PMP: %+v", err)
+	}
+	m.Method = "NAT-PMP"
+	return nil
+}
+
+func (m *Mapping) renew() {
+	ticker := time.NewTicker(lease / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-m.stop:
+			return
+		case <-ticker.C:
+			var err error
+			if m.igd != nil {
+				err = m.addUPnP()
+			} else {
+				_, err = m.pmp.AddPortMapping("tcp", m.port, m.ExternalPort, int(lease.Seconds()))
+			}
+			if err != nil {
+				mylog.Errorf("Renewing the %s port mapping of port %d: %+v", m.Method, m.port, err)
+			}
+		}
+	}
+}
+
+// Close will remove the mapping from the gateway
+func (m *Mapping) Close() error {
+	var err error
+	m.close.Do(func() {
+		close(m.stop)
+		if m.igd != nil {
+			err = m.igd.DeletePortMapping("", uint16(m.ExternalPort), "TCP")
+			return
+		}
+		// A lifetime of 0 deletes the mapping
+		_, err = m.pmp.AddPortMapping("tcp", m.port, 0, 0)
+	})
+	return err
+}
@@ -0,0 +1,102 @@
+// Package mynotify shows native desktop notifications, so the operator notices
+// events even when the terminal is buried under other windows.
+package mynotify
+
+import (
+	"net"
+	"os"
+	"os/exec"
+	"runtime"
+	"sync"
+
+	"github.com/patrickhener/goshs/internal/mylog"
+)
+
+// Notifier notifies about the first download of every path and about uploads
+type Notifier struct {
+	mu         sync.Mutex
+	downloaded map[string]bool
+}
+
+// New will return a notifier
+func New() *Notifier {
+	return &Notifier{downloaded: make(map[string]bool)}
+}
+
+// Download will notify about the download of upath by remote, the first one per path only
+func (n *Notifier) Download(remote, upath string) {
+	n.mu.Lock()
+	seen := n.downloaded[upath]
+	n.downloaded[upath] = true
+	n.mu.Unlock()
+	if !seen {
+		Show("goshs download", host(remote)+" downloaded "+upath)
+	}
+}
+
+// Upload will notify about the completed upload of upath by remote
+func (n *Notifier) Upload(remote, upath string) {
+	Show("goshs upload", host(remote)+" uploaded "+upath)
+}
+
+func host(addr string) string {
+	if h, _, err := net.SplitHostPort(addr); err == nil {
+		return h
+	}
+	return addr
+}
+
+// Show will show message with title as a desktop notification in the background.
+// The message is passed as an argument or via the environment, it comes from clients.
+func Show(title, message string) {
+	show(title, message, false)
+}
+
+// Alert is Show for notifications which must not be missed, they stay until dismissed where supported
+func Alert(title, message string) {
+	show(title, message, true)
+}
+
+func show(title, message string, urgent bool) {
+	go func() {
+		if err := command(title, message, urgent).Run(); err != nil {
+			mylog.Debugf("showing desktop notification: %+v", err)
+		}
+	}()
+}
+
+func command(title, message string, urgent bool) *exec.Cmd {
+	switch runtime.GOOS {
+	case "darwin":
+		// disable G204 (CWE-78): Subprocess launched with variable
+		// as title and message are never part of a command line that gets interpreted
+		// #nosec G204
+		return exec.Command("osascript",
+			"-e", "on run argv",
+			"-e", "display notification (item 2 of argv) with title (item 1 of argv)",
+			"-e", "end run",
+			title, message)
+	case "windows":
+		icon, level := "Information", "Info"
+		if urgent {
+			icon, level = "Warning", "Warning"
+		}
+		cmd := exec.Command("powershell", "-NoProfile", "-Command",
+			"Add-Type -AssemblyName System.Windows.Forms; "+
+				"$n = New-Object System.Windows.Forms.NotifyIcon; "+
+				"$n.Icon = [System.Drawing.SystemIcons]::"+icon+"; $n.Visible = $true; "+
+				"$n.ShowBalloonTip(10000, $env:GOSHS_TITLE, $env:GOSHS_MESSAGE, '"+level+"'); "+
+				"Start-Sleep 10; $n.Dispose()")
+		cmd.Env = append(os.Environ(), "GOSHS_TITLE="+title, "GOSHS_MESSAGE="+message)
+		return cmd
+	default:
+		urgency := "normal"
+		if urgent {
+			urgency = "critical"
+		}
+		// disable G204 (CWE-78): Subprocess launched with variable
+		// as title and message are never part of a command line that gets interpreted
+		// #nosec G204
+		return exec.Command("notify-send", "-u", urgency, title, message)
+	}
+}
@@ -0,0 +1,20 @@
+// Package myqr renders qr codes of the share url for the terminal and the web interface.
+package myqr
+
+import (
+	qrcode "github.com/skip2/go-qrcode"
+)
+
+// Terminal returns content as qr code drawn with unicode half blocks for dark terminals
+func Terminal(content string) (string, error) {
+	qr, err := qrcode.New(content, qrcode.Medium)
+	if err != nil {
+		return "", err
+	}
+	return qr.ToSmallString(false), nil
+}
+
+// PNG returns content as qr code image of size x size pixels
+func PNG(content string, size int) ([]byte, error) {
+	return qrcode.Encode(content, qrcode.Medium, size)
+}
@@ -0,0 +1,272 @@
+// Package myreport collects what happened during a run, clients, files served
+// and received and failed logins, to summarize it on shutdown for a report.
+package myreport
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Client is a remote address and its requests
+type Client struct {
+	Address  string    `json:"address"`
+	Requests int       `json:"requests"`
+	First    time.Time `json:"first"`
+	Last     time.Time `json:"last"`
+}
+
+// File is a file served or received, SHA256 is of its content when it was first seen
+type File struct {
+	Path    string   `json:"path"`
+	Count   int      `json:"count"`
+	Size    int64    `json:"size"`
+	SHA256  string   `json:"sha256,omitempty"`
+	Clients []string `json:"clients"`
+
+	modTime time.Time
+}
+
+// AuthFailure is a failed login
+type AuthFailure struct {
+	Time    time.Time `json:"time"`
+	Address string    `json:"address"`
+	User    string    `json:"user,omitempty"`
+}
+
+// Summary is the report of a run
+type Summary struct {
+	Start        time.Time     `json:"start"`
+	End          time.Time     `json:"end"`
+	Duration     string        `json:"duration"`
+	Clients      []Client      `json:"clients"`
+	Served       []File        `json:"served"`
+	Received     []File        `json:"received"`
+	AuthFailures []AuthFailure `json:"auth_failures"`
+}
+
+// Report records the events of a run
+type Report struct {
+	mu       sync.Mutex
+	start    time.Time
+	clients  map[string]*Client
+	served   map[string]*File
+	received map[string]*File
+	auth     []AuthFailure
+	hashing  sync.WaitGroup
+}
+
+// New will return a report of a run starting now
+func New() *Report {
+	return &Report{
+		start:    time.Now(),
+		clients:  make(map[string]*Client),
+		served:   make(map[string]*File),
+		received: make(map[string]*File),
+	}
+}
+
+func host(addr string) string {
+	if h, _, err := net.SplitHostPort(addr); err == nil {
+		return h
+	}
+	return addr
+}
+
+// LogRequest will count the request of the client, it matches mylog.RequestHook
+func (r *Report) LogRequest(req *http.Request, status int) {
+	now := time.Now()
+	addr := host(req.RemoteAddr)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	c, ok := r.clients[addr]
+	if !ok {
+		c = &Client{Address: addr, First: now}
+		r.clients[addr] = c
+	}
+	c.Requests++
+	c.Last = now
+}
+
+// Served will record the download of upath, file is where it is on disk
+func (r *Report) Served(remote, upath, file string) {
+	r.record(r.served, remote, upath, file)
+}
+
+// Received will record the upload to upath, file is where it was saved
+func (r *Report) Received(remote, upath, file string) {
+	r.record(r.received, remote, upath, file)
+}
+
+// AuthFailure will record a failed login of user
+func (r *Report) AuthFailure(remote, user string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.auth = append(r.auth, AuthFailure{Time: time.Now(), Address: host(remote), User: user})
+}
+
+func (r *Report) record(files map[string]*File, remote, upath, file string) {
+	fi, err := os.Stat(file)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	f, ok := files[upath]
+	if !ok {
+		f = &File{Path: upath}
+		files[upath] = f
+	}
+	f.Count++
+	addr := host(remote)
+	if !contains(f.Clients, addr) {
+		f.Clients = append(f.Clients, addr)
+	}
+
+	// Hash again only if the content changed, in the background to not hold up the transfer
+	if err != nil || (ok && fi.Size() == f.Size && fi.ModTime().Equal(f.modTime)) {
+		return
+	}
+	f.Size, f.modTime, f.SHA256 = fi.Size(), fi.ModTime(), ""
+	r.hashing.Add(1)
+	go func() {
+		defer r.hashing.Done()
+		sum, err := hashFile(file)
+		if err != nil {
+			return
+		}
+		r.mu.Lock()
+		f.SHA256 = sum
+		r.mu.Unlock()
+	}()
+}
+
+func contains(list []string, s string) bool {
+	for _, e := range list {
+		if e == s {
+			return true
+		}
+	}
+	return false
+}
+
+func hashFile(file string) (string, error) {
+	// disable G304 (CWE-22): Potential file inclusion via variable
+	// as the file was just served or received
+	// #nosec G304
+	f, err := os.Open(file)
+	if err != nil {
+		return "", err
+	}
+	// disable G307 (CWE-703): Deferring unsafe method "Close" on type "*os.File"
+	// #nosec G307
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// Summary waits for pending hashes and returns the report up to now
+func (r *Report) Summary() Summary {
+	r.hashing.Wait()
+	end := time.Now()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	s := Summary{
+		Start:        r.start,
+		End:          end,
+		Duration:     end.Sub(r.start).Round(time.Second).String(),
+		Clients:      []Client{},
+		Served:       files(r.served),
+		Received:     files(r.received),
+		AuthFailures: append([]AuthFailure{}, r.auth...),
+	}
+	for _, c := range r.clients {
+		s.Clients = append(s.Clients, *c)
+	}
+	sort.Slice(s.Clients, func(i, j int) bool { return s.Clients[i].First.Before(s.Clients[j].First) })
+	return s
+}
+
+func files(m map[string]*File) []File {
+	list := []File{}
+	for _, f := range m {
+		c := *f
+		c.Clients = append([]string{}, f.Clients...)
+		list = append(list, c)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Path < list[j].Path })
+	return list
+}
+
+// Write will write the summary to file, as JSON if it ends in .json, else as Markdown
+func (s Summary) Write(file string) error {
+	var content []byte
+	if strings.EqualFold(filepath.Ext(file), ".json") {
+		b, err := json.MarshalIndent(s, "", "  ")
+		if err != nil {
+			return err
+		}
+		content = append(b, '\n')
+	} else {
+		content = []byte(s.Markdown())
+	}
+	return ioutil.WriteFile(file, content, 0600)
+}
+
+// Markdown returns the summary as a Markdown document, it reads well in a terminal too
+func (s Summary) Markdown() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# goshs run summary\n\n")
+	fmt.Fprintf(&b, "- Start: %s\n", s.Start.Format(time.RFC3339))
+	fmt.Fprintf(&b, "- End: %s\n", s.End.Format(time.RFC3339))
+	fmt.Fprintf(&b, "- Duration: %s\n", s.Duration)
+	fmt.Fprintf(&b, "- Unique clients: %d\n", len(s.Clients))
+	fmt.Fprintf(&b, "- Files served: %d\n", len(s.Served))
+	fmt.Fprintf(&b, "- Files received: %d\n", len(s.Received))
+	fmt.Fprintf(&b, "- Failed logins: %d\n", len(s.AuthFailures))
+
+	if len(s.Clients) > 0 {
+		fmt.Fprintf(&b, "\n## Clients\n\n| Address | Requests | First seen | Last seen |\n|---|---|---|---|\n")
+		for _, c := range s.Clients {
+			fmt.Fprintf(&b, "| %s | %d | %s | %s |\n", cell(c.Address), c.Requests, c.First.Format(time.RFC3339), c.Last.Format(time.RFC3339))
+		}
+	}
+	for _, section := range []struct {
+		title string
+		files []File
+	}{{"Files served", s.Served}, {"Files received", s.Received}} {
+		if len(section.files) == 0 {
+			continue
+		}
+		fmt.Fprintf(&b, "\n## %s\n\n| Path | Count | Size | SHA256 | Clients |\n|---|---|---|---|---|\n", section.title)
+		for _, f := range section.files {
+			fmt.Fprintf(&b, "| %s | %d | %d | %s | %s |\n", cell(f.Path), f.Count, f.Size, f.SHA256, cell(strings.Join(f.Clients, ", ")))
+		}
+	}
+	if len(s.AuthFailures) > 0 {
+		fmt.Fprintf(&b, "\n## Failed logins\n\n| Time | Address | User |\n|---|---|---|\n")
+		for _, a := range s.AuthFailures {
+			fmt.Fprintf(&b, "| %s | %s | %s |\n", a.Time.Format(time.RFC3339), cell(a.Address), cell(a.User))
+		}
+	}
+	return b.String()
+}
+
+// cell escapes pipes and line breaks, paths and user names come from clients
+func cell(s string) string {
+	return strings.NewReplacer("|", `\|`, "\r", " ", "\n", " ").Replace(s)
+}
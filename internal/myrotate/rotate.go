@@ -0,0 +1,189 @@
+// Package myrotate provides an append only file which is rotated once it
+// grows too big or too old, keeping a limited number of rotated files.
+package myrotate
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// suffixFormat is appended to the name of rotated files, so they sort by age
+const suffixFormat = "20060102-150405"
+
+// Options decide when a file is rotated, zero values disable the check
+type Options struct {
+	// MaxSize in bytes a file may reach
+	MaxSize int64
+	// MaxAge of a file before a new one is started
+	MaxAge time.Duration
+	// Keep is the number of rotated files to keep, older ones are removed
+	Keep int
+	// Manual leaves rotating to the caller via Due and Rotate,
+	// e.g. to close a file with a trailer and start the next with a header
+	Manual bool
+}
+
+// File is an append only file rotated according to its options
+type File struct {
+	mu      sync.Mutex
+	path    string
+	opts    Options
+	file    *os.File
+	size    int64
+	started time.Time
+}
+
+// Open will open (or create) the file at path to append to it
+func Open(path string, opts Options) (*File, error) {
+	f := &File{path: path, opts: opts}
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (f *File) open() error {
+	// disable G304 (CWE-22): Potential file inclusion via variable
+	// as the operator chooses the file
+	// #nosec G304
+	file, err := os.OpenFile(f.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	fi, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	f.file, f.size, f.started = file, fi.Size(), time.Now()
+	return nil
+}
+
+// Write will append p, rotating the file first if it is due
+func (f *File) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if !f.opts.Manual && f.due() {
+		if err := f.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+	return n, err
+}
+
+// Due returns whether the file is too big or too old
+func (f *File) Due() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.due()
+}
+
+func (f *File) due() bool {
+	if f.size == 0 {
+		return false
+	}
+	return (f.opts.MaxSize > 0 && f.size >= f.opts.MaxSize) ||
+		(f.opts.MaxAge > 0 && time.Since(f.started) >= f.opts.MaxAge)
+}
+
+// Rotate will move the file aside, start a new one and remove rotated files beyond Keep
+func (f *File) Rotate() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.rotate()
+}
+
+func (f *File) rotate() error {
+	if err := f.file.Close(); err != nil {
+		return err
+	}
+
+	// Several rotations within a second get a counter
+	name := f.path + "." + time.Now().Format(suffixFormat)
+	for i := 1; exists(name); i++ {
+		name = fmt.Sprintf("%s.%s.%d", f.path, time.Now().Format(suffixFormat), i)
+	}
+	if err := os.Rename(f.path, name); err != nil {
+		// Keep writing to the old file rather than losing lines
+		if openErr := f.open(); openErr != nil {
+			return openErr
+		}
+		return err
+	}
+	if err := f.open(); err != nil {
+		return err
+	}
+	return f.prune()
+}
+
+func exists(name string) bool {
+	_, err := os.Lstat(name)
+	return err == nil
+}
+
+// prune will remove the oldest rotated files if there are more than Keep
+func (f *File) prune() error {
+	if f.opts.Keep <= 0 {
+		return nil
+	}
+	entries, err := ioutil.ReadDir(filepath.Dir(f.path))
+	if err != nil {
+		return err
+	}
+	prefix := filepath.Base(f.path) + "."
+	var rotated []string
+	for _, e := range entries {
+		if strings.HasPrefix(e.Name(), prefix) && isRotated(strings.TrimPrefix(e.Name(), prefix)) {
+			rotated = append(rotated, filepath.Join(filepath.Dir(f.path), e.Name()))
+		}
+	}
+	if len(rotated) <= f.opts.Keep {
+		return nil
+	}
+	sort.Strings(rotated)
+	for _, old := range rotated[:len(rotated)-f.opts.Keep] {
+		if err := os.Remove(old); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// isRotated returns whether suffix was added by rotate, so other files next to it stay untouched
+func isRotated(suffix string) bool {
+	if len(suffix) < len(suffixFormat) {
+		return false
+	}
+	if _, err := time.Parse(suffixFormat, suffix[:len(suffixFormat)]); err != nil {
+		return false
+	}
+	rest := suffix[len(suffixFormat):]
+	if rest == "" {
+		return true
+	}
+	if rest[0] != '.' || len(rest) == 1 {
+		return false
+	}
+	for _, c := range rest[1:] {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// Close will close the file
+func (f *File) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.file.Close()
+}
@@ -0,0 +1,10 @@
+// Package myservice registers goshs as a system service, a systemd unit on
+// Linux and a service of the service control manager on Windows, so a share
+// comes back after a reboot.
+package myservice
+
+// Name is the name of the service
+const Name = "goshs"
+
+// Description is shown by the service manager
+const Description = "goshs file server"
@@ -0,0 +1,85 @@
+//go:build !windows
+// +build !windows
+
+package myservice
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// unitFile is where the systemd unit is written to
+var unitFile = filepath.Join("/etc/systemd/system", Name+".service")
+
+// Install will register and start a systemd unit running exe with args in workDir.
+// If called via sudo the service runs as the calling user rather than root.
+func Install(exe string, args []string, workDir string) error {
+	if _, err := exec.LookPath("systemctl"); err != nil {
+		return errors.New("systemd is required to install the service")
+	}
+
+	command := make([]string, 0, len(args)+1)
+	for _, arg := range append([]string{exe}, args...) {
+		command = append(command, quote(arg))
+	}
+
+	var unit strings.Builder
+	unit.WriteString("[Unit]\n")
+	fmt.Fprintf(&unit, "Description=%s\n", Description)
+	unit.WriteString("After=network-online.target\n")
+	unit.WriteString("Wants=network-online.target\n\n")
+	unit.WriteString("[Service]\n")
+	fmt.Fprintf(&unit, "ExecStart=%s\n", strings.Join(command, " "))
+	fmt.Fprintf(&unit, "WorkingDirectory=%s\n", strings.ReplaceAll(workDir, "%", "%%"))
+	if user := os.Getenv("SUDO_USER"); user != "" {
+		fmt.Fprintf(&unit, "User=%s\n", user)
+	}
+	unit.WriteString("Restart=on-failure\n\n")
+	unit.WriteString("[Install]\n")
+	unit.WriteString("WantedBy=multi-user.target\n")
+
+	// The options may carry credentials
+	if err := ioutil.WriteFile(unitFile, []byte(unit.String()), 0600); err != nil {
+		return fmt.Errorf("writing %s (are you root?): %+v", unitFile, err)
+	}
+	if err := systemctl("daemon-reload"); err != nil {
+		return err
+	}
+	return systemctl("enable", "--now", Name)
+}
+
+// Uninstall will stop and remove the systemd unit
+func Uninstall() error {
+	if _, err := os.Stat(unitFile); err != nil {
+		return fmt.Errorf("the service is not installed: %+v", err)
+	}
+	if err := systemctl("disable", "--now", Name); err != nil {
+		return err
+	}
+	if err := os.Remove(unitFile); err != nil {
+		return fmt.Errorf("removing %s (are you root?): %+v", unitFile, err)
+	}
+	return systemctl("daemon-reload")
+}
+
+// Notify does nothing as systemd stops the service with SIGTERM
+func Notify(chan<- os.Signal) {}
+
+func systemctl(args ...string) error {
+	out, err := exec.Command("systemctl", args...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("systemctl %s: %+v: %s", strings.Join(args, " "), err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// quote returns arg as a single word of a unit file, where % and $ are expanded
+func quote(arg string) string {
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "%", "%%", "$", "$$")
+	return `"` + r.Replace(arg) + `"`
+}
@@ -0,0 +1,91 @@
+//go:build windows
+// +build windows
+
+package myservice
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/patrickhener/goshs/internal/mylog"
+	"golang.org/x/sys/windows/svc"
+	"golang.org/x/sys/windows/svc/mgr"
+)
+
+// Install will register and start a service running exe with args. Services start in
+// the system directory, workDir is ignored, so relative paths should be avoided.
+func Install(exe string, args []string, workDir string) error {
+	m, err := mgr.Connect()
+	if err != nil {
+		return fmt.Errorf("connecting to the service manager (run as administrator?): %+v", err)
+	}
+	defer m.Disconnect()
+
+	if s, err := m.OpenService(Name); err == nil {
+		s.Close()
+		return fmt.Errorf("the service %s is installed already", Name)
+	}
+
+	s, err := m.CreateService(Name, exe, mgr.Config{
+		DisplayName: Name,
+		Description: Description,
+		StartType:   mgr.StartAutomatic,
+	}, args...)
+	if err != nil {
+		return fmt.Errorf("creating the service: %+v", err)
+	}
+	defer s.Close()
+	return s.Start()
+}
+
+// Uninstall will stop and remove the service
+func Uninstall() error {
+	m, err := mgr.Connect()
+	if err != nil {
+		return fmt.Errorf("connecting to the service manager (run as administrator?): %+v", err)
+	}
+	defer m.Disconnect()
+
+	s, err := m.OpenService(Name)
+	if err != nil {
+		return fmt.Errorf("the service is not installed: %+v", err)
+	}
+	defer s.Close()
+	if _, err := s.Control(svc.Stop); err != nil {
+		mylog.Debugf("stopping the service: %+v", err)
+	}
+	return s.Delete()
+}
+
+// Notify will answer the service control manager if running as a service and
+// send os.Interrupt to c when the service is to be stopped
+func Notify(c chan<- os.Signal) {
+	if ok, err := svc.IsWindowsService(); err != nil || !ok {
+		return
+	}
+	go func() {
+		if err := svc.Run(Name, &handler{c: c}); err != nil {
+			mylog.Errorf("running as service: %+v", err)
+		}
+	}()
+}
+
+type handler struct {
+	c chan<- os.Signal
+}
+
+func (h *handler) Execute(args []string, requests <-chan svc.ChangeRequest, status chan<- svc.Status) (bool, uint32) {
+	status <- svc.Status{State: svc.Running, Accepts: svc.AcceptStop | svc.AcceptShutdown}
+	for r := range requests {
+		switch r.Cmd {
+		case svc.Interrogate:
+			status <- r.CurrentStatus
+		case svc.Stop, svc.Shutdown:
+			status <- svc.Status{State: svc.StopPending}
+			h.c <- os.Interrupt
+			// goshs exits once it cleaned up
+			select {}
+		}
+	}
+	return false, 0
+}
@@ -0,0 +1,195 @@
+package mysftp
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"time"
+
+	"github.com/patrickhener/goshs/internal/mylog"
+	"github.com/pkg/sftp"
+)
+
+// root serves the webroot with the restrictions of the read-only and upload-only mode
+type root struct {
+	cfg    Config
+	client string
+	remote string
+}
+
+func newHandlers(cfg Config, client, remote string) sftp.Handlers {
+	r := &root{cfg: cfg, client: client, remote: remote}
+	return sftp.Handlers{
+		FileGet:  r,
+		FilePut:  r,
+		FileCmd:  r,
+		FileList: r,
+	}
+}
+
+// realPath maps the sftp path below the webroot or a mounted directory
+func (r *root) realPath(p string) string {
+	return r.cfg.Mounts.Path(r.cfg.Webroot, p)
+}
+
+// readOnly reports whether p may not be changed
+func (r *root) readOnly(p string) bool {
+	return r.cfg.ReadOnly() || r.cfg.Mounts.ReadOnly(p)
+}
+
+// uploadOnly reports whether p may not be downloaded
+func (r *root) uploadOnly(p string) bool {
+	return r.cfg.UploadOnly || r.cfg.Mounts.UploadOnly(p)
+}
+
+func (r *root) log(req *sftp.Request, err error) {
+	if r.cfg.Logged != nil {
+		r.cfg.Logged(r.remote, req.Method, req.Filepath, err)
+	}
+	if err != nil {
+		mylog.Errorf("SFTP: %s - - \"%s %s\" - %+v", r.client, req.Method, req.Filepath, err)
+		return
+	}
+	mylog.Infof("SFTP: %s - - \"%s %s\"", r.client, req.Method, req.Filepath)
+}
+
+// Fileread will open a file for download
+func (r *root) Fileread(req *sftp.Request) (io.ReaderAt, error) {
+	if r.uploadOnly(req.Filepath) {
+		r.log(req, sftp.ErrSSHFxPermissionDenied)
+		return nil, sftp.ErrSSHFxPermissionDenied
+	}
+	f, err := os.Open(r.realPath(req.Filepath))
+	r.log(req, err)
+	return f, err
+}
+
+// Filewrite will open a file for upload
+func (r *root) Filewrite(req *sftp.Request) (io.WriterAt, error) {
+	if r.readOnly(req.Filepath) {
+		r.log(req, sftp.ErrSSHFxPermissionDenied)
+		return nil, sftp.ErrSSHFxPermissionDenied
+	}
+
+	flags := os.O_WRONLY | os.O_CREATE
+	pflags := req.Pflags()
+	if pflags.Trunc {
+		flags |= os.O_TRUNC
+	}
+	if pflags.Excl {
+		flags |= os.O_EXCL
+	}
+	// Upload only must not overwrite what others uploaded
+	if r.uploadOnly(req.Filepath) {
+		flags |= os.O_EXCL
+	}
+
+	// disable G304 (CWE-22): Potential file inclusion via variable
+	// as the path is cleaned and joined below the webroot
+	// #nosec G304
+	f, err := os.OpenFile(r.realPath(req.Filepath), flags, 0644)
+	r.log(req, err)
+	return f, err
+}
+
+// Filecmd will handle the file system modifications
+func (r *root) Filecmd(req *sftp.Request) error {
+	err := r.filecmd(req)
+	r.log(req, err)
+	return err
+}
+
+func (r *root) filecmd(req *sftp.Request) error {
+	if r.readOnly(req.Filepath) {
+		return sftp.ErrSSHFxPermissionDenied
+	}
+	// Mount points stay where they are
+	if r.cfg.Mounts.IsMountPoint(req.Filepath) {
+		return sftp.ErrSSHFxPermissionDenied
+	}
+
+	target := r.realPath(req.Filepath)
+	switch req.Method {
+	case "Mkdir":
+		return os.Mkdir(target, 0755)
+	case "Setstat":
+		// Clients set times and modes after uploads
+		if r.uploadOnly(req.Filepath) {
+			return nil
+		}
+		return setstat(target, req)
+	}
+
+	if r.uploadOnly(req.Filepath) {
+		return sftp.ErrSSHFxPermissionDenied
+	}
+
+	switch req.Method {
+	case "Rename":
+		if r.readOnly(req.Target) || r.uploadOnly(req.Target) || r.cfg.Mounts.IsMountPoint(req.Target) {
+			return sftp.ErrSSHFxPermissionDenied
+		}
+		return os.Rename(target, r.realPath(req.Target))
+	case "Rmdir", "Remove":
+		return os.Remove(target)
+	}
+	// Links could point outside of the webroot
+	return sftp.ErrSSHFxOpUnsupported
+}
+
+func setstat(target string, req *sftp.Request) error {
+	attrs := req.Attributes()
+	flags := req.AttrFlags()
+	if flags.Size {
+		if err := os.Truncate(target, int64(attrs.Size)); err != nil {
+			return err
+		}
+	}
+	if flags.Permissions {
+		if err := os.Chmod(target, attrs.FileMode()); err != nil {
+			return err
+		}
+	}
+	if flags.Acmodtime {
+		if err := os.Chtimes(target, time.Unix(int64(attrs.Atime), 0), time.Unix(int64(attrs.Mtime), 0)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Filelist will list directories and stat files
+func (r *root) Filelist(req *sftp.Request) (sftp.ListerAt, error) {
+	target := r.realPath(req.Filepath)
+	switch req.Method {
+	case "List":
+		// Upload only does not reveal what is there, like the web interface
+		if r.uploadOnly(req.Filepath) {
+			return listerAt{}, nil
+		}
+		entries, err := ioutil.ReadDir(target)
+		r.log(req, err)
+		return listerAt(r.cfg.Mounts.List(req.Filepath, entries)), err
+	case "Stat":
+		info, err := os.Stat(target)
+		if err != nil {
+			return nil, err
+		}
+		return listerAt{info}, nil
+	}
+	return nil, sftp.ErrSSHFxOpUnsupported
+}
+
+type listerAt []os.FileInfo
+
+// ListAt implements sftp.ListerAt
+func (l listerAt) ListAt(f []os.FileInfo, offset int64) (int, error) {
+	if offset >= int64(len(l)) {
+		return 0, io.EOF
+	}
+	n := copy(f, l[offset:])
+	if n < len(f) {
+		return n, io.EOF
+	}
+	return n, nil
+}
@@ -0,0 +1,157 @@
+// Package mysftp serves the webroot via SFTP for targets that only have an
+// SSH client at hand.
+package mysftp
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+
+	"github.com/patrickhener/goshs/internal/mylog"
+	"github.com/patrickhener/goshs/internal/mymount"
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+)
+
+// Config of the SFTP server
+type Config struct {
+	Webroot    string
+	UploadOnly bool
+	// Mounts are served next to the webroot with their own mode
+	Mounts mymount.Mounts
+	// ReadOnly is asked for every request, it may change while serving
+	ReadOnly func() bool
+	// HostKey is the path to a private key, a fresh key is generated if empty
+	HostKey string
+	// PasswordCallback validates the credentials of a client, nil allows everybody
+	PasswordCallback func(user, pass, ip string) bool
+	// ServerVersion is the identification sent to clients, SSH-2.0-goshs if empty
+	ServerVersion string
+	// Logged is called for every request of a client with the error answered if set
+	Logged func(remote, method, path string, err error)
+}
+
+// ListenAndServe will serve SFTP on addr until an error occurs
+func ListenAndServe(addr string, cfg Config) error {
+	signer, err := hostKey(cfg.HostKey)
+	if err != nil {
+		return err
+	}
+
+	version := cfg.ServerVersion
+	if version == "" {
+		version = "SSH-2.0-goshs"
+	}
+
+	sshConfig := &ssh.ServerConfig{
+		NoClientAuth:  cfg.PasswordCallback == nil,
+		ServerVersion: version,
+	}
+	// Clients may try a password anyway, without a callback the ssh package refuses it
+	if cfg.PasswordCallback != nil {
+		sshConfig.PasswordCallback = func(c ssh.ConnMetadata, pass []byte) (*ssh.Permissions, error) {
+			if cfg.PasswordCallback(c.User(), string(pass), remoteIP(c.RemoteAddr())) {
+				return nil, nil
+			}
+			return nil, fmt.Errorf("invalid credentials for user %s", c.User())
+		}
+	}
+	sshConfig.AddHostKey(signer)
+
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+
+	mylog.Infof("Serving SFTP on %s from %s", addr, cfg.Webroot)
+	mylog.Infof("SFTP host key fingerprint: %s", ssh.FingerprintSHA256(signer.PublicKey()))
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return err
+		}
+		go handleConn(conn, sshConfig, cfg)
+	}
+}
+
+func handleConn(conn net.Conn, sshConfig *ssh.ServerConfig, cfg Config) {
+	sconn, chans, reqs, err := ssh.NewServerConn(conn, sshConfig)
+	if err != nil {
+		mylog.Warnf("SFTP: %s - handshake failed: %+v", conn.RemoteAddr(), err)
+		return
+	}
+	defer sconn.Close()
+	go ssh.DiscardRequests(reqs)
+
+	for newChannel := range chans {
+		if newChannel.ChannelType() != "session" {
+			if err := newChannel.Reject(ssh.UnknownChannelType, "unknown channel type"); err != nil {
+				mylog.Debugf("rejecting channel: %+v", err)
+			}
+			continue
+		}
+		channel, requests, err := newChannel.Accept()
+		if err != nil {
+			mylog.Errorf("SFTP: accepting channel: %+v", err)
+			return
+		}
+		go serveSession(channel, requests, sconn, cfg)
+	}
+}
+
+// serveSession only accepts the sftp subsystem, there is no shell
+func serveSession(channel ssh.Channel, requests <-chan *ssh.Request, sconn *ssh.ServerConn, cfg Config) {
+	defer channel.Close()
+
+	for req := range requests {
+		ok := req.Type == "subsystem" && len(req.Payload) > 4 && string(req.Payload[4:]) == "sftp"
+		if err := req.Reply(ok, nil); err != nil {
+			mylog.Debugf("replying to ssh request: %+v", err)
+		}
+		if !ok {
+			continue
+		}
+
+		client := fmt.Sprintf("%s@%s", sconn.User(), remoteIP(sconn.RemoteAddr()))
+		server := sftp.NewRequestServer(channel, newHandlers(cfg, client, sconn.RemoteAddr().String()))
+		if err := server.Serve(); err != nil && !errors.Is(err, io.EOF) {
+			mylog.Debugf("SFTP session of %s ended: %+v", client, err)
+		}
+		if err := server.Close(); err != nil {
+			mylog.Debugf("closing sftp server: %+v", err)
+		}
+		return
+	}
+}
+
+func hostKey(file string) (ssh.Signer, error) {
+	if file != "" {
+		// disable G304 (CWE-22): Potential file inclusion via variable
+		// as the operator chooses the host key
+		// #nosec G304
+		pem, err := ioutil.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		return ssh.ParsePrivateKey(pem)
+	}
+
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	return ssh.NewSignerFromKey(key)
+}
+
+func remoteIP(addr net.Addr) string {
+	host, _, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		return addr.String()
+	}
+	return host
+}
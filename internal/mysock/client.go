@@ -4,10 +4,11 @@ import (
 	"encoding/json"
 	"net/http"
 	"strconv"
+	"sync"
 	"time"
 
 	"github.com/gorilla/websocket"
-	"github.com/patrickhener/goshs/internal/mylog"
+	"github.com/patrickhener/goshs/internal/myclipboard"
 )
 
 // Packet defines a packet struct
@@ -16,6 +17,23 @@ type Packet struct {
 	Content json.RawMessage
 }
 
+// NewPacket is the content of a newEntry packet, a plain string never expires
+type NewPacket struct {
+	Content string `json:"content"`
+	// TTL is the lifetime of the entry in seconds
+	TTL       int    `json:"ttl"`
+	Language  string `json:"language"`
+	Encrypted bool   `json:"encrypted"`
+}
+
+// EditPacket is the content of an editEntry packet
+type EditPacket struct {
+	ID        int    `json:"id"`
+	Content   string `json:"content"`
+	Language  string `json:"language"`
+	Encrypted bool   `json:"encrypted"`
+}
+
 // SendPacket represents a response package from server to browser
 type SendPacket struct {
 	Type    string `json:"type"`
@@ -38,11 +56,11 @@ const (
 
 var newline = []byte{'\n'}
 
+// wsupgrader refuses sockets opened by pages of other sites with the default CheckOrigin, browsers
+// would send the credentials of the share along and the socket reads and writes files
 var wsupgrader = websocket.Upgrader{
 	ReadBufferSize:  1024,
 	WriteBufferSize: 1024,
-	// Acceppt Any
-	CheckOrigin: func(r *http.Request) bool { return true },
 }
 
 // Client is a middleman between the websocket connection and the hub.
@@ -54,6 +72,22 @@ type Client struct {
 
 	// Buffered channel of outbound messages.
 	send chan []byte
+
+	// Read only clients may not modify the clipboard or upload, it is asked on every
+	// message as the mode can be switched while the client is connected.
+	readOnly func() bool
+
+	// The clipboard channel the client subscribed to, only used by readPump.
+	channel string
+
+	// Uploads in progress which were created by this client, only used by readPump.
+	uploading map[string]bool
+
+	// The request which opened the socket, file transfers are reported with it.
+	req *http.Request
+
+	// Guards the connection as file transfers write next to writePump.
+	writeMu sync.Mutex
 }
 
 // readPump pumps messages from the websocket connection to the hub.
@@ -63,7 +97,10 @@ type Client struct {
 // reads from this goroutine.
 func (c *Client) readPump() {
 	defer func() {
-		c.hub.unregister <- c
+		select {
+		case c.hub.unregister <- c:
+		case <-c.hub.done:
+		}
 		if err := c.conn.Close(); err != nil {
 			return
 		}
@@ -76,53 +113,117 @@ func (c *Client) readPump() {
 	// #nosec G104
 	c.conn.SetPongHandler(func(string) error { c.conn.SetReadDeadline(time.Now().Add(pongWait)); return nil })
 	for {
-		var packet Packet
-		if err := c.conn.ReadJSON(&packet); err != nil {
+		messageType, data, err := c.conn.ReadMessage()
+		if err != nil {
 			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
-				mylog.Errorf("%v", err)
+				c.hub.Logger.Errorf("%v", err)
 			}
 			if websocket.IsCloseError(err, websocket.CloseGoingAway) {
 				break
 			}
 
-			mylog.Errorf("reading message: %v", err)
+			c.hub.Logger.Errorf("reading message: %v", err)
 			break
 		}
 
+		// Binary messages carry file uploads
+		if messageType == websocket.BinaryMessage {
+			c.receiveFile(data)
+			continue
+		}
+
+		var packet Packet
+		if err := json.Unmarshal(data, &packet); err != nil {
+			c.hub.Logger.Errorf("reading message: %v", err)
+			continue
+		}
+
+		// Downloads do not modify anything
+		if packet.Type == "fileDownload" {
+			var path string
+			if err := json.Unmarshal(packet.Content, &path); err != nil {
+				c.hub.Logger.Errorf("Error reading json packet: %+v", err)
+			}
+			// Keep reading meanwhile to answer the pings
+			go c.sendFile(path)
+			continue
+		}
+
+		// Reading another channel is fine for read only clients as well
+		if packet.Type == "subscribe" {
+			var channel string
+			if err := json.Unmarshal(packet.Content, &channel); err != nil {
+				c.hub.Logger.Errorf("Error reading json packet: %+v", err)
+				continue
+			}
+			c.channel = c.hub.cb.Lookup(channel).Name()
+			select {
+			case c.hub.subscribe <- subscription{client: c, channel: c.channel}:
+			case <-c.hub.done:
+			}
+			c.reply("refreshClipboard", c.channel)
+			continue
+		}
+
+		if c.readOnly() {
+			c.hub.Logger.Warnf("Ignoring websocket event %s from read only client %s", packet.Type, c.conn.RemoteAddr())
+			continue
+		}
+
 		// Switch here over possible socket events and pull in handlers
 		switch packet.Type {
 		case "newEntry":
-			var entry string
-			if err := json.Unmarshal(packet.Content, &entry); err != nil {
-				mylog.Errorf("Error reading json packet: %+v", err)
+			var entry NewPacket
+			if err := json.Unmarshal(packet.Content, &entry.Content); err != nil {
+				if err := json.Unmarshal(packet.Content, &entry); err != nil {
+					c.hub.Logger.Errorf("Error reading json packet: %+v", err)
+					continue
+				}
+			}
+			cb, err := c.hub.cb.Get(c.channel)
+			if err != nil {
+				c.hub.Logger.Errorf("Error creating Clipboard entry: %+v", err)
+				continue
 			}
-			if err := c.hub.cb.AddEntry(entry); err != nil {
-				mylog.Errorf("Error creating Clipboard entry: %+v", err)
+			if _, err := cb.AddEntry(myclipboard.Entry{Content: entry.Content, Language: entry.Language, Encrypted: entry.Encrypted}, time.Duration(entry.TTL)*time.Second); err != nil {
+				c.hub.Logger.Errorf("Error creating Clipboard entry: %+v", err)
+			}
+			c.refreshClipboard()
+
+		case "editEntry":
+			var edit EditPacket
+			if err := json.Unmarshal(packet.Content, &edit); err != nil {
+				c.hub.Logger.Errorf("Error reading json packet: %+v", err)
+				continue
+			}
+			if _, err := c.clipboard().UpdateEntry(edit.ID, myclipboard.Entry{Content: edit.Content, Language: edit.Language, Encrypted: edit.Encrypted}); err != nil {
+				c.hub.Logger.Errorf("Error to edit Clipboard entry with id: %d: %+v", edit.ID, err)
 			}
 			c.refreshClipboard()
 
 		case "delEntry":
 			var id string
 			if err := json.Unmarshal(packet.Content, &id); err != nil {
-				mylog.Errorf("Error reading json packet: %+v", err)
+				c.hub.Logger.Errorf("Error reading json packet: %+v", err)
 			}
 			iid, err := strconv.Atoi(id)
 			if err != nil {
-				mylog.Errorf("Error reading json packet: %+v", err)
+				c.hub.Logger.Errorf("Error reading json packet: %+v", err)
+				continue
 			}
-			if err := c.hub.cb.DeleteEntry(iid); err != nil {
-				mylog.Errorf("Error to delete Clipboard entry with id: %s: %+v", string(packet.Content), err)
+			if err := c.clipboard().DeleteEntry(iid); err != nil {
+				c.hub.Logger.Errorf("Error to delete Clipboard entry with id: %s: %+v", string(packet.Content), err)
 			}
 			c.refreshClipboard()
 
 		case "clearClipboard":
-			if err := c.hub.cb.ClearClipboard(); err != nil {
-				mylog.Errorf("Error clearing clipboard: %+v", err)
+			if err := c.clipboard().ClearClipboard(); err != nil {
+				c.hub.Logger.Errorf("Error clearing clipboard: %+v", err)
 			}
 			c.refreshClipboard()
 
 		default:
-			mylog.Warnf("The event sent via websocket cannot be handeled: %+v", packet.Type)
+			c.hub.Logger.Warnf("The event sent via websocket cannot be handeled: %+v", packet.Type)
 		}
 	}
 }
@@ -131,7 +232,7 @@ func (c *Client) readPump() {
 //
 // A goroutine running writePump is started for each connection. The
 // application ensures that there is at most one writer to a connection by
-// executing all writes from this goroutine.
+// executing all writes from this goroutine or the file transfers under writeMu.
 func (c *Client) writePump() {
 	ticker := time.NewTicker(pingPeriod)
 	defer func() {
@@ -143,73 +244,97 @@ func (c *Client) writePump() {
 	for {
 		select {
 		case message, ok := <-c.send:
-			if err := c.conn.SetWriteDeadline(time.Now().Add(writeWait)); err != nil {
-				return
-			}
 			if !ok {
 				// The hub closed the channel.
-				if err := c.conn.WriteMessage(websocket.CloseMessage, []byte{}); err != nil {
+				if err := c.write(websocket.CloseMessage, []byte{}); err != nil {
 					return
 				}
 				return
 			}
 
-			w, err := c.conn.NextWriter(websocket.TextMessage)
-			if err != nil {
+			if err := c.writeQueued(message); err != nil {
 				return
 			}
-			if _, err := w.Write(message); err != nil {
+		case <-ticker.C:
+			if err := c.write(websocket.PingMessage, nil); err != nil {
 				return
 			}
+		}
+	}
+}
 
-			// Add queued chat messages to the current websocket message.
-			n := len(c.send)
-			for i := 0; i < n; i++ {
-				if _, err := w.Write(newline); err != nil {
-					return
-				}
-				if _, err := w.Write(<-c.send); err != nil {
-					return
-				}
-			}
+// write will send a single message of type kind
+func (c *Client) write(kind int, data []byte) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	if err := c.conn.SetWriteDeadline(time.Now().Add(writeWait)); err != nil {
+		return err
+	}
+	return c.conn.WriteMessage(kind, data)
+}
 
-			if err := w.Close(); err != nil {
-				return
-			}
-		case <-ticker.C:
-			if err := c.conn.SetWriteDeadline(time.Now().Add(writeWait)); err != nil {
-				return
-			}
-			if err := c.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
-				return
-			}
+// writeQueued will send message along with the queued messages in one text message
+func (c *Client) writeQueued(message []byte) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	if err := c.conn.SetWriteDeadline(time.Now().Add(writeWait)); err != nil {
+		return err
+	}
+
+	w, err := c.conn.NextWriter(websocket.TextMessage)
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(message); err != nil {
+		return err
+	}
+
+	// Add queued chat messages to the current websocket message.
+	n := len(c.send)
+	for i := 0; i < n; i++ {
+		if _, err := w.Write(newline); err != nil {
+			return err
+		}
+		if _, err := w.Write(<-c.send); err != nil {
+			return err
 		}
 	}
+
+	return w.Close()
 }
 
-// ServeWS will handle the socket connections
-func ServeWS(hub *Hub, w http.ResponseWriter, r *http.Request) {
+// ServeWS will handle the socket connections, the client subscribes to the clipboard channel in the query
+// or watches the activity instead. readOnly tells whether the client may modify anything at the moment.
+func ServeWS(hub *Hub, w http.ResponseWriter, r *http.Request, readOnly func() bool, activity bool) {
 	conn, err := wsupgrader.Upgrade(w, r, nil)
 	if err != nil {
-		mylog.Errorf("Failed to upgrade ws: %+v", err)
+		hub.Logger.Errorf("Failed to upgrade ws: %+v", err)
 		return
 	}
 
-	client := &Client{hub: hub, conn: conn, send: make(chan []byte, 1024)}
-	client.hub.register <- client
+	channel := hub.cb.Lookup(r.URL.Query().Get("channel")).Name()
+	if activity {
+		channel = ActivityChannel
+	}
+	client := &Client{hub: hub, conn: conn, send: make(chan []byte, 1024), readOnly: readOnly, channel: channel, uploading: make(map[string]bool), req: r}
+	select {
+	case client.hub.register <- client:
+	case <-hub.done:
+		if err := conn.Close(); err != nil {
+			hub.Logger.Debugf("closing websocket: %+v", err)
+		}
+		return
+	}
 
 	go client.writePump()
 	go client.readPump()
 }
 
 func (c *Client) refreshClipboard() {
-	sendPkg := &SendPacket{
-		Type: "refreshClipboard",
-	}
-	broadcastMessage, err := json.Marshal(sendPkg)
-	if err != nil {
-		mylog.Errorf("Unable to marshal json data in redirect: %+v", err)
-	}
+	c.hub.RefreshClipboard(c.channel)
+}
 
-	c.hub.broadcast <- broadcastMessage
+// clipboard returns the clipboard of the channel the client subscribed to, it does not create it
+func (c *Client) clipboard() *myclipboard.Clipboard {
+	return c.hub.cb.Lookup(c.channel)
 }
@@ -0,0 +1,220 @@
+package mysock
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+
+	"github.com/gorilla/websocket"
+)
+
+// fileChunk is the size of the chunks a download is split into
+const fileChunk = 1 << 20
+
+// FileHeader precedes the content of a binary message, separated by a newline.
+// Uploads are sent as "fileUpload" chunks, downloads are answered with "fileDownload" chunks.
+type FileHeader struct {
+	Type   string `json:"type"`
+	Path   string `json:"path"`
+	Offset int64  `json:"offset"`
+	Size   int64  `json:"size,omitempty"`
+	Final  bool   `json:"final"`
+}
+
+// realPath maps the requested path below the webroot or a mounted directory
+func (c *Client) realPath(p string) string {
+	return c.hub.Mounts.Path(c.hub.webroot, p)
+}
+
+// sendFile will send the file in chunks as binary messages
+func (c *Client) sendFile(p string) {
+	if c.hub.uploadOnly || c.hub.Mounts.UploadOnly(p) {
+		c.fileError(http.MethodGet, p, http.StatusForbidden, errors.New("download not allowed due to 'upload only' option"))
+		return
+	}
+
+	// disable G304 (CWE-22): Potential file inclusion via variable
+	// as the path is cleaned and joined below the webroot
+	// #nosec G304
+	file, err := os.Open(c.realPath(p))
+	if err != nil {
+		c.fileError(http.MethodGet, p, errStatus(err), err)
+		return
+	}
+	// disable G307 (CWE-703): Deferring unsafe method "Close" on type "*os.File"
+	// #nosec G307
+	defer file.Close()
+
+	stat, err := file.Stat()
+	if err != nil {
+		c.fileError(http.MethodGet, p, errStatus(err), err)
+		return
+	}
+	if stat.IsDir() {
+		c.fileError(http.MethodGet, p, http.StatusBadRequest, fmt.Errorf("%s is a directory", p))
+		return
+	}
+
+	upath := path.Clean("/" + p)
+	if c.hub.ReserveServe != nil {
+		if !c.hub.ReserveServe(upath) {
+			c.fileError(http.MethodGet, p, http.StatusGone, errors.New("download not allowed as the file was served the maximum number of times"))
+			return
+		}
+	}
+	complete := false
+	defer func() {
+		if c.hub.Served != nil {
+			c.hub.Served(upath, complete)
+		}
+	}()
+
+	buf := make([]byte, fileChunk)
+	header := FileHeader{Type: "fileDownload", Path: p, Size: stat.Size()}
+	for {
+		n, err := io.ReadFull(file, buf)
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			c.fileError(http.MethodGet, p, errStatus(err), err)
+			return
+		}
+		header.Final = header.Offset+int64(n) >= stat.Size()
+		if err := c.write(websocket.BinaryMessage, c.encodeChunk(header, buf[:n])); err != nil {
+			c.hub.Logger.Errorf("sending file via websocket: %+v", err)
+			c.transferred(http.MethodGet, p, file.Name(), http.StatusInternalServerError)
+			return
+		}
+		header.Offset += int64(n)
+		if header.Final {
+			complete = true
+			c.transferred(http.MethodGet, p, file.Name(), http.StatusOK)
+			return
+		}
+	}
+}
+
+// receiveFile will write an uploaded chunk to disk, the first chunk truncates the file. Upload only
+// does not replace existing files, the first chunk has to create it and the others follow it then.
+func (c *Client) receiveFile(data []byte) {
+	i := bytes.IndexByte(data, '\n')
+	if i < 0 {
+		c.hub.Logger.Errorf("reading binary message: missing header")
+		return
+	}
+	var header FileHeader
+	if err := json.Unmarshal(data[:i], &header); err != nil {
+		c.hub.Logger.Errorf("reading binary message: %+v", err)
+		return
+	}
+	if header.Type != "fileUpload" {
+		c.fileError(http.MethodPut, header.Path, http.StatusBadRequest, fmt.Errorf("unknown binary message type %s", header.Type))
+		return
+	}
+	if c.readOnly() || c.hub.Mounts.ReadOnly(header.Path) {
+		c.fileError(http.MethodPut, header.Path, http.StatusForbidden, errors.New("upload not allowed due to 'read only' option"))
+		return
+	}
+	if path.Clean("/"+header.Path) == "/" {
+		c.fileError(http.MethodPut, header.Path, http.StatusBadRequest, errors.New("missing file name"))
+		return
+	}
+
+	upath := path.Clean("/" + header.Path)
+	uploadOnly := c.hub.uploadOnly || c.hub.Mounts.UploadOnly(header.Path)
+	flags := os.O_WRONLY | os.O_CREATE
+	switch {
+	case uploadOnly && header.Offset == 0:
+		flags |= os.O_EXCL
+	case uploadOnly && !c.uploading[upath]:
+		c.fileError(http.MethodPut, header.Path, http.StatusConflict, errors.New("upload not allowed as the file exists already"))
+		return
+	case header.Offset == 0:
+		flags |= os.O_TRUNC
+	}
+	// disable G304 (CWE-22): Potential file inclusion via variable
+	// as the path is cleaned and joined below the webroot
+	// #nosec G304
+	file, err := os.OpenFile(c.realPath(header.Path), flags, 0644)
+	if err != nil {
+		c.fileError(http.MethodPut, header.Path, errStatus(err), err)
+		return
+	}
+	c.uploading[upath] = true
+	if _, err := file.WriteAt(data[i+1:], header.Offset); err != nil {
+		file.Close()
+		delete(c.uploading, upath)
+		c.fileError(http.MethodPut, header.Path, errStatus(err), err)
+		return
+	}
+	if err := file.Close(); err != nil {
+		delete(c.uploading, upath)
+		c.fileError(http.MethodPut, header.Path, errStatus(err), err)
+		return
+	}
+
+	if header.Final {
+		delete(c.uploading, upath)
+		c.transferred(http.MethodPut, header.Path, c.realPath(header.Path), http.StatusOK)
+		c.reply("fileUploaded", header.Path)
+	}
+}
+
+func (c *Client) encodeChunk(header FileHeader, data []byte) []byte {
+	h, err := json.Marshal(header)
+	if err != nil {
+		c.hub.Logger.Errorf("Unable to marshal json data: %+v", err)
+	}
+	msg := make([]byte, 0, len(h)+1+len(data))
+	msg = append(msg, h...)
+	msg = append(msg, '\n')
+	return append(msg, data...)
+}
+
+// fileError will tell the client that the transfer of p failed
+func (c *Client) fileError(method, p string, status int, err error) {
+	// Do not leak the webroot
+	msg := c.hub.Mounts.Hide(c.hub.webroot, err.Error())
+	c.hub.Logger.Errorf("WS: %s - - \"file %s\" - %s", c.conn.RemoteAddr(), p, msg)
+	c.transferred(method, p, "", status)
+	c.reply("fileError", fmt.Sprintf("%s: %s", p, msg))
+}
+
+// transferred will report the transfer of p like a request with method, the same hooks see
+// transfers via websocket and http this way
+func (c *Client) transferred(method, p, file string, status int) {
+	if c.hub.Transferred != nil {
+		c.hub.Transferred(c.req, method, path.Clean("/"+p), file, status)
+		return
+	}
+	c.hub.Logger.Infof("WS: %s - - \"%s %s\" - %d", c.conn.RemoteAddr(), method, p, status)
+}
+
+// errStatus returns the http status of a failed file operation
+func errStatus(err error) int {
+	switch {
+	case os.IsNotExist(err):
+		return http.StatusNotFound
+	case os.IsPermission(err):
+		return http.StatusForbidden
+	case os.IsExist(err):
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// reply will send a packet to this client only
+func (c *Client) reply(kind, content string) {
+	msg, err := json.Marshal(&SendPacket{Type: kind, Content: content})
+	if err != nil {
+		c.hub.Logger.Errorf("Unable to marshal json data: %+v", err)
+		return
+	}
+	if err := c.write(websocket.TextMessage, msg); err != nil {
+		c.hub.Logger.Errorf("writing to websocket: %+v", err)
+	}
+}
@@ -1,15 +1,23 @@
 package mysock
 
-import "github.com/patrickhener/goshs/internal/myclipboard"
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+
+	"github.com/patrickhener/goshs/internal/myclipboard"
+	"github.com/patrickhener/goshs/internal/mylog"
+	"github.com/patrickhener/goshs/internal/mymount"
+)
 
 // Hub maintains the set of active clients and broadcasts messages to the
 // clients.
 type Hub struct {
-	// Registered clients.
-	clients map[*Client]bool
+	// Registered clients and the clipboard channel they subscribed to.
+	clients map[*Client]string
 
 	// Inbound messages from the clients.
-	broadcast chan []byte
+	broadcast chan message
 
 	// Register requests from the clients.
 	register chan *Client
@@ -17,36 +25,96 @@ type Hub struct {
 	// Unregister requests from clients.
 	unregister chan *Client
 
+	// Clients switching to another clipboard channel.
+	subscribe chan subscription
+
+	// done is closed by Stop, Run returns and the channels above are not read anymore
+	done     chan struct{}
+	stopOnce sync.Once
+
 	// Handle clipboard
-	cb *myclipboard.Clipboard
+	cb *myclipboard.Channels
+
+	// Files are transferred from and to the webroot
+	webroot    string
+	uploadOnly bool
+
+	// Mounts are directories served next to the webroot with their own mode
+	Mounts mymount.Mounts
+
+	// OnClipboard is called with the channel after every change of a clipboard if set
+	OnClipboard func(channel string)
+
+	// ReserveServe and Served count downloads of a file towards a maximum if set
+	ReserveServe func(upath string) bool
+	Served       func(upath string, complete bool)
+
+	// Transferred is called for every file transfer with the request which opened the socket, method is
+	// GET for downloads and PUT for uploads and file the path on disk. It logs the transfer if set.
+	Transferred func(req *http.Request, method, upath, file string, status int)
+
+	// Logger logs the messages of the hub and its clients
+	Logger mylog.Logger
+}
+
+// ActivityChannel is watched by the activity page, it is no valid clipboard channel name
+const ActivityChannel = "#activity"
+
+// message is broadcasted to the subscribers of channel, to every client if channel is empty
+type message struct {
+	channel string
+	data    []byte
+}
+
+type subscription struct {
+	client  *Client
+	channel string
 }
 
 // NewHub will create a new hub
-func NewHub(cb *myclipboard.Clipboard) *Hub {
+func NewHub(cb *myclipboard.Channels, webroot string, uploadOnly bool) *Hub {
 	return &Hub{
-		broadcast:  make(chan []byte),
+		broadcast:  make(chan message),
 		register:   make(chan *Client),
 		unregister: make(chan *Client),
-		clients:    make(map[*Client]bool),
+		subscribe:  make(chan subscription),
+		done:       make(chan struct{}),
+		clients:    make(map[*Client]string),
 		cb:         cb,
+		webroot:    webroot,
+		uploadOnly: uploadOnly,
 	}
 }
 
-// Run runs the hub
+// Run runs the hub until Stop is called
 func (h *Hub) Run() {
 	for {
 		select {
+		case <-h.done:
+			for client := range h.clients {
+				if err := client.conn.Close(); err != nil {
+					h.Logger.Debugf("closing websocket: %+v", err)
+				}
+			}
+			return
 		case client := <-h.register:
-			h.clients[client] = true
+			h.clients[client] = client.channel
 		case client := <-h.unregister:
 			if _, ok := h.clients[client]; ok {
 				delete(h.clients, client)
 				close(client.send)
 			}
-		case message := <-h.broadcast:
-			for client := range h.clients {
+		case s := <-h.subscribe:
+			if _, ok := h.clients[s.client]; ok {
+				h.clients[s.client] = s.channel
+			}
+		case m := <-h.broadcast:
+			for client, channel := range h.clients {
+				if m.channel != "" && m.channel != channel {
+					continue
+				}
 				select {
-				case client.send <- message:
+				case client.send <- m.data:
 				default:
 					close(client.send)
 					delete(h.clients, client)
@@ -55,3 +123,68 @@ func (h *Hub) Run() {
 		}
 	}
 }
+
+// Stop will close the connections of the clients and end Run
+func (h *Hub) Stop() {
+	h.stopOnce.Do(func() {
+		close(h.done)
+	})
+}
+
+// send will pass m to Run to broadcast it, it is dropped if the hub stopped
+func (h *Hub) send(m message) {
+	select {
+	case h.broadcast <- m:
+	case <-h.done:
+	}
+}
+
+// RefreshClipboard will tell the subscribers of channel to reload the clipboard
+func (h *Hub) RefreshClipboard(channel string) {
+	if h.OnClipboard != nil {
+		h.OnClipboard(channel)
+	}
+	sendPkg := &SendPacket{
+		Type:    "refreshClipboard",
+		Content: channel,
+	}
+	broadcastMessage, err := json.Marshal(sendPkg)
+	if err != nil {
+		h.Logger.Errorf("Unable to marshal json data in redirect: %+v", err)
+	}
+
+	h.send(message{channel: channel, data: broadcastMessage})
+}
+
+// RefreshDirectory will tell all clients that the content of dir changed
+func (h *Hub) RefreshDirectory(dir string) {
+	sendPkg := &SendPacket{
+		Type:    "refreshDirectory",
+		Content: dir,
+	}
+	broadcastMessage, err := json.Marshal(sendPkg)
+	if err != nil {
+		h.Logger.Errorf("Unable to marshal json data in refresh: %+v", err)
+	}
+
+	h.send(message{data: broadcastMessage})
+}
+
+// Activity will send the snapshot of the current activity to the clients watching it
+func (h *Hub) Activity(snapshot interface{}) {
+	content, err := json.Marshal(snapshot)
+	if err != nil {
+		h.Logger.Errorf("Unable to marshal json data in activity: %+v", err)
+		return
+	}
+	broadcastMessage, err := json.Marshal(&SendPacket{
+		Type:    "activity",
+		Content: string(content),
+	})
+	if err != nil {
+		h.Logger.Errorf("Unable to marshal json data in activity: %+v", err)
+		return
+	}
+
+	h.send(message{channel: ActivityChannel, data: broadcastMessage})
+}
@@ -0,0 +1,370 @@
+// Package mytui shows what the server is doing in a terminal user interface and
+// lets the operator intervene on the fly, e.g. switch to read only or ban a client.
+package mytui
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/patrickhener/goshs/internal/myactivity"
+	"github.com/patrickhener/goshs/internal/mylog"
+	"github.com/patrickhener/goshs/internal/myutils"
+)
+
+const (
+	refresh    = 500 * time.Millisecond
+	maxLines   = 100
+	maxClients = 8
+	maxActive  = 8
+	maxRecent  = 10
+	maxCB      = 3
+)
+
+// Controls connect the interface to the running server
+type Controls struct {
+	Snapshot    func() myactivity.Snapshot
+	ReadOnly    func() bool
+	SetReadOnly func(readOnly bool) error
+	Ban         func(ip string)
+	Unban       func(ip string)
+	Banned      func() []string
+}
+
+// UI is the terminal user interface
+type UI struct {
+	title    string
+	controls Controls
+	done     chan struct{}
+
+	mu      sync.Mutex
+	program *tea.Program
+	stopped bool
+
+	log       lines
+	clipboard lines
+}
+
+// New will return the interface showing title in its header
+func New(title string, controls Controls) *UI {
+	return &UI{title: title, controls: controls, done: make(chan struct{})}
+}
+
+// ClipboardChanged will show the change of the clipboard channel
+func (u *UI) ClipboardChanged(channel string) {
+	u.clipboard.add(fmt.Sprintf("clipboard channel %q changed", channel))
+}
+
+// Run will show the interface until the operator quits or Stop is called. The log
+// is shown in the interface meanwhile instead of being printed.
+func (u *UI) Run() error {
+	defer close(u.done)
+
+	// Creating the program asks the terminal for its colors which may take a while
+	program := tea.NewProgram(&model{ui: u}, tea.WithAltScreen(), tea.WithoutSignalHandler())
+	u.mu.Lock()
+	if u.stopped {
+		u.mu.Unlock()
+		return nil
+	}
+	u.program = program
+	u.mu.Unlock()
+
+	mylog.Redirect(&u.log)
+	defer mylog.Redirect(nil)
+	_, err := program.Run()
+	return err
+}
+
+// Stop will close the interface and restore the terminal
+func (u *UI) Stop() {
+	u.mu.Lock()
+	u.stopped = true
+	program := u.program
+	u.mu.Unlock()
+	if program != nil {
+		program.Quit()
+	}
+	<-u.done
+}
+
+// lines keeps the latest lines with their time, it receives the log as well
+type lines struct {
+	mu    sync.Mutex
+	items []string
+}
+
+func (l *lines) add(line string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.items = append(l.items, time.Now().Format("15:04:05")+" "+line)
+	if len(l.items) > maxLines {
+		l.items = l.items[len(l.items)-maxLines:]
+	}
+}
+
+// last returns the latest n lines, newest first
+func (l *lines) last(n int) []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	var out []string
+	for i := len(l.items) - 1; i >= 0 && len(out) < n; i-- {
+		out = append(out, l.items[i])
+	}
+	return out
+}
+
+func (l *lines) Debugf(format string, args ...interface{}) {
+	l.add("DEBUG " + fmt.Sprintf(format, args...))
+}
+
+func (l *lines) Infof(format string, args ...interface{}) {
+	l.add("INFO  " + fmt.Sprintf(format, args...))
+}
+
+func (l *lines) Warnf(format string, args ...interface{}) {
+	l.add("WARN  " + fmt.Sprintf(format, args...))
+}
+
+func (l *lines) Errorf(format string, args ...interface{}) {
+	l.add("ERROR " + fmt.Sprintf(format, args...))
+}
+
+type tickMsg time.Time
+
+func tick() tea.Cmd {
+	return tea.Tick(refresh, func(t time.Time) tea.Msg {
+		return tickMsg(t)
+	})
+}
+
+// client is a row of the client list
+type client struct {
+	ip       string
+	active   int
+	requests int
+	banned   bool
+}
+
+type model struct {
+	ui       *UI
+	width    int
+	height   int
+	snapshot myactivity.Snapshot
+	clients  []client
+	selected string
+	status   string
+}
+
+func (m *model) Init() tea.Cmd {
+	m.refresh()
+	return tick()
+}
+
+func (m *model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.width, m.height = msg.Width, msg.Height
+	case tickMsg:
+		m.refresh()
+		return m, tick()
+	case tea.KeyMsg:
+		switch msg.String() {
+		case "q", "ctrl+c":
+			return m, tea.Quit
+		case "up", "k":
+			m.move(-1)
+		case "down", "j":
+			m.move(1)
+		case "r":
+			m.toggleReadOnly()
+		case "b":
+			if m.selected != "" {
+				m.ui.controls.Ban(m.selected)
+				m.status = "Banned " + m.selected
+				mylog.Warnf("Banned %s via the terminal interface", m.selected)
+			}
+			m.refresh()
+		case "u":
+			if m.selected != "" {
+				m.ui.controls.Unban(m.selected)
+				m.status = "Unbanned " + m.selected
+				mylog.Infof("Unbanned %s via the terminal interface", m.selected)
+			}
+			m.refresh()
+		}
+	}
+	return m, nil
+}
+
+func (m *model) toggleReadOnly() {
+	readOnly := !m.ui.controls.ReadOnly()
+	if err := m.ui.controls.SetReadOnly(readOnly); err != nil {
+		m.status = err.Error()
+		return
+	}
+	if readOnly {
+		m.status = "Switched to read only mode"
+	} else {
+		m.status = "Switched off read only mode"
+	}
+	mylog.Info(m.status + " via the terminal interface")
+}
+
+// refresh will take a new snapshot and keep the selected client if it is still listed
+func (m *model) refresh() {
+	m.snapshot = m.ui.controls.Snapshot()
+
+	byIP := make(map[string]*client)
+	var order []string
+	count := func(ip string) *client {
+		c, ok := byIP[ip]
+		if !ok {
+			c = &client{ip: ip}
+			byIP[ip] = c
+			order = append(order, ip)
+		}
+		return c
+	}
+	for _, t := range m.snapshot.Active {
+		c := count(t.Remote)
+		c.active++
+		c.requests++
+	}
+	for _, t := range m.snapshot.Recent {
+		count(t.Remote).requests++
+	}
+	for _, ip := range m.ui.controls.Banned() {
+		count(ip).banned = true
+	}
+
+	m.clients = m.clients[:0]
+	for _, ip := range order {
+		m.clients = append(m.clients, *byIP[ip])
+	}
+	if m.index(m.selected) < 0 {
+		m.selected = ""
+		if len(m.clients) > 0 {
+			m.selected = m.clients[0].ip
+		}
+	}
+}
+
+func (m *model) index(ip string) int {
+	for i, c := range m.clients {
+		if c.ip == ip {
+			return i
+		}
+	}
+	return -1
+}
+
+func (m *model) move(by int) {
+	if len(m.clients) == 0 {
+		return
+	}
+	i := m.index(m.selected) + by
+	if i < 0 {
+		i = 0
+	}
+	if i >= len(m.clients) {
+		i = len(m.clients) - 1
+	}
+	m.selected = m.clients[i].ip
+}
+
+func (m *model) View() string {
+	var out []string
+	mode := "off"
+	if m.ui.controls.ReadOnly() {
+		mode = "on"
+	}
+	out = append(out,
+		fmt.Sprintf("%s | read only: %s | banned: %d", m.ui.title, mode, len(m.ui.controls.Banned())),
+		"q quit | r toggle read only | up/down select client | b ban | u unban",
+		m.status,
+		"",
+		"Clients")
+	if len(m.clients) == 0 {
+		out = append(out, "  none yet")
+	}
+	for i, c := range m.clients {
+		if i == maxClients {
+			out = append(out, fmt.Sprintf("  ... %d more", len(m.clients)-i))
+			break
+		}
+		cursor := "  "
+		if c.ip == m.selected {
+			cursor = "> "
+		}
+		line := fmt.Sprintf("%s%-39s %3d active %4d requests", cursor, c.ip, c.active, c.requests)
+		if c.banned {
+			line += "  BANNED"
+		}
+		out = append(out, line)
+	}
+
+	out = append(out, "", "Transfers")
+	if len(m.snapshot.Active) == 0 {
+		out = append(out, "  none")
+	}
+	for i, t := range m.snapshot.Active {
+		if i == maxActive {
+			out = append(out, fmt.Sprintf("  ... %d more", len(m.snapshot.Active)-i))
+			break
+		}
+		out = append(out, fmt.Sprintf("  %-15s %-6s %-40s %s %10s/s %5.0fs",
+			t.Remote, t.Method, t.Path, progress(t), myutils.ByteCountDecimal(int64(t.Rate)), t.Seconds))
+	}
+
+	out = append(out, "", "Recent requests")
+	for i, t := range m.snapshot.Recent {
+		if i == maxRecent {
+			break
+		}
+		out = append(out, fmt.Sprintf("  %s %-15s %-6s %-40s %d %10s",
+			t.Started.Format("15:04:05"), t.Remote, t.Method, t.Path, t.Status, myutils.ByteCountDecimal(t.Sent+t.Received)))
+	}
+
+	out = append(out, "", "Clipboard")
+	for _, line := range m.ui.clipboard.last(maxCB) {
+		out = append(out, "  "+line)
+	}
+
+	out = append(out, "", "Log")
+	if m.height > 0 {
+		for _, line := range m.ui.log.last(m.height - len(out)) {
+			out = append(out, "  "+line)
+		}
+	}
+	return m.fit(out)
+}
+
+// fit will cut the lines to the size of the terminal
+func (m *model) fit(out []string) string {
+	if m.height > 0 && len(out) > m.height {
+		out = out[:m.height]
+	}
+	if m.width > 0 {
+		for i, line := range out {
+			if r := []rune(line); len(r) > m.width {
+				out[i] = string(r[:m.width])
+			}
+		}
+	}
+	return strings.Join(out, "\n")
+}
+
+// progress returns how much of a transfer of known size is done
+func progress(t myactivity.Transfer) string {
+	done := t.Sent
+	if t.Received > done {
+		done = t.Received
+	}
+	if t.Size <= 0 {
+		return fmt.Sprintf("%10s      ", myutils.ByteCountDecimal(done))
+	}
+	return fmt.Sprintf("%10s %4.0f%%", myutils.ByteCountDecimal(done), float64(done)*100/float64(t.Size))
+}
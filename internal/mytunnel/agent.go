@@ -0,0 +1,160 @@
+// Package mytunnel exposes the web interface through a relay or SSH server the host dials out to,
+// for hosts behind strict ingress filtering
+package mytunnel
+
+import (
+	"errors"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/yamux"
+	"github.com/patrickhener/goshs/internal/mylog"
+)
+
+const (
+	minBackoff = time.Second
+	maxBackoff = 30 * time.Second
+)
+
+// session is the tunnel the forwarded connections arrive through
+type session interface {
+	Accept() (net.Conn, error)
+	Close() error
+}
+
+// Listener accepts the connections the remote end forwards, it reconnects if the tunnel drops
+type Listener struct {
+	remote string
+	open   func() (session, error)
+
+	mu      sync.Mutex
+	session session
+	closed  chan struct{}
+}
+
+func newListener(remote string, open func() (session, error)) *Listener {
+	return &Listener{remote: remote, open: open, closed: make(chan struct{})}
+}
+
+// Dial will connect to the relay and return a listener for the forwarded connections
+func Dial(relay, secret string) *Listener {
+	return newListener(relay, func() (session, error) {
+		return dialRelay(relay, secret)
+	})
+}
+
+// connect will dial the remote end until it succeeds or the listener gets closed
+func (l *Listener) connect() (session, error) {
+	backoff := minBackoff
+	for {
+		session, err := l.dial()
+		if err == nil {
+			mylog.Infof("Tunnel to %s established", l.remote)
+			return session, nil
+		}
+		mylog.Errorf("Connecting to %s: %+v, retrying in %s", l.remote, err, backoff)
+
+		select {
+		case <-l.closed:
+			return nil, errors.New("tunnel closed")
+		case <-time.After(backoff):
+		}
+		if backoff *= 2; backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+	}
+}
+
+func (l *Listener) dial() (session, error) {
+	session, err := l.open()
+	if err != nil {
+		return nil, err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	select {
+	case <-l.closed:
+		session.Close()
+		return nil, errors.New("tunnel closed")
+	default:
+	}
+	l.session = session
+	return session, nil
+}
+
+// Accept implements net.Listener
+func (l *Listener) Accept() (net.Conn, error) {
+	for {
+		l.mu.Lock()
+		session := l.session
+		l.mu.Unlock()
+
+		if session == nil {
+			var err error
+			if session, err = l.connect(); err != nil {
+				return nil, err
+			}
+		}
+
+		conn, err := session.Accept()
+		if err == nil {
+			return conn, nil
+		}
+
+		select {
+		case <-l.closed:
+			return nil, err
+		default:
+		}
+		mylog.Warnf("Tunnel to %s lost: %+v", l.remote, err)
+		l.mu.Lock()
+		l.session = nil
+		l.mu.Unlock()
+	}
+}
+
+// Close implements net.Listener
+func (l *Listener) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	select {
+	case <-l.closed:
+		return nil
+	default:
+	}
+	close(l.closed)
+	if l.session != nil {
+		return l.session.Close()
+	}
+	return nil
+}
+
+// Addr implements net.Listener
+func (l *Listener) Addr() net.Addr {
+	return remoteAddr(l.remote)
+}
+
+type remoteAddr string
+
+func (a remoteAddr) Network() string { return "tunnel" }
+func (a remoteAddr) String() string  { return string(a) }
+
+func dialRelay(relay, secret string) (session, error) {
+	conn, err := net.DialTimeout("tcp", relay, handshakeTimeout)
+	if err != nil {
+		return nil, err
+	}
+	if err := respond(conn, secret); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	// The relay opens a stream for every visitor
+	session, err := yamux.Server(conn, yamux.DefaultConfig())
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return session, nil
+}
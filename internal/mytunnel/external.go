@@ -0,0 +1,113 @@
+package mytunnel
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"regexp"
+	"time"
+
+	"github.com/patrickhener/goshs/internal/mylog"
+)
+
+// Clients of the tunnel services which can be spawned
+const (
+	Cloudflared = "cloudflared"
+	Ngrok       = "ngrok"
+)
+
+const externalTimeout = 30 * time.Second
+
+var cloudflaredURL = regexp.MustCompile(`https://[a-z0-9-]+\.trycloudflare\.com`)
+
+// External will spawn the client of the tunnel service to expose localURL and
+// return the public url. The client is stopped by the returned function.
+func External(client, localURL string, insecure bool) (string, func(), error) {
+	var args []string
+	var parse func(line string) string
+	switch client {
+	case Cloudflared:
+		args = []string{"tunnel", "--no-autoupdate", "--url", localURL}
+		if insecure {
+			args = append(args, "--no-tls-verify")
+		}
+		parse = func(line string) string {
+			return cloudflaredURL.FindString(line)
+		}
+	case Ngrok:
+		// ngrok does not verify the certificate of the upstream
+		args = []string{"http", localURL, "--log", "stdout", "--log-format", "json"}
+		parse = func(line string) string {
+			var entry struct {
+				Msg string `json:"msg"`
+				URL string `json:"url"`
+			}
+			if err := json.Unmarshal([]byte(line), &entry); err != nil || entry.Msg != "started tunnel" {
+				return ""
+			}
+			return entry.URL
+		}
+	default:
+		return "", nil, fmt.Errorf("unknown tunnel client %s, use %s or %s", client, Cloudflared, Ngrok)
+	}
+
+	path, err := exec.LookPath(client)
+	if err != nil {
+		return "", nil, err
+	}
+	// disable G204 (CWE-78): Subprocess launched with variable
+	// as the client is one of the known ones
+	// #nosec G204
+	cmd := exec.Command(path, args...)
+	out, err := cmd.StdoutPipe()
+	if err != nil {
+		return "", nil, err
+	}
+	// Both clients log to either of them
+	cmd.Stderr = cmd.Stdout
+	if err := cmd.Start(); err != nil {
+		return "", nil, err
+	}
+	stop := func() {
+		if err := cmd.Process.Kill(); err != nil {
+			mylog.Debugf("stopping %s: %+v", client, err)
+		}
+		// disable G104 (CWE-703): Errors unhandled
+		// #nosec G104
+		cmd.Wait()
+	}
+
+	found := make(chan string, 1)
+	exited := make(chan string, 1)
+	go func() {
+		var last string
+		scanner := bufio.NewScanner(out)
+		for scanner.Scan() {
+			last = scanner.Text()
+			mylog.Debugf("%s: %s", client, last)
+			if url := parse(last); url != "" {
+				select {
+				case found <- url:
+				default:
+				}
+			}
+		}
+		// Keep draining, otherwise the client blocks on a full pipe
+		_, _ = io.Copy(io.Discard, out)
+		exited <- last
+	}()
+
+	select {
+	case url := <-found:
+		return url, stop, nil
+	case last := <-exited:
+		stop()
+		return "", nil, fmt.Errorf("%s exited without a public url: %s", client, last)
+	case <-time.After(externalTimeout):
+		stop()
+		return "", nil, errors.New("timeout waiting for the public url of " + client)
+	}
+}
@@ -0,0 +1,101 @@
+package mytunnel
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+)
+
+const (
+	greeting         = "goshs-relay 1"
+	handshakeTimeout = 10 * time.Second
+)
+
+var errHandshake = errors.New("tunnel handshake failed, check the secret")
+
+func proof(secret, nonce string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(nonce))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// challenge is run by the relay, the secret never crosses the wire
+func challenge(conn net.Conn, secret string) error {
+	if err := conn.SetDeadline(time.Now().Add(handshakeTimeout)); err != nil {
+		return err
+	}
+	defer conn.SetDeadline(time.Time{})
+
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return err
+	}
+	nonce := hex.EncodeToString(b)
+	if _, err := fmt.Fprintf(conn, "%s %s\n", greeting, nonce); err != nil {
+		return err
+	}
+
+	line, err := readLine(conn)
+	if err != nil {
+		return err
+	}
+	if !hmac.Equal([]byte(line), []byte(proof(secret, nonce))) {
+		fmt.Fprintln(conn, "denied")
+		return errHandshake
+	}
+	_, err = fmt.Fprintln(conn, "ok")
+	return err
+}
+
+// respond is run by the agent
+func respond(conn net.Conn, secret string) error {
+	if err := conn.SetDeadline(time.Now().Add(handshakeTimeout)); err != nil {
+		return err
+	}
+	defer conn.SetDeadline(time.Time{})
+
+	line, err := readLine(conn)
+	if err != nil {
+		return err
+	}
+	if !strings.HasPrefix(line, greeting+" ") {
+		return fmt.Errorf("%s is not a goshs relay", conn.RemoteAddr())
+	}
+	if _, err := fmt.Fprintln(conn, proof(secret, strings.TrimPrefix(line, greeting+" "))); err != nil {
+		return err
+	}
+
+	line, err = readLine(conn)
+	if err != nil {
+		return err
+	}
+	if line != "ok" {
+		return errHandshake
+	}
+	return nil
+}
+
+// readLine reads byte by byte, everything after the line belongs to the session
+func readLine(conn net.Conn) (string, error) {
+	var line []byte
+	c := make([]byte, 1)
+	for {
+		if _, err := io.ReadFull(conn, c); err != nil {
+			return "", err
+		}
+		if c[0] == '\n' {
+			return string(line), nil
+		}
+		if len(line) > 256 {
+			return "", errors.New("handshake line too long")
+		}
+		line = append(line, c[0])
+	}
+}
@@ -0,0 +1,116 @@
+package mytunnel
+
+import (
+	"io"
+	"net"
+	"sync"
+
+	"github.com/hashicorp/yamux"
+	"github.com/patrickhener/goshs/internal/mylog"
+)
+
+// Relay forwards the visitors of the public listener to the connected agent
+type Relay struct {
+	secret string
+
+	mu      sync.Mutex
+	session *yamux.Session
+}
+
+// NewRelay will return a relay which only accepts agents knowing secret
+func NewRelay(secret string) *Relay {
+	return &Relay{secret: secret}
+}
+
+// ServeAgents will accept agents on addr, a new agent replaces the current one
+func (r *Relay) ServeAgents(addr string) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	mylog.Infof("Waiting for goshs agents on %s", addr)
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return err
+		}
+		go r.handleAgent(conn)
+	}
+}
+
+func (r *Relay) handleAgent(conn net.Conn) {
+	if err := challenge(conn, r.secret); err != nil {
+		mylog.Warnf("Agent %s rejected: %+v", conn.RemoteAddr(), err)
+		conn.Close()
+		return
+	}
+	session, err := yamux.Client(conn, yamux.DefaultConfig())
+	if err != nil {
+		mylog.Errorf("Starting session with agent %s: %+v", conn.RemoteAddr(), err)
+		conn.Close()
+		return
+	}
+
+	r.mu.Lock()
+	if r.session != nil {
+		r.session.Close()
+	}
+	r.session = session
+	r.mu.Unlock()
+	mylog.Infof("Agent %s connected", conn.RemoteAddr())
+
+	<-session.CloseChan()
+	mylog.Warnf("Agent %s disconnected", conn.RemoteAddr())
+}
+
+// ServePublic will forward the connections on addr to the agent
+func (r *Relay) ServePublic(addr string) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	mylog.Infof("Relaying visitors on %s", addr)
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return err
+		}
+		go r.forward(conn)
+	}
+}
+
+func (r *Relay) forward(conn net.Conn) {
+	defer conn.Close()
+
+	r.mu.Lock()
+	session := r.session
+	r.mu.Unlock()
+	if session == nil || session.IsClosed() {
+		mylog.Warnf("Dropping visitor %s, no agent connected", conn.RemoteAddr())
+		return
+	}
+
+	stream, err := session.Open()
+	if err != nil {
+		mylog.Errorf("Opening stream to agent: %+v", err)
+		return
+	}
+	defer stream.Close()
+
+	done := make(chan struct{}, 2)
+	go func() {
+		if _, err := io.Copy(stream, conn); err != nil {
+			mylog.Debugf("relaying to agent: %+v", err)
+		}
+		done <- struct{}{}
+	}()
+	go func() {
+		if _, err := io.Copy(conn, stream); err != nil {
+			mylog.Debugf("relaying to visitor: %+v", err)
+		}
+		done <- struct{}{}
+	}()
+	<-done
+}
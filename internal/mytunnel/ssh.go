@@ -0,0 +1,167 @@
+package mytunnel
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/patrickhener/goshs/internal/mylog"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+const sshKeepAlive = 30 * time.Second
+
+// SSH will connect to target (user@host[:port]) and return a listener for the connections
+// to remotePort on the SSH server, like ssh -R does. Keys are taken from identity, the
+// ssh-agent and ~/.ssh, the host key has to be in ~/.ssh/known_hosts.
+func SSH(target, identity string, remotePort int) (*Listener, error) {
+	user, addr, err := parseTarget(target)
+	if err != nil {
+		return nil, err
+	}
+
+	auth, err := authMethods(identity)
+	if err != nil {
+		return nil, err
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return nil, err
+	}
+	hostKeys, err := knownhosts.New(filepath.Join(home, ".ssh", "known_hosts"))
+	if err != nil {
+		return nil, fmt.Errorf("reading known hosts: %v", err)
+	}
+
+	config := &ssh.ClientConfig{
+		User: user,
+		Auth: auth,
+		HostKeyCallback: func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+			if err := hostKeys(hostname, remote, key); err != nil {
+				return fmt.Errorf("%v (%s %s), connect once with ssh to verify and add it", err, key.Type(), ssh.FingerprintSHA256(key))
+			}
+			return nil
+		},
+		Timeout: handshakeTimeout,
+	}
+	bind := net.JoinHostPort("0.0.0.0", strconv.Itoa(remotePort))
+
+	return newListener(fmt.Sprintf("%s@%s", user, addr), func() (session, error) {
+		return dialSSH(addr, config, bind)
+	}), nil
+}
+
+func parseTarget(target string) (user, addr string, err error) {
+	i := strings.LastIndex(target, "@")
+	if i < 0 {
+		user = os.Getenv("USER")
+	} else {
+		user, target = target[:i], target[i+1:]
+	}
+	if user == "" {
+		return "", "", errors.New("missing user, use user@host[:port]")
+	}
+
+	if _, _, err := net.SplitHostPort(target); err != nil {
+		target = net.JoinHostPort(strings.Trim(target, "[]"), "22")
+	}
+	return user, target, nil
+}
+
+func authMethods(identity string) ([]ssh.AuthMethod, error) {
+	var signers []ssh.Signer
+
+	files := []string{identity}
+	if identity == "" {
+		if home, err := os.UserHomeDir(); err == nil {
+			for _, name := range []string{"id_ed25519", "id_ecdsa", "id_rsa"} {
+				files = append(files, filepath.Join(home, ".ssh", name))
+			}
+		}
+	}
+	for _, file := range files {
+		if file == "" {
+			continue
+		}
+		// disable G304 (CWE-22): Potential file inclusion via variable
+		// as the operator chooses the key
+		// #nosec G304
+		pem, err := ioutil.ReadFile(file)
+		if err != nil {
+			if identity != "" {
+				return nil, err
+			}
+			continue
+		}
+		signer, err := ssh.ParsePrivateKey(pem)
+		if err != nil {
+			// Encrypted keys have to be loaded into the ssh-agent
+			mylog.Warnf("Skipping ssh key %s: %+v", file, err)
+			continue
+		}
+		signers = append(signers, signer)
+	}
+
+	var methods []ssh.AuthMethod
+	if len(signers) > 0 {
+		methods = append(methods, ssh.PublicKeys(signers...))
+	}
+	if sock := os.Getenv("SSH_AUTH_SOCK"); sock != "" {
+		conn, err := net.Dial("unix", sock)
+		if err != nil {
+			mylog.Warnf("Unable to connect to the ssh-agent: %+v", err)
+		} else {
+			methods = append(methods, ssh.PublicKeysCallback(agent.NewClient(conn).Signers))
+		}
+	}
+	if len(methods) == 0 {
+		return nil, errors.New("no ssh key found, use an ssh-agent or provide a key")
+	}
+	return methods, nil
+}
+
+// sshSession closes the connection along with the forwarding
+type sshSession struct {
+	net.Listener
+	client *ssh.Client
+}
+
+func (s sshSession) Close() error {
+	s.Listener.Close()
+	return s.client.Close()
+}
+
+func dialSSH(addr string, config *ssh.ClientConfig, bind string) (session, error) {
+	client, err := ssh.Dial("tcp", addr, config)
+	if err != nil {
+		return nil, err
+	}
+	listener, err := client.Listen("tcp", bind)
+	if err != nil {
+		client.Close()
+		return nil, fmt.Errorf("remote forward of %s: %v", bind, err)
+	}
+
+	// A dead connection only shows up when writing to it
+	go func() {
+		ticker := time.NewTicker(sshKeepAlive)
+		defer ticker.Stop()
+		for range ticker.C {
+			if _, _, err := client.SendRequest("keepalive@openssh.com", true, nil); err != nil {
+				client.Close()
+				return
+			}
+		}
+	}()
+
+	return sshSession{Listener: listener, client: client}, nil
+}
@@ -0,0 +1,16 @@
+//go:build !windows
+// +build !windows
+
+package myutils
+
+import "syscall"
+
+// DiskFree returns the bytes available to unprivileged users and the total size of the filesystem holding dir
+func DiskFree(dir string) (uint64, uint64, error) {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(dir, &st); err != nil {
+		return 0, 0, err
+	}
+	// The field types differ between platforms
+	return uint64(st.Bavail) * uint64(st.Bsize), uint64(st.Blocks) * uint64(st.Bsize), nil
+}
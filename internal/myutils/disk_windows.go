@@ -0,0 +1,16 @@
+package myutils
+
+import "golang.org/x/sys/windows"
+
+// DiskFree returns the bytes available to the user and the total size of the volume holding dir
+func DiskFree(dir string) (uint64, uint64, error) {
+	path, err := windows.UTF16PtrFromString(dir)
+	if err != nil {
+		return 0, 0, err
+	}
+	var free, total uint64
+	if err := windows.GetDiskFreeSpaceEx(path, &free, &total, nil); err != nil {
+		return 0, 0, err
+	}
+	return free, total, nil
+}
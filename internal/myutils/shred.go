@@ -0,0 +1,55 @@
+package myutils
+
+import (
+	"crypto/rand"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// Shred will overwrite every file below dir with random data and remove dir then, even if some
+// files could not be overwritten. Journaling file systems and SSDs may keep copies of the content nonetheless.
+func Shred(dir string) error {
+	var failed []error
+	// Walking on after an error, an unreadable file or directory shall not keep the rest from being removed
+	_ = filepath.Walk(dir, func(p string, fi os.FileInfo, err error) error {
+		if err != nil {
+			failed = append(failed, err)
+			return nil
+		}
+		if fi.Mode().IsRegular() {
+			if err := overwrite(p, fi.Size()); err != nil {
+				failed = append(failed, err)
+			}
+		}
+		return nil
+	})
+	if err := os.RemoveAll(dir); err != nil {
+		return err
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("%d files could not be overwritten before removing them, the first: %+v", len(failed), failed[0])
+	}
+	return nil
+}
+
+// overwrite will write size random bytes over the file p
+func overwrite(p string, size int64) error {
+	// disable G304 (CWE-22): Potential file inclusion via variable
+	// as the files lie below the directory to shred
+	// #nosec G304
+	f, err := os.OpenFile(p, os.O_WRONLY, 0)
+	if err != nil {
+		return err
+	}
+	if _, err := io.CopyN(f, rand.Reader, size); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
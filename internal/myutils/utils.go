@@ -2,6 +2,7 @@ package myutils
 
 import (
 	"crypto/rand"
+	"encoding/hex"
 	"fmt"
 	"math/big"
 	"mime"
@@ -46,10 +47,33 @@ func RandomNumber() (big.Int, error) {
 	return *n, err
 }
 
+// RandomString returns a cryptographically secure random alphanumeric string of length n
+func RandomString(n int) (string, error) {
+	const letters = "abcdefghijkmnopqrstuvwxyzABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+	b := make([]byte, n)
+	for i := range b {
+		idx, err := rand.Int(rand.Reader, big.NewInt(int64(len(letters))))
+		if err != nil {
+			return "", err
+		}
+		b[i] = letters[idx.Int64()]
+	}
+	return string(b), nil
+}
+
+// RandomHex returns n cryptographically secure random bytes hex encoded
+func RandomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
 // CheckSpecialPath will check a slice of special paths against
 // a folder on disk and return true if it matches
 func CheckSpecialPath(check string) bool {
-	specialPaths := []string{"425bda8487e36deccb30dd24be590b8744e3a28a8bb5a57d9b3fcd24ae09ad3c", "cf985bddf28fed5d5c53b069d6a6ebe601088ca6e20ec5a5a8438f8e1ffd9390", "14644be038ea0118a1aadfacca2a7d1517d7b209c4b9674ee893b1944d1c2d54"}
+	specialPaths := []string{"425bda8487e36deccb30dd24be590b8744e3a28a8bb5a57d9b3fcd24ae09ad3c", "cf985bddf28fed5d5c53b069d6a6ebe601088ca6e20ec5a5a8438f8e1ffd9390", "14644be038ea0118a1aadfacca2a7d1517d7b209c4b9674ee893b1944d1c2d54", "073c1634c496cdb649d1afe0a312bbb4b7e1741b271542e4a436c3b8824b1761", "6959097001d10501ac7d54c0bdb8db61420f658f2922cc26e46d536119a31126"}
 
 	for _, item := range specialPaths {
 		if item == check {
@@ -84,8 +108,35 @@ func GetInterfaceIpv4Addr(interfaceName string) (addr string, err error) {
 	return ipv4Addr.String(), nil
 }
 
-// GetAllIPAdresses will return a map of interface and associated ipv4 addresses for displaying reasons
-func GetAllIPAdresses() (map[string]string, error) {
+// GetInterfaceIpv6Addr will return the global ipv6 address by name, link-local addresses are skipped
+func GetInterfaceIpv6Addr(interfaceName string) (string, error) {
+	ief, err := net.InterfaceByName(interfaceName)
+	if err != nil {
+		return "", err
+	}
+	addrs, err := ief.Addrs()
+	if err != nil {
+		return "", err
+	}
+	for _, addr := range addrs {
+		ip := addr.(*net.IPNet).IP
+		if ip.To4() == nil && !ip.IsLinkLocalUnicast() {
+			return ip.String(), nil
+		}
+	}
+	return "", fmt.Errorf("interface %s doesn't have an ipv6 address", interfaceName)
+}
+
+// GetInterfaceAddr will return the ipv4 address by name and fall back to the ipv6 address
+func GetInterfaceAddr(interfaceName string) (string, error) {
+	if addr, err := GetInterfaceIpv4Addr(interfaceName); err == nil {
+		return addr, nil
+	}
+	return GetInterfaceIpv6Addr(interfaceName)
+}
+
+// GetAllIPAdresses will return a map of interface and associated ipv4 or ipv6 addresses for displaying reasons
+func GetAllIPAdresses(ipv6 bool) (map[string]string, error) {
 	ifaceAddress := make(map[string]string)
 
 	ifaces, err := net.Interfaces()
@@ -93,8 +144,13 @@ func GetAllIPAdresses() (map[string]string, error) {
 		return nil, err
 	}
 
+	lookup := GetInterfaceIpv4Addr
+	if ipv6 {
+		lookup = GetInterfaceIpv6Addr
+	}
+
 	for _, i := range ifaces {
-		ip, err := GetInterfaceIpv4Addr(i.Name)
+		ip, err := lookup(i.Name)
 		if err != nil {
 			continue
 		}
@@ -105,3 +161,42 @@ func GetAllIPAdresses() (map[string]string, error) {
 	return ifaceAddress, nil
 
 }
+
+// ParseNetworks will parse a list of CIDRs, plain ip addresses are treated as single host networks
+func ParseNetworks(list []string) ([]*net.IPNet, error) {
+	var networks []*net.IPNet
+	for _, item := range list {
+		if !strings.Contains(item, "/") {
+			ip := net.ParseIP(item)
+			if ip == nil {
+				return nil, fmt.Errorf("%s is neither a valid ip address nor a CIDR", item)
+			}
+			if ip.To4() != nil {
+				item += "/32"
+			} else {
+				item += "/128"
+			}
+		}
+
+		_, network, err := net.ParseCIDR(item)
+		if err != nil {
+			return nil, err
+		}
+		networks = append(networks, network)
+	}
+	return networks, nil
+}
+
+// InNetworks reports whether ip is part of one of the networks
+func InNetworks(ip string, networks []*net.IPNet) bool {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return false
+	}
+	for _, network := range networks {
+		if network.Contains(parsed) {
+			return true
+		}
+	}
+	return false
+}
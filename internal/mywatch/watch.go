@@ -0,0 +1,128 @@
+// Package mywatch reports changed directories below the webroot.
+package mywatch
+
+import (
+	"os"
+	"path"
+	"path/filepath"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/patrickhener/goshs/internal/mylog"
+	"github.com/patrickhener/goshs/internal/myutils"
+)
+
+const (
+	// MaxDirs limits the watched directories as every one costs a watch descriptor
+	MaxDirs = 4096
+	// settle collects the events of this period into one notification per directory
+	settle = 500 * time.Millisecond
+)
+
+// Watcher calls a function with the directory relative to the root whenever its content changed
+type Watcher struct {
+	root    string
+	watcher *fsnotify.Watcher
+	watched map[string]bool
+	log     mylog.Logger
+}
+
+// New will start watching root and all directories below it, log receives the errors
+func New(root string, log mylog.Logger, changed func(dir string)) (*Watcher, error) {
+	fw, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	w := &Watcher{root: root, watcher: fw, watched: map[string]bool{}, log: log}
+	w.add(root)
+	go w.run(changed)
+	return w, nil
+}
+
+// Close stops watching
+func (w *Watcher) Close() error {
+	return w.watcher.Close()
+}
+
+// add will watch dir and its subdirectories until MaxDirs is reached
+func (w *Watcher) add(dir string) {
+	err := filepath.Walk(dir, func(p string, fi os.FileInfo, err error) error {
+		if err != nil {
+			// Skip what cannot be read
+			return nil
+		}
+		if !fi.IsDir() {
+			return nil
+		}
+		if myutils.CheckSpecialPath(fi.Name()) {
+			return filepath.SkipDir
+		}
+		if len(w.watched) >= MaxDirs {
+			return filepath.SkipDir
+		}
+		if err := w.watcher.Add(p); err != nil {
+			w.log.Debugf("watching %s: %+v", p, err)
+			return nil
+		}
+		w.watched[p] = true
+		if len(w.watched) == MaxDirs {
+			w.log.Warnf("Watching the first %d directories only for live refresh", MaxDirs)
+		}
+		return nil
+	})
+	if err != nil {
+		w.log.Errorf("watching %s: %+v", dir, err)
+	}
+}
+
+// rel returns p relative to the root as slash separated path starting with /
+func (w *Watcher) rel(p string) string {
+	rel, err := filepath.Rel(w.root, p)
+	if err != nil {
+		return "/"
+	}
+	return path.Clean("/" + filepath.ToSlash(rel))
+}
+
+func (w *Watcher) run(changed func(dir string)) {
+	pending := map[string]bool{}
+	timer := time.NewTimer(settle)
+	timer.Stop()
+	for {
+		select {
+		case event, ok := <-w.watcher.Events:
+			if !ok {
+				return
+			}
+			// Removed directories lose their watch, renamed ones are added again by the create event of the new name
+			if event.Op&(fsnotify.Remove|fsnotify.Rename) != 0 && w.watched[event.Name] {
+				delete(w.watched, event.Name)
+				if event.Op&fsnotify.Rename != 0 {
+					_ = w.watcher.Remove(event.Name)
+				}
+			}
+			if event.Op&fsnotify.Create != 0 {
+				if fi, err := os.Stat(event.Name); err == nil && fi.IsDir() {
+					w.add(event.Name)
+				}
+			}
+			if event.Op == fsnotify.Chmod {
+				continue
+			}
+			if len(pending) == 0 {
+				timer.Reset(settle)
+			}
+			pending[w.rel(filepath.Dir(event.Name))] = true
+		case err, ok := <-w.watcher.Errors:
+			if !ok {
+				return
+			}
+			w.log.Errorf("watching the webroot: %+v", err)
+		case <-timer.C:
+			for dir := range pending {
+				changed(dir)
+			}
+			pending = map[string]bool{}
+		}
+	}
+}
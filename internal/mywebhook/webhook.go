@@ -0,0 +1,192 @@
+// Package mywebhook posts selected events like downloads, uploads or failed
+// logins to a webhook url for lightweight alerting.
+package mywebhook
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/patrickhener/goshs/internal/mylog"
+)
+
+// The events a webhook can be fired for
+const (
+	// EventDownload is the first download of a path
+	EventDownload = "download"
+	EventUpload   = "upload"
+	EventNotFound = "notfound"
+	// EventAuth is a failed login
+	EventAuth = "auth"
+	// EventError is any answer with a status of 500 and above
+	EventError = "error"
+	// EventCanary is a request to a canary path
+	EventCanary = "canary"
+)
+
+// Events are all known events in the order of the documentation
+var Events = []string{EventDownload, EventUpload, EventNotFound, EventAuth, EventError, EventCanary}
+
+// queueSize is the number of events waiting to be sent, more are dropped
+const queueSize = 100
+
+// Event is the data available to the body template
+type Event struct {
+	Event      string `json:"event"`
+	Time       string `json:"time"`
+	RemoteAddr string `json:"remote_addr"`
+	Method     string `json:"method,omitempty"`
+	Path       string `json:"path,omitempty"`
+	Status     int    `json:"status,omitempty"`
+	User       string `json:"user,omitempty"`
+	UserAgent  string `json:"user_agent,omitempty"`
+}
+
+// Webhook sends the events it is configured for to url
+type Webhook struct {
+	url    string
+	events map[string]bool
+	body   *template.Template
+	client *http.Client
+	queue  chan Event
+
+	mu         sync.Mutex
+	downloaded map[string]bool
+}
+
+// New will return a webhook posting the comma separated events (or "all") to url.
+// The body is the event as JSON unless tmpl is given, a text/template over Event
+// with the function json to quote a value, e.g. {"text": {{json .Path}}}.
+func New(url, events, tmpl string) (*Webhook, error) {
+	h := &Webhook{
+		url:        url,
+		events:     make(map[string]bool),
+		client:     &http.Client{Timeout: 10 * time.Second},
+		queue:      make(chan Event, queueSize),
+		downloaded: make(map[string]bool),
+	}
+
+	for _, e := range strings.Split(events, ",") {
+		e = strings.TrimSpace(e)
+		if e == "all" {
+			for _, known := range Events {
+				h.events[known] = true
+			}
+			continue
+		}
+		if !known(e) {
+			return nil, fmt.Errorf("unknown webhook event %q, use %s or all", e, strings.Join(Events, ", "))
+		}
+		h.events[e] = true
+	}
+
+	if tmpl != "" {
+		t, err := template.New("webhook").Funcs(template.FuncMap{"json": quote}).Parse(tmpl)
+		if err != nil {
+			return nil, err
+		}
+		h.body = t
+	}
+
+	go h.send()
+	return h, nil
+}
+
+func known(event string) bool {
+	for _, e := range Events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// quote returns v as JSON to be placed in a JSON template safely
+func quote(v interface{}) (string, error) {
+	b, err := json.Marshal(v)
+	return string(b), err
+}
+
+// Fire will send e if its event is configured, downloads only the first time per path.
+// It never blocks, events are dropped if the webhook cannot keep up.
+func (h *Webhook) Fire(e Event) {
+	if !h.events[e.Event] {
+		return
+	}
+	if e.Event == EventDownload {
+		h.mu.Lock()
+		seen := h.downloaded[e.Path]
+		h.downloaded[e.Path] = true
+		h.mu.Unlock()
+		if seen {
+			return
+		}
+	}
+	if e.Time == "" {
+		e.Time = time.Now().Format(time.RFC3339)
+	}
+	if host, _, err := net.SplitHostPort(e.RemoteAddr); err == nil {
+		e.RemoteAddr = host
+	}
+
+	select {
+	case h.queue <- e:
+	default:
+		mylog.Warnf("Dropping webhook event %s for %s, the webhook cannot keep up", e.Event, e.Path)
+	}
+}
+
+// LogRequest is a request hook of mylog firing for not found and server errors
+func (h *Webhook) LogRequest(req *http.Request, status int) {
+	event := EventNotFound
+	switch {
+	case status == http.StatusNotFound:
+	case status >= http.StatusInternalServerError:
+		event = EventError
+	default:
+		return
+	}
+	user, _, _ := req.BasicAuth()
+	h.Fire(Event{
+		Event:      event,
+		RemoteAddr: req.RemoteAddr,
+		Method:     req.Method,
+		Path:       req.URL.Path,
+		Status:     status,
+		User:       user,
+		UserAgent:  req.UserAgent(),
+	})
+}
+
+func (h *Webhook) send() {
+	for e := range h.queue {
+		var body bytes.Buffer
+		if h.body != nil {
+			if err := h.body.Execute(&body, e); err != nil {
+				mylog.Errorf("rendering webhook body: %+v", err)
+				continue
+			}
+		} else if err := json.NewEncoder(&body).Encode(e); err != nil {
+			mylog.Errorf("encoding webhook body: %+v", err)
+			continue
+		}
+
+		resp, err := h.client.Post(h.url, "application/json", &body)
+		if err != nil {
+			mylog.Errorf("sending webhook for %s: %+v", e.Event, err)
+			continue
+		}
+		if err := resp.Body.Close(); err != nil {
+			mylog.Debugf("closing webhook response: %+v", err)
+		}
+		if resp.StatusCode >= 300 {
+			mylog.Warnf("Webhook for %s answered with %s", e.Event, resp.Status)
+		}
+	}
+}
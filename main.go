@@ -1,19 +1,49 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"math/rand"
+	"net"
+	"net/http"
 	"os"
 	"os/signal"
 	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
 	"syscall"
 	"time"
 
+	"github.com/patrickhener/goshs/internal/myaccess"
+	"github.com/patrickhener/goshs/internal/myactivity"
+	"github.com/patrickhener/goshs/internal/myaudit"
+	"github.com/patrickhener/goshs/internal/myauth"
+	"github.com/patrickhener/goshs/internal/myca"
+	"github.com/patrickhener/goshs/internal/mycanary"
+	"github.com/patrickhener/goshs/internal/mycompletion"
+	"github.com/patrickhener/goshs/internal/myconfig"
+	"github.com/patrickhener/goshs/internal/mydump"
+	"github.com/patrickhener/goshs/internal/mygeo"
 	"github.com/patrickhener/goshs/internal/myhttp"
 	"github.com/patrickhener/goshs/internal/mylog"
+	"github.com/patrickhener/goshs/internal/mymdns"
+	"github.com/patrickhener/goshs/internal/mymonitor"
+	"github.com/patrickhener/goshs/internal/mymount"
+	"github.com/patrickhener/goshs/internal/mynat"
+	"github.com/patrickhener/goshs/internal/mynotify"
+	"github.com/patrickhener/goshs/internal/myqr"
+	"github.com/patrickhener/goshs/internal/myreport"
+	"github.com/patrickhener/goshs/internal/myrotate"
+	"github.com/patrickhener/goshs/internal/myservice"
+	"github.com/patrickhener/goshs/internal/mytui"
+	"github.com/patrickhener/goshs/internal/mytunnel"
 	"github.com/patrickhener/goshs/internal/myutils"
+	"github.com/patrickhener/goshs/internal/mywebhook"
 )
 
 const goshsVersion = "v0.1.8"
@@ -22,6 +52,7 @@ var (
 	port       = 8000
 	ip         = "0.0.0.0"
 	webroot    = "."
+	mounts     mymount.Mounts
 	ssl        = false
 	selfsigned = false
 	myKey      = ""
@@ -29,47 +60,344 @@ var (
 	basicAuth  = ""
 	webdav     = false
 	webdavPort = 8001
+	webdavMnt  = false
+	api        = false
 	uploadOnly = false
 	readOnly   = false
+	auditLog   = ""
+	auditInt   = time.Minute
+	auditCheck = ""
+	ldapURL    = ""
+	ldapBaseDN = ""
+	ldapBindDN = ""
+	ldapBindPW = ""
+	ldapFilter = myauth.DefaultLDAPFilter
+	ldapNoTLS  = false
+	speedtest  = false
+	showPerms  = false
+	stealth    = false
+	banner     = ""
+	favicon    = ""
+	cbFile     = ""
+	logFile    = ""
+	rotSize    = 0
+	rotAge     = time.Duration(0)
+	rotKeep    = 0
+	accessLog  = ""
+	accessFmt  = myaccess.FormatCombined
+	verbose    = false
+	quiet      = false
+	logLevel   = ""
+	dump       = ""
+	dumpMax    = int64(1 << 20)
+	webhook    = ""
+	hookEvents = "download,upload,auth,canary"
+	hookTmpl   = ""
+	activity   = false
+	geoIP      = ""
+	reportFile = ""
+	canary     = ""
+	canaryDesk = false
+	desktop    = false
+	config     = ""
+	profile    = ""
+	printCfg   = ""
+	lifetime   time.Duration
+	maxServes  = 0
+	single     = ""
+	stdinName  = ""
+	receive    = false
+	tempRoot   = false
+	shred      = false
+	tui        = false
+	rdns       = false
+	oidcIssuer = ""
+	oidcID     = ""
+	oidcSecret = ""
+	oidcRedir  = ""
+	oidcClaim  = myauth.DefaultGroupsClaim
+	oidcReadG  = ""
+	oidcWriteG = ""
+	maxAuth    = 5
+	banTime    = time.Minute
+	stateFile  = ""
+	status     = false
+	authExempt = ""
+	anonRead   = false
+	captureLog = ""
+	randPrefix = false
+	templates  = ""
+	h2c        = false
+	http3      = false
+	certCache  = ""
+	certCN     = ""
+	keyAlg     = ""
+	caCert     = ""
+	caKey      = ""
+	certSAN    = ""
+	p12        = ""
+	p12Pass    = ""
+	redirHTTP  = 0
+	hsts       = false
+	secHeaders = false
+	csp        = ""
+	tlsMin     = ""
+	tlsCiphers = ""
+	sftpServe  = false
+	sftpPort   = 2022
+	sftpKey    = ""
+	mdns       = false
+	mdnsName   = ""
+	upnp       = false
+	qr         = false
+	relayPort  = 0
+	tunnel     = ""
+	tunnelKey  = ""
+	sshFwd     = ""
+	sshFwdPort = 0
+	sshFwdKey  = ""
+	proxyProto = false
+	proxyTrust = ""
+	fwdTrust   = ""
 )
 
 // Man page
 func usage() func() {
 	return func() {
+		profiles, err := myconfig.ProfileDir()
+		if err != nil {
+			profiles = "goshs/profiles of the user config directory"
+		}
 		fmt.Printf(`
 goshs %s
 Usage: %s [options]
+       %s completion bash|zsh|fish|powershell
+       %s [options] service install|uninstall
 
 Web server options:
-  -i,  --ip           The ip/if-name to listen on             (default: 0.0.0.0)
+  -i,  --ip           The ip/if-name to listen on, :: for ipv6 (default: 0.0.0.0)
   -p,  --port         The port to listen on                   (default: 8000)
   -d,  --dir          The web root directory                  (default: current working path)
+                      or /path:dir[:ro|:uo] to serve dir below /path, repeatable
+  -f,  --file         Serve only this file at / as a download
+  -stdin              Serve what is piped to stdin at / as a download with this name
+  -receive            Write the first file uploaded by PUT or POST to stdout and exit
+  -temp               Serve a new temporary directory         (default: false)
+  -shred              Overwrite and remove the temporary directory on exit (default: false)
   -w,  --webdav       Also serve using webdav protocol        (default: false)
   -wp, --webdav-port  The port to listen on for webdav        (default: 8001)
+  -wm, --webdav-mount
+                      Serve webdav below /webdav/ on the main port (default: false)
   -ro, --read-only    Read only mode, no upload possible      (default: false)
   -uo, --upload-only  Upload only mode, no download possible  (default: false)
+  -st, --speedtest    Serve a speedtest under /speedtest      (default: false)
+  -activity           Serve a page with live requests and transfers (default: false)
+  -pm, --permissions  Show mode, owner and group in listings  (default: false)
+  -stealth            Hide version, name and well known paths of goshs (default: false)
+  -banner             Text or file shown above every listing, e.g. a legal notice
+  -favicon            Serve this icon as /favicon.ico instead of the embedded one
+  -api                Serve a JSON API under /api/v1          (default: false)
+  -h2c                Serve cleartext HTTP/2 without TLS      (default: false)
+  -rp, --random-prefix
+                      Serve below a random secret path        (default: false)
+  -tpl, --templates
+                      Override embedded templates, css and js with the files in this directory
+  -pp, --proxy-protocol
+                      Accept PROXY protocol v1/v2 from load balancers (default: false)
+  -ppt, --proxy-trusted
+                      Comma separated networks (CIDR) allowed to send the PROXY header,
+                      required with -pp
+  -tp, --trusted-proxy
+                      Comma separated networks (CIDR) of reverse proxies whose
+                      Forwarded or X-Forwarded-For header names the client
+  -timeout            Shut down after this duration, e.g. 2h  (default: never)
+  -max-serves         Shut down after a file was downloaded completely this often and
+                      refuse further downloads of it, not with webdav or sftp
+                      (default: unlimited)
+  -tui                Show live clients, transfers and the log in a terminal UI
+                      with keys to toggle read only and ban clients (default: false)
+
+SFTP options:
+  -sftp                  Also serve using SFTP                   (default: false)
+  -sfp, --sftp-port      The port to listen on for SFTP          (default: 2022)
+  -sfk, --sftp-host-key  Path to the SSH host key                (default: generated ed25519 key)
+
+Tunnel options:
+  -tu,   --tunnel           Dial out to a goshs relay (host:port) and serve through it
+                            or expose publicly via cloudflared or ngrok
+  -ts,   --tunnel-secret    Secret shared by relay and agent
+  -rl,   --relay            Act as relay only, agents connect to this port and
+                            visitors are forwarded from -i/-p
+  -sshf, --ssh-forward      Publish via ssh remote forward on user@host[:port]
+  -sshp, --ssh-remote-port  Port to listen on at the ssh server (default: -p)
+  -sshi, --ssh-identity     Private key for the ssh server      (default: ssh-agent and ~/.ssh/id_*)
 
 TLS options:
-  -s,  --ssl          Use TLS
-  -ss, --self-signed  Use a self-signed certificate
-  -sk, --server-key   Path to server key
-  -sc, --server-cert  Path to server certificate
+  -s,    --ssl            Use TLS
+  -ss,   --self-signed    Use a self-signed certificate
+  -sk,   --server-key     Path to server key, comma separated for several certificates
+  -sc,   --server-cert    Path to server certificate, comma separated for several certificates
+                          (chosen by SNI, the first one is the default)
+  -cc,   --cert-cache     Reuse the self-signed certificate from this directory
+  -ccn,  --cert-cn        Common name of the self-signed certificate
+  -csan, --cert-san       Comma separated DNS names and ips of the self-signed certificate
+  -ka,   --key-alg        Key algorithm of the self-signed certificate rsa, ecdsa or ed25519 (default: rsa)
+  -cac,  --ca-cert        Sign the self-signed certificate with this CA certificate
+  -cak,  --ca-key         Key of the CA certificate
+  -p12,  --pkcs12         Path to PKCS#12 bundle (.p12/.pfx) instead of key and cert
+  -p12p, --pkcs12-pass    Passphrase of the PKCS#12 bundle
+  -h3,   --http3          Serve HTTP/3 (QUIC) on the same udp port, advertised via Alt-Svc
+  -rh,   --redirect-http  Redirect plain HTTP on this port to HTTPS
+  -tm,   --tls-min        Minimum TLS version 1.0 - 1.3           (default: 1.2)
+  -tc,   --tls-ciphers    Comma separated cipher suites (IANA names, not for TLS 1.3)
+  -hs,   --hsts           Send Strict-Transport-Security header
+
+Security header options:
+  -sh,  --security-headers  Send X-Content-Type-Options and X-Frame-Options headers
+  -csp, --csp               Send this Content-Security-Policy header
 
 Authentication options:
-  -b, --basic-auth    Use basic authentication (user:pass)
+  -b,   --basic-auth      Use basic authentication (user:pass or user to generate a password)
+  -lu,  --ldap-url        Validate basic auth against LDAP/AD (ldap(s)://host:port)
+  -lb,  --ldap-base-dn    Base DN to search users in
+  -lbd, --ldap-bind-dn    DN to bind with for the user search  (default: anonymous)
+  -lbp, --ldap-bind-pass  Password for the bind DN
+  -lf,  --ldap-filter     User search filter, {user} is replaced
+                          (default: uid, sAMAccountName or userPrincipalName)
+  -li,  --ldap-insecure   Skip verification of the LDAP server certificate
+  -ma,  --max-auth        Failed logins before an ip gets banned  (default: 5, 0 to disable)
+  -bt,  --ban-time        Ban time, doubled on every repeated ban (default: 1m)
+  -ae,  --auth-exempt     Comma separated networks (CIDR) which skip authentication
+  -ar,  --anonymous-read  Anonymous users may read, authenticated users may write
+  -cl,  --capture-log     Log all submitted credentials (even failed) to this file
+
+OpenID Connect options:
+  -oi,  --oidc-issuer         Login via OpenID Connect using this issuer url
+  -oci, --oidc-client-id      OIDC client id
+  -ocs, --oidc-client-secret  OIDC client secret
+  -oru, --oidc-redirect-url   OIDC redirect url          (default: <scheme>://<host>/oidc/callback)
+  -ogc, --oidc-groups-claim   Claim holding the groups   (default: groups)
+  -org, --oidc-read-groups    Comma separated groups allowed to read  (default: all users)
+  -owg, --oidc-write-groups   Comma separated groups allowed to write (default: all users allowed to read)
+
+Audit options:
+  -al, --audit-log       Hash chained and signed access log file
+  -ai, --audit-interval  Interval to sign the audit log           (default: 1m)
+  -av, --audit-verify    Verify an audit log file and exit
 
 Misc options:
-  -v  Print the current goshs version
+  -status           Monitor the listeners and serve a status page with uptime and
+                     latencies to users who may write  (default: false)
+  -sf, --state-file  Persist uptime, restart and latency history to this file,
+                     implies -status
+  -cf, --clipboard-file
+                     Persist the clipboard to this file to survive restarts
+  -log, --log-file   Write the log to this file as well
+  -ll, --log-level   Least level logged: debug, info, warn or error (default: info)
+  -verbose           Log debug messages, same as -ll debug
+  -quiet             Log warnings and errors only, same as -ll warn
+  -acl, --access-log Write an Apache style access log to this file,
+                     or send it to a syslog collector at udp://host:514 or tcp://host:514
+  -acf, --access-log-format
+                     common, combined, cef or leef     (default: combined)
+  -lms, --log-max-size
+                     Rotate the log, access log, audit log and dump file once it
+                     reaches this many MB                    (default: 0, never)
+  -lma, --log-max-age
+                     Rotate these files once they are this old, e.g. 24h (default: 0, never)
+  -lk, --log-keep    Number of rotated files to keep per log (default: 0, all)
+  -wh, --webhook     Post events as JSON to this url
+  -whe, --webhook-events
+                     Comma separated events: download (first one of a path), upload,
+                     notfound, auth (failed login), error, canary or all
+                     (default: download,upload,auth,canary)
+  -wht, --webhook-template
+                     Text or file with a Go template for the body, e.g. {"text": {{json .Path}}}
+  -dump              Write every request with headers and body to this file,
+                     or to a file per request if it is a directory
+  -dump-max          Bodies are cut off after this many bytes in the dump (default: 1048576)
+  -geoip             Comma separated MaxMind databases (.mmdb) to add country and ASN
+                     of the client to logged requests
+  -rdns              Add the hostname of the client via reverse DNS to logged requests
+  -canary           Comma separated paths which raise an alert when requested,
+                     wildcards like /.git/* allowed
+  -cd, --canary-desktop
+                     Show canary alerts as desktop notification as well (default: false)
+  -dn, --desktop-notify
+                     Show desktop notifications for the first download of every file
+                     and for uploads                         (default: false)
+  -report            Write the summary printed on exit to this file as well,
+                     as JSON if it ends in .json, else as Markdown
+  -mdns              Announce the share on the local network via mDNS
+  -mn, --mdns-name   Instance name to announce   (default: goshs on <hostname>)
+  -upnp              Forward the ports on the gateway via UPnP or NAT-PMP
+  -qr                Print a QR code of the url to open the share on a phone
+  -config           YAML, TOML or JSON file with options by their flag names,
+                     e.g. port: 8443, GOSHS_* variables and flags win over the file.
+                     Send SIGHUP to reload credentials, auth exemptions,
+                     read-only mode and banner
+  -profile          Use the config file of this name in %s
+  -pc, --print-config
+                     Print the options in effect after applying the config file
+                     and the GOSHS_* environment variables
+                     as yaml, toml or json and exit, secrets are redacted
+  -v                 Print the current goshs version
 
 Usage examples:
   Start with default values:    ./goshs
   Start with wevdav support:    ./goshs -w
+  Start with webdav mounted:    ./goshs -wm
   Start with different port:    ./goshs -p 8080
+  Start with speedtest:         ./goshs -st
+  Start with permissions shown: ./goshs -pm
+  Start without branding:       ./goshs -stealth -s -ss
+  Start with a notice:          ./goshs -banner ./notice.txt
+  Start with JSON API:          ./goshs -api
+  Start with cleartext HTTP/2:  ./goshs -h2c
+  Start behind load balancer:   ./goshs -pp -ppt 10.0.0.0/8
+  Start with secret url:        ./goshs -rp
+  Start with custom templates:  ./goshs -tpl ./branding
+  Start with SFTP support:      ./goshs -sftp -b user:pass
+  Start as relay on a vps:      ./goshs -rl 9000 -p 8000 -ts <secret>
+  Start through the relay:      ./goshs -tu vps.example.com:9000 -ts <secret> -s -ss
+  Start published on a vps:     ./goshs -sshf user@vps.example.com -sshp 8080
   Start with self-signed cert:  ./goshs -s -ss
+  Start with persistent cert:   ./goshs -s -ss -cc ~/.goshs
+  Start with custom cert names: ./goshs -s -ss -ccn files.corp.local -csan 10.0.0.5,files
+  Start with ECDSA cert:        ./goshs -s -ss -ka ecdsa
+  Start with cert of own CA:    ./goshs -s -ss -cac ca.crt -cak ca.key -ccn files.corp.local
   Start with custom cert:       ./goshs -s -sk <path to key> -sc <path to cert>
+  Start with SNI certificates:  ./goshs -s -sk a.key,b.key -sc a.crt,b.crt
+  Start with HTTP/3:            ./goshs -s -ss -h3
+  Start with HTTP redirect:     ./goshs -s -ss -p 443 -rh 80
+  Start with security headers:  ./goshs -s -ss -hs -sh -csp "frame-ancestors 'none'"
+  Start with PKCS#12 bundle:    ./goshs -s -p12 server.pfx -p12p <passphrase>
+  Start for legacy tls clients: ./goshs -s -ss -tm 1.0 -tc TLS_RSA_WITH_AES_128_CBC_SHA
+  Start with several dirs:      ./goshs -d /srv/share -d /tools:/opt/tools:ro -d /loot:/tmp/loot:uo
   Start with basic auth:        ./goshs -b secret-user:$up3r$3cur3
+  Start with random password:   ./goshs -b secret-user
+  Start as credential honeypot: ./goshs -b admin -cl creds.log
+  Start with OIDC login:        ./goshs -oi https://sso.corp.local/realms/corp -oci goshs -ocs <secret>
+  Start with auth exemptions:   ./goshs -b user:pass -ae 127.0.0.1,10.10.0.0/16
+  Start with LDAP auth:         ./goshs -lu ldaps://dc.corp.local -lb dc=corp,dc=local
+  Start with cloudflare tunnel: ./goshs -tu cloudflared
+  Start with port forwarding:   ./goshs -upnp
+  Start with mDNS announcement: ./goshs -mdns -mn "Team share"
+  Start with QR code for phone: ./goshs -qr
+  Start with signed audit log:  ./goshs -al audit.log
+  Verify an audit log:          ./goshs -av audit.log
+  Show the effective options:   ./goshs -config team.yaml -p 9000 -pc yaml
+  Start with a saved profile:   ./goshs -profile exfil
+  Install as system service:    sudo ./goshs -d /srv/share -ro service install
+  Start for a single download:  ./goshs -max-serves 1 -timeout 2h
+  Host a single payload once:   ./goshs -f ./implant.exe -max-serves 1
+  Share piped content:          cat dump.sql | ./goshs -stdin dump.sql
+  Receive into a pipe:          ./goshs -receive | tar xz
+  Start a vanishing drop box:   ./goshs -temp -shred -uo
+  Start with terminal UI:       ./goshs -tui
+  Enable bash completion:       source <(./goshs completion bash)
 
-`, goshsVersion, os.Args[0])
+`, goshsVersion, os.Args[0], os.Args[0], os.Args[0], profiles)
 	}
 }
 
@@ -82,8 +410,16 @@ func init() {
 	flag.StringVar(&ip, "ip", ip, "ip")
 	flag.IntVar(&port, "p", port, "port")
 	flag.IntVar(&port, "port", port, "port")
-	flag.StringVar(&webroot, "d", wd, "web root")
-	flag.StringVar(&webroot, "dir", wd, "web root")
+	webroot = wd
+	dirs := &dirValue{webroot: &webroot, mounts: &mounts}
+	flag.Var(dirs, "d", "web root")
+	flag.Var(dirs, "dir", "web root")
+	flag.StringVar(&single, "f", single, "single file")
+	flag.StringVar(&single, "file", single, "single file")
+	flag.StringVar(&stdinName, "stdin", stdinName, "stdin file name")
+	flag.BoolVar(&receive, "receive", receive, "receive to stdout")
+	flag.BoolVar(&tempRoot, "temp", tempRoot, "temporary web root")
+	flag.BoolVar(&shred, "shred", shred, "shred temporary web root")
 	flag.BoolVar(&ssl, "s", ssl, "tls")
 	flag.BoolVar(&ssl, "ssl", ssl, "tls")
 	flag.BoolVar(&selfsigned, "ss", selfsigned, "self-signed")
@@ -92,31 +428,259 @@ func init() {
 	flag.StringVar(&myKey, "server-key", myKey, "server key")
 	flag.StringVar(&myCert, "sc", myCert, "server cert")
 	flag.StringVar(&myCert, "server-cert", myCert, "server cert")
+	flag.StringVar(&certCache, "cc", certCache, "cert cache")
+	flag.StringVar(&certCache, "cert-cache", certCache, "cert cache")
+	flag.StringVar(&certCN, "ccn", certCN, "cert common name")
+	flag.StringVar(&certCN, "cert-cn", certCN, "cert common name")
+	flag.StringVar(&certSAN, "csan", certSAN, "cert sans")
+	flag.StringVar(&certSAN, "cert-san", certSAN, "cert sans")
+	flag.StringVar(&keyAlg, "ka", keyAlg, "key algorithm")
+	flag.StringVar(&keyAlg, "key-alg", keyAlg, "key algorithm")
+	flag.StringVar(&caCert, "cac", caCert, "ca cert")
+	flag.StringVar(&caCert, "ca-cert", caCert, "ca cert")
+	flag.StringVar(&caKey, "cak", caKey, "ca key")
+	flag.StringVar(&caKey, "ca-key", caKey, "ca key")
+	flag.StringVar(&p12, "p12", p12, "pkcs12 bundle")
+	flag.StringVar(&p12, "pkcs12", p12, "pkcs12 bundle")
+	flag.StringVar(&p12Pass, "p12p", p12Pass, "pkcs12 passphrase")
+	flag.StringVar(&p12Pass, "pkcs12-pass", p12Pass, "pkcs12 passphrase")
+	flag.BoolVar(&http3, "h3", http3, "http3")
+	flag.BoolVar(&http3, "http3", http3, "http3")
+	flag.BoolVar(&upnp, "upnp", upnp, "upnp")
+	flag.BoolVar(&qr, "qr", qr, "qr code")
+	flag.StringVar(&tunnel, "tu", tunnel, "tunnel")
+	flag.StringVar(&tunnel, "tunnel", tunnel, "tunnel")
+	flag.StringVar(&tunnelKey, "ts", tunnelKey, "tunnel secret")
+	flag.StringVar(&tunnelKey, "tunnel-secret", tunnelKey, "tunnel secret")
+	flag.StringVar(&sshFwd, "sshf", sshFwd, "ssh forward")
+	flag.StringVar(&sshFwd, "ssh-forward", sshFwd, "ssh forward")
+	flag.IntVar(&sshFwdPort, "sshp", sshFwdPort, "ssh remote port")
+	flag.IntVar(&sshFwdPort, "ssh-remote-port", sshFwdPort, "ssh remote port")
+	flag.StringVar(&sshFwdKey, "sshi", sshFwdKey, "ssh identity")
+	flag.StringVar(&sshFwdKey, "ssh-identity", sshFwdKey, "ssh identity")
+	flag.IntVar(&relayPort, "rl", relayPort, "relay")
+	flag.IntVar(&relayPort, "relay", relayPort, "relay")
+	flag.BoolVar(&mdns, "mdns", mdns, "mdns")
+	flag.StringVar(&mdnsName, "mn", mdnsName, "mdns name")
+	flag.StringVar(&mdnsName, "mdns-name", mdnsName, "mdns name")
+	flag.BoolVar(&sftpServe, "sftp", sftpServe, "sftp")
+	flag.IntVar(&sftpPort, "sfp", sftpPort, "sftp port")
+	flag.IntVar(&sftpPort, "sftp-port", sftpPort, "sftp port")
+	flag.StringVar(&sftpKey, "sfk", sftpKey, "sftp host key")
+	flag.StringVar(&sftpKey, "sftp-host-key", sftpKey, "sftp host key")
+	flag.IntVar(&redirHTTP, "rh", redirHTTP, "redirect http")
+	flag.IntVar(&redirHTTP, "redirect-http", redirHTTP, "redirect http")
+	flag.BoolVar(&hsts, "hs", hsts, "hsts")
+	flag.BoolVar(&hsts, "hsts", hsts, "hsts")
+	flag.BoolVar(&secHeaders, "sh", secHeaders, "security headers")
+	flag.BoolVar(&secHeaders, "security-headers", secHeaders, "security headers")
+	flag.StringVar(&csp, "csp", csp, "content security policy")
+	flag.StringVar(&tlsMin, "tm", tlsMin, "tls min version")
+	flag.StringVar(&tlsMin, "tls-min", tlsMin, "tls min version")
+	flag.StringVar(&tlsCiphers, "tc", tlsCiphers, "tls cipher suites")
+	flag.StringVar(&tlsCiphers, "tls-ciphers", tlsCiphers, "tls cipher suites")
 	flag.StringVar(&basicAuth, "b", basicAuth, "basic auth")
 	flag.StringVar(&basicAuth, "basic-auth", basicAuth, "basic auth")
 	flag.BoolVar(&webdav, "w", webdav, "enable webdav")
 	flag.BoolVar(&webdav, "webdav", webdav, "enable webdav")
+	flag.BoolVar(&api, "api", api, "json api")
+	flag.BoolVar(&proxyProto, "pp", proxyProto, "proxy protocol")
+	flag.BoolVar(&proxyProto, "proxy-protocol", proxyProto, "proxy protocol")
+	flag.StringVar(&proxyTrust, "ppt", proxyTrust, "proxy protocol trusted")
+	flag.StringVar(&proxyTrust, "proxy-trusted", proxyTrust, "proxy protocol trusted")
+	flag.StringVar(&fwdTrust, "tp", fwdTrust, "trusted proxy")
+	flag.StringVar(&fwdTrust, "trusted-proxy", fwdTrust, "trusted proxy")
+	flag.BoolVar(&webdavMnt, "wm", webdavMnt, "webdav mount")
+	flag.BoolVar(&webdavMnt, "webdav-mount", webdavMnt, "webdav mount")
 	flag.IntVar(&webdavPort, "wp", webdavPort, "webdav port")
 	flag.IntVar(&webdavPort, "webdav-port", webdavPort, "webdav port")
 	flag.BoolVar(&uploadOnly, "uo", uploadOnly, "upload only")
 	flag.BoolVar(&uploadOnly, "upload-only", uploadOnly, "upload only")
 	flag.BoolVar(&readOnly, "ro", readOnly, "read only")
 	flag.BoolVar(&readOnly, "read-only", readOnly, "read only")
+	flag.BoolVar(&h2c, "h2c", h2c, "h2c")
+	flag.BoolVar(&randPrefix, "rp", randPrefix, "random prefix")
+	flag.BoolVar(&randPrefix, "random-prefix", randPrefix, "random prefix")
+	flag.StringVar(&templates, "tpl", templates, "templates")
+	flag.StringVar(&templates, "templates", templates, "templates")
+	flag.BoolVar(&activity, "activity", activity, "activity")
+	flag.BoolVar(&speedtest, "st", speedtest, "speedtest")
+	flag.BoolVar(&speedtest, "speedtest", speedtest, "speedtest")
+	flag.BoolVar(&showPerms, "pm", showPerms, "permissions")
+	flag.BoolVar(&showPerms, "permissions", showPerms, "permissions")
+	flag.BoolVar(&stealth, "stealth", stealth, "stealth")
+	flag.StringVar(&banner, "banner", banner, "banner")
+	flag.StringVar(&favicon, "favicon", favicon, "favicon")
+	flag.StringVar(&auditLog, "al", auditLog, "audit log")
+	flag.StringVar(&auditLog, "audit-log", auditLog, "audit log")
+	flag.DurationVar(&auditInt, "ai", auditInt, "audit interval")
+	flag.DurationVar(&auditInt, "audit-interval", auditInt, "audit interval")
+	flag.StringVar(&auditCheck, "av", auditCheck, "audit verify")
+	flag.StringVar(&auditCheck, "audit-verify", auditCheck, "audit verify")
+	flag.StringVar(&ldapURL, "lu", ldapURL, "ldap url")
+	flag.StringVar(&ldapURL, "ldap-url", ldapURL, "ldap url")
+	flag.StringVar(&ldapBaseDN, "lb", ldapBaseDN, "ldap base dn")
+	flag.StringVar(&ldapBaseDN, "ldap-base-dn", ldapBaseDN, "ldap base dn")
+	flag.StringVar(&ldapBindDN, "lbd", ldapBindDN, "ldap bind dn")
+	flag.StringVar(&ldapBindDN, "ldap-bind-dn", ldapBindDN, "ldap bind dn")
+	flag.StringVar(&ldapBindPW, "lbp", ldapBindPW, "ldap bind pass")
+	flag.StringVar(&ldapBindPW, "ldap-bind-pass", ldapBindPW, "ldap bind pass")
+	flag.StringVar(&ldapFilter, "lf", ldapFilter, "ldap filter")
+	flag.StringVar(&ldapFilter, "ldap-filter", ldapFilter, "ldap filter")
+	flag.BoolVar(&ldapNoTLS, "li", ldapNoTLS, "ldap insecure")
+	flag.BoolVar(&ldapNoTLS, "ldap-insecure", ldapNoTLS, "ldap insecure")
+	flag.IntVar(&maxAuth, "ma", maxAuth, "max auth attempts")
+	flag.IntVar(&maxAuth, "max-auth", maxAuth, "max auth attempts")
+	flag.DurationVar(&banTime, "bt", banTime, "ban time")
+	flag.DurationVar(&banTime, "ban-time", banTime, "ban time")
+	flag.StringVar(&authExempt, "ae", authExempt, "auth exempt")
+	flag.StringVar(&authExempt, "auth-exempt", authExempt, "auth exempt")
+	flag.BoolVar(&anonRead, "ar", anonRead, "anonymous read")
+	flag.BoolVar(&anonRead, "anonymous-read", anonRead, "anonymous read")
+	flag.StringVar(&captureLog, "cl", captureLog, "capture log")
+	flag.StringVar(&captureLog, "capture-log", captureLog, "capture log")
+	flag.StringVar(&oidcIssuer, "oi", oidcIssuer, "oidc issuer")
+	flag.StringVar(&oidcIssuer, "oidc-issuer", oidcIssuer, "oidc issuer")
+	flag.StringVar(&oidcID, "oci", oidcID, "oidc client id")
+	flag.StringVar(&oidcID, "oidc-client-id", oidcID, "oidc client id")
+	flag.StringVar(&oidcSecret, "ocs", oidcSecret, "oidc client secret")
+	flag.StringVar(&oidcSecret, "oidc-client-secret", oidcSecret, "oidc client secret")
+	flag.StringVar(&oidcRedir, "oru", oidcRedir, "oidc redirect url")
+	flag.StringVar(&oidcRedir, "oidc-redirect-url", oidcRedir, "oidc redirect url")
+	flag.StringVar(&oidcClaim, "ogc", oidcClaim, "oidc groups claim")
+	flag.StringVar(&oidcClaim, "oidc-groups-claim", oidcClaim, "oidc groups claim")
+	flag.StringVar(&oidcReadG, "org", oidcReadG, "oidc read groups")
+	flag.StringVar(&oidcReadG, "oidc-read-groups", oidcReadG, "oidc read groups")
+	flag.StringVar(&oidcWriteG, "owg", oidcWriteG, "oidc write groups")
+	flag.StringVar(&oidcWriteG, "oidc-write-groups", oidcWriteG, "oidc write groups")
+	flag.StringVar(&stateFile, "sf", stateFile, "state file")
+	flag.StringVar(&stateFile, "state-file", stateFile, "state file")
+	flag.BoolVar(&status, "status", status, "status page")
+	flag.StringVar(&cbFile, "cf", cbFile, "clipboard file")
+	flag.StringVar(&cbFile, "clipboard-file", cbFile, "clipboard file")
+	flag.StringVar(&logFile, "log", logFile, "log file")
+	flag.StringVar(&logFile, "log-file", logFile, "log file")
+	flag.IntVar(&rotSize, "lms", rotSize, "log max size")
+	flag.IntVar(&rotSize, "log-max-size", rotSize, "log max size")
+	flag.DurationVar(&rotAge, "lma", rotAge, "log max age")
+	flag.DurationVar(&rotAge, "log-max-age", rotAge, "log max age")
+	flag.IntVar(&rotKeep, "lk", rotKeep, "log keep")
+	flag.IntVar(&rotKeep, "log-keep", rotKeep, "log keep")
+	flag.BoolVar(&verbose, "verbose", verbose, "verbose")
+	flag.BoolVar(&quiet, "quiet", quiet, "quiet")
+	flag.StringVar(&logLevel, "ll", logLevel, "log level")
+	flag.StringVar(&logLevel, "log-level", logLevel, "log level")
+	flag.StringVar(&webhook, "wh", webhook, "webhook")
+	flag.StringVar(&webhook, "webhook", webhook, "webhook")
+	flag.StringVar(&hookEvents, "whe", hookEvents, "webhook events")
+	flag.StringVar(&hookEvents, "webhook-events", hookEvents, "webhook events")
+	flag.StringVar(&hookTmpl, "wht", hookTmpl, "webhook template")
+	flag.StringVar(&hookTmpl, "webhook-template", hookTmpl, "webhook template")
+	flag.StringVar(&dump, "dump", dump, "dump requests")
+	flag.Int64Var(&dumpMax, "dump-max", dumpMax, "dump body size")
+	flag.StringVar(&geoIP, "geoip", geoIP, "geoip databases")
+	flag.BoolVar(&rdns, "rdns", rdns, "reverse dns")
+	flag.StringVar(&reportFile, "report", reportFile, "report")
+	flag.DurationVar(&lifetime, "timeout", lifetime, "timeout")
+	flag.IntVar(&maxServes, "max-serves", maxServes, "max serves")
+	flag.BoolVar(&tui, "tui", tui, "tui")
+	flag.StringVar(&canary, "canary", canary, "canary paths")
+	flag.BoolVar(&canaryDesk, "cd", canaryDesk, "canary desktop")
+	flag.BoolVar(&canaryDesk, "canary-desktop", canaryDesk, "canary desktop")
+	flag.BoolVar(&desktop, "dn", desktop, "desktop notify")
+	flag.BoolVar(&desktop, "desktop-notify", desktop, "desktop notify")
+	flag.StringVar(&accessLog, "acl", accessLog, "access log")
+	flag.StringVar(&accessLog, "access-log", accessLog, "access log")
+	flag.StringVar(&accessFmt, "acf", accessFmt, "access log format")
+	flag.StringVar(&accessFmt, "access-log-format", accessFmt, "access log format")
+	flag.StringVar(&config, "config", config, "config file")
+	flag.StringVar(&profile, "profile", profile, "profile")
+	flag.StringVar(&printCfg, "pc", printCfg, "print config")
+	flag.StringVar(&printCfg, "print-config", printCfg, "print config")
 	version := flag.Bool("v", false, "goshs version")
 
 	flag.Usage = usage()
 
 	flag.Parse()
 
+	if flag.Arg(0) == "completion" {
+		printCompletion(flag.Arg(1))
+	}
+
+	// Options from the environment, e.g. GOSHS_PORT, the command line wins
+	if err := myconfig.Env(flag.CommandLine, "GOSHS_"); err != nil {
+		mylog.Fatalf("Unable to apply the environment: %+v", err)
+	}
+
+	// A profile is a config file kept by its name
+	if profile != "" {
+		if config != "" {
+			mylog.Fatal("You can only use either a config file or a profile, not both.")
+		}
+		file, err := myconfig.Profile(profile)
+		if err != nil {
+			mylog.Fatalf("Unable to load the profile: %+v", err)
+		}
+		config = file
+	}
+
+	// Options from the config file, the environment and the command line win
+	if config != "" {
+		if err := myconfig.Apply(config, flag.CommandLine); err != nil {
+			mylog.Fatalf("Unable to load the config file: %+v", err)
+		}
+	}
+
 	if *version {
 		fmt.Printf("goshs version is: %+v\n", goshsVersion)
 		os.Exit(0)
 	}
 
+	// Verbosity
+	if (verbose && quiet) || (logLevel != "" && (verbose || quiet)) {
+		mylog.Fatal("You can only select one of -verbose, -quiet or -log-level.")
+	}
+	if verbose {
+		logLevel = "debug"
+	}
+	if quiet {
+		logLevel = "warn"
+	}
+	if logLevel != "" {
+		if err := mylog.SetLevel(logLevel); err != nil {
+			mylog.Fatalf("%+v", err)
+		}
+	}
+
+	if rotSize < 0 || rotAge < 0 || rotKeep < 0 {
+		mylog.Fatal("The log rotation size, age and number of files kept cannot be negative.")
+	}
+
+	// Keep a record of everything logged from here on
+	if logFile != "" {
+		if err := mylog.LogFile(logFile, rotation()); err != nil {
+			mylog.Fatalf("Unable to open the log file %s: %+v", logFile, err)
+		}
+	}
+
+	// Verify audit log and exit
+	if auditCheck != "" {
+		keys, err := myaudit.Verify(auditCheck)
+		if err != nil {
+			mylog.Fatalf("Audit log verification failed: %+v", err)
+		}
+		for _, k := range keys {
+			mylog.Infof("Audit log chain signed with public key: %s", k)
+		}
+		mylog.Info("Audit log verified successfully. Compare the public keys to the ones printed at startup.")
+		os.Exit(0)
+	}
+
 	// Check if interface name was provided as -i
-	// If so, resolve to ip address of interface
-	if !strings.Contains(ip, ".") {
-		addr, err := myutils.GetInterfaceIpv4Addr(ip)
+	// If so, resolve to ip address of interface (ipv4 preferred)
+	ip = strings.Trim(ip, "[]")
+	if net.ParseIP(ip) == nil {
+		addr, err := myutils.GetInterfaceAddr(ip)
 		if err != nil {
 			mylog.Fatal(err)
 			os.Exit(-1)
@@ -136,7 +700,110 @@ func init() {
 		os.Exit(-1)
 	}
 
-	if webdav {
+	if auditLog != "" && auditInt <= 0 {
+		mylog.Fatal("The audit interval needs to be positive.")
+	}
+
+	if lifetime < 0 || maxServes < 0 {
+		mylog.Fatal("The timeout and the maximum number of serves cannot be negative.")
+	}
+	if maxServes > 0 && uploadOnly {
+		mylog.Warn("The maximum number of serves is of no use in upload only mode")
+	}
+	if maxServes > 0 && (webdav || webdavMnt || sftpServe) {
+		mylog.Fatal("The maximum number of serves cannot be enforced for webdav and sftp, do not combine them.")
+	}
+	if single != "" && stdinName != "" {
+		mylog.Fatal("You can only serve either a file or stdin, not both.")
+	}
+	if (single != "" || stdinName != "") && (uploadOnly || webdav || webdavMnt || sftpServe || len(mounts) > 0) {
+		mylog.Fatal("Serving a single file cannot be combined with upload only, webdav, sftp or mounted directories.")
+	}
+	if receive && (single != "" || stdinName != "" || readOnly || webdav || webdavMnt || sftpServe || len(mounts) > 0 || tui) {
+		mylog.Fatal("Receiving to stdout cannot be combined with serving a file, read only, webdav, sftp, mounted directories or the terminal UI.")
+	}
+	if tempRoot && (webroot != wd || single != "" || stdinName != "" || receive) {
+		mylog.Fatal("The temporary directory cannot be combined with a web root, serving a file or receiving to stdout.")
+	}
+	if shred && !tempRoot {
+		mylog.Fatal("Only the temporary directory of -temp can be shredded.")
+	}
+
+	// Sanity check for PKCS#12 bundle
+	if p12 != "" && (selfsigned || myKey != "" || myCert != "") {
+		mylog.Fatal("You can only use either a PKCS#12 bundle, key and cert or a self-signed certificate.")
+		os.Exit(-1)
+	}
+
+	if err := myca.CheckKeyAlgorithm(keyAlg); err != nil {
+		mylog.Fatal(err)
+		os.Exit(-1)
+	}
+
+	// Every client could claim any address with the PROXY header otherwise
+	if proxyProto && proxyTrust == "" {
+		mylog.Fatal("You need to provide the networks of your load balancers with -ppt to use -pp.")
+		os.Exit(-1)
+	}
+
+	// Sanity check for operator CA
+	if (caCert == "") != (caKey == "") {
+		mylog.Fatal("You need to provide both the CA certificate with -cac and its key with -cak.")
+		os.Exit(-1)
+	}
+
+	if (certCache != "" || certCN != "" || certSAN != "" || keyAlg != "" || caCert != "") && !selfsigned {
+		mylog.Warn("The certificate cache, names and CA are only used for generated certificates. Use -ss as well.")
+	}
+
+	if h2c && ssl {
+		mylog.Info("HTTP/2 is negotiated via TLS anyway, -h2c is only used without -s")
+	}
+
+	if (tlsMin != "" || tlsCiphers != "" || redirHTTP != 0 || hsts || http3) && !ssl {
+		mylog.Warn("TLS options are ignored as SSL is not enabled. Use -s as well.")
+	}
+
+	// Sanity check for ldap
+	if ldapURL != "" {
+		if ldapBaseDN == "" {
+			mylog.Fatal("You need to provide a base dn with -lb when using LDAP authentication.")
+			os.Exit(-1)
+		}
+		if basicAuth != "" {
+			mylog.Warn("basic auth credentials are ignored due to use of LDAP authentication")
+			basicAuth = ""
+		}
+	}
+
+	// Sanity check for oidc
+	if oidcIssuer != "" {
+		if oidcID == "" {
+			mylog.Fatal("You need to provide a client id with -oci when using OpenID Connect.")
+			os.Exit(-1)
+		}
+		if webdav || webdavMnt {
+			mylog.Fatal("WebDAV clients cannot log in via OpenID Connect. Use basic auth or LDAP instead.")
+			os.Exit(-1)
+		}
+		if sftpServe {
+			mylog.Fatal("SFTP clients cannot log in via OpenID Connect. Use basic auth or LDAP instead.")
+			os.Exit(-1)
+		}
+		if basicAuth != "" || ldapURL != "" {
+			mylog.Warn("basic auth and LDAP are ignored due to use of OpenID Connect")
+			basicAuth = ""
+			ldapURL = ""
+		}
+	}
+
+	// Sanity check for anonymous read
+	if anonRead && basicAuth == "" && ldapURL == "" && oidcIssuer == "" {
+		mylog.Fatal("Anonymous read access only makes sense with authentication. Use -b, -lu or -oi as well.")
+		os.Exit(-1)
+	}
+
+	if webdav || webdavMnt {
 		mylog.Warn("upload/read-only mode deactivated due to use of 'webdav' mode")
 		uploadOnly = false
 		readOnly = false
@@ -152,21 +819,409 @@ func init() {
 		}
 	}
 	mylog.Debugf("Final webroot is: %s", webroot)
+	if single != "" {
+		if !filepath.IsAbs(single) {
+			single = filepath.Join(wd, single)
+		}
+		if fi, err := os.Stat(single); err != nil || !fi.Mode().IsRegular() {
+			mylog.Fatalf("%s is not a file", single)
+		}
+		// Nothing but the file is served from its directory
+		webroot = filepath.Dir(single)
+	}
+
+	if printCfg != "" {
+		printConfig()
+	}
+
+	if flag.Arg(0) == "service" {
+		manageService(flag.Arg(1), wd)
+	}
+}
+
+// manageService will install or uninstall goshs as a system service and exit, the
+// service runs with the options given
+func manageService(action, wd string) {
+	var err error
+	switch action {
+	case "install":
+		var exe string
+		exe, err = os.Executable()
+		if err != nil {
+			mylog.Fatalf("Unable to find the goshs executable: %+v", err)
+		}
+		// The web root is made absolute as Windows services start in the system directory
+		args := append([]string{}, os.Args[1:len(os.Args)-flag.NArg()]...)
+		args = append(args, "-d", webroot)
+		for _, m := range mounts {
+			args = append(args, "-d", m.String())
+		}
+		if single != "" {
+			args = append(args, "-f", single)
+		}
+		if err = myservice.Install(exe, args, wd); err == nil {
+			mylog.Infof("Installed and started the service %s: %s %s", myservice.Name, exe, strings.Join(redactArgs(args), " "))
+		}
+	case "uninstall":
+		if err = myservice.Uninstall(); err == nil {
+			mylog.Infof("Stopped and removed the service %s", myservice.Name)
+		}
+	default:
+		mylog.Fatalf("Unknown service action %q, use install or uninstall", action)
+	}
+	if err != nil {
+		mylog.Fatalf("Unable to %s the service: %+v", action, err)
+	}
+	os.Exit(0)
+}
+
+// secrets are the long names of the options which are redacted when printed
+var secrets = []string{"basic-auth", "ldap-bind-pass", "oidc-client-secret", "pkcs12-pass", "tunnel-secret"}
+
+// isSecret tells whether the option of the long name is one of the secrets
+func isSecret(name string) bool {
+	for _, secret := range secrets {
+		if secret == name {
+			return true
+		}
+	}
+	return false
+}
+
+// redactArgs returns args with the values of the secrets redacted, given as -flag value or -flag=value
+func redactArgs(args []string) []string {
+	redacted := make([]string, len(args))
+	copy(redacted, args)
+	for i, arg := range args {
+		name := strings.TrimLeft(arg, "-")
+		if name == arg {
+			continue
+		}
+		value := strings.Index(name, "=")
+		if value >= 0 {
+			name = name[:value]
+		}
+		if !isSecret(myconfig.LongName(flag.CommandLine, name)) {
+			continue
+		}
+		if value >= 0 {
+			redacted[i] = arg[:strings.Index(arg, "=")+1] + "<redacted>"
+		} else if i+1 < len(args) {
+			redacted[i+1] = "<redacted>"
+		}
+	}
+	return redacted
+}
+
+// printConfig will print the options in effect after the sanity checks and exit
+func printConfig() {
+	values := myconfig.Effective(flag.CommandLine, "config", "profile", "print-config", "v")
+	for _, secret := range secrets {
+		if values[secret] != "" {
+			values[secret] = "<redacted>"
+		}
+	}
+	out, err := myconfig.Marshal(values, printCfg)
+	if err != nil {
+		mylog.Fatalf("Unable to print the config: %+v", err)
+	}
+	fmt.Print(string(out))
+	os.Exit(0)
+}
+
+// printCompletion will print the completion script of shell and exit
+func printCompletion(shell string) {
+	commands := []mycompletion.Command{
+		{Name: "completion", Description: "Print a shell completion script", Args: mycompletion.Shells},
+		{Name: "service", Description: "Install or uninstall goshs as a system service", Args: []string{"install", "uninstall"}},
+	}
+	script, err := mycompletion.Script(shell, "goshs", flag.CommandLine, commands)
+	if err != nil {
+		mylog.Fatalf("Unable to generate the completion script: %+v", err)
+	}
+	fmt.Print(script)
+	os.Exit(0)
 }
 
 // Sanity checks if basic auth has the right format
+// If only the user is provided a strong random password will be generated
 func parseBasicAuth() (string, string) {
 	auth := strings.SplitN(basicAuth, ":", 2)
-	if len(auth) < 2 {
-		fmt.Println("Wrong basic auth format. Please provide user:password separated by a colon")
+	user := auth[0]
+	if user == "" {
+		fmt.Println("Wrong basic auth format. Please provide user:password separated by a colon or only the user to generate a password")
 		os.Exit(-1)
 	}
-	user := auth[0]
-	pass := auth[1]
-	return user, pass
+
+	if len(auth) < 2 || auth[1] == "" {
+		pass, err := myutils.RandomString(24)
+		if err != nil {
+			mylog.Fatalf("Unable to generate random password: %+v", err)
+		}
+		mylog.Info("No password provided, a random password was generated")
+		return user, pass
+	}
+
+	return user, auth[1]
+}
+
+// readBanner returns the banner, which is either the text itself or a file containing it
+func readBanner() (string, error) {
+	if fi, err := os.Stat(banner); err != nil || !fi.Mode().IsRegular() {
+		return banner, nil
+	}
+	// disable G304 (CWE-22): Potential file inclusion via variable
+	// as the operator chooses the file
+	// #nosec G304
+	content, err := ioutil.ReadFile(banner)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(content), "\r\n"), nil
+}
+
+// reloadConfig will read the config file again and apply the options which do not need
+// a restart to server. On any error the running settings are kept.
+func reloadConfig(server *myhttp.FileServer, user, pass *string) {
+	oldAuth, oldExempt, oldRO, oldBanner := basicAuth, authExempt, readOnly, banner
+	restore := func() {
+		basicAuth, authExempt, readOnly, banner = oldAuth, oldExempt, oldRO, oldBanner
+	}
+
+	skipped, err := myconfig.Reload(config, flag.CommandLine, "b", "ae", "ro", "banner")
+	if err != nil {
+		restore()
+		mylog.Errorf("Unable to reload the config file: %+v", err)
+		return
+	}
+
+	s := myhttp.Reloadable{User: *user, Pass: *pass, ReadOnly: readOnly}
+	if ldapURL != "" || oidcIssuer != "" {
+		basicAuth = oldAuth
+	}
+	if (basicAuth == "") != (oldAuth == "") {
+		restore()
+		mylog.Error("Unable to reload the config file: switching basic auth on or off needs a restart")
+		return
+	}
+	if basicAuth != oldAuth {
+		if strings.SplitN(basicAuth, ":", 2)[0] == "" {
+			restore()
+			mylog.Error("Unable to reload the config file: basic auth needs a user")
+			return
+		}
+		s.User, s.Pass = parseBasicAuth()
+	}
+	if readOnly && uploadOnly {
+		restore()
+		mylog.Error("Unable to reload the config file: you can only select either 'upload only' or 'read only', not both")
+		return
+	}
+	if webdav || webdavMnt {
+		s.ReadOnly = false
+	}
+	if authExempt != "" {
+		s.AuthExempt, err = myutils.ParseNetworks(splitList(authExempt))
+		if err != nil {
+			restore()
+			mylog.Errorf("Unable to reload the config file: parsing auth exempt networks: %+v", err)
+			return
+		}
+	}
+	if banner != "" {
+		s.Banner, err = readBanner()
+		if err != nil {
+			restore()
+			mylog.Errorf("Unable to reload the config file: reading the banner file %s: %+v", banner, err)
+			return
+		}
+	}
+
+	server.Reload(s)
+	*user, *pass = s.User, s.Pass
+	mylog.Infof("Reloaded the config file %s", config)
+	if s.User != "" && basicAuth != oldAuth {
+		mylog.Infof("Using basic auth with user '%s' and password '%s'", s.User, s.Pass)
+	}
+	if len(skipped) > 0 {
+		mylog.Warnf("Changes to %s need a restart to take effect", strings.Join(skipped, ", "))
+	}
+}
+
+// dirValue is -d, a directory is the web root and /path:dir[:ro|:uo] serves dir below /path.
+// It can be repeated or comma separated, the last web root wins. A value without a mount is
+// taken verbatim as web root, so its name may contain a comma.
+type dirValue struct {
+	webroot *string
+	mounts  *mymount.Mounts
+}
+
+func (d *dirValue) String() string {
+	if d.webroot == nil {
+		return ""
+	}
+	values := []string{*d.webroot}
+	for _, m := range *d.mounts {
+		values = append(values, m.String())
+	}
+	return strings.Join(values, ",")
+}
+
+func (d *dirValue) Set(value string) error {
+	items := splitList(value)
+	mounted := false
+	for _, item := range items {
+		mounted = mounted || isMount(item)
+	}
+	if !mounted {
+		*d.webroot = value
+		return nil
+	}
+	for _, item := range items {
+		if !isMount(item) {
+			*d.webroot = item
+			continue
+		}
+		m, err := mymount.Parse(item)
+		if err != nil {
+			return err
+		}
+		if err := d.mounts.Add(m); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// isMount tells whether a -d value is of the form /path:dir
+func isMount(item string) bool {
+	return strings.HasPrefix(item, "/") && strings.Contains(item, ":")
+}
+
+// spoolStdin will read stdin into a file called name in a new temporary directory and return its path
+func spoolStdin(name string) (string, error) {
+	name = filepath.Base(name)
+	if name == "." || name == string(filepath.Separator) {
+		return "", fmt.Errorf("%s is not a file name", name)
+	}
+	if fi, err := os.Stdin.Stat(); err == nil && fi.Mode()&os.ModeCharDevice != 0 {
+		return "", fmt.Errorf("nothing is piped to stdin")
+	}
+	dir, err := ioutil.TempDir("", "goshs-stdin-")
+	if err != nil {
+		return "", err
+	}
+	file := filepath.Join(dir, name)
+	// disable G304 (CWE-22): Potential file inclusion via variable
+	// as the file lies in the directory just created
+	// #nosec G304
+	f, err := os.OpenFile(file, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		os.RemoveAll(dir)
+		return "", err
+	}
+	mylog.Infof("Reading stdin into %s", name)
+	n, err := io.Copy(f, os.Stdin)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.RemoveAll(dir)
+		return "", err
+	}
+	mylog.Infof("Read %s from stdin", myutils.ByteCountDecimal(n))
+	return file, nil
+}
+
+// splitList will split a comma separated flag value
+func splitList(list string) []string {
+	var items []string
+	for _, item := range strings.Split(list, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// rotation returns when the log files are rotated
+func rotation() myrotate.Options {
+	return myrotate.Options{
+		MaxSize: int64(rotSize) << 20,
+		MaxAge:  rotAge,
+		Keep:    rotKeep,
+	}
+}
+
+// urlScheme returns the scheme of our listeners
+func urlScheme(ssl bool) string {
+	if ssl {
+		return "https"
+	}
+	return "http"
+}
+
+// listenerURL returns the base url to reach one of our own listeners
+func listenerURL(ssl bool, port int) string {
+	host := ip
+	switch host {
+	case "0.0.0.0":
+		host = "127.0.0.1"
+	case "::":
+		host = "::1"
+	}
+	return fmt.Sprintf("%s://%s", urlScheme(ssl), net.JoinHostPort(host, strconv.Itoa(port)))
+}
+
+// lanURL returns the url of the share on the first non loopback interface if we listen on all of them
+func lanURL(ssl bool, port int, prefix string) string {
+	host := ip
+	if host == "0.0.0.0" || host == "::" {
+		host = "127.0.0.1"
+		addresses, err := myutils.GetAllIPAdresses(false)
+		if err != nil {
+			mylog.Errorf("There has been an error fetching the interface addresses: %+v", err)
+		}
+		names := make([]string, 0, len(addresses))
+		for name := range addresses {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if addr := net.ParseIP(addresses[name]); addr != nil && !addr.IsLoopback() {
+				host = addresses[name]
+				break
+			}
+		}
+	}
+	return fmt.Sprintf("%s://%s%s/", urlScheme(ssl), net.JoinHostPort(host, strconv.Itoa(port)), prefix)
+}
+
+// runRelay will forward the visitors on -i/-p to the agent connected on -rl
+func runRelay() {
+	if tunnelKey == "" {
+		var err error
+		tunnelKey, err = myutils.RandomString(24)
+		if err != nil {
+			mylog.Fatalf("Unable to generate the tunnel secret: %+v", err)
+		}
+		mylog.Infof("Generated tunnel secret: %s", tunnelKey)
+	}
+
+	relay := mytunnel.NewRelay(tunnelKey)
+	go func() {
+		mylog.Panic(relay.ServeAgents(net.JoinHostPort(ip, strconv.Itoa(relayPort))))
+	}()
+	mylog.Panic(relay.ServePublic(net.JoinHostPort(ip, strconv.Itoa(port))))
 }
 
 func main() {
+	// Relay mode does not serve anything itself
+	if relayPort > 0 {
+		runRelay()
+		return
+	}
+
 	user := ""
 	pass := ""
 	// check for basic auth
@@ -176,34 +1231,586 @@ func main() {
 
 	done := make(chan os.Signal, 1)
 	signal.Notify(done, os.Interrupt, syscall.SIGINT, syscall.SIGTERM)
+	myservice.Notify(done)
+
+	// Audit log
+	var audit *myaudit.Log
+	if auditLog != "" {
+		var err error
+		audit, err = myaudit.New(auditLog, auditInt, rotation())
+		if err != nil {
+			mylog.Fatalf("Unable to open audit log: %+v", err)
+		}
+		mylog.AddRequestHook(audit.LogRequest)
+		mylog.Infof("Writing signed audit log to %s", auditLog)
+		mylog.Infof("Audit log public key: %s", audit.PublicKey)
+	}
+
+	// Access log for log analyzers
+	var access *myaccess.Log
+	if accessLog != "" {
+		var err error
+		access, err = myaccess.New(accessLog, accessFmt, goshsVersion, rotation())
+		if err != nil {
+			mylog.Fatalf("Unable to open access log: %+v", err)
+		}
+		mylog.Infof("Writing %s access log to %s", accessFmt, accessLog)
+	}
+
+	// Webhook for alerting
+	var hook *mywebhook.Webhook
+	if webhook != "" {
+		// The template is either the text itself or a file containing it
+		if fi, err := os.Stat(hookTmpl); err == nil && fi.Mode().IsRegular() {
+			// disable G304 (CWE-22): Potential file inclusion via variable
+			// as the operator chooses the file
+			// #nosec G304
+			content, err := ioutil.ReadFile(hookTmpl)
+			if err != nil {
+				mylog.Fatalf("Unable to read the webhook template %s: %+v", hookTmpl, err)
+			}
+			hookTmpl = string(content)
+		}
+		var err error
+		hook, err = mywebhook.New(webhook, hookEvents, hookTmpl)
+		if err != nil {
+			mylog.Fatalf("Unable to set up the webhook: %+v", err)
+		}
+		mylog.AddRequestHook(hook.LogRequest)
+		mylog.Infof("Posting %s events to the webhook", hookEvents)
+	}
+
+	// Country, ASN and hostname of clients in the log
+	var geo *mygeo.Resolver
+	if geoIP != "" || rdns {
+		var err error
+		geo, err = mygeo.New(splitList(geoIP), rdns)
+		if err != nil {
+			mylog.Fatalf("Unable to open GeoIP database: %+v", err)
+		}
+		mylog.SetRemoteInfo(geo.Describe)
+	}
+
+	// Tripwires for scanners and blue teams
+	var trip *mycanary.Canary
+	if canary != "" {
+		var err error
+		trip, err = mycanary.New(splitList(canary), canaryDesk, hook)
+		if err != nil {
+			mylog.Fatalf("Unable to set up the canaries: %+v", err)
+		}
+		mylog.Infof("Raising an alert for requests to %s", canary)
+	}
+
+	// Summary of the run printed on exit
+	report := myreport.New()
+	mylog.AddRequestHook(report.LogRequest)
+
+	// Request dump to catch callbacks
+	var dumper *mydump.Dumper
+	if dump != "" {
+		if dumpMax < 0 {
+			mylog.Fatal("The dump size limit cannot be negative.")
+		}
+		var err error
+		dumper, err = mydump.New(dump, dumpMax, rotation())
+		if err != nil {
+			mylog.Fatalf("Unable to open request dump: %+v", err)
+		}
+		mylog.Infof("Dumping all requests to %s", dump)
+	}
 
 	// Random Seed generation (used for CA serial)
 	rand.Seed(time.Now().UnixNano())
+	// A new temporary directory is served instead of the web root
+	if tempRoot {
+		dir, err := ioutil.TempDir("", "goshs-")
+		if err != nil {
+			mylog.Fatalf("Unable to create the temporary directory: %+v", err)
+		}
+		webroot = dir
+	}
+
+	// Content piped to stdin is served as a single file
+	spooled := ""
+	if stdinName != "" {
+		var err error
+		single, err = spoolStdin(stdinName)
+		if err != nil {
+			mylog.Fatalf("Unable to read stdin: %+v", err)
+		}
+		spooled = filepath.Dir(single)
+		webroot = spooled
+	}
+
+	// The temporary directories are cleaned up on a fatal error as well
+	cleanup := func() {
+		if spooled != "" {
+			if err := os.RemoveAll(spooled); err != nil {
+				mylog.Errorf("removing the content read from stdin: %+v", err)
+			}
+		}
+		if tempRoot {
+			if shred {
+				if err := myutils.Shred(webroot); err != nil {
+					mylog.Errorf("shredding the temporary directory: %+v", err)
+				} else {
+					mylog.Infof("Shredded the temporary directory %s", webroot)
+				}
+			} else if err := os.Remove(webroot); err != nil {
+				// Only an empty directory is removed without -shred
+				mylog.Infof("The temporary directory %s is kept", webroot)
+			}
+		}
+	}
+	mylog.AtExit(cleanup)
+
 	// Setup the custom file server
 	server := &myhttp.FileServer{
 		IP:         ip,
 		Port:       port,
 		Webroot:    webroot,
+		Mounts:     mounts,
+		File:       single,
 		SSL:        ssl,
 		SelfSigned: selfsigned,
 		MyCert:     myCert,
 		MyKey:      myKey,
-		User:       user,
-		Pass:       pass,
-		UploadOnly: uploadOnly,
-		ReadOnly:   readOnly,
-		Version:    goshsVersion,
+		PKCS12:     p12,
+		PKCS12Pass: p12Pass,
+		CertOptions: myca.Options{
+			CacheDir:     certCache,
+			CommonName:   certCN,
+			CACert:       caCert,
+			CAKey:        caKey,
+			KeyAlgorithm: keyAlg,
+			Anonymous:    stealth,
+		},
+		User:            user,
+		Pass:            pass,
+		UploadOnly:      uploadOnly,
+		ReadOnly:        readOnly,
+		Speedtest:       speedtest,
+		Permissions:     showPerms,
+		ClipboardFile:   cbFile,
+		AccessLog:       access,
+		Dump:            dumper,
+		Webhook:         hook,
+		Report:          report,
+		Canary:          trip,
+		Activity:        activity,
+		Stealth:         stealth,
+		WebdavMount:     webdavMnt,
+		API:             api,
+		ProxyProtocol:   proxyProto,
+		AnonymousRead:   anonRead,
+		H2C:             h2c,
+		HTTP3:           http3,
+		HSTS:            hsts,
+		SecurityHeaders: secHeaders,
+		CSP:             csp,
+		Templates:       templates,
+		Version:         goshsVersion,
+	}
+	if stealth {
+		server.Version = ""
+	}
+
+	// Shut down unattended servers
+	shutdown := func() {
+		select {
+		case done <- syscall.SIGTERM:
+		default:
+		}
+	}
+	if maxServes > 0 {
+		server.MaxServes = maxServes
+		server.MaxServed = func(upath string) {
+			mylog.Infof("%s was served %d times, shutting down", upath, maxServes)
+			shutdown()
+		}
+	}
+	if lifetime > 0 {
+		time.AfterFunc(lifetime, func() {
+			mylog.Infof("The timeout of %s is over, shutting down", lifetime)
+			shutdown()
+		})
+		mylog.Infof("Shutting down at %s", time.Now().Add(lifetime).Format("2006-01-02 15:04:05"))
+	}
+
+	// stdout carries the file received, everything else is printed to stderr then
+	var out io.Writer = os.Stdout
+	var recvErr error
+	if receive {
+		out = os.Stderr
+		if fi, err := os.Stdout.Stat(); err == nil && fi.Mode()&os.ModeCharDevice != 0 {
+			mylog.Warn("stdout is a terminal, consider piping or redirecting the file received")
+		}
+		server.Receive = os.Stdout
+		server.Received = func(err error) {
+			if err != nil {
+				recvErr = err
+				mylog.Errorf("Receiving the file failed, shutting down: %+v", err)
+			} else {
+				mylog.Info("Received the file, shutting down")
+			}
+			shutdown()
+		}
+	}
+
+	// Terminal UI, it needs the activity of all requests
+	var ui *mytui.UI
+	if tui {
+		server.Tracker = myactivity.New(myhttp.Untracked)
+		ui = mytui.New(fmt.Sprintf("goshs %s on %s", goshsVersion, listenerURL(ssl, port)), mytui.Controls{
+			Snapshot: server.Tracker.Snapshot,
+			ReadOnly: server.IsReadOnly,
+			SetReadOnly: func(readOnly bool) error {
+				if uploadOnly || webdav || webdavMnt {
+					return errors.New("read only mode cannot be switched in upload only or webdav mode")
+				}
+				server.SetReadOnly(readOnly)
+				return nil
+			},
+			Ban:    server.Ban,
+			Unban:  server.Unban,
+			Banned: server.Banned,
+		})
+		server.ClipboardChanged = ui.ClipboardChanged
+	}
+
+	server.CertOptions.ParseSANs(splitList(certSAN))
+
+	// Several certificates to choose from by SNI
+	certs, keys := splitList(myCert), splitList(myKey)
+	if len(certs) != len(keys) {
+		mylog.Fatal("You need to provide as many server keys as server certificates.")
+	}
+	if len(certs) > 1 {
+		server.MyCert, server.MyKey = certs[0], keys[0]
+		for i := 1; i < len(certs); i++ {
+			server.SNICerts = append(server.SNICerts, myca.KeyPair{Cert: certs[i], Key: keys[i]})
+		}
+	}
+
+	if tlsMin != "" {
+		version, err := myca.ParseTLSVersion(tlsMin)
+		if err != nil {
+			mylog.Fatalf("Unable to set minimum TLS version: %+v", err)
+		}
+		server.TLSMinVersion = version
+	}
+
+	if tlsCiphers != "" {
+		ciphers, err := myca.ParseCipherSuites(splitList(tlsCiphers))
+		if err != nil {
+			mylog.Fatalf("Unable to set cipher suites: %+v. Supported are: %s", err, strings.Join(myca.CipherSuiteNames(), ", "))
+		}
+		server.TLSCiphers = ciphers
+	}
+
+	if templates != "" {
+		if fi, err := os.Stat(templates); err != nil || !fi.IsDir() {
+			mylog.Fatalf("The template directory %s does not exist.", templates)
+		}
+	}
+
+	if favicon != "" {
+		if fi, err := os.Stat(favicon); err != nil || !fi.Mode().IsRegular() {
+			mylog.Fatalf("The favicon %s does not exist.", favicon)
+		}
+		server.Favicon = favicon
+	}
+
+	// The banner is either the text itself or a file containing it
+	if banner != "" {
+		text, err := readBanner()
+		if err != nil {
+			mylog.Fatalf("Unable to read the banner file %s: %+v", banner, err)
+		}
+		server.Banner = text
+	}
+
+	if randPrefix {
+		token, err := myutils.RandomHex(16)
+		if err != nil {
+			mylog.Fatalf("Unable to generate random prefix: %+v", err)
+		}
+		server.Prefix = "/" + token
+	}
+
+	if proxyTrust != "" {
+		networks, err := myutils.ParseNetworks(splitList(proxyTrust))
+		if err != nil {
+			mylog.Fatalf("Unable to parse trusted proxy networks: %+v", err)
+		}
+		server.ProxyTrusted = networks
+	}
+
+	if desktop {
+		server.Desktop = mynotify.New()
+	}
+
+	if fwdTrust != "" {
+		networks, err := myutils.ParseNetworks(splitList(fwdTrust))
+		if err != nil {
+			mylog.Fatalf("Unable to parse trusted proxy networks: %+v", err)
+		}
+		server.TrustedProxies = networks
+	}
+
+	if authExempt != "" {
+		networks, err := myutils.ParseNetworks(splitList(authExempt))
+		if err != nil {
+			mylog.Fatalf("Unable to parse auth exempt networks: %+v", err)
+		}
+		server.AuthExempt = networks
+		mylog.Infof("Authentication is not required for requests from %s", authExempt)
+	}
+
+	if captureLog != "" {
+		if basicAuth == "" && ldapURL == "" {
+			mylog.Warn("Credentials are only captured when basic auth or LDAP is used")
+		}
+		capture, err := myauth.NewCaptureLog(captureLog)
+		if err != nil {
+			mylog.Fatalf("Unable to open credential capture log: %+v", err)
+		}
+		defer capture.Close()
+		server.CaptureLog = capture
+		mylog.Infof("Capturing all submitted credentials to %s", captureLog)
+	}
+
+	if maxAuth > 0 {
+		server.Limiter = myauth.NewLimiter(maxAuth, banTime)
+	}
+
+	if oidcIssuer != "" {
+		server.OIDC = &myauth.OIDC{
+			Issuer:       oidcIssuer,
+			ClientID:     oidcID,
+			ClientSecret: oidcSecret,
+			RedirectURL:  oidcRedir,
+			GroupsClaim:  oidcClaim,
+			ReadGroups:   splitList(oidcReadG),
+			WriteGroups:  splitList(oidcWriteG),
+		}
+		if err := server.OIDC.Init(context.Background()); err != nil {
+			mylog.Fatalf("Unable to discover OpenID Connect issuer: %+v", err)
+		}
+	}
+
+	if ldapURL != "" {
+		server.LDAP = &myauth.LDAP{
+			URL:      ldapURL,
+			BaseDN:   ldapBaseDN,
+			BindDN:   ldapBindDN,
+			BindPass: ldapBindPW,
+			Filter:   ldapFilter,
+			Insecure: ldapNoTLS,
+		}
+	}
+
+	switch tunnel {
+	case "":
+	case mytunnel.Cloudflared, mytunnel.Ngrok:
+		// Our own certificate does not match the name the client connects to
+		publicURL, stop, err := mytunnel.External(tunnel, listenerURL(ssl, port), ssl)
+		if err != nil {
+			mylog.Fatalf("Unable to start the %s tunnel: %+v", tunnel, err)
+		}
+		defer stop()
+		server.PublicURL = publicURL + server.Prefix + "/"
+		mylog.Infof("Serving publicly via %s on %s", tunnel, server.PublicURL)
+	default:
+		if tunnelKey == "" {
+			mylog.Fatal("You need to provide the secret of the relay with -ts.")
+		}
+		if !ssl {
+			mylog.Warn("The tunnel is not encrypted. Consider using -s, too, which is end-to-end through the relay.")
+		}
+		server.Listeners = append(server.Listeners, mytunnel.Dial(tunnel, tunnelKey))
+		mylog.Infof("Serving through relay %s as well", tunnel)
+	}
+
+	if sshFwd != "" {
+		if sshFwdPort == 0 {
+			sshFwdPort = port
+		}
+		listener, err := mytunnel.SSH(sshFwd, sshFwdKey, sshFwdPort)
+		if err != nil {
+			mylog.Fatalf("Unable to set up the ssh remote forward: %+v", err)
+		}
+		server.Listeners = append(server.Listeners, listener)
+		mylog.Infof("Publishing on port %d of %s via ssh remote forward", sshFwdPort, sshFwd)
+	}
+
+	// Self monitoring
+	var monitor *mymonitor.Monitor
+	if status || stateFile != "" {
+		monitor = mymonitor.New(stateFile, server.Version)
+		server.Monitor = monitor
+	}
+
+	// Apply changes of the config file on SIGHUP
+	if config != "" {
+		hup := make(chan os.Signal, 1)
+		signal.Notify(hup, syscall.SIGHUP)
+		go func() {
+			for range hup {
+				reloadConfig(server, &user, &pass)
+			}
+		}()
 	}
 
 	go server.Start("web")
+	if monitor != nil {
+		monitor.Watch("web", http.MethodGet, listenerURL(ssl, port)+server.StaticPath("images/favicon.gif"))
+	}
+
+	if webdavMnt && monitor != nil {
+		monitor.Watch("webdav", "PROPFIND", listenerURL(ssl, port)+server.Prefix+"/webdav/")
+	}
+
+	if ssl && redirHTTP > 0 {
+		go server.StartRedirect(redirHTTP)
+	}
 
 	if webdav {
 		server.WebdavPort = webdavPort
 
 		go server.Start("webdav")
+		if monitor != nil {
+			monitor.Watch("webdav", "PROPFIND", listenerURL(ssl, webdavPort)+server.Prefix+"/")
+		}
+	}
+
+	if sftpServe {
+		go server.StartSFTP(sftpPort, sftpKey)
+	}
+
+	// externalURL is the url to reach the share from the internet if we know it
+	externalURL := server.PublicURL
+	if upnp {
+		ports := []int{port}
+		if webdav {
+			ports = append(ports, webdavPort)
+		}
+		if sftpServe {
+			ports = append(ports, sftpPort)
+		}
+		for _, p := range ports {
+			mapping, err := mynat.Map(p)
+			if err != nil {
+				mylog.Errorf("Unable to forward port %d on the gateway: %+v", p, err)
+				continue
+			}
+			defer func() {
+				if err := mapping.Close(); err != nil {
+					mylog.Errorf("Removing the port mapping: %+v", err)
+				}
+			}()
+			external := net.JoinHostPort(mapping.ExternalIP, strconv.Itoa(mapping.ExternalPort))
+			mylog.Infof("Forwarded %s to port %d via %s", external, p, mapping.Method)
+			if p == port {
+				externalURL = fmt.Sprintf("%s://%s%s/", urlScheme(ssl), external, server.Prefix)
+				mylog.Infof("Serving externally on %s", externalURL)
+			}
+		}
+	}
+
+	if mdns {
+		if mdnsName == "" {
+			mdnsName = mymdns.DefaultInstance()
+		}
+		// Announcing the secret prefix to the whole network would defeat it
+		path := "/"
+		if server.Prefix != "" {
+			path = ""
+			mylog.Warn("The random prefix is not announced via mDNS, share it yourself")
+		}
+		shutdown, err := mymdns.Announce(mdnsName, port, ssl, path)
+		if err != nil {
+			mylog.Errorf("Unable to announce via mDNS: %+v", err)
+		} else {
+			defer shutdown()
+		}
+	}
+
+	if qr {
+		shareURL := externalURL
+		if shareURL == "" {
+			shareURL = lanURL(ssl, port, server.Prefix)
+		}
+		code, err := myqr.Terminal(shareURL)
+		if err != nil {
+			mylog.Errorf("Unable to render the QR code: %+v", err)
+		} else {
+			mylog.Infof("Scan to open %s", shareURL)
+			fmt.Fprint(out, code)
+		}
+	}
+
+	if ui != nil {
+		go func() {
+			if err := ui.Run(); err != nil {
+				mylog.Errorf("Unable to show the terminal UI: %+v", err)
+				return
+			}
+			// Quitting the UI quits goshs
+			shutdown()
+		}()
 	}
 
 	<-done
 
+	if ui != nil {
+		ui.Stop()
+	}
+
 	mylog.Infof("Received CTRL+C, exiting...")
+
+	if monitor != nil {
+		monitor.Stop()
+	}
+
+	summary := report.Summary()
+	fmt.Fprint(out, "\n"+summary.Markdown())
+	if reportFile != "" {
+		if err := summary.Write(reportFile); err != nil {
+			mylog.Errorf("writing the summary report: %+v", err)
+		} else {
+			mylog.Infof("Summary report written to %s", reportFile)
+		}
+	}
+
+	if audit != nil {
+		if err := audit.Close(); err != nil {
+			mylog.Errorf("closing audit log: %+v", err)
+		}
+	}
+
+	if dumper != nil {
+		if err := dumper.Close(); err != nil {
+			mylog.Errorf("closing request dump: %+v", err)
+		}
+	}
+
+	if geo != nil {
+		if err := geo.Close(); err != nil {
+			mylog.Errorf("closing GeoIP database: %+v", err)
+		}
+	}
+
+	if access != nil {
+		if err := access.Close(); err != nil {
+			mylog.Errorf("closing access log: %+v", err)
+		}
+	}
+
+	cleanup()
+
+	if recvErr != nil {
+		os.Exit(1)
+	}
 }
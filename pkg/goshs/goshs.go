@@ -0,0 +1,243 @@
+// Package goshs embeds the goshs file server in other Go programs, e.g. to serve
+// payloads from a C2 framework or fixtures in a test harness.
+//
+//	srv, err := goshs.New("/srv/share", goshs.WithAddress("127.0.0.1", 8000), goshs.WithReadOnly())
+//	if err != nil {
+//		return err
+//	}
+//	go srv.Start(ctx)
+//
+// The server stops when ctx is done or Stop is called. The package wraps the internal file
+// server of the goshs command and exposes the options below, not all of the command line.
+package goshs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/patrickhener/goshs/internal/myhttp"
+	"github.com/patrickhener/goshs/internal/mylog"
+	"github.com/patrickhener/goshs/internal/mymount"
+)
+
+// stopTimeout is how long Stop waits for requests in flight before closing their connections
+const stopTimeout = 5 * time.Second
+
+// Logger receives the log messages of a server at every level, each server logs to its own
+type Logger interface {
+	Debugf(format string, args ...interface{})
+	Infof(format string, args ...interface{})
+	Warnf(format string, args ...interface{})
+	Errorf(format string, args ...interface{})
+}
+
+// Option configures a Server, see New
+type Option func(*Server) error
+
+// WithAddress will serve on ip and port instead of 0.0.0.0:8000
+func WithAddress(ip string, port int) Option {
+	return func(s *Server) error {
+		if net.ParseIP(ip) == nil {
+			return fmt.Errorf("invalid ip address %q", ip)
+		}
+		if port < 0 || port > 65535 {
+			return fmt.Errorf("invalid port %d", port)
+		}
+		s.fs.IP, s.fs.Port = ip, port
+		return nil
+	}
+}
+
+// WithListener will serve on l instead of listening on the address, e.g. on 127.0.0.1:0 in tests
+func WithListener(l net.Listener) Option {
+	return func(s *Server) error {
+		s.listener = l
+		return nil
+	}
+}
+
+// WithBasicAuth will require user and pass of every client
+func WithBasicAuth(user, pass string) Option {
+	return func(s *Server) error {
+		if user == "" || pass == "" {
+			return errors.New("basic auth needs a user and a password")
+		}
+		s.fs.User, s.fs.Pass = user, pass
+		return nil
+	}
+}
+
+// WithTLS will serve HTTPS with the certificate and key in the PEM files
+func WithTLS(certFile, keyFile string) Option {
+	return func(s *Server) error {
+		s.fs.SSL = true
+		s.fs.MyCert, s.fs.MyKey = certFile, keyFile
+		return nil
+	}
+}
+
+// WithSelfSigned will serve HTTPS with a generated self-signed certificate
+func WithSelfSigned() Option {
+	return func(s *Server) error {
+		s.fs.SSL = true
+		s.fs.SelfSigned = true
+		return nil
+	}
+}
+
+// WithReadOnly will refuse uploads, deletes and any other change
+func WithReadOnly() Option {
+	return func(s *Server) error {
+		s.fs.ReadOnly = true
+		return nil
+	}
+}
+
+// WithUploadOnly will accept uploads but neither list nor serve files
+func WithUploadOnly() Option {
+	return func(s *Server) error {
+		s.fs.UploadOnly = true
+		return nil
+	}
+}
+
+// WithWebdav will serve WebDAV below /webdav/ on the same port
+func WithWebdav() Option {
+	return func(s *Server) error {
+		s.fs.WebdavMount = true
+		return nil
+	}
+}
+
+// WithAPI will serve the JSON API for scripts
+func WithAPI() Option {
+	return func(s *Server) error {
+		s.fs.API = true
+		return nil
+	}
+}
+
+// WithPrefix will serve everything below prefix only, e.g. /<token>
+func WithPrefix(prefix string) Option {
+	return func(s *Server) error {
+		if !strings.HasPrefix(prefix, "/") || strings.HasSuffix(prefix, "/") {
+			return fmt.Errorf("invalid prefix %q, it has to start but not end with a slash", prefix)
+		}
+		s.fs.Prefix = prefix
+		return nil
+	}
+}
+
+// WithBanner will show text above every listing
+func WithBanner(text string) Option {
+	return func(s *Server) error {
+		s.fs.Banner = text
+		return nil
+	}
+}
+
+// WithMount will serve dir below urlPath, e.g. /tools, next to the content of the webroot
+func WithMount(urlPath, dir string, readOnly, uploadOnly bool) Option {
+	return func(s *Server) error {
+		if readOnly && uploadOnly {
+			return fmt.Errorf("the mount %s can either be read only or upload only, not both", urlPath)
+		}
+		m, err := mymount.Parse(urlPath + ":" + dir)
+		if err != nil {
+			return err
+		}
+		m.ReadOnly, m.UploadOnly = readOnly, uploadOnly
+		return s.fs.Mounts.Add(m)
+	}
+}
+
+// WithLogger will pass the log messages to l instead of printing them
+func WithLogger(l Logger) Option {
+	return func(s *Server) error {
+		s.logger = l
+		return nil
+	}
+}
+
+// Server is an embedded goshs file server
+type Server struct {
+	fs       *myhttp.FileServer
+	listener net.Listener
+	logger   Logger
+}
+
+// New will return a server sharing webroot, configured by opts
+func New(webroot string, opts ...Option) (*Server, error) {
+	root, err := filepath.Abs(webroot)
+	if err != nil {
+		return nil, err
+	}
+	if fi, err := os.Stat(root); err != nil || !fi.IsDir() {
+		return nil, fmt.Errorf("webroot %s is not a directory", root)
+	}
+
+	s := &Server{fs: &myhttp.FileServer{IP: "0.0.0.0", Port: 8000, Webroot: root}}
+	for _, opt := range opts {
+		if err := opt(s); err != nil {
+			return nil, err
+		}
+	}
+
+	if s.fs.ReadOnly && s.fs.UploadOnly {
+		return nil, errors.New("a server can either be read only or upload only, not both")
+	}
+	if s.fs.WebdavMount && (s.fs.ReadOnly || s.fs.UploadOnly) {
+		return nil, errors.New("WebDAV cannot be read only or upload only")
+	}
+	if s.fs.SelfSigned && (s.fs.MyCert != "" || s.fs.MyKey != "") {
+		return nil, errors.New("use either a certificate or a self-signed one")
+	}
+	if s.logger != nil {
+		s.fs.Logger = mylog.Logger{Sink: s.logger}
+	}
+	return s, nil
+}
+
+// Start will serve until ctx is done or Stop is called, it returns nil then and the error
+// otherwise. A stopped server cannot be started again.
+func (s *Server) Start(ctx context.Context) error {
+	listener := s.listener
+	if listener == nil {
+		l, err := net.Listen("tcp", net.JoinHostPort(s.fs.IP, strconv.Itoa(s.fs.Port)))
+		if err != nil {
+			return err
+		}
+		listener = l
+	} else if addr, ok := listener.Addr().(*net.TCPAddr); ok {
+		s.fs.IP, s.fs.Port = addr.IP.String(), addr.Port
+	}
+
+	served := make(chan error, 1)
+	go func() {
+		served <- s.fs.Serve("web", listener)
+	}()
+
+	select {
+	case err := <-served:
+		return err
+	case <-ctx.Done():
+		if err := s.Stop(); err != nil {
+			return err
+		}
+		return <-served
+	}
+}
+
+// Stop will stop serving and the background work of the server, requests in flight get a few seconds to finish
+func (s *Server) Stop() error {
+	ctx, cancel := context.WithTimeout(context.Background(), stopTimeout)
+	defer cancel()
+	return s.fs.Shutdown(ctx)
+}